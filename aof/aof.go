@@ -11,6 +11,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"time"
 )
 
 // aof append only file  将redis的写操作写入的文件中（持久化），实现redis的持久化。
@@ -30,6 +31,8 @@ type AofHandler struct {
 	aofFile     *os.File
 	aofFilename string
 	currentDB   int
+
+	incompleteTx bool // 加载时发现文件结尾有不完整的事务
 }
 
 // NewAofHandler
@@ -46,6 +49,13 @@ func NewAofHandler(database database.Database) (*AofHandler, error) {
 		return nil, err
 	}
 	handler.aofFile = aoffile
+	// 在不完整的事务之后追加DISCARD，避免之后写入的指令在下次加载时被当作事务的一部分
+	if handler.incompleteTx {
+		data := reply.MakeMultiBulkReply(utils.ToCmdLine("discard")).ToBytes()
+		if _, err := handler.aofFile.Write(data); err != nil {
+			return nil, err
+		}
+	}
 	// channel的实现
 	handler.aofChan = make(chan *payload, aofBufferSize)
 
@@ -126,5 +136,17 @@ func (handler *AofHandler) LoadAof() {
 		}
 
 	}
+	// 事务以 MULTI ... EXEC 的形式记录，文件结尾只有MULTI没有EXEC说明写入事务时中断了，丢弃不完整的事务
+	if fackConn.InMultiState() {
+		logger.Warn("aof file ends with an incomplete transaction, discarded " +
+			strconv.Itoa(len(fackConn.GetQueuedCmdLine())) + " commands")
+		fackConn.SetMultiState(false)
+		handler.incompleteTx = true
+	}
+
+}
 
+// MakeExpireCmd 生成设置过期时间的命令，统一使用绝对时间PEXPIREAT，保证aof重放时过期时间不会因为重启而推迟
+func MakeExpireCmd(key string, expireAt time.Time) CmdLine {
+	return utils.ToCmdLine("pexpireat", key, strconv.FormatInt(expireAt.UnixMilli(), 10))
 }
@@ -0,0 +1,62 @@
+package cluster
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+	"strings"
+)
+
+// 阻塞指令：转发给其他节点的请求有超时时间，无法长时间阻塞，
+// 因此只在所有的key都在当前节点上时执行，否则提示客户端直接连接key所在的节点
+
+// relayBlocking 所有的key在当前节点上时直接执行
+func relayBlocking(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte, keys [][]byte) resp.Reply {
+	peer := cluster.peerPicker.PickNode(string(keys[0]))
+	for _, key := range keys[1:] {
+		if cluster.peerPicker.PickNode(string(key)) != peer {
+			return reply.MakeErrReply("ERR " + string(cmdArgs[0]) + " keys must within on the same peer")
+		}
+	}
+	if peer != cluster.self {
+		return reply.MakeErrReply("ERR blocking command " + string(cmdArgs[0]) + " must be sent to peer " + peer)
+	}
+	return cluster.db.Exec(c, cmdArgs)
+}
+
+// blocking BLPOP/BRPOP/BZPOPMIN/BZPOPMAX/BLMOVE/BRPOPLPUSH
+func blocking(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	keys, errReply := commandKeys(cmdArgs)
+	if errReply != nil {
+		return errReply
+	}
+	return relayBlocking(cluster, c, cmdArgs, keys)
+}
+
+// hasBlockOption 判断 XREAD/XREADGROUP 是否带有 BLOCK 选项
+func hasBlockOption(cmdArgs [][]byte) bool {
+	for _, arg := range cmdArgs {
+		option := strings.ToUpper(string(arg))
+		if option == "STREAMS" {
+			return false
+		}
+		if option == "BLOCK" {
+			return true
+		}
+	}
+	return false
+}
+
+// xread XREAD/XREADGROUP ... STREAMS K1 K2 ... id1 id2 ...   带有BLOCK时按照阻塞指令处理
+func xread(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	keys, errReply := commandKeys(cmdArgs)
+	if errReply != nil {
+		return errReply
+	}
+	if len(keys) == 0 {
+		return reply.MakeSyntaxErrReply()
+	}
+	if hasBlockOption(cmdArgs) {
+		return relayBlocking(cluster, c, cmdArgs, keys)
+	}
+	return relayByKeys(cluster, c, cmdArgs, keys)
+}
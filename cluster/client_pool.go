@@ -20,6 +20,7 @@ func (f *connectionFactory) MakeObject(ctx context.Context) (*pool.PooledObject,
 		return nil, err
 	}
 	c.Start()
+	// 连接使用的协议版本在转发时按照客户端的协议版本切换，见 relay
 	return pool.NewPooledObject(c), nil
 }
 
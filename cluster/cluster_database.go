@@ -8,6 +8,7 @@ import (
 	"go_redis/interface/resp"
 	"go_redis/lib/consistenthash"
 	"go_redis/lib/logger"
+	"go_redis/pubsub"
 	"go_redis/resp/reply"
 	"strings"
 
@@ -20,6 +21,7 @@ type ClusterDatabase struct {
 	nodes          []string                    // 所有的节点
 	peerPicker     *consistenthash.NodeMap     // 节点选择器
 	peerConnection map[string]*pool.ObjectPool // 每个节点的 客户端对象池
+	peerHosts      map[string]struct{}         // 其他节点的IP，用于识别节点之间的连接
 	db             database.Database
 }
 
@@ -29,6 +31,7 @@ func MakeClusterDatabase() *ClusterDatabase {
 		db:             database2.NewStandaloneDatabase(),
 		peerPicker:     consistenthash.NewNodeMap(nil),
 		peerConnection: make(map[string]*pool.ObjectPool),
+		peerHosts:      resolvePeerHosts(config.Properties.Peers),
 	}
 
 	nodes := make([]string, 0, len(config.Properties.Peers)+1)
@@ -66,10 +69,16 @@ func (cluster *ClusterDatabase) Exec(client resp.Connection, args [][]byte) (res
 	}()
 
 	cmdName := strings.ToLower(string(args[0]))
+	// 订阅模式下只能执行订阅相关的指令
+	if errReply := pubsub.CheckSubscriberMode(client, cmdName, args); errReply != nil {
+		return errReply
+	}
+	if client.InMultiState() && cmdName != "exec" && cmdName != "discard" && cmdName != "multi" && cmdName != "watch" {
+		return enqueue(cluster, client, args)
+	}
 	cmdfunc, ok := router[cmdName]
 	if !ok {
-		reply.MakeErrReply("not supported cmd")
-
+		return reply.MakeErrReply("ERR unknown command '" + cmdName + "'")
 	}
 	result = cmdfunc(cluster, client, args)
 
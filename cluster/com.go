@@ -52,6 +52,13 @@ func (cluster *ClusterDatabase) relay(peer string, c resp.Connection, args [][]b
 	defer func() {
 		_ = cluster.returnPeerClient(peer, peerClient)
 	}()
+	// 节点之间的连接使用和客户端相同的协议版本，回复的格式和单机执行时一致
+	// (RESP3 的 map/double 等类型，以及 RESP2 中扁平的 member score 列表)，断线重连之后重新协商
+	if protocol := c.GetProtocol(); peerClient.Protocol() != protocol {
+		if r := peerClient.SetProtocol(protocol); reply.IsErrReply(r) {
+			return r
+		}
+	}
 	// 注意其他peer节点的数据库的选择,先选择数据库再进行操作
 	peerClient.Send(utils.ToCmdLine("SELECT", strconv.Itoa(c.GetDBIndex())))
 	return peerClient.Send(args)
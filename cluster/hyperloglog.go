@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"go_redis/datastruct/hyperloglog"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+)
+
+// PFCOUNT K1 K2 ...   所有的key都在同一个节点上时直接转发；
+// 否则从各个节点取出HyperLogLog的原始字符串，在当前节点合并寄存器之后再计算基数
+func pfcount(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if len(cmdArgs) < 2 {
+		return reply.MakeArgNumErrReply("pfcount")
+	}
+	keys := cmdArgs[1:]
+	groups := cluster.groupByPeer(keys)
+	if len(groups) == 1 {
+		for peer := range groups {
+			return cluster.relay(peer, c, cmdArgs)
+		}
+	}
+
+	merged := hyperloglog.MakeHyperLogLog()
+	for peer, indices := range groups {
+		subKeys := make([][]byte, len(indices))
+		for i, index := range indices {
+			subKeys[i] = keys[index]
+		}
+		// 使用 PFCOUNT 先在对应的节点上校验类型，再取出原始字符串
+		r := cluster.relay(peer, c, utils.ToCmdLine3("pfcount", subKeys...))
+		if reply.IsErrReply(r) {
+			return r
+		}
+		r = cluster.relay(peer, c, utils.ToCmdLine3("mget", subKeys...))
+		if reply.IsErrReply(r) {
+			return r
+		}
+		values, ok := r.(*reply.MultiBulkReply)
+		if !ok || len(values.Args) != len(indices) {
+			return reply.MakeErrReply("ERR unexpected reply from " + peer)
+		}
+		for _, value := range values.Args {
+			if value == nil { // key不存在
+				continue
+			}
+			h, err := hyperloglog.Parse(value)
+			if err != nil {
+				return reply.MakeErrReply(err.Error())
+			}
+			merged.Merge(h)
+		}
+	}
+	return reply.MakeIntReply(int64(merged.Count()))
+}
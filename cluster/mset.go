@@ -0,0 +1,251 @@
+package cluster
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/lib/logger"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+// MSET/MGET/MSETNX：按照key所在的节点分组(scatter)，分别转发给对应的节点执行，再按照参数的顺序合并结果(gather)
+
+// groupByPeer 按照节点对key分组，返回 节点 -> key在参数中的下标
+func (cluster *ClusterDatabase) groupByPeer(keys [][]byte) map[string][]int {
+	groups := make(map[string][]int)
+	for i, key := range keys {
+		peer := cluster.peerPicker.PickNode(string(key))
+		groups[peer] = append(groups[peer], i)
+	}
+	return groups
+}
+
+// MGET K1 K2 ...
+func mget(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if len(cmdArgs) < 2 {
+		return reply.MakeArgNumErrReply("mget")
+	}
+	keys := cmdArgs[1:]
+	result := make([][]byte, len(keys))
+	for peer, indices := range cluster.groupByPeer(keys) {
+		subKeys := make([][]byte, len(indices))
+		for i, index := range indices {
+			subKeys[i] = keys[index]
+		}
+		r := cluster.relay(peer, c, utils.ToCmdLine3("mget", subKeys...))
+		if reply.IsErrReply(r) {
+			return r
+		}
+		values, ok := r.(*reply.MultiBulkReply)
+		if !ok || len(values.Args) != len(indices) {
+			return reply.MakeErrReply("ERR unexpected reply from " + peer)
+		}
+		// 按照原先参数的顺序放回结果
+		for i, index := range indices {
+			result[index] = values.Args[i]
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// splitPairs 按照节点对 K V 分组，返回 节点 -> 该节点上的 K V 参数
+func (cluster *ClusterDatabase) splitPairs(pairs [][]byte) map[string][][]byte {
+	keys := make([][]byte, len(pairs)/2)
+	for i := range keys {
+		keys[i] = pairs[2*i]
+	}
+	result := make(map[string][][]byte)
+	for peer, indices := range cluster.groupByPeer(keys) {
+		subArgs := make([][]byte, 0, len(indices)*2)
+		for _, index := range indices {
+			subArgs = append(subArgs, pairs[2*index], pairs[2*index+1])
+		}
+		result[peer] = subArgs
+	}
+	return result
+}
+
+// 跨节点的 MSET/MSETNX 没有全局的锁，逐个节点写入，某个节点失败时撤销已经写入的节点，最终的结果是全部写入或者全部不写入；
+// 每个节点上的检查和写入由脚本原子地完成，撤销时只恢复仍然是本次写入的值的key，不会覆盖其他客户端在这期间的修改。
+// 写入过程中其他客户端可能读到部分节点上的新值
+
+// msetScript 记录key原先的值和剩余的过期时间(毫秒，-2表示不存在)之后写入新值，
+// 只能撤销字符串类型的修改，key是其他类型时不写入
+const msetScript = `
+local old = {}
+for i, key in ipairs(KEYS) do
+	local kind = redis.call('type', key)['ok']
+	if kind == 'none' then
+		old[2 * i - 1] = false
+		old[2 * i] = '-2'
+	elseif kind == 'string' then
+		old[2 * i - 1] = redis.call('get', key)
+		old[2 * i] = tostring(redis.call('pttl', key))
+	else
+		return redis.error_reply('ERR MSET keys on different peers must not hold non-string values')
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call('set', key, ARGV[i])
+end
+return old
+`
+
+// rollbackScript 撤销写入，ARGV 中每个key依次为 写入的值、原先的过期时间、原先的值
+const rollbackScript = `
+for i, key in ipairs(KEYS) do
+	local base = 3 * (i - 1)
+	if redis.pcall('get', key) == ARGV[base + 1] then
+		local pttl = tonumber(ARGV[base + 2])
+		if pttl == -2 then
+			redis.call('del', key)
+		elseif pttl > 0 then
+			redis.call('set', key, ARGV[base + 3], 'px', pttl)
+		else
+			redis.call('set', key, ARGV[base + 3])
+		end
+	end
+end
+return redis.status_reply('OK')
+`
+
+// written 一个节点上已经写入的key，用于撤销
+type written struct {
+	peer   string
+	pairs  [][]byte // K V K V ...
+	oldTTL [][]byte // 原先的过期时间，-2表示原先不存在
+	oldVal [][]byte
+}
+
+// evalCmdLine 构造在一个节点上执行脚本的指令  EVAL script numkeys K1 K2 ... ARGV...
+func evalCmdLine(script string, keys [][]byte, argv [][]byte) [][]byte {
+	cmdLine := make([][]byte, 0, 3+len(keys)+len(argv))
+	cmdLine = append(cmdLine, []byte("eval"), []byte(script), []byte(strconv.Itoa(len(keys))))
+	cmdLine = append(cmdLine, keys...)
+	return append(cmdLine, argv...)
+}
+
+// rollback 撤销已经写入的节点，返回撤销失败的错误
+func (cluster *ClusterDatabase) rollback(c resp.Connection, done []*written) resp.Reply {
+	var failed resp.Reply
+	for _, w := range done {
+		size := len(w.pairs) / 2
+		keys := make([][]byte, size)
+		argv := make([][]byte, 0, size*3)
+		for i := 0; i < size; i++ {
+			keys[i] = w.pairs[2*i]
+			argv = append(argv, w.pairs[2*i+1], w.oldTTL[i], w.oldVal[i])
+		}
+		r := cluster.relay(w.peer, c, evalCmdLine(rollbackScript, keys, argv))
+		if reply.IsErrReply(r) {
+			logger.Error("rollback on " + w.peer + " failed: " + string(r.ToBytes()))
+			failed = r
+		}
+	}
+	return failed
+}
+
+// bulkArgs 取出数组回复中的字符串，本节点执行时脚本返回 MultiRawReply，其他节点返回的数组经过解析之后是 MultiBulkReply
+func bulkArgs(r resp.Reply) ([][]byte, bool) {
+	switch r := r.(type) {
+	case *reply.MultiBulkReply:
+		return r.Args, true
+	case *reply.MultiRawReply:
+		args := make([][]byte, len(r.Replies))
+		for i, element := range r.Replies {
+			switch element := element.(type) {
+			case *reply.BulkReply:
+				args[i] = element.Arg
+			case *reply.NullBulkReply:
+				args[i] = nil
+			default:
+				return nil, false
+			}
+		}
+		return args, true
+	}
+	return nil, false
+}
+
+// abort 写入失败时撤销其他节点，撤销也失败时返回的错误说明写入不完整
+func (cluster *ClusterDatabase) abort(c resp.Connection, done []*written, cause resp.Reply) resp.Reply {
+	if failed := cluster.rollback(c, done); failed != nil {
+		return reply.MakeErrReply("ERR partial write, rollback failed: " + strings.TrimSpace(string(failed.ToBytes())))
+	}
+	return cause
+}
+
+// MSET K1 V1 K2 V2 ...
+func mset(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if len(cmdArgs) < 3 || len(cmdArgs)%2 != 1 {
+		return reply.MakeArgNumErrReply("mset")
+	}
+	groups := cluster.splitPairs(cmdArgs[1:])
+	if len(groups) == 1 { // 只涉及一个节点，直接转发，由该节点保证原子性
+		for peer := range groups {
+			return cluster.relay(peer, c, cmdArgs)
+		}
+	}
+	done := make([]*written, 0, len(groups))
+	for peer, pairs := range groups {
+		size := len(pairs) / 2
+		keys := make([][]byte, size)
+		values := make([][]byte, size)
+		for i := 0; i < size; i++ {
+			keys[i], values[i] = pairs[2*i], pairs[2*i+1]
+		}
+		r := cluster.relay(peer, c, evalCmdLine(msetScript, keys, values))
+		if reply.IsErrReply(r) {
+			return cluster.abort(c, done, r)
+		}
+		old, ok := bulkArgs(r)
+		if !ok || len(old) != 2*size {
+			return cluster.abort(c, done, reply.MakeErrReply("ERR unexpected reply from "+peer))
+		}
+		w := &written{peer: peer, pairs: pairs, oldTTL: make([][]byte, size), oldVal: make([][]byte, size)}
+		for i := 0; i < size; i++ {
+			w.oldVal[i], w.oldTTL[i] = old[2*i], old[2*i+1]
+			if w.oldVal[i] == nil { // 原先不存在，作为脚本参数时使用空字符串
+				w.oldVal[i] = []byte{}
+			}
+		}
+		done = append(done, w)
+	}
+	return reply.MakeOkReply()
+}
+
+// MSETNX K1 V1 K2 V2 ...   每个节点上执行MSETNX，某个节点上有key已经存在时撤销其他节点的写入并返回0
+func msetnx(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if len(cmdArgs) < 3 || len(cmdArgs)%2 != 1 {
+		return reply.MakeArgNumErrReply("msetnx")
+	}
+	groups := cluster.splitPairs(cmdArgs[1:])
+	if len(groups) == 1 {
+		for peer := range groups {
+			return cluster.relay(peer, c, cmdArgs)
+		}
+	}
+	done := make([]*written, 0, len(groups))
+	for peer, pairs := range groups {
+		r := cluster.relay(peer, c, utils.ToCmdLine3("msetnx", pairs...))
+		if reply.IsErrReply(r) {
+			return cluster.abort(c, done, r)
+		}
+		intReply, ok := r.(*reply.IntReply)
+		if !ok {
+			return cluster.abort(c, done, reply.MakeErrReply("ERR unexpected reply from "+peer))
+		}
+		if intReply.Code == 0 {
+			return cluster.abort(c, done, reply.MakeIntReply(0))
+		}
+		// 写入成功说明这些key原先都不存在
+		size := len(pairs) / 2
+		w := &written{peer: peer, pairs: pairs, oldTTL: make([][]byte, size), oldVal: make([][]byte, size)}
+		for i := 0; i < size; i++ {
+			w.oldTTL[i], w.oldVal[i] = []byte("-2"), []byte{}
+		}
+		done = append(done, w)
+	}
+	return reply.MakeIntReply(1)
+}
@@ -0,0 +1,19 @@
+package cluster
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+)
+
+// 涉及多个key的指令：当前只支持所有的key都在同一个节点上的情况
+
+// relayByKeys 所有的key在同一个节点上时，转发给该节点执行
+func relayByKeys(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte, keys [][]byte) resp.Reply {
+	peer := cluster.peerPicker.PickNode(string(keys[0]))
+	for _, key := range keys[1:] {
+		if cluster.peerPicker.PickNode(string(key)) != peer {
+			return reply.MakeErrReply("ERR " + string(cmdArgs[0]) + " keys must within on the same peer")
+		}
+	}
+	return cluster.relay(peer, c, cmdArgs)
+}
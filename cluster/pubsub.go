@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/lib/logger"
+	"go_redis/resp/reply"
+	"net"
+)
+
+// 集群模式下订阅只在当前节点登记，PUBLISH 广播给所有的节点，任意节点上的订阅者都可以收到消息
+
+// relayPublish 节点之间转发的PUBLISH，收到的节点只在本地发布，避免再次广播
+const relayPublish = "_publish"
+
+// publish PUBLISH channel message   返回所有节点上收到消息的订阅者个数
+func publish(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if len(cmdArgs) != 3 {
+		return reply.MakeArgNumErrReply("publish")
+	}
+	relayArgs := make([][]byte, len(cmdArgs))
+	copy(relayArgs, cmdArgs)
+	relayArgs[0] = []byte(relayPublish)
+	var count int64
+	for _, node := range cluster.nodes {
+		var result resp.Reply
+		if node == cluster.self {
+			result = cluster.db.Exec(c, cmdArgs)
+		} else {
+			result = cluster.relay(node, c, relayArgs)
+		}
+		// 消息已经发布给了其他节点上的订阅者，某个节点失败时只记录日志，返回收到消息的订阅者个数
+		if errReply, ok := result.(reply.ErrorReply); ok {
+			logger.Error("error occurs while publishing to " + node + ": " + errReply.Error())
+			continue
+		}
+		if intReply, ok := result.(*reply.IntReply); ok {
+			count += intReply.Code
+		}
+	}
+	return reply.MakeIntReply(count)
+}
+
+// localPublish 其他节点转发的PUBLISH，只接受来自其他节点的连接，对普通客户端和不存在的指令一样
+func localPublish(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if !cluster.isPeerConn(c) {
+		return reply.MakeErrReply("ERR unknown command '" + relayPublish + "'")
+	}
+	if len(cmdArgs) != 3 {
+		return reply.MakeArgNumErrReply(relayPublish)
+	}
+	args := make([][]byte, len(cmdArgs))
+	copy(args, cmdArgs)
+	args[0] = []byte("publish")
+	return cluster.db.Exec(c, args)
+}
+
+// isPeerConn 判断连接是否来自配置的其他节点(按照对端的IP)
+func (cluster *ClusterDatabase) isPeerConn(c resp.Connection) bool {
+	conn, ok := c.(interface{ RemoteAddr() net.Addr })
+	if !ok {
+		return false
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	_, ok = cluster.peerHosts[addr.IP.String()]
+	return ok
+}
+
+// resolvePeerHosts 解析其他节点的地址，得到它们的IP
+func resolvePeerHosts(peers []string) map[string]struct{} {
+	hosts := make(map[string]struct{})
+	for _, peer := range peers {
+		host, _, err := net.SplitHostPort(peer)
+		if err != nil {
+			logger.Error("invalid peer address " + peer + ": " + err.Error())
+			continue
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			logger.Error("cannot resolve peer " + peer + ": " + err.Error())
+			continue
+		}
+		for _, ip := range ips {
+			hosts[ip.String()] = struct{}{}
+		}
+	}
+	return hosts
+}
+
+// 分片频道和key一样由 peerPicker 分配所属的节点，SSUBSCRIBE 必须在所属的节点上执行，SPUBLISH 只转发给所属的节点
+
+// ssubscribe SSUBSCRIBE shardchannel [shardchannel ...]   所有的频道必须属于当前节点
+func ssubscribe(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if len(cmdArgs) < 2 {
+		return reply.MakeArgNumErrReply("ssubscribe")
+	}
+	peer := cluster.peerPicker.PickNode(string(cmdArgs[1]))
+	for _, channel := range cmdArgs[2:] {
+		if cluster.peerPicker.PickNode(string(channel)) != peer {
+			return reply.MakeErrReply("ERR ssubscribe channels must within on the same peer")
+		}
+	}
+	if peer != cluster.self {
+		return reply.MakeErrReply("ERR shard channel " + string(cmdArgs[1]) + " must be subscribed on peer " + peer)
+	}
+	return cluster.db.Exec(c, cmdArgs)
+}
+
+// spublish SPUBLISH shardchannel message   只在频道所属的节点上发布
+func spublish(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	if len(cmdArgs) != 3 {
+		return reply.MakeArgNumErrReply("spublish")
+	}
+	peer := cluster.peerPicker.PickNode(string(cmdArgs[1]))
+	return cluster.relay(peer, c, cmdArgs)
+}
@@ -1,30 +1,79 @@
 package cluster
 
-import "go_redis/interface/resp"
+import (
+	database2 "go_redis/database"
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+)
 
 // 根据用户的指令确定模式  --->创建一个map: 指令对应的方法（该方法已经明确了模式的具体执行）----->只是负责转发到对应的节点
+// 需要特殊处理的指令(跨节点、广播、阻塞、事务等)单独登记，其余的指令根据指令元数据中的key转发
 func makeRouter() map[string]CmdFunc {
 	routerMap := make(map[string]CmdFunc)
 	// 方法表  --路由表
-	routerMap["exists"] = deafaultFunc
-	routerMap["type"] = deafaultFunc
-	routerMap["set"] = deafaultFunc
-	routerMap["setnx"] = deafaultFunc
-	routerMap["get"] = deafaultFunc
-	routerMap["getset"] = deafaultFunc
+	routerMap["mset"] = mset
+	routerMap["mget"] = mget
+	routerMap["msetnx"] = msetnx
+	routerMap["pfcount"] = pfcount
+	routerMap["xreadgroup"] = xread
+	routerMap["xread"] = xread
+	routerMap["blpop"] = blocking
+	routerMap["brpop"] = blocking
+	routerMap["blmove"] = blocking
+	routerMap["brpoplpush"] = blocking
+	routerMap["bzpopmin"] = blocking
+	routerMap["bzpopmax"] = blocking
 	routerMap["ping"] = ping
 	routerMap["rename"] = Rename
 	routerMap["renamenx"] = Rename
 	routerMap["flushdb"] = flushdb
 	routerMap["del"] = Del
 	routerMap["select"] = execSelect
+	routerMap["multi"] = execLocal
+	routerMap["exec"] = execLocal
+	routerMap["discard"] = execLocal
+	routerMap["unwatch"] = execLocal
+	routerMap["watch"] = watch
+	routerMap["subscribe"] = execLocal
+	routerMap["unsubscribe"] = execLocal
+	routerMap["psubscribe"] = execLocal
+	routerMap["punsubscribe"] = execLocal
+	routerMap["pubsub"] = execLocal
+	routerMap["publish"] = publish
+	routerMap["ssubscribe"] = ssubscribe
+	routerMap["sunsubscribe"] = execLocal
+	routerMap["spublish"] = spublish
+	routerMap[relayPublish] = localPublish
 
+	for _, name := range database2.CommandNames() {
+		if _, ok := routerMap[name]; !ok {
+			routerMap[name] = routeByKeys
+		}
+	}
 	return routerMap
 }
 
-// 默认方法   GET K  SET K1 V1  转发模式
-func deafaultFunc(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
-	key := string(cmdArgs[1])
-	peer := cluster.peerPicker.PickNode(key)
-	return cluster.relay(peer, c, cmdArgs)
+// routeByKeys 默认方法   GET K  SET K1 V1  根据指令元数据取出key，转发给key所在的节点；没有key的指令在当前节点执行
+func routeByKeys(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	keys, errReply := commandKeys(cmdArgs)
+	if errReply != nil {
+		return errReply
+	}
+	if len(keys) == 0 {
+		return cluster.db.Exec(c, cmdArgs)
+	}
+	return relayByKeys(cluster, c, cmdArgs, keys)
+}
+
+// commandKeys 根据指令元数据取出指令中的key
+func commandKeys(cmdArgs [][]byte) ([][]byte, reply.ErrorReply) {
+	keys, errReply := database2.GetKeys(cmdArgs)
+	if errReply != nil {
+		return nil, reply.MakeArgNumErrReply(string(cmdArgs[0]))
+	}
+	result := make([][]byte, len(keys))
+	for i, key := range keys {
+		result[i] = []byte(key)
+	}
+	return result, nil
 }
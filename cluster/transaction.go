@@ -0,0 +1,39 @@
+package cluster
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+)
+
+// 集群模式下的事务只在当前节点上执行，事务中涉及的key都必须属于当前节点
+
+// execLocal MULTI/EXEC/DISCARD/UNWATCH 直接交给当前节点处理
+func execLocal(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	return cluster.db.Exec(c, cmdArgs)
+}
+
+// watch WATCH K1 K2 ...
+func watch(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	for _, key := range cmdArgs[1:] {
+		if cluster.peerPicker.PickNode(string(key)) != cluster.self {
+			return reply.MakeErrReply("ERR transaction keys must be on the peer " + cluster.self)
+		}
+	}
+	return cluster.db.Exec(c, cmdArgs)
+}
+
+// enqueue 事务中的指令交给当前节点排队，key不属于当前节点时放弃整个事务
+func enqueue(cluster *ClusterDatabase, c resp.Connection, cmdArgs [][]byte) resp.Reply {
+	keys, errReply := commandKeys(cmdArgs)
+	if errReply != nil { // 指令不存在或者参数错误，由当前节点记录错误
+		return cluster.db.Exec(c, cmdArgs)
+	}
+	for _, key := range keys {
+		if cluster.peerPicker.PickNode(string(key)) != cluster.self {
+			errReply := reply.MakeErrReply("ERR transaction keys must be on the peer " + cluster.self)
+			c.AddTxError(errReply)
+			return errReply
+		}
+	}
+	return cluster.db.Exec(c, cmdArgs)
+}
@@ -21,27 +21,29 @@ var (
 // ServerProperties defines global config properties
 type ServerProperties struct {
 	// for Public configuration
-	RunID             string `cfg:"runid"` // runID always different at every exec.
-	Bind              string `cfg:"bind"`
-	Port              int    `cfg:"port"`
-	Dir               string `cfg:"dir"`
-	AnnounceHost      string `cfg:"announce-host"`
-	AppendOnly        bool   `cfg:"appendonly"`
-	AppendFilename    string `cfg:"appendfilename"`
-	AppendFsync       string `cfg:"appendfsync"`
-	AofUseRdbPreamble bool   `cfg:"aof-use-rdb-preamble"`
-	MaxClients        int    `cfg:"maxclients"`
-	RequirePass       string `cfg:"requirepass"`
-	Databases         int    `cfg:"databases"`
-	RDBFilename       string `cfg:"dbfilename"`
-	MasterAuth        string `cfg:"masterauth"`
-	SlaveAnnouncePort int    `cfg:"slave-announce-port"`
-	SlaveAnnounceIP   string `cfg:"slave-announce-ip"`
-	ReplTimeout       int    `cfg:"repl-timeout"`
-	ClusterEnable     bool   `cfg:"cluster-enable"`
-	ClusterAsSeed     bool   `cfg:"cluster-as-seed"`
-	ClusterSeed       string `cfg:"cluster-seed"`
-	ClusterConfigFile string `cfg:"cluster-config-file"`
+	RunID                string `cfg:"runid"` // runID always different at every exec.
+	Bind                 string `cfg:"bind"`
+	Port                 int    `cfg:"port"`
+	Dir                  string `cfg:"dir"`
+	AnnounceHost         string `cfg:"announce-host"`
+	AppendOnly           bool   `cfg:"appendonly"`
+	AppendFilename       string `cfg:"appendfilename"`
+	AppendFsync          string `cfg:"appendfsync"`
+	AofUseRdbPreamble    bool   `cfg:"aof-use-rdb-preamble"`
+	MaxClients           int    `cfg:"maxclients"`
+	RequirePass          string `cfg:"requirepass"`
+	Databases            int    `cfg:"databases"`
+	RDBFilename          string `cfg:"dbfilename"`
+	MasterAuth           string `cfg:"masterauth"`
+	SlaveAnnouncePort    int    `cfg:"slave-announce-port"`
+	SlaveAnnounceIP      string `cfg:"slave-announce-ip"`
+	ReplTimeout          int    `cfg:"repl-timeout"`
+	ClusterEnable        bool   `cfg:"cluster-enable"`
+	ClusterAsSeed        bool   `cfg:"cluster-as-seed"`
+	ClusterSeed          string `cfg:"cluster-seed"`
+	ClusterConfigFile    string `cfg:"cluster-config-file"`
+	LuaTimeLimit         int    `cfg:"lua-time-limit"`         // 脚本执行超过该时间(毫秒)之后可以被SCRIPT KILL中止
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"` // 键空间通知的事件类型，例如 "KEA"，为空时不发布
 
 	// for cluster mode configuration
 	ClusterEnabled string   `cfg:"cluster-enabled"` // Not used at present.
@@ -137,6 +139,46 @@ func parse(src io.Reader) *ServerProperties {
 	return config
 }
 
+// Param 配置项的名称和当前的值
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Params 按照字段定义的顺序返回所有的配置项，供 CONFIG GET 使用
+func (p *ServerProperties) Params() []Param {
+	t := reflect.TypeOf(p).Elem()
+	v := reflect.ValueOf(p).Elem()
+	params := make([]Param, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, ok := field.Tag.Lookup("cfg")
+		key = strings.TrimLeft(strings.Split(key, ",")[0], " ")
+		if !ok || key == "" {
+			key = field.Name
+		}
+		fieldVal := v.Field(i)
+		var value string
+		switch field.Type.Kind() {
+		case reflect.String:
+			value = fieldVal.String()
+		case reflect.Int:
+			value = strconv.FormatInt(fieldVal.Int(), 10)
+		case reflect.Bool:
+			value = "no"
+			if fieldVal.Bool() {
+				value = "yes"
+			}
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.String {
+				value = strings.Join(fieldVal.Interface().([]string), ",")
+			}
+		}
+		params = append(params, Param{Name: strings.ToLower(key), Value: value})
+	}
+	return params
+}
+
 // SetupConfig read config file and store properties into Properties
 func SetupConfig(configFilename string) {
 	file, err := os.Open(configFilename)
@@ -0,0 +1,481 @@
+package database
+
+import (
+	"go_redis/datastruct/bitmap"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 位图的常用指令，位图就是普通的string类型的值
+
+const maxBitOffset = 4*1024*1024*1024 - 1 // 位的最大偏移量 2^32-1 (512MB)
+
+// getAsBitMap 取出key对应的值并拷贝一份，修改之后再写回，避免修改到aof队列中仍在引用的切片
+func (db *DB) getAsBitMap(key string) (*bitmap.BitMap, reply.ErrorReply) {
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	bytes := make([]byte, len(value))
+	copy(bytes, value)
+	return bitmap.FromBytes(bytes), nil
+}
+
+func parseBitOffset(arg []byte) (int64, reply.ErrorReply) {
+	offset, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || offset < 0 || offset > maxBitOffset {
+		return 0, reply.MakeErrReply("ERR bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// SETBIT K offset value   返回原先的值
+func execSetBit(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	val := string(args[2])
+	if val != "0" && val != "1" {
+		return reply.MakeErrReply("ERR bit is not an integer or out of range")
+	}
+
+	bm, errReply := db.getAsBitMap(key)
+	if errReply != nil {
+		return errReply
+	}
+	old := bm.SetBit(offset, val[0]-'0')
+	db.PutEntity(key, &database.DataEntity{
+		Data: bm.ToBytes(),
+	})
+	db.addAof(utils.ToCmdLine3("setbit", args...))
+	db.notify(notifyString, "setbit", key)
+	return reply.MakeIntReply(int64(old))
+}
+
+// GETBIT K offset
+func execGetBit(db *DB, args [][]byte) resp.Reply {
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeIntReply(int64(bitmap.FromBytes(value).GetBit(offset)))
+}
+
+// parseBitRange 解析 [start end [BYTE|BIT]]，返回位的范围 [begin, end)，范围为空时返回false
+func parseBitRange(args [][]byte, bm *bitmap.BitMap) (begin int64, end int64, hasEnd bool, empty bool, errReply reply.ErrorReply) {
+	isBit := false
+	if len(args) == 3 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BIT":
+			isBit = true
+		case "BYTE":
+		default:
+			return 0, 0, false, false, reply.MakeSyntaxErrReply()
+		}
+	}
+	size := int64(len(bm.ToBytes()))
+	if isBit {
+		size = bm.BitSize()
+	}
+	start := int64(0)
+	stop := size - 1
+	if len(args) >= 1 {
+		var err error
+		start, err = strconv.ParseInt(string(args[0]), 10, 64)
+		if err != nil {
+			return 0, 0, false, false, reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	if len(args) >= 2 {
+		var err error
+		stop, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return 0, 0, false, false, reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		hasEnd = true
+	}
+	// 负数下标从末尾开始计算，超出范围的截断到边界
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop < 0 {
+		stop = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop || size == 0 {
+		return 0, 0, hasEnd, true, nil
+	}
+	if isBit {
+		return start, stop + 1, hasEnd, false, nil
+	}
+	return start * 8, (stop + 1) * 8, hasEnd, false, nil
+}
+
+// BITCOUNT K [start end [BYTE|BIT]]
+func execBitCount(db *DB, args [][]byte) resp.Reply {
+	if len(args) == 2 || len(args) > 4 {
+		return reply.MakeSyntaxErrReply()
+	}
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	bm := bitmap.FromBytes(value)
+	begin, end, _, empty, errReply := parseBitRange(args[1:], bm)
+	if errReply != nil {
+		return errReply
+	}
+	if empty {
+		return reply.MakeIntReply(0)
+	}
+	if begin%8 == 0 && end%8 == 0 { // 按照字节统计，使用查表加速
+		return reply.MakeIntReply(bm.CountBytes(begin/8, end/8))
+	}
+	return reply.MakeIntReply(bm.CountBits(begin, end))
+}
+
+// BITPOS K bit [start [end [BYTE|BIT]]]
+func execBitPos(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 5 {
+		return reply.MakeSyntaxErrReply()
+	}
+	bitArg := string(args[1])
+	if bitArg != "0" && bitArg != "1" {
+		return reply.MakeErrReply("ERR The bit argument must be 1 or 0.")
+	}
+	bit := bitArg[0] - '0'
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if value == nil { // key不存在时视为全0的字符串
+		if bit == 0 {
+			return reply.MakeIntReply(0)
+		}
+		return reply.MakeIntReply(-1)
+	}
+	bm := bitmap.FromBytes(value)
+	begin, end, hasEnd, empty, errReply := parseBitRange(args[2:], bm)
+	if errReply != nil {
+		return errReply
+	}
+	if empty {
+		return reply.MakeIntReply(-1)
+	}
+	pos := bm.FindBit(bit, begin, end)
+	// 查找0但是指定范围内全是1，并且没有指定end时，认为字符串右边补充了0
+	if pos < 0 && bit == 0 && !hasEnd {
+		return reply.MakeIntReply(end)
+	}
+	return reply.MakeIntReply(pos)
+}
+
+// BITOP AND|OR|XOR|NOT dest K1 K2 ...   返回dest的长度
+func execBitOp(db *DB, args [][]byte) resp.Reply {
+	op := strings.ToUpper(string(args[0]))
+	dest := string(args[1])
+	keys := args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(keys) != 1 {
+			return reply.MakeErrReply("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return reply.MakeSyntaxErrReply()
+	}
+
+	values := make([][]byte, len(keys))
+	maxLen := 0
+	for i, key := range keys {
+		value, errReply := db.getAsString(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		values[i] = value
+		if len(value) > maxLen {
+			maxLen = len(value)
+		}
+	}
+	// 较短的字符串右边补0
+	result := make([]byte, maxLen)
+	for i := 0; i < maxLen; i++ {
+		var b byte
+		for j, value := range values {
+			var v byte
+			if i < len(value) {
+				v = value[i]
+			}
+			if j == 0 {
+				b = v
+				continue
+			}
+			switch op {
+			case "AND":
+				b &= v
+			case "OR":
+				b |= v
+			case "XOR":
+				b ^= v
+			}
+		}
+		if op == "NOT" {
+			b = ^b
+		}
+		result[i] = b
+	}
+
+	if maxLen == 0 {
+		if _, exists := db.GetEntity(dest); exists {
+			db.Remove(dest)
+			db.notify(notifyGeneric, "del", dest)
+		}
+	} else {
+		db.PutEntity(dest, &database.DataEntity{
+			Data: result,
+		})
+		db.Persist(dest)
+		db.notify(notifyString, "set", dest)
+	}
+	db.addAof(utils.ToCmdLine3("bitop", args...))
+	return reply.MakeIntReply(int64(maxLen))
+}
+
+// ---------------------- BITFIELD ----------------------
+
+// BITFIELD 溢出的处理方式
+const (
+	overflowWrap = iota // 回绕(默认)
+	overflowSat         // 饱和，取最大值或者最小值
+	overflowFail        // 失败，返回nil并且不修改
+)
+
+// bitFieldType 整数类型  i8 u16 ...
+type bitFieldType struct {
+	signed bool
+	bits   int
+}
+
+func parseBitFieldType(arg []byte) (*bitFieldType, reply.ErrorReply) {
+	s := strings.ToLower(string(arg))
+	errReply := reply.MakeErrReply("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'u') {
+		return nil, errReply
+	}
+	bits, err := strconv.Atoi(s[1:])
+	if err != nil || bits < 1 || (s[0] == 'i' && bits > 64) || (s[0] == 'u' && bits > 63) {
+		return nil, errReply
+	}
+	return &bitFieldType{signed: s[0] == 'i', bits: bits}, nil
+}
+
+// parseBitFieldOffset 解析偏移量，#N 表示第N个该类型的整数
+func parseBitFieldOffset(arg []byte, typ *bitFieldType) (int64, reply.ErrorReply) {
+	s := string(arg)
+	multiply := false
+	if strings.HasPrefix(s, "#") {
+		multiply = true
+		s = s[1:]
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, reply.MakeErrReply("ERR bit offset is not an integer or out of range")
+	}
+	if multiply {
+		offset *= int64(typ.bits)
+	}
+	if offset+int64(typ.bits)-1 > maxBitOffset {
+		return 0, reply.MakeErrReply("ERR bit offset is not an integer or out of range")
+	}
+	return offset, nil
+}
+
+// bounds 返回该类型可以表示的范围
+func (typ *bitFieldType) bounds() (*big.Int, *big.Int) {
+	if typ.signed {
+		max := new(big.Int).Lsh(big.NewInt(1), uint(typ.bits-1))
+		min := new(big.Int).Neg(max)
+		return min, max.Sub(max, big.NewInt(1))
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), uint(typ.bits))
+	return big.NewInt(0), max.Sub(max, big.NewInt(1))
+}
+
+// decode 将位图中读取出来的原始位转化为整数
+func (typ *bitFieldType) decode(raw uint64) *big.Int {
+	val := new(big.Int).SetUint64(raw)
+	if typ.signed && raw>>uint(typ.bits-1)&1 == 1 { // 符号位为1，为负数
+		val.Sub(val, new(big.Int).Lsh(big.NewInt(1), uint(typ.bits)))
+	}
+	return val
+}
+
+// encode 将整数转化为位图中的原始位(补码)
+func (typ *bitFieldType) encode(val *big.Int) uint64 {
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(typ.bits))
+	v := new(big.Int).Mod(val, mod) // 结果为非负数
+	return v.Uint64()
+}
+
+// handleOverflow 按照溢出策略处理，返回false表示FAIL
+func (typ *bitFieldType) handleOverflow(val *big.Int, overflow int) (*big.Int, bool) {
+	min, max := typ.bounds()
+	if val.Cmp(min) >= 0 && val.Cmp(max) <= 0 {
+		return val, true
+	}
+	switch overflow {
+	case overflowSat:
+		if val.Cmp(min) < 0 {
+			return min, true
+		}
+		return max, true
+	case overflowFail:
+		return nil, false
+	}
+	// WRAP：按照补码回绕
+	return typ.decode(typ.encode(val)), true
+}
+
+// BITFIELD K [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL] ...
+func execBitField(db *DB, args [][]byte) resp.Reply {
+	return bitField(db, args, false)
+}
+
+// BITFIELD_RO K [GET type offset] ...
+func execBitFieldRO(db *DB, args [][]byte) resp.Reply {
+	return bitField(db, args, true)
+}
+
+func bitField(db *DB, args [][]byte, readOnly bool) resp.Reply {
+	key := string(args[0])
+	// 先解析所有的子命令，有任何语法错误都不执行
+	type subCommand struct {
+		op       string
+		typ      *bitFieldType
+		offset   int64
+		value    *big.Int
+		overflow int
+	}
+	var commands []*subCommand
+	overflow := overflowWrap
+	for i := 1; i < len(args); i++ {
+		op := strings.ToUpper(string(args[i]))
+		switch op {
+		case "GET", "SET", "INCRBY":
+			if readOnly && op != "GET" {
+				return reply.MakeErrReply("ERR BITFIELD_RO only supports the GET subcommand")
+			}
+			argc := 2
+			if op != "GET" {
+				argc = 3
+			}
+			if i+argc >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			typ, errReply := parseBitFieldType(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			offset, errReply := parseBitFieldOffset(args[i+2], typ)
+			if errReply != nil {
+				return errReply
+			}
+			cmd := &subCommand{op: op, typ: typ, offset: offset, overflow: overflow}
+			if op != "GET" {
+				value, ok := new(big.Int).SetString(string(args[i+3]), 10)
+				if !ok || !value.IsInt64() {
+					return reply.MakeErrReply("ERR value is not an integer or out of range")
+				}
+				cmd.value = value
+			}
+			commands = append(commands, cmd)
+			i += argc
+		case "OVERFLOW":
+			if readOnly || i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return reply.MakeErrReply("ERR Invalid OVERFLOW type specified")
+			}
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	bm, errReply := db.getAsBitMap(key)
+	if errReply != nil {
+		return errReply
+	}
+	modified := false
+	results := make([]resp.Reply, 0, len(commands))
+	for _, cmd := range commands {
+		old := cmd.typ.decode(bm.GetBits(cmd.offset, cmd.typ.bits))
+		switch cmd.op {
+		case "GET":
+			results = append(results, reply.MakeIntReply(old.Int64()))
+		case "SET":
+			val, ok := cmd.typ.handleOverflow(cmd.value, cmd.overflow)
+			if !ok {
+				results = append(results, reply.MakeNullBulkReply())
+				continue
+			}
+			bm.SetBits(cmd.offset, cmd.typ.bits, cmd.typ.encode(val))
+			modified = true
+			results = append(results, reply.MakeIntReply(old.Int64()))
+		case "INCRBY":
+			val, ok := cmd.typ.handleOverflow(new(big.Int).Add(old, cmd.value), cmd.overflow)
+			if !ok {
+				results = append(results, reply.MakeNullBulkReply())
+				continue
+			}
+			bm.SetBits(cmd.offset, cmd.typ.bits, cmd.typ.encode(val))
+			modified = true
+			results = append(results, reply.MakeIntReply(val.Int64()))
+		}
+	}
+	if modified {
+		db.PutEntity(key, &database.DataEntity{
+			Data: bm.ToBytes(),
+		})
+		db.addAof(utils.ToCmdLine3("bitfield", args...))
+		db.notify(notifyString, "setbit", key)
+	}
+	return reply.MakeMultiRawReply(results)
+}
+
+func init() {
+	RegisterCommand("setbit", execSetBit, nil, 4, flagWrite|flagFast, 1, 1, 1) // SETBIT K offset value
+	RegisterCommand("getbit", execGetBit, nil, 3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("bitcount", execBitCount, nil, -2, flagReadOnly, 1, 1, 1)  // BITCOUNT K [start end [BYTE|BIT]]
+	RegisterCommand("bitpos", execBitPos, nil, -3, flagReadOnly, 1, 1, 1)      // BITPOS K bit [start [end [BYTE|BIT]]]
+	RegisterCommand("bitop", execBitOp, prepareBitOp, -4, flagWrite, 2, -1, 1) // BITOP op dest K1 K2 ...
+	RegisterCommand("bitfield", execBitField, nil, -2, flagWrite, 1, 1, 1)
+	RegisterCommand("bitfield_ro", execBitFieldRO, nil, -2, flagReadOnly|flagFast, 1, 1, 1)
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestSetBitGetBit(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"setbit", "k", "7", "1"}, ":0\r\n"},
+		{[]string{"setbit", "k", "7", "0"}, ":1\r\n"},
+		{[]string{"setbit", "k", "7", "1"}, ":0\r\n"},
+		{[]string{"getbit", "k", "0"}, ":0\r\n"},
+		{[]string{"getbit", "k", "7"}, ":1\r\n"},
+		{[]string{"getbit", "k", "100"}, ":0\r\n"}, // 超出长度的位为0
+		{[]string{"strlen", "k"}, ":1\r\n"},
+		{[]string{"setbit", "k", "23", "1"}, ":0\r\n"}, // 按需扩展
+		{[]string{"get", "k"}, "$3\r\n\x01\x00\x01\r\n"},
+		{[]string{"setbit", "k", "4294967296", "1"}, "-ERR bit offset is not an integer or out of range\r\n"},
+		{[]string{"setbit", "k", "-1", "1"}, "-ERR bit offset is not an integer or out of range\r\n"},
+		{[]string{"setbit", "k", "1", "2"}, "-ERR bit is not an integer or out of range\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+}
+
+func TestBitCountBitPos(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "set", "foo", "foobar")
+	execCmd(e, c, "set", "a", "\xff\xf0\x00")
+	execCmd(e, c, "set", "b", "\x00\xff\xf0")
+	execCmd(e, c, "set", "zero", "\x00\x00\x00")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"bitcount", "foo"}, ":26\r\n"},
+		{[]string{"bitcount", "foo", "0", "0"}, ":4\r\n"},
+		{[]string{"bitcount", "foo", "1", "1", "byte"}, ":6\r\n"},
+		{[]string{"bitcount", "foo", "5", "30", "bit"}, ":17\r\n"},
+		{[]string{"bitcount", "foo", "-2", "-1"}, ":7\r\n"},
+		{[]string{"bitcount", "missing"}, ":0\r\n"},
+		{[]string{"bitcount", "foo", "0"}, "-Err syntax error\r\n"},
+		{[]string{"bitpos", "a", "0"}, ":12\r\n"},
+		{[]string{"bitpos", "b", "1", "0"}, ":8\r\n"},
+		{[]string{"bitpos", "b", "1", "2"}, ":16\r\n"},
+		{[]string{"bitpos", "b", "1", "2", "-1", "byte"}, ":16\r\n"},
+		{[]string{"bitpos", "b", "1", "7", "15", "bit"}, ":8\r\n"},
+		{[]string{"bitpos", "zero", "1"}, ":-1\r\n"},
+		{[]string{"bitpos", "zero", "1", "7", "-3", "bit"}, ":-1\r\n"},
+		{[]string{"bitpos", "missing", "0"}, ":0\r\n"},
+		{[]string{"bitpos", "missing", "1"}, ":-1\r\n"},
+		{[]string{"bitpos", "a", "2"}, "-ERR The bit argument must be 1 or 0.\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+	// 全是1并且没有指定end时，认为右边补充了0
+	execCmd(e, c, "set", "ones", "\xff\xff")
+	assertReply(t, execCmd(e, c, "bitpos", "ones", "0"), ":16\r\n")
+	assertReply(t, execCmd(e, c, "bitpos", "ones", "0", "0", "-1"), ":-1\r\n")
+}
+
+func TestBitOp(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "set", "key1", "foobar")
+	execCmd(e, c, "set", "key2", "abcdef")
+	execCmd(e, c, "set", "short", "\xff")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"bitop", "and", "dest", "key1", "key2"}, ":6\r\n"},
+		{[]string{"get", "dest"}, "$6\r\n`bc`ab\r\n"},
+		{[]string{"bitop", "or", "dest", "short", "missing"}, ":1\r\n"},
+		{[]string{"get", "dest"}, "$1\r\n\xff\r\n"},
+		{[]string{"bitop", "xor", "dest", "short", "key1"}, ":6\r\n"}, // 较短的字符串右边补0
+		{[]string{"get", "dest"}, "$6\r\n\x99oobar\r\n"},
+		{[]string{"bitop", "not", "dest", "short"}, ":1\r\n"},
+		{[]string{"get", "dest"}, "$1\r\n\x00\r\n"},
+		{[]string{"bitop", "not", "dest", "key1", "key2"}, "-ERR BITOP NOT must be called with a single source key.\r\n"},
+		{[]string{"bitop", "nand", "dest", "key1"}, "-Err syntax error\r\n"},
+		{[]string{"set", "dest", "v", "ex", "100"}, "+OK\r\n"},
+		{[]string{"bitop", "and", "dest", "missing"}, ":0\r\n"}, // 结果为空时删除dest
+		{[]string{"exists", "dest"}, ":0\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+}
+
+func TestBitField(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"bitfield", "k", "incrby", "i5", "100", "1", "get", "u4", "0"}, "*2\r\n:1\r\n:0\r\n"},
+		{[]string{"bitfield", "k", "set", "i8", "#1", "-100", "get", "i8", "8"}, "*2\r\n:0\r\n:-100\r\n"},
+		{[]string{"bitfield", "k", "get", "u8", "#1"}, "*1\r\n:156\r\n"},
+		// WRAP(默认)
+		{[]string{"bitfield", "w", "set", "u2", "0", "3", "incrby", "u2", "0", "1"}, "*2\r\n:0\r\n:0\r\n"},
+		{[]string{"bitfield", "w", "set", "i8", "8", "127", "incrby", "i8", "8", "1"}, "*2\r\n:0\r\n:-128\r\n"},
+		// SAT
+		{[]string{"bitfield", "s", "overflow", "sat", "incrby", "u2", "0", "5"}, "*1\r\n:3\r\n"},
+		{[]string{"bitfield", "s", "overflow", "sat", "incrby", "i8", "8", "-1000"}, "*1\r\n:-128\r\n"},
+		{[]string{"bitfield", "s", "overflow", "sat", "set", "u2", "0", "100"}, "*1\r\n:3\r\n"},
+		// FAIL 时返回nil并且不修改
+		{[]string{"bitfield", "f", "set", "u2", "0", "3", "overflow", "fail", "incrby", "u2", "0", "1", "get", "u2", "0"},
+			"*3\r\n:0\r\n$-1\r\n:3\r\n"},
+		{[]string{"bitfield", "f", "overflow", "fail", "set", "u2", "0", "4"}, "*1\r\n$-1\r\n"},
+		{[]string{"bitfield", "k", "get", "i65", "0"},
+			"-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"},
+		{[]string{"bitfield", "k", "get", "u64", "0"},
+			"-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n"},
+		{[]string{"bitfield", "k", "get", "u8", "4294967290"}, "-ERR bit offset is not an integer or out of range\r\n"},
+		{[]string{"bitfield", "k", "overflow", "foo"}, "-ERR Invalid OVERFLOW type specified\r\n"},
+		{[]string{"bitfield", "k", "set", "u8", "0"}, "-Err syntax error\r\n"},
+		{[]string{"bitfield_ro", "k", "get", "u4", "0"}, "*1\r\n:0\r\n"},
+		{[]string{"bitfield_ro", "k", "set", "u4", "0", "1"}, "-ERR BITFIELD_RO only supports the GET subcommand\r\n"},
+		{[]string{"bitfield", "missing", "get", "u8", "0"}, "*1\r\n:0\r\n"},
+		{[]string{"exists", "missing"}, ":0\r\n"}, // 只读取时不创建key
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+}
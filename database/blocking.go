@@ -0,0 +1,376 @@
+package database
+
+import (
+	"container/list"
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 阻塞指令 BLPOP/BRPOP/BLMOVE/BZPOPMIN/XREAD BLOCK 等
+// 指令执行时先尝试一次，没有数据时将客户端登记到所在db的阻塞表中(key -> 按照阻塞先后排列的客户端)，然后等待；
+// 写指令执行完毕之后，在执行该指令的协程中按照先进先出的顺序为阻塞在相关key上的客户端重新尝试，
+// 成功的客户端从阻塞表中移除并收到结果，和redis在写指令之后处理 ready keys 的方式一致
+
+// tryFunc 尝试执行阻塞指令，key为刚刚被修改的key(第一次尝试时为空)，没有数据可以返回时ok为false
+// 阻塞指令执行之前执行器不会加锁，tryFunc需要自己对访问的key加锁
+type tryFunc func(key string) (result resp.Reply, ok bool)
+
+// blockedClient 阻塞中的客户端
+type blockedClient struct {
+	conn     resp.Connection
+	keys     []string
+	elements map[string]*list.Element // 在每个key的等待队列中的位置
+	try      tryFunc
+	writes   []string        // 成功执行之后会写入的key(如BLMOVE的dest)，需要继续唤醒阻塞在这些key上的客户端
+	result   chan resp.Reply // 缓冲为1，结果为nil表示客户端断开了连接
+}
+
+type blockingTable struct {
+	mu       sync.Mutex
+	waiters  map[string]*list.List // key -> 阻塞在该key上的客户端
+	count    int32                 // 阻塞中的客户端个数，为0时指令执行之后不需要检查
+	deferred []string              // EXEC执行期间写入的key，事务结束之后再唤醒
+	// StandaloneDatabase 的事务锁，指令执行时持有读锁，阻塞等待期间释放，避免等待中的客户端阻止EXEC和脚本的执行
+	txLock *sync.RWMutex
+}
+
+func makeBlockingTable() *blockingTable {
+	return &blockingTable{
+		waiters: make(map[string]*list.List),
+	}
+}
+
+// add 登记阻塞中的客户端，调用方需要持有锁
+func (table *blockingTable) add(client *blockedClient) {
+	client.elements = make(map[string]*list.Element, len(client.keys))
+	for _, key := range client.keys {
+		if _, ok := client.elements[key]; ok { // 同一个key出现多次时只登记一次
+			continue
+		}
+		queue, ok := table.waiters[key]
+		if !ok {
+			queue = list.New()
+			table.waiters[key] = queue
+		}
+		client.elements[key] = queue.PushBack(client)
+	}
+	atomic.AddInt32(&table.count, 1)
+}
+
+// remove 移除阻塞中的客户端，调用方需要持有锁，已经移除时返回false
+func (table *blockingTable) remove(client *blockedClient) bool {
+	if client.elements == nil {
+		return false
+	}
+	for key, element := range client.elements {
+		queue := table.waiters[key]
+		queue.Remove(element)
+		if queue.Len() == 0 {
+			delete(table.waiters, key)
+		}
+	}
+	client.elements = nil
+	atomic.AddInt32(&table.count, -1)
+	return true
+}
+
+// block 先尝试执行一次，没有数据时阻塞等待直到被唤醒或者超时(timeout为0表示一直等待)，超时返回 timeoutReply
+func (table *blockingTable) block(c resp.Connection, keys []string, writes []string, timeout time.Duration,
+	try tryFunc, timeoutReply resp.Reply) resp.Reply {
+	// 尝试和登记在同一个锁内完成，避免两者之间的写入无法唤醒客户端
+	table.mu.Lock()
+	if result, ok := try(""); ok {
+		table.mu.Unlock()
+		return result
+	}
+	if _, inScript := c.(*scriptClient); inScript || c.InMultiState() { // 事务和脚本中的阻塞指令不会阻塞，和超时的结果一致
+		table.mu.Unlock()
+		return timeoutReply
+	}
+	client := &blockedClient{
+		conn:   c,
+		keys:   keys,
+		try:    try,
+		writes: writes,
+		result: make(chan resp.Reply, 1),
+	}
+	table.add(client)
+	table.mu.Unlock()
+	// 等待期间不持有事务锁，被唤醒时结果已经由写入的客户端在持有事务锁的情况下得到
+	if table.txLock != nil {
+		table.txLock.RUnlock()
+		defer table.txLock.RLock()
+	}
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+	var result resp.Reply
+	select {
+	case result = <-client.result:
+	case <-timer:
+		table.mu.Lock()
+		removed := table.remove(client)
+		table.mu.Unlock()
+		if !removed { // 超时的同时被唤醒了，以唤醒的结果为准
+			result = <-client.result
+		}
+	}
+	if result == nil {
+		return timeoutReply
+	}
+	return result
+}
+
+// signal 在指令执行完毕之后调用，按照先进先出的顺序为阻塞在这些key上的客户端重新尝试
+func (table *blockingTable) signal(keys []string) {
+	if atomic.LoadInt32(&table.count) == 0 {
+		return
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	ready := make([]string, len(keys))
+	copy(ready, keys)
+	for i := 0; i < len(ready); i++ {
+		key := ready[i]
+		queue, ok := table.waiters[key]
+		if !ok {
+			continue
+		}
+		for element := queue.Front(); element != nil; {
+			next := element.Next()
+			client := element.Value.(*blockedClient)
+			if result, ok := client.try(key); ok {
+				table.remove(client)
+				client.result <- result
+				ready = append(ready, client.writes...)
+			}
+			element = next
+		}
+	}
+}
+
+// deferSignal EXEC中的写指令记录写入的key，事务中的指令全部执行完毕之后再唤醒阻塞的客户端，保证事务的原子性
+func (table *blockingTable) deferSignal(keys []string) {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	table.deferred = append(table.deferred, keys...)
+}
+
+// signalDeferred 唤醒阻塞在EXEC写入的key上的客户端
+func (table *blockingTable) signalDeferred() {
+	table.mu.Lock()
+	keys := table.deferred
+	table.deferred = nil
+	table.mu.Unlock()
+	if len(keys) > 0 {
+		table.signal(keys)
+	}
+}
+
+// removeClient 客户端断开连接时移除它的阻塞状态，并唤醒阻塞中的指令
+func (table *blockingTable) removeClient(c resp.Connection) {
+	if atomic.LoadInt32(&table.count) == 0 {
+		return
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	var clients []*blockedClient
+	for _, queue := range table.waiters {
+		for element := queue.Front(); element != nil; element = element.Next() {
+			client := element.Value.(*blockedClient)
+			if client.conn == c {
+				clients = append(clients, client)
+			}
+		}
+	}
+	for _, client := range clients {
+		if table.remove(client) {
+			client.result <- nil
+		}
+	}
+}
+
+// maxBlockTimeout 阻塞超时时间的上限，超过之后 time.Duration 会溢出
+const maxBlockTimeout = time.Duration(math.MaxInt64)
+
+// parseBlockTimeout 解析以秒为单位的超时时间，支持小数
+func parseBlockTimeout(arg []byte) (time.Duration, reply.ErrorReply) {
+	seconds, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if math.IsNaN(seconds) {
+		return 0, reply.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, reply.MakeErrReply("ERR timeout is negative")
+	}
+	if seconds > float64(maxBlockTimeout/time.Second) {
+		return 0, reply.MakeErrReply("ERR timeout is out of range")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ---------------------- 阻塞指令 ----------------------
+
+// blockingPop BLPOP/BRPOP K1 K2 ... timeout   返回 [key, value]
+func blockingPop(db *DB, c resp.Connection, args [][]byte, fromLeft bool) resp.Reply {
+	timeout, errReply := parseBlockTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[:len(args)-1] {
+		keys[i] = string(arg)
+	}
+	cmdName := "rpop"
+	if fromLeft {
+		cmdName = "lpop"
+	}
+	popKey := func(key string) (resp.Reply, bool) {
+		db.RWLocks([]string{key}, nil)
+		defer db.RWUnLocks([]string{key}, nil)
+		l, errReply := db.getAsList(key)
+		if errReply != nil {
+			return errReply, true
+		}
+		if l == nil {
+			return nil, false
+		}
+		r := popList(db, [][]byte{[]byte(key)}, cmdName, fromLeft)
+		value, ok := r.(*reply.BulkReply)
+		if !ok {
+			return r, true
+		}
+		return reply.MakeMultiBulkReply([][]byte{[]byte(key), value.Arg}), true
+	}
+	try := func(readyKey string) (resp.Reply, bool) {
+		if readyKey != "" {
+			return popKey(readyKey)
+		}
+		for _, key := range keys { // 第一次尝试时按照参数的顺序
+			if result, ok := popKey(key); ok {
+				return result, true
+			}
+		}
+		return nil, false
+	}
+	return db.blocking.block(c, keys, nil, timeout, try, reply.MakeNullMultiBulkReply())
+}
+
+// BLPOP K1 K2 ... timeout
+func execBLPop(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingPop(db, c, args, true)
+}
+
+// BRPOP K1 K2 ... timeout
+func execBRPop(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingPop(db, c, args, false)
+}
+
+// blockingMove BLMOVE/BRPOPLPUSH 的公共逻辑
+func blockingMove(db *DB, c resp.Connection, src string, dest string, fromLeft bool, toLeft bool, timeoutArg []byte) resp.Reply {
+	timeout, errReply := parseBlockTimeout(timeoutArg)
+	if errReply != nil {
+		return errReply
+	}
+	keys := []string{src, dest}
+	try := func(string) (resp.Reply, bool) {
+		db.RWLocks(keys, nil)
+		defer db.RWUnLocks(keys, nil)
+		result := lMove(db, src, dest, fromLeft, toLeft)
+		if _, isNull := result.(*reply.NullBulkReply); isNull {
+			return nil, false
+		}
+		return result, true
+	}
+	return db.blocking.block(c, []string{src}, []string{dest}, timeout, try, reply.MakeNullMultiBulkReply())
+}
+
+// BLMOVE src dest LEFT|RIGHT LEFT|RIGHT timeout
+func execBLMove(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	fromLeft, ok1 := parseListDirection(args[2])
+	toLeft, ok2 := parseListDirection(args[3])
+	if !ok1 || !ok2 {
+		return reply.MakeSyntaxErrReply()
+	}
+	return blockingMove(db, c, string(args[0]), string(args[1]), fromLeft, toLeft, args[4])
+}
+
+// BRPOPLPUSH src dest timeout
+func execBRPopLPush(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingMove(db, c, string(args[0]), string(args[1]), false, true, args[2])
+}
+
+// blockingZPop BZPOPMIN/BZPOPMAX K1 K2 ... timeout   返回 [key, member, score]
+func blockingZPop(db *DB, c resp.Connection, args [][]byte, max bool) resp.Reply {
+	timeout, errReply := parseBlockTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[:len(args)-1] {
+		keys[i] = string(arg)
+	}
+	cmdName := "zpopmin"
+	if max {
+		cmdName = "zpopmax"
+	}
+	popKey := func(key string) (resp.Reply, bool) {
+		db.RWLocks([]string{key}, nil)
+		defer db.RWUnLocks([]string{key}, nil)
+		sortedSet, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return errReply, true
+		}
+		if sortedSet == nil || sortedSet.Len() == 0 {
+			return nil, false
+		}
+		r := popSortedSet(db, [][]byte{[]byte(key)}, cmdName, max)
+		elements, ok := r.(*reply.MultiRawReply)
+		if !ok || len(elements.Replies) != 2 {
+			return r, true
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{reply.MakeBulkReply([]byte(key)), elements.Replies[0], elements.Replies[1]}), true
+	}
+	try := func(readyKey string) (resp.Reply, bool) {
+		if readyKey != "" {
+			return popKey(readyKey)
+		}
+		for _, key := range keys {
+			if result, ok := popKey(key); ok {
+				return result, true
+			}
+		}
+		return nil, false
+	}
+	return db.blocking.block(c, keys, nil, timeout, try, reply.MakeNullMultiBulkReply())
+}
+
+// BZPOPMIN K1 K2 ... timeout
+func execBZPopMin(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingZPop(db, c, args, false)
+}
+
+// BZPOPMAX K1 K2 ... timeout
+func execBZPopMax(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingZPop(db, c, args, true)
+}
+
+func init() {
+	RegisterConnCommand("blpop", execBLPop, nil, -3, flagWrite|flagBlocking, 1, -2, 1) // BLPOP K1 K2 ... timeout
+	RegisterConnCommand("brpop", execBRPop, nil, -3, flagWrite|flagBlocking, 1, -2, 1)
+	RegisterConnCommand("blmove", execBLMove, nil, 6, flagWrite|flagBlocking, 1, 2, 1) // BLMOVE src dest LEFT|RIGHT LEFT|RIGHT timeout
+	RegisterConnCommand("brpoplpush", execBRPopLPush, nil, 4, flagWrite|flagBlocking, 1, 2, 1)
+	RegisterConnCommand("bzpopmin", execBZPopMin, nil, -3, flagWrite|flagBlocking|flagFast, 1, -2, 1) // BZPOPMIN K1 K2 ... timeout
+	RegisterConnCommand("bzpopmax", execBZPopMax, nil, -3, flagWrite|flagBlocking|flagFast, 1, -2, 1)
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/connection"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockAsync 在新的协程中执行阻塞指令，等到客户端登记到阻塞表之后返回结果channel
+func blockAsync(t *testing.T, e *StandaloneDatabase, args ...string) <-chan resp.Reply {
+	t.Helper()
+	db := e.dbSet[0]
+	blocked := atomic.LoadInt32(&db.blocking.count)
+	result := make(chan resp.Reply, 1)
+	go func() {
+		result <- execCmd(e, &connection.Connection{}, args...)
+	}()
+	waitBlocked(t, db, blocked+1)
+	return result
+}
+
+func receive(t *testing.T, result <-chan resp.Reply) resp.Reply {
+	t.Helper()
+	select {
+	case r := <-result:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("blocked client was not woken up")
+		return nil
+	}
+}
+
+// 多个客户端阻塞在同一个key上时按照阻塞的先后顺序唤醒，每次写入只唤醒能够取到数据的客户端
+func TestBlockingFIFO(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	first := blockAsync(t, e, "blpop", "l", "0")
+	second := blockAsync(t, e, "blpop", "other", "l", "0")
+
+	execCmd(e, c, "rpush", "l", "a")
+	assertReply(t, receive(t, first), "*2\r\n$1\r\nl\r\n$1\r\na\r\n")
+	select {
+	case r := <-second:
+		t.Fatalf("second client woken without data: %q", r.ToBytes())
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	execCmd(e, c, "rpush", "l", "b", "c")
+	assertReply(t, receive(t, second), "*2\r\n$1\r\nl\r\n$1\r\nb\r\n")
+	assertReply(t, execCmd(e, c, "lrange", "l", "0", "-1"), "*1\r\n$1\r\nc\r\n")
+
+	// BLMOVE 写入的dest继续唤醒阻塞在dest上的客户端
+	third := blockAsync(t, e, "blpop", "dest", "0")
+	moved := blockAsync(t, e, "blmove", "src", "dest", "left", "right", "0")
+	execCmd(e, c, "rpush", "src", "x")
+	assertReply(t, receive(t, moved), "$1\r\nx\r\n")
+	assertReply(t, receive(t, third), "*2\r\n$4\r\ndest\r\n$1\r\nx\r\n")
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	start := time.Now()
+	assertReply(t, execCmd(e, c, "blpop", "l", "0.05"), "*-1\r\n")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("returned after %v", elapsed)
+	}
+	assertReply(t, execCmd(e, c, "bzpopmin", "z", "0.01"), "*-1\r\n")
+	assertReply(t, execCmd(e, c, "xread", "block", "10", "streams", "s", "$"), "*-1\r\n")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"blpop", "l", "-1"}, "-ERR timeout is negative\r\n"},
+		{[]string{"blpop", "l", "abc"}, "-ERR timeout is not a float or out of range\r\n"},
+		{[]string{"blpop", "l", "nan"}, "-ERR timeout is not a float or out of range\r\n"},
+		{[]string{"blpop", "l", "1e20"}, "-ERR timeout is out of range\r\n"},
+		{[]string{"blpop", "l", "inf"}, "-ERR timeout is out of range\r\n"},
+		{[]string{"blmove", "a", "b", "left", "left", "9999999999"}, "-ERR timeout is out of range\r\n"},
+		{[]string{"xread", "block", "9223372036854775807", "streams", "s", "$"}, "-ERR timeout is out of range\r\n"},
+		{[]string{"xread", "block", "-1", "streams", "s", "$"}, "-ERR timeout is negative\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+	if n := atomic.LoadInt32(&e.dbSet[0].blocking.count); n != 0 {
+		t.Errorf("%d clients left in the blocking table", n)
+	}
+}
+
+// 事务中的阻塞指令不会阻塞，没有数据时和超时的结果一致
+func TestBlockingInMulti(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "multi")
+	execCmd(e, c, "blpop", "l", "0")
+	execCmd(e, c, "rpush", "l", "a")
+	execCmd(e, c, "blpop", "l", "0")
+	assertReply(t, execCmd(e, c, "exec"), "*3\r\n*-1\r\n:1\r\n*2\r\n$1\r\nl\r\n$1\r\na\r\n")
+
+	// EXEC写入的key在事务结束之后才唤醒阻塞的客户端
+	blocked := blockAsync(t, e, "blpop", "l", "0")
+	execCmd(e, c, "multi")
+	execCmd(e, c, "rpush", "l", "b")
+	execCmd(e, c, "lpop", "l")
+	execCmd(e, c, "rpush", "l", "c")
+	assertReply(t, execCmd(e, c, "exec"), "*3\r\n:1\r\n$1\r\nb\r\n:1\r\n")
+	assertReply(t, receive(t, blocked), "*2\r\n$1\r\nl\r\n$1\r\nc\r\n")
+}
+
+// 超时的阻塞指令和条件不满足的写指令没有写入数据，不会使WATCH失效
+func TestBlockingTimeoutKeepsWatch(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	other := &connection.Connection{}
+	execCmd(e, other, "set", "s", "v")
+	execCmd(e, c, "watch", "l", "s")
+	assertReply(t, execCmd(e, other, "blpop", "l", "0.01"), "*-1\r\n")
+	assertReply(t, execCmd(e, other, "setnx", "s", "v2"), ":0\r\n")
+	execCmd(e, c, "multi")
+	execCmd(e, c, "set", "x", "1")
+	assertReply(t, execCmd(e, c, "exec"), "*1\r\n+OK\r\n")
+
+	execCmd(e, c, "watch", "l")
+	assertReply(t, execCmd(e, other, "rpush", "l", "a"), ":1\r\n")
+	execCmd(e, c, "multi")
+	execCmd(e, c, "set", "x", "2")
+	assertReply(t, execCmd(e, c, "exec"), "*-1\r\n")
+}
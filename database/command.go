@@ -1,18 +1,256 @@
 package database
 
-import "strings"
+import (
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+)
 
 var cmdTable = make(map[string]*command) // 可以理解为指令对应的方法池
 
+// 指令的标志位，和redis的 COMMAND 输出保持一致
+const (
+	flagWrite        = 1 << iota // 可能修改数据
+	flagReadOnly                 // 只读取数据
+	flagAdmin                    // 管理指令
+	flagPubSub                   // 发布订阅相关的指令
+	flagNoScript                 // 不允许在脚本中执行
+	flagFast                     // 时间复杂度为O(1)或者O(log(N))
+	flagBlocking                 // 可能阻塞客户端
+	flagMayReplicate             // 本身不是写指令，但是可能产生需要写入aof的修改(如执行写指令的脚本)
+)
+
+// flagNames 标志位对应的名称，按照输出的顺序排列
+var flagNames = []struct {
+	flag int
+	name string
+}{
+	{flagWrite, "write"},
+	{flagReadOnly, "readonly"},
+	{flagAdmin, "admin"},
+	{flagPubSub, "pubsub"},
+	{flagNoScript, "noscript"},
+	{flagFast, "fast"},
+	{flagBlocking, "blocking"},
+	{flagMayReplicate, "may_replicate"},
+}
+
 type command struct {
-	exector ExecFunc // 指令对应的redis方法
-	arity   int      //参数个数
+	name        string
+	exector     ExecFunc     // 指令对应的redis方法
+	connExector ConnExecFunc // 需要用到客户端连接的指令(如阻塞指令)，不为nil时代替exector
+	prepare     PreFunc      // 返回指令写入和读取的key，执行之前对这些key加锁
+	arity       int          //参数个数
+	flags       int
+	// key在参数中的位置(包含指令名，从1开始)：第一个key、最后一个key(负数表示从后往前数)、相邻两个key的间隔
+	// 都为0表示没有key，或者key的位置需要解析参数才能确定(此时由prepare给出)
+	// 注册时prepare为nil则由key的位置推导，只有位置无法表达的情况(同时读写不同的key、需要解析参数)才单独提供prepare
+	firstKey int
+	lastKey  int
+	keyStep  int
+}
+
+// PreFunc 分析指令的参数(不包含指令名)，返回写入的key和读取的key
+type PreFunc func(args [][]byte) (writeKeys []string, readKeys []string)
+
+// RegisterCommand 注册指令及其元数据，prepare 为nil时由 firstKey/lastKey/keyStep 推导，见 specPrepare
+func RegisterCommand(name string, exector ExecFunc, prepare PreFunc, arity int, flags int,
+	firstKey int, lastKey int, keyStep int) {
+	registerCommand(&command{
+		exector:  exector,
+		prepare:  prepare,
+		arity:    arity,
+		flags:    flags,
+		firstKey: firstKey,
+		lastKey:  lastKey,
+		keyStep:  keyStep,
+	}, name)
+}
+
+// RegisterConnCommand 注册需要用到客户端连接的指令
+// 这类指令可能会阻塞，执行之前不会对key加锁，需要指令自己在访问数据时加锁
+func RegisterConnCommand(name string, exector ConnExecFunc, prepare PreFunc, arity int, flags int,
+	firstKey int, lastKey int, keyStep int) {
+	registerCommand(&command{
+		connExector: exector,
+		prepare:     prepare,
+		arity:       arity,
+		flags:       flags,
+		firstKey:    firstKey,
+		lastKey:     lastKey,
+		keyStep:     keyStep,
+	}, name)
 }
 
-func RegisterCommand(name string, exector ExecFunc, arity int) {
-	name = strings.ToLower(name)
-	cmdTable[name] = &command{
-		exector: exector,
+// registerSpecialCommand 只登记元数据，由上层(StandaloneDatabase)直接处理的指令，如 SELECT/MULTI/EXEC
+func registerSpecialCommand(name string, arity int, flags int) {
+	registerCommand(&command{
+		prepare: noPrepare,
 		arity:   arity,
+		flags:   flags,
+	}, name)
+}
+
+func registerCommand(cmd *command, name string) {
+	cmd.name = strings.ToLower(name)
+	if cmd.prepare == nil {
+		cmd.prepare = specPrepare(cmd.firstKey, cmd.lastKey, cmd.keyStep, cmd.flags&flagWrite > 0)
+	}
+	cmdTable[cmd.name] = cmd
+}
+
+// CommandFlags 返回指令的标志位，供集群、ACL等根据元数据决定行为，指令不存在时返回false
+func CommandFlags(name string) (int, bool) {
+	cmd, ok := cmdTable[strings.ToLower(name)]
+	if !ok {
+		return 0, false
+	}
+	return cmd.flags, true
+}
+
+// IsWriteCommand 判断是否为写指令
+func IsWriteCommand(name string) bool {
+	flags, _ := CommandFlags(name)
+	return flags&flagWrite > 0
+}
+
+// mayReplicate 判断指令是否可能产生需要写入aof的修改
+func mayReplicate(name string) bool {
+	flags, _ := CommandFlags(name)
+	return flags&(flagWrite|flagMayReplicate) > 0
+}
+
+// IsReadOnlyCommand 判断是否为只读指令
+func IsReadOnlyCommand(name string) bool {
+	flags, _ := CommandFlags(name)
+	return flags&flagReadOnly > 0
+}
+
+// CommandNames 返回所有注册的指令名
+func CommandNames() []string {
+	names := make([]string, 0, len(cmdTable))
+	for name := range cmdTable {
+		names = append(names, name)
+	}
+	return names
+}
+
+// GetKeys 返回指令中的所有key(先写入的key，再读取的key)，指令不存在或者参数个数错误时返回错误
+func GetKeys(cmdLine [][]byte) ([]string, reply.ErrorReply) {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return nil, reply.MakeErrReply("ERR Invalid command specified")
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		return nil, reply.MakeErrReply("ERR Invalid number of arguments specified for command")
+	}
+	writeKeys, readKeys := cmd.prepare(cmdLine[1:])
+	return append(writeKeys, readKeys...), nil
+}
+
+// writeKeysOf 返回写指令写入的key，由 flagWrite 和key规格推导，不是写指令或者参数个数错误时返回nil
+func writeKeysOf(cmdLine [][]byte) []string {
+	cmd, ok := cmdTable[strings.ToLower(string(cmdLine[0]))]
+	if !ok || cmd.flags&flagWrite == 0 || !validateArity(cmd.arity, cmdLine) {
+		return nil
+	}
+	writeKeys, _ := cmd.prepare(cmdLine[1:])
+	return writeKeys
+}
+
+// ---------------------- 常用的 PreFunc ----------------------
+
+func toKeys(args [][]byte) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return keys
+}
+
+// noPrepare 不涉及key的指令   PING  KEYS pattern
+func noPrepare(args [][]byte) ([]string, []string) {
+	return nil, nil
+}
+
+// keysBySpec 按照key的位置取出参数(不包含指令名)中的key
+func keysBySpec(args [][]byte, firstKey int, lastKey int, keyStep int) []string {
+	if firstKey <= 0 || keyStep <= 0 {
+		return nil
+	}
+	if lastKey < 0 { // -1 为最后一个参数，位置包含指令名
+		lastKey = len(args) + 1 + lastKey
+	}
+	var keys []string
+	for i := firstKey; i <= lastKey && i <= len(args); i += keyStep {
+		keys = append(keys, string(args[i-1]))
+	}
+	return keys
+}
+
+// specPrepare 由key的位置推导出 PreFunc：写指令的key都是写入的key，其余指令的key都是读取的key
+//
+//	GET K (1,1,1)   DEL K1 K2 ... (1,-1,1)   MSET K1 V1 K2 V2 ... (1,-1,2)   BLPOP K1 K2 ... timeout (1,-2,1)
+func specPrepare(firstKey int, lastKey int, keyStep int, write bool) PreFunc {
+	if firstKey <= 0 {
+		return noPrepare
+	}
+	return func(args [][]byte) ([]string, []string) {
+		keys := keysBySpec(args, firstKey, lastKey, keyStep)
+		if write {
+			return keys, nil
+		}
+		return nil, keys
+	}
+}
+
+// writeAllKeys 所有的参数都是写入的key   PFCOUNT K1 K2 ... 是只读指令，但是会更新缓存的基数
+func writeAllKeys(args [][]byte) ([]string, []string) {
+	return toKeys(args), nil
+}
+
+// writeFirstReadOthers 写入第一个key，读取其余的key   SINTERSTORE dest K1 K2 ...
+func writeFirstReadOthers(args [][]byte) ([]string, []string) {
+	return toKeys(args[:1]), toKeys(args[1:])
+}
+
+// streamKeys STREAMS 之后的key   XREAD ... STREAMS K1 K2 ... id1 id2 ...
+func streamKeys(args [][]byte) []string {
+	for i, arg := range args {
+		if strings.ToUpper(string(arg)) == "STREAMS" {
+			rest := args[i+1:]
+			return toKeys(rest[:len(rest)/2])
+		}
+	}
+	return nil
+}
+
+// readStreamKeys XREAD
+func readStreamKeys(args [][]byte) ([]string, []string) {
+	return nil, streamKeys(args)
+}
+
+// writeStreamKeys XREADGROUP 会修改消费者组的状态
+func writeStreamKeys(args [][]byte) ([]string, []string) {
+	return streamKeys(args), nil
+}
+
+// prepareBitOp BITOP op dest K1 K2 ...
+func prepareBitOp(args [][]byte) ([]string, []string) {
+	return toKeys(args[1:2]), toKeys(args[2:])
+}
+
+// prepareGeoSearchStore GEOSEARCHSTORE dest K ...
+func prepareGeoSearchStore(args [][]byte) ([]string, []string) {
+	return toKeys(args[:1]), toKeys(args[1:2])
+}
+
+// prepareZStore ZUNIONSTORE/ZINTERSTORE dest numkeys K1 K2 ... [WEIGHTS ...] [AGGREGATE ...]
+func prepareZStore(args [][]byte) ([]string, []string) {
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil || numKeys < 0 || len(args) < 2+numKeys {
+		return toKeys(args[:1]), nil // 参数错误由指令本身返回
 	}
+	return toKeys(args[:1]), toKeys(args[2 : 2+numKeys])
 }
@@ -0,0 +1,195 @@
+package database
+
+// COMMAND DOCS 输出的指令文档：所属的分组和简介，和redis的文档保持一致
+
+type commandDoc struct {
+	group   string
+	summary string
+}
+
+var commandDocs = map[string]commandDoc{
+	// string
+	"get":         {"string", "Returns the string value of a key."},
+	"set":         {"string", "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist."},
+	"setnx":       {"string", "Set the string value of a key only when the key doesn't exist."},
+	"getset":      {"string", "Returns the previous string value of a key after setting it to a new value."},
+	"strlen":      {"string", "Returns the length of a string value."},
+	"incr":        {"string", "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+	"decr":        {"string", "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+	"incrby":      {"string", "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+	"decrby":      {"string", "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist."},
+	"incrbyfloat": {"string", "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+	"mset":        {"string", "Atomically creates or modifies the string values of one or more keys."},
+	"mget":        {"string", "Atomically returns the string values of one or more keys."},
+	"msetnx":      {"string", "Atomically modifies the string values of one or more keys only when all keys don't exist."},
+	"append":      {"string", "Appends a string to the value of a key. Creates the key if it doesn't exist."},
+	"getrange":    {"string", "Returns a substring of the string stored at a key."},
+	"setrange":    {"string", "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist."},
+	"getdel":      {"string", "Returns the string value of a key after deleting the key."},
+	"getex":       {"string", "Returns the string value of a key after setting its expiration time."},
+	"lcs":         {"string", "Finds the longest common substring."},
+
+	// bitmap
+	"setbit":      {"bitmap", "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist."},
+	"getbit":      {"bitmap", "Returns a bit value by offset."},
+	"bitcount":    {"bitmap", "Counts the number of set bits (population counting) in a string."},
+	"bitpos":      {"bitmap", "Finds the first set (1) or clear (0) bit in a string."},
+	"bitop":       {"bitmap", "Performs bitwise operations on multiple strings, and stores the result."},
+	"bitfield":    {"bitmap", "Performs arbitrary bitfield integer operations on strings."},
+	"bitfield_ro": {"bitmap", "Performs arbitrary read-only bitfield integer operations on strings."},
+
+	// hyperloglog
+	"pfadd":   {"hyperloglog", "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist."},
+	"pfcount": {"hyperloglog", "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s)."},
+	"pfmerge": {"hyperloglog", "Merges one or more HyperLogLog values into a single key."},
+
+	// geo
+	"geoadd":         {"geo", "Adds one or more members to a geospatial index. The key is created if it doesn't exist."},
+	"geopos":         {"geo", "Returns the longitude and latitude of members from a geospatial index."},
+	"geodist":        {"geo", "Returns the distance between two members of a geospatial index."},
+	"geohash":        {"geo", "Returns members from a geospatial index as geohash strings."},
+	"geosearch":      {"geo", "Queries a geospatial index for members inside an area of a box or a circle."},
+	"geosearchstore": {"geo", "Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result."},
+
+	// stream
+	"xadd":       {"stream", "Appends a new message to a stream. Creates the key if it doesn't exist."},
+	"xtrim":      {"stream", "Deletes messages from the beginning of a stream."},
+	"xdel":       {"stream", "Returns the number of messages after removing them from a stream."},
+	"xlen":       {"stream", "Return the number of messages in a stream."},
+	"xrange":     {"stream", "Returns the messages from a stream within a range of IDs."},
+	"xrevrange":  {"stream", "Returns the messages from a stream within a range of IDs in reverse order."},
+	"xread":      {"stream", "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise."},
+	"xgroup":     {"stream", "A container for consumer groups commands."},
+	"xreadgroup": {"stream", "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise."},
+	"xack":       {"stream", "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream."},
+	"xpending":   {"stream", "Returns the information and entries from a stream consumer group's pending entries list."},
+	"xclaim":     {"stream", "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member."},
+	"xautoclaim": {"stream", "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member."},
+	"xinfo":      {"stream", "A container for stream introspection commands."},
+
+	// generic
+	"del":       {"generic", "Deletes one or more keys."},
+	"exists":    {"generic", "Determines whether one or more keys exist."},
+	"type":      {"generic", "Determines the type of value stored at a key."},
+	"rename":    {"generic", "Renames a key and overwrites the destination."},
+	"renamenx":  {"generic", "Renames a key only when the target key name doesn't exist."},
+	"keys":      {"generic", "Returns all key names that match a pattern."},
+	"expire":    {"generic", "Sets the expiration time of a key in seconds."},
+	"pexpire":   {"generic", "Sets the expiration time of a key in milliseconds."},
+	"expireat":  {"generic", "Sets the expiration time of a key to a Unix timestamp."},
+	"pexpireat": {"generic", "Sets the expiration time of a key to a Unix milliseconds timestamp."},
+	"ttl":       {"generic", "Returns the expiration time in seconds of a key."},
+	"pttl":      {"generic", "Returns the expiration time in milliseconds of a key."},
+	"persist":   {"generic", "Removes the expiration time of a key."},
+
+	// list
+	"lpush":      {"list", "Prepends one or more elements to a list. Creates the key if it doesn't exist."},
+	"rpush":      {"list", "Appends one or more elements to a list. Creates the key if it doesn't exist."},
+	"lpop":       {"list", "Returns the first elements in a list after removing it. Deletes the list if the last element was popped."},
+	"rpop":       {"list", "Returns and removes the last elements of a list. Deletes the list if the last element was popped."},
+	"lrange":     {"list", "Returns a range of elements from a list."},
+	"lindex":     {"list", "Returns an element from a list by its index."},
+	"lset":       {"list", "Sets the value of an element in a list by its index."},
+	"lrem":       {"list", "Removes elements from a list. Deletes the list if the last element was removed."},
+	"ltrim":      {"list", "Removes elements from both ends a list. Deletes the list if all elements were trimmed."},
+	"linsert":    {"list", "Inserts an element before or after another element in a list."},
+	"llen":       {"list", "Returns the length of a list."},
+	"lmove":      {"list", "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved."},
+	"rpoplpush":  {"list", "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped."},
+	"blpop":      {"list", "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+	"brpop":      {"list", "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+	"blmove":     {"list", "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved."},
+	"brpoplpush": {"list", "Pops an element from a list, pushes it to another list and returns it. Block until an element is available otherwise. Deletes the list if the last element was popped."},
+
+	// set
+	"sadd":        {"set", "Adds one or more members to a set. Creates the key if it doesn't exist."},
+	"srem":        {"set", "Removes one or more members from a set. Deletes the set if the last member was removed."},
+	"sismember":   {"set", "Determines whether a member belongs to a set."},
+	"smismember":  {"set", "Determines whether multiple members belong to a set."},
+	"smembers":    {"set", "Returns all members of a set."},
+	"scard":       {"set", "Returns the number of members in a set."},
+	"sinter":      {"set", "Returns the intersect of multiple sets."},
+	"sunion":      {"set", "Returns the union of multiple sets."},
+	"sdiff":       {"set", "Returns the difference of multiple sets."},
+	"sinterstore": {"set", "Stores the intersect of multiple sets in a key."},
+	"sunionstore": {"set", "Stores the union of multiple sets in a key."},
+	"sdiffstore":  {"set", "Stores the difference of multiple sets in a key."},
+	"spop":        {"set", "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped."},
+	"srandmember": {"set", "Get one or multiple random members from a set."},
+	"smove":       {"set", "Moves a member from one set to another."},
+
+	// sorted-set
+	"zadd":             {"sorted-set", "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist."},
+	"zincrby":          {"sorted-set", "Increments the score of a member in a sorted set."},
+	"zscore":           {"sorted-set", "Returns the score of a member in a sorted set."},
+	"zcard":            {"sorted-set", "Returns the number of members in a sorted set."},
+	"zrem":             {"sorted-set", "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed."},
+	"zrank":            {"sorted-set", "Returns the index of a member in a sorted set ordered by ascending scores."},
+	"zrevrank":         {"sorted-set", "Returns the index of a member in a sorted set ordered by descending scores."},
+	"zcount":           {"sorted-set", "Returns the count of members in a sorted set that have scores within a range."},
+	"zrange":           {"sorted-set", "Returns members in a sorted set within a range of indexes."},
+	"zrevrange":        {"sorted-set", "Returns members in a sorted set within a range of indexes in reverse order."},
+	"zrangebyscore":    {"sorted-set", "Returns members in a sorted set within a range of scores."},
+	"zrevrangebyscore": {"sorted-set", "Returns members in a sorted set within a range of scores in reverse order."},
+	"zrangebylex":      {"sorted-set", "Returns members in a sorted set within a lexicographical range."},
+	"zrevrangebylex":   {"sorted-set", "Returns members in a sorted set within a lexicographical range in reverse order."},
+	"zpopmin":          {"sorted-set", "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+	"zpopmax":          {"sorted-set", "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+	"bzpopmin":         {"sorted-set", "Removes and returns the member with the lowest score from one or more sorted sets. Blocks until a member is available otherwise."},
+	"bzpopmax":         {"sorted-set", "Removes and returns the member with the highest score from one or more sorted sets. Blocks until a member available otherwise."},
+	"zunionstore":      {"sorted-set", "Stores the union of multiple sorted sets in a key."},
+	"zinterstore":      {"sorted-set", "Stores the intersect of multiple sorted sets in a key."},
+
+	// hash
+	"hset":         {"hash", "Creates or modifies the value of a field in a hash."},
+	"hmset":        {"hash", "Sets the values of multiple fields."},
+	"hsetnx":       {"hash", "Sets the value of a field in a hash only when the field doesn't exist."},
+	"hget":         {"hash", "Returns the value of a field in a hash."},
+	"hmget":        {"hash", "Returns the values of all fields in a hash."},
+	"hexists":      {"hash", "Determines whether a field exists in a hash."},
+	"hdel":         {"hash", "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain."},
+	"hlen":         {"hash", "Returns the number of fields in a hash."},
+	"hstrlen":      {"hash", "Returns the length of the value of a field."},
+	"hgetall":      {"hash", "Returns all fields and values in a hash."},
+	"hkeys":        {"hash", "Returns all fields in a hash."},
+	"hvals":        {"hash", "Returns all values in a hash."},
+	"hincrby":      {"hash", "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist."},
+	"hincrbyfloat": {"hash", "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist."},
+	"hscan":        {"hash", "Iterates over fields and values of a hash."},
+	"hrandfield":   {"hash", "Returns one or more random fields from a hash."},
+
+	// transactions
+	"multi":   {"transactions", "Starts a transaction."},
+	"exec":    {"transactions", "Executes all commands in a transaction."},
+	"discard": {"transactions", "Discards a transaction."},
+	"watch":   {"transactions", "Monitors changes to keys to determine the execution of a transaction."},
+	"unwatch": {"transactions", "Forgets about watched keys of a transaction."},
+
+	// pubsub
+	"subscribe":    {"pubsub", "Listens for messages published to channels."},
+	"unsubscribe":  {"pubsub", "Stops listening to messages posted to channels."},
+	"psubscribe":   {"pubsub", "Listens for messages published to channels that match one or more patterns."},
+	"punsubscribe": {"pubsub", "Stops listening to messages published to channels that match one or more patterns."},
+	"publish":      {"pubsub", "Posts a message to a channel."},
+	"pubsub":       {"pubsub", "A container for Pub/Sub commands."},
+	"ssubscribe":   {"pubsub", "Listens for messages published to shard channels."},
+	"sunsubscribe": {"pubsub", "Stops listening to messages posted to shard channels."},
+	"spublish":     {"pubsub", "Post a message to a shard channel"},
+
+	// scripting
+	"eval":     {"scripting", "Executes a server-side Lua script."},
+	"evalsha":  {"scripting", "Executes a server-side Lua script by SHA1 digest."},
+	"script":   {"scripting", "A container for Lua scripts management commands."},
+	"fcall":    {"scripting", "Invokes a function."},
+	"fcall_ro": {"scripting", "Invokes a read-only function."},
+	"function": {"scripting", "A container for function commands."},
+
+	// connection & server
+	"ping":    {"connection", "Returns the server's liveliness response."},
+	"select":  {"connection", "Changes the selected database."},
+	"client":  {"connection", "A container for client connection commands."},
+	"hello":   {"connection", "Handshakes with the Redis server."},
+	"flushdb": {"server", "Removes all keys from the current database."},
+	"command": {"server", "Returns detailed information about all commands."},
+	"config":  {"server", "A container for server configuration commands."},
+}
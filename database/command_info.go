@@ -0,0 +1,183 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+	"sort"
+	"strings"
+)
+
+// COMMAND [COUNT|LIST|INFO|DOCS|GETKEYS]   根据指令的元数据返回指令的信息
+
+// flagsToNames 返回标志位对应的名称
+func flagsToNames(flags int) [][]byte {
+	names := make([][]byte, 0, len(flagNames))
+	for _, item := range flagNames {
+		if flags&item.flag > 0 {
+			names = append(names, []byte(item.name))
+		}
+	}
+	return names
+}
+
+// aclCategories 根据标志位和所属的分组推导出ACL分类
+func (cmd *command) aclCategories() [][]byte {
+	var categories []string
+	if cmd.flags&flagWrite > 0 {
+		categories = append(categories, "@write")
+	}
+	if cmd.flags&flagReadOnly > 0 {
+		categories = append(categories, "@read")
+	}
+	if doc, ok := commandDocs[cmd.name]; ok && doc.group != "generic" && doc.group != "server" {
+		categories = append(categories, "@"+doc.group)
+	} else if cmd.firstKey > 0 || cmd.flags&(flagWrite|flagReadOnly) > 0 {
+		categories = append(categories, "@keyspace")
+	}
+	if cmd.flags&flagFast > 0 {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	if cmd.flags&flagAdmin > 0 {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if cmd.flags&flagPubSub > 0 {
+		categories = append(categories, "@pubsub")
+	}
+	if cmd.flags&flagBlocking > 0 {
+		categories = append(categories, "@blocking")
+	}
+	result := make([][]byte, len(categories))
+	for i, category := range categories {
+		result[i] = []byte(category)
+	}
+	return result
+}
+
+// infoReply 单个指令的信息
+// [name, arity, flags, first key, last key, step, acl categories, tips, key specs, subcommands]
+func (cmd *command) infoReply() resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(cmd.name)),
+		reply.MakeIntReply(int64(cmd.arity)),
+		reply.MakeMultiBulkReply(flagsToNames(cmd.flags)),
+		reply.MakeIntReply(int64(cmd.firstKey)),
+		reply.MakeIntReply(int64(cmd.lastKey)),
+		reply.MakeIntReply(int64(cmd.keyStep)),
+		reply.MakeMultiBulkReply(cmd.aclCategories()),
+		reply.MakeEmptyMultiBulkReply(),
+		reply.MakeEmptyMultiBulkReply(),
+		reply.MakeEmptyMultiBulkReply(),
+	})
+}
+
+// docReply 单个指令的文档  [summary, ..., group, ..., arity, ...]
+func (cmd *command) docReply() resp.Reply {
+	doc := commandDocs[cmd.name]
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte("summary")),
+		reply.MakeBulkReply([]byte(doc.summary)),
+		reply.MakeBulkReply([]byte("group")),
+		reply.MakeBulkReply([]byte(doc.group)),
+		reply.MakeBulkReply([]byte("arity")),
+		reply.MakeIntReply(int64(cmd.arity)),
+	})
+}
+
+// sortedCommands 按照名称排序的所有指令
+func sortedCommands() []*command {
+	cmds := make([]*command, 0, len(cmdTable))
+	for _, cmd := range cmdTable {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].name < cmds[j].name
+	})
+	return cmds
+}
+
+// lookupCommands 根据参数查找指令，参数为空时返回所有的指令，不存在的指令为nil
+func lookupCommands(names [][]byte) []*command {
+	if len(names) == 0 {
+		return sortedCommands()
+	}
+	cmds := make([]*command, len(names))
+	for i, name := range names {
+		cmds[i] = cmdTable[strings.ToLower(string(name))]
+	}
+	return cmds
+}
+
+// COMMAND [subcommand [arg ...]]
+func execCommand(db *DB, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return commandInfo(nil)
+	}
+	subCommand := strings.ToUpper(string(args[0]))
+	switch subCommand {
+	case "COUNT":
+		if len(args) != 1 {
+			return reply.MakeArgNumErrReply("command|count")
+		}
+		return reply.MakeIntReply(int64(len(cmdTable)))
+	case "LIST":
+		if len(args) != 1 {
+			return reply.MakeArgNumErrReply("command|list")
+		}
+		cmds := sortedCommands()
+		names := make([][]byte, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = []byte(cmd.name)
+		}
+		return reply.MakeMultiBulkReply(names)
+	case "INFO":
+		return commandInfo(args[1:])
+	case "DOCS":
+		var result []resp.Reply
+		for _, cmd := range lookupCommands(args[1:]) {
+			if cmd == nil { // 不存在的指令直接忽略
+				continue
+			}
+			result = append(result, reply.MakeBulkReply([]byte(cmd.name)), cmd.docReply())
+		}
+		return reply.MakeMultiRawReply(result)
+	case "GETKEYS":
+		if len(args) < 2 {
+			return reply.MakeArgNumErrReply("command|getkeys")
+		}
+		keys, errReply := GetKeys(args[1:])
+		if errReply != nil {
+			return errReply
+		}
+		if len(keys) == 0 {
+			return reply.MakeErrReply("ERR The command has no key arguments")
+		}
+		result := make([][]byte, len(keys))
+		for i, key := range keys {
+			result[i] = []byte(key)
+		}
+		return reply.MakeMultiBulkReply(result)
+	default:
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) +
+			"'. Try COMMAND HELP.")
+	}
+}
+
+// commandInfo COMMAND INFO [name ...]
+func commandInfo(names [][]byte) resp.Reply {
+	cmds := lookupCommands(names)
+	result := make([]resp.Reply, len(cmds))
+	for i, cmd := range cmds {
+		if cmd == nil {
+			result[i] = reply.MakeNullMultiBulkReply()
+			continue
+		}
+		result[i] = cmd.infoReply()
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+func init() {
+	RegisterCommand("command", execCommand, noPrepare, -1, 0, 0, 0, 0) // COMMAND [COUNT|LIST|INFO|DOCS|GETKEYS]
+}
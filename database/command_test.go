@@ -0,0 +1,98 @@
+package database
+
+import (
+	"go_redis/lib/utils"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestGetKeysBySpec(t *testing.T) {
+	tests := []struct {
+		cmdLine []string
+		want    []string
+	}{
+		{[]string{"get", "k"}, []string{"k"}},
+		{[]string{"del", "k1", "k2", "k3"}, []string{"k1", "k2", "k3"}},
+		{[]string{"mset", "k1", "v1", "k2", "v2"}, []string{"k1", "k2"}},
+		{[]string{"blpop", "k1", "k2", "0"}, []string{"k1", "k2"}},
+		{[]string{"rename", "src", "dest"}, []string{"src", "dest"}},
+		{[]string{"xinfo", "stream", "k"}, []string{"k"}},
+		{[]string{"ssubscribe", "c1", "c2"}, []string{"c1", "c2"}},
+		{[]string{"ping"}, nil},
+	}
+	for _, tt := range tests {
+		keys, errReply := GetKeys(utils.ToCmdLine(tt.cmdLine...))
+		if errReply != nil {
+			t.Errorf("%v: %s", tt.cmdLine, errReply.Error())
+			continue
+		}
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.cmdLine, keys, tt.want)
+		}
+	}
+}
+
+// 单独提供prepare的指令，key的位置给出的key都必须被prepare加锁
+func TestPrepareCoversKeySpec(t *testing.T) {
+	for name, cmd := range cmdTable {
+		if cmd.firstKey <= 0 {
+			continue
+		}
+		size := cmd.arity - 1
+		if cmd.arity < 0 {
+			size = -cmd.arity - 1 + 3
+		}
+		args := make([][]byte, size)
+		for i := range args {
+			args[i] = []byte("a" + strconv.Itoa(i+1))
+		}
+		writeKeys, readKeys := cmd.prepare(args)
+		locked := append(writeKeys, readKeys...)
+		for _, key := range keysBySpec(args, cmd.firstKey, cmd.lastKey, cmd.keyStep) {
+			if !slices.Contains(locked, key) {
+				t.Errorf("%s: key %s of spec (%d,%d,%d) not returned by prepare %v",
+					name, key, cmd.firstKey, cmd.lastKey, cmd.keyStep, locked)
+			}
+		}
+		if cmd.flags&flagWrite > 0 && len(writeKeys) == 0 {
+			t.Errorf("%s: write command without write keys", name)
+		}
+	}
+}
+
+// aof记录的指令由元数据推导写入的key，作为指令实际修改的key
+func TestWriteKeysOf(t *testing.T) {
+	tests := []struct {
+		cmdLine []string
+		want    []string
+	}{
+		{[]string{"set", "k", "v", "KEEPTTL"}, []string{"k"}},
+		{[]string{"pexpireat", "k", "1"}, []string{"k"}},
+		{[]string{"mset", "k1", "v1", "k2", "v2"}, []string{"k1", "k2"}},
+		{[]string{"bitop", "and", "dest", "k1", "k2"}, []string{"dest"}}, // 读取的key不算修改
+		{[]string{"zunionstore", "dest", "2", "k1", "k2"}, []string{"dest"}},
+		{[]string{"lmove", "src", "dest", "LEFT", "RIGHT"}, []string{"src", "dest"}},
+		{[]string{"xgroup", "create", "s", "g", "0"}, []string{"s"}},
+		{[]string{"get", "k"}, nil}, // 不是写指令
+		{[]string{"set", "k"}, nil}, // 参数个数错误
+		{[]string{"unknown", "k"}, nil},
+	}
+	for _, tt := range tests {
+		if keys := writeKeysOf(utils.ToCmdLine(tt.cmdLine...)); !slices.Equal(keys, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.cmdLine, keys, tt.want)
+		}
+	}
+}
+
+// 事务中的脚本和函数可能执行写指令，由 may_replicate 标志决定是否需要在aof中包裹 MULTI ... EXEC
+func TestMayReplicate(t *testing.T) {
+	for name, want := range map[string]bool{
+		"set": true, "eval": true, "evalsha": true, "fcall": true, "function": true,
+		"get": false, "fcall_ro": false, "script": false, "ping": false,
+	} {
+		if got := mayReplicate(name); got != want {
+			t.Errorf("mayReplicate(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
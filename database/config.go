@@ -0,0 +1,43 @@
+package database
+
+import (
+	"go_redis/config"
+	"go_redis/interface/resp"
+	"go_redis/lib/wildcard"
+	"go_redis/resp/reply"
+	"strings"
+)
+
+// execConfig CONFIG GET parameter [parameter ...]   参数支持通配符，回复为配置项名称到值的map
+func execConfig(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	switch subCmd {
+	case "get":
+		if len(args) < 2 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'get'. Try CONFIG HELP.")
+		}
+		patterns := make([]*wildcard.Pattern, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			pattern, err := wildcard.CompilePattern(strings.ToLower(string(arg)))
+			if err != nil {
+				return reply.MakeErrReply("ERR " + err.Error())
+			}
+			patterns = append(patterns, pattern)
+		}
+		var result [][]byte
+		for _, param := range config.Properties.Params() {
+			for _, pattern := range patterns {
+				if pattern.IsMatch(param.Name) { // 同一个配置项只返回一次
+					result = append(result, []byte(param.Name), []byte(param.Value))
+					break
+				}
+			}
+		}
+		return reply.MakeBulkMapReply(result)
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try CONFIG HELP.")
+}
+
+func init() {
+	RegisterCommand("config", execConfig, noPrepare, -2, flagAdmin|flagNoScript, 0, 0, 0) // CONFIG GET parameter [parameter ...]
+}
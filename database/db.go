@@ -4,26 +4,45 @@ import (
 	"go_redis/datastruct/dict"
 	"go_redis/interface/database"
 	"go_redis/interface/resp"
+	"go_redis/lib/sync/lock"
 	"go_redis/resp/reply"
 	"strings"
+	"time"
 )
 
 // redis 上层面向用户的数据结构db
 type DB struct {
-	index  int       // 当前数据库的编号
-	Data   dict.Dict //对应的接口方法，底层的sync.Map结构体会实现该方法
-	addAof func(CmdLine)
+	index    int                                       // 当前数据库的编号
+	Data     dict.Dict                                 //对应的接口方法，底层的sync.Map结构体会实现该方法
+	ttlMap   dict.Dict                                 // key -> 过期时间(time.Time)，只记录设置了过期时间的key
+	locker   *lock.Locks                               // 分段锁，保证对同一个key的读-改-写操作的原子性
+	writeAof func(CmdLine)                             // 写入aof文件，没有开启aof时为空操作
+	effects  *cmdEffects                               // 当前指令的修改记录，只在 Exec 创建的拷贝中不为nil
+	notify   func(class int, event string, key string) // 键空间通知，数据修改之后调用
+
+	blocking *blockingTable // 阻塞在该db的key上的客户端
+	watches  *watchTable    // 被WATCH的key，所有的db共享
+	tracking *trackingTable // 客户端缓存，所有的db共享
 }
 
+const lockerSize = 1024
+
 // redis的执行函数的格式
 type ExecFunc func(db *DB, args [][]byte) resp.Reply
 
+// ConnExecFunc 需要用到客户端连接的执行函数
+type ConnExecFunc func(db *DB, c resp.Connection, args [][]byte) resp.Reply
+
 type CmdLine = [][]byte
 
 func makeDB() *DB {
 	return &DB{
-		Data:   dict.MakeSyncDict(), // 返回实现该接口的sync.Map结构体指针
-		addAof: func(cl CmdLine) {},
+		Data:     dict.MakeSyncDict(), // 返回实现该接口的sync.Map结构体指针
+		ttlMap:   dict.MakeSyncDict(),
+		locker:   lock.Make(lockerSize),
+		writeAof: func(cl CmdLine) {},
+		notify:   func(class int, event string, key string) {},
+		blocking: makeBlockingTable(),
 	}
 }
 
@@ -39,10 +58,94 @@ func (db *DB) Exec(c resp.Connection, cmdLine CmdLine) resp.Reply {
 	if !validateArity(cmd.arity, cmdLine) { // 参数错误
 		return reply.MakeArgNumErrReply(cmdName)
 	}
+	if cmd.exector == nil && cmd.connExector == nil { // SELECT/MULTI 等由上层处理的指令
+		return reply.MakeErrReply("ERR '" + cmdName + "' command is not allowed here")
+	}
 	// 校验参数无误，执行相关的命令
-	fun := cmd.exector // 真正的执行函数
-	//Set k  v  -> k, v  只需要k  v即可
-	return fun(db, cmdLine[1:])
+	writeKeys, readKeys := cmd.prepare(cmdLine[1:])
+	cmdDB, effects := db.withEffects()
+	var result resp.Reply
+	if cmd.connExector != nil { // 可能阻塞的指令自己加锁
+		result = cmd.connExector(cmdDB, c, cmdLine[1:])
+	} else {
+		//Set k  v  -> k, v  只需要k  v即可
+		result = cmdDB.execWithLock(cmd.exector, writeKeys, readKeys, cmdLine[1:])
+	}
+	// 指令执行完毕之后(已经释放锁)，唤醒阻塞在实际写入的key上的客户端
+	// 没有写入数据的写指令(条件不满足的SETNX、超时的BLPOP等)不会影响阻塞的客户端
+	if writeKeys := effects.written(); len(writeKeys) > 0 {
+		db.tracking.invalidate(c, writeKeys)
+		if client, ok := c.(*scriptClient); ok { // 脚本中的写指令等脚本结束之后再唤醒
+			client.deferSignal(db, writeKeys)
+		} else if c.InMultiState() { // EXEC中的写指令等事务结束之后再唤醒
+			db.blocking.deferSignal(writeKeys)
+		} else {
+			db.blocking.signal(writeKeys)
+		}
+	}
+	// 只读指令读取的key记录到客户端缓存
+	if cmd.flags&flagReadOnly > 0 && len(readKeys) > 0 && !reply.IsErrReply(result) {
+		db.tracking.track(c, readKeys)
+	}
+	return result
+}
+
+// cmdEffects 一条指令实际写入的key，写指令通过 addAof 记录自己的修改
+// 阻塞的指令被唤醒时由执行写指令的协程记录，之后通过结果channel交给阻塞的协程，因此不需要加锁
+type cmdEffects struct {
+	writes []string
+}
+
+// withEffects 返回db的浅拷贝，通过拷贝执行的指令的修改记录在返回的 cmdEffects 中
+func (db *DB) withEffects() (*DB, *cmdEffects) {
+	effects := &cmdEffects{}
+	cmdDB := *db
+	cmdDB.effects = effects
+	return &cmdDB, effects
+}
+
+// written 返回去重之后的写入的key
+func (effects *cmdEffects) written() []string {
+	if len(effects.writes) <= 1 {
+		return effects.writes
+	}
+	seen := make(map[string]struct{}, len(effects.writes))
+	keys := effects.writes[:0]
+	for _, key := range effects.writes {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// addAof 记录指令对数据的修改：写入aof，增加被WATCH的key的版本号，并记录修改的key
+// 修改的key由aof指令的元数据(写指令的key规格)推导，和执行该指令时加写锁的key一致，调用方需要持有这些key的写锁
+func (db *DB) addAof(line CmdLine) {
+	keys := writeKeysOf(line)
+	db.watches.touch(db.index, keys...)
+	if db.effects != nil {
+		db.effects.writes = append(db.effects.writes, keys...)
+	}
+	db.writeAof(line)
+}
+
+// execWithLock 按照固定的顺序对所有的key加锁之后执行，保证多个key的读-改-写操作的原子性
+func (db *DB) execWithLock(fun ExecFunc, writeKeys []string, readKeys []string, args [][]byte) resp.Reply {
+	db.RWLocks(writeKeys, readKeys)
+	defer db.RWUnLocks(writeKeys, readKeys) // 指令panic时也要释放锁
+	return fun(db, args)
+}
+
+// RWLocks 对写入的key加写锁，读取的key加读锁
+func (db *DB) RWLocks(writeKeys []string, readKeys []string) {
+	db.locker.RWLocks(writeKeys, readKeys)
+}
+
+// RWUnLocks 释放 RWLocks 获取的锁
+func (db *DB) RWUnLocks(writeKeys []string, readKeys []string) {
+	db.locker.RWUnLocks(writeKeys, readKeys)
 }
 
 // SET k v  -> arity 3
@@ -61,6 +164,9 @@ func (db *DB) GetEntity(key string) (*database.DataEntity, bool) {
 	if !ok { // 不存在
 		return nil, false
 	}
+	if db.IsExpired(key) { // 惰性删除：访问时发现已经过期，直接删除
+		return nil, false
+	}
 	return val.(*database.DataEntity), true
 }
 
@@ -69,21 +175,26 @@ func (db *DB) PutEntity(key string, entity *database.DataEntity) int {
 }
 
 func (db *DB) PutIfAbsent(key string, entity *database.DataEntity) int {
+	db.IsExpired(key) // 已经过期的key视为不存在
 	return db.Data.PutIfAbsent(key, entity)
 }
 
 func (db *DB) PutIfExits(key string, entity *database.DataEntity) int {
+	db.IsExpired(key)
 	return db.Data.PutIfExits(key, entity)
 }
 
 func (db *DB) Remove(key string) {
-	db.Data.Remove(key)
+	if db.Data.Remove(key) > 0 { // 过期删除等不经过写指令的删除也需要让WATCH感知到
+		db.watches.touch(db.index, key)
+	}
+	db.ttlMap.Remove(key) // 删除key的同时删除其过期时间
 }
 
 func (db *DB) Removes(keys ...string) int { // 删除多个keys，返回删除的个数
 	deleted := 0
 	for _, key := range keys {
-		_, ok := db.Data.Get(key)
+		_, ok := db.GetEntity(key)
 		if ok {
 			db.Remove(key)
 			deleted++
@@ -93,5 +204,48 @@ func (db *DB) Removes(keys ...string) int { // 删除多个keys，返回删除
 }
 
 func (db *DB) Flush() {
+	db.watches.touchDB(db.index, func(key string) bool {
+		_, ok := db.Data.Get(key)
+		return ok
+	})
 	db.Data.Clear()
+	db.ttlMap.Clear()
+}
+
+// ---------------- 过期时间 TTL ----------------
+
+// Expire 设置key的过期时间(绝对时间)
+func (db *DB) Expire(key string, expireTime time.Time) {
+	db.ttlMap.Put(key, expireTime)
+}
+
+// Persist 移除key的过期时间，使其永久有效
+func (db *DB) Persist(key string) {
+	db.ttlMap.Remove(key)
+}
+
+// TTL 返回key的过期时间，没有设置过期时间时返回false
+func (db *DB) TTL(key string) (time.Time, bool) {
+	raw, ok := db.ttlMap.Get(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	return raw.(time.Time), true
+}
+
+// ttlExpired 判断key的过期时间是否已经过去，不会删除key，可以在没有持有key的锁时调用
+func (db *DB) ttlExpired(key string) bool {
+	expireTime, ok := db.TTL(key)
+	return ok && time.Now().After(expireTime)
+}
+
+// IsExpired 判断key是否已经过期，过期则直接删除该key，调用方需要持有key的写锁
+func (db *DB) IsExpired(key string) bool {
+	expired := db.ttlExpired(key)
+	if expired {
+		db.Remove(key)
+		db.notify(notifyExpired, "expired", key)
+		db.tracking.invalidate(nil, []string{key})
+	}
+	return expired
 }
@@ -0,0 +1,59 @@
+package database
+
+import "time"
+
+// 主动过期：仿照redis的activeExpireCycle，定期从设置了过期时间的key中随机抽样，删除其中已经过期的key
+// 如果一轮抽样中过期的比例超过25%，说明过期的key比较多，继续下一轮抽样
+
+const (
+	activeExpireInterval   = 100 * time.Millisecond // 后台抽样的周期
+	activeExpireSampleSize = 20                     // 每轮抽样的key的个数
+	activeExpireTimeLimit  = 25 * time.Millisecond  // 每个周期单个db最多占用的时间
+)
+
+// activeExpireCycle 对当前db执行一次主动过期
+func (db *DB) activeExpireCycle() {
+	start := time.Now()
+	for {
+		if db.ttlMap.Len() == 0 {
+			return
+		}
+		keys := db.ttlMap.RandomDistinctKeys(activeExpireSampleSize)
+		expired := 0
+		for _, key := range keys {
+			if db.expireWithLock(key) {
+				expired++
+			}
+		}
+		// 过期比例不足25%，或者已经超过了时间限制，结束本次循环
+		if expired*4 <= len(keys) || time.Since(start) > activeExpireTimeLimit {
+			return
+		}
+	}
+}
+
+// expireWithLock 持有key的写锁删除过期的key，避免和正在执行的指令冲突
+func (db *DB) expireWithLock(key string) bool {
+	keys := []string{key}
+	db.RWLocks(keys, nil)
+	defer db.RWUnLocks(keys, nil)
+	return db.IsExpired(key)
+}
+
+// startActiveExpire 开启后台协程，周期性地对所有的db进行主动过期
+func (e *StandaloneDatabase) startActiveExpire() {
+	ticker := time.NewTicker(activeExpireInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, db := range e.dbSet {
+					db.activeExpireCycle()
+				}
+			case <-e.closeChan:
+				return
+			}
+		}
+	}()
+}
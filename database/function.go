@@ -0,0 +1,574 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"go_redis/interface/resp"
+	"go_redis/lib/wildcard"
+	"go_redis/resp/reply"
+	"hash/crc64"
+	"sort"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// redis7 的 FUNCTION：以库为单位加载的lua函数
+// 库的代码以 "#!lua name=<库名>" 开头，加载时执行一次，通过 redis.register_function 注册函数；
+// 每个库有自己的lua虚拟机，FCALL 在库的虚拟机中调用注册的函数，和脚本一样持有事务锁执行。
+// FUNCTION LOAD/DELETE/FLUSH/RESTORE 成功之后写入aof，重启之后重新加载
+
+const (
+	functionLoadTimeout = 500 * time.Millisecond // 加载库的代码的时间限制
+	functionOpcode      = 245                    // DUMP中每个库的标记，和redis的RDB_OPCODE_FUNCTION2一致
+	functionDumpVersion = 1
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+func init() {
+	registerCommand(&command{prepare: prepareEval, arity: -3, flags: flagNoScript | flagMayReplicate}, "fcall")
+	registerCommand(&command{prepare: prepareEvalRO, arity: -3, flags: flagNoScript | flagReadOnly}, "fcall_ro")
+	registerSpecialCommand("function", -2, flagNoScript|flagMayReplicate)
+}
+
+// prepareEvalRO FCALL_RO function numkeys key [key ...] arg [arg ...]  只读取key
+func prepareEvalRO(args [][]byte) ([]string, []string) {
+	keys, _ := prepareEval(args)
+	return nil, keys
+}
+
+// 函数可以设置的标志
+var functionFlags = map[string]bool{
+	"no-writes":             true,
+	"allow-oom":             true,
+	"allow-stale":           true,
+	"no-cluster":            true,
+	"allow-cross-slot-keys": true,
+}
+
+type luaFunction struct {
+	name        string
+	description string
+	flags       []string
+	callback    *lua.LFunction
+	library     *luaLibrary
+}
+
+func (fn *luaFunction) noWrites() bool {
+	for _, flag := range fn.flags {
+		if flag == "no-writes" {
+			return true
+		}
+	}
+	return false
+}
+
+type luaLibrary struct {
+	name      string
+	code      []byte
+	L         *lua.LState
+	functions map[string]*luaFunction
+	client    *scriptClient // 正在执行的函数使用的伪客户端
+}
+
+// functionRegistry 已经加载的库和函数，调用方持有事务锁
+type functionRegistry struct {
+	libraries map[string]*luaLibrary
+	functions map[string]*luaFunction // 函数名在所有的库中唯一
+}
+
+func makeFunctionRegistry() *functionRegistry {
+	return &functionRegistry{
+		libraries: make(map[string]*luaLibrary),
+		functions: make(map[string]*luaFunction),
+	}
+}
+
+func (registry *functionRegistry) clone() *functionRegistry {
+	result := makeFunctionRegistry()
+	for name, lib := range registry.libraries {
+		result.libraries[name] = lib
+	}
+	for name, fn := range registry.functions {
+		result.functions[name] = fn
+	}
+	return result
+}
+
+// install 登记库，replace 为true时替换同名的库，返回被替换的库
+func (registry *functionRegistry) install(lib *luaLibrary, replace bool) (*luaLibrary, reply.ErrorReply) {
+	old, exists := registry.libraries[lib.name]
+	if exists && !replace {
+		return nil, reply.MakeErrReply("ERR Library '" + lib.name + "' already exists")
+	}
+	for name := range lib.functions {
+		if fn, ok := registry.functions[name]; ok && fn.library != old {
+			return nil, reply.MakeErrReply("ERR Function " + name + " already exists")
+		}
+	}
+	if exists {
+		registry.remove(lib.name)
+	}
+	registry.libraries[lib.name] = lib
+	for name, fn := range lib.functions {
+		registry.functions[name] = fn
+	}
+	return old, nil
+}
+
+// remove 删除库及其函数，返回被删除的库
+func (registry *functionRegistry) remove(name string) *luaLibrary {
+	lib, ok := registry.libraries[name]
+	if !ok {
+		return nil
+	}
+	delete(registry.libraries, name)
+	for fnName := range lib.functions {
+		delete(registry.functions, fnName)
+	}
+	return lib
+}
+
+// sortedLibraries 按照库名排序的库
+func (registry *functionRegistry) sortedLibraries() []*luaLibrary {
+	libs := make([]*luaLibrary, 0, len(registry.libraries))
+	for _, lib := range registry.libraries {
+		libs = append(libs, lib)
+	}
+	sort.Slice(libs, func(i, j int) bool {
+		return libs[i].name < libs[j].name
+	})
+	return libs
+}
+
+// closeRemoved 关闭旧的登记中存在、新的登记中不存在的库的虚拟机
+func closeRemoved(old *functionRegistry, current *functionRegistry) {
+	for name, lib := range old.libraries {
+		if current.libraries[name] != lib {
+			lib.L.Close()
+		}
+	}
+}
+
+// ---------------------- 加载库 ----------------------
+
+// parseLibraryMeta 解析库的代码第一行的元数据 "#!lua name=<库名>"，返回库名和去掉第一行之后的代码
+func parseLibraryMeta(code []byte) (string, string, reply.ErrorReply) {
+	if !bytes.HasPrefix(code, []byte("#!")) {
+		return "", "", reply.MakeErrReply("ERR Missing library metadata")
+	}
+	firstLine := string(code[2:])
+	body := ""
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine, body = firstLine[:i], "\n"+firstLine[i+1:] // 保留空行，出错时的行号和原始代码一致
+	}
+	fields := strings.Fields(firstLine)
+	if len(fields) == 0 {
+		return "", "", reply.MakeErrReply("ERR Missing library metadata")
+	}
+	if fields[0] != "lua" {
+		return "", "", reply.MakeErrReply("ERR Engine '" + fields[0] + "' not found")
+	}
+	name := ""
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "name=") {
+			return "", "", reply.MakeErrReply("ERR Invalid metadata value given: " + field)
+		}
+		name = strings.TrimPrefix(field, "name=")
+	}
+	if name == "" {
+		return "", "", reply.MakeErrReply("ERR Library name was not given")
+	}
+	if !isValidFunctionName(name) {
+		return "", "", reply.MakeErrReply("ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	return name, body, nil
+}
+
+func isValidFunctionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, ch := range name {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// loadLibrary 在新的虚拟机中执行库的代码，收集注册的函数
+func loadLibrary(code []byte) (*luaLibrary, reply.ErrorReply) {
+	name, body, errReply := parseLibraryMeta(code)
+	if errReply != nil {
+		return nil, errReply
+	}
+	chunk, err := parse.Parse(strings.NewReader(body), "@user_function")
+	if err != nil {
+		return nil, reply.MakeErrReply("ERR Error compiling function: " + singleLine(err.Error()))
+	}
+	proto, err := lua.Compile(chunk, "@user_function")
+	if err != nil {
+		return nil, reply.MakeErrReply("ERR Error compiling function: " + singleLine(err.Error()))
+	}
+
+	lib := &luaLibrary{
+		name:      name,
+		code:      code,
+		functions: make(map[string]*luaFunction),
+	}
+	L := newScriptState(func() *scriptClient { return lib.client })
+	lib.L = L
+	loading := true
+	redis := L.GetGlobal("redis").(*lua.LTable)
+	redis.RawSetString("register_function", L.NewFunction(func(L *lua.LState) int {
+		if !loading {
+			L.RaiseError("redis.register_function can only be called on FUNCTION LOAD command")
+		}
+		fn := parseRegisterFunction(L)
+		if _, ok := lib.functions[fn.name]; ok {
+			L.RaiseError("Function already exists in the library")
+		}
+		fn.library = lib
+		lib.functions[fn.name] = fn
+		return 0
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), functionLoadTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+	L.Push(L.NewFunctionFromProto(proto))
+	err = L.PCall(0, 0, nil)
+	L.RemoveContext()
+	loading = false
+	if err != nil {
+		L.Close()
+		if ctx.Err() != nil {
+			return nil, reply.MakeErrReply("ERR FUNCTION LOAD timeout")
+		}
+		msg := err.Error()
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			msg = apiErr.Object.String()
+			if errReply := tableToErrReply(apiErr.Object); errReply != nil { // redis.call 返回的错误
+				msg = errReply.Error()
+			}
+		}
+		return nil, reply.MakeErrReply("ERR Error registering functions: " + singleLine(msg))
+	}
+	if len(lib.functions) == 0 {
+		L.Close()
+		return nil, reply.MakeErrReply("ERR No functions registered")
+	}
+	return lib, nil
+}
+
+// parseRegisterFunction redis.register_function(name, callback)
+// 或者 redis.register_function{function_name=..., callback=..., flags={...}, description=...}
+func parseRegisterFunction(L *lua.LState) *luaFunction {
+	fn := &luaFunction{}
+	if table, ok := L.Get(1).(*lua.LTable); ok {
+		table.ForEach(func(key lua.LValue, value lua.LValue) {
+			switch key.String() {
+			case "function_name":
+				fn.name = value.String()
+			case "callback":
+				fn.callback, _ = value.(*lua.LFunction)
+			case "description":
+				fn.description = value.String()
+			case "flags":
+				flags, ok := value.(*lua.LTable)
+				if !ok {
+					L.RaiseError("flags argument to redis.register_function must be a table representing function flags")
+				}
+				flags.ForEach(func(_ lua.LValue, flag lua.LValue) {
+					if !functionFlags[flag.String()] {
+						L.RaiseError("unknown flag given")
+					}
+					fn.flags = append(fn.flags, flag.String())
+				})
+			default:
+				L.RaiseError("unknown argument given to redis.register_function")
+			}
+		})
+		if fn.callback == nil {
+			L.RaiseError("redis.register_function must get a callback argument")
+		}
+	} else {
+		fn.name = L.CheckString(1)
+		fn.callback = L.CheckFunction(2)
+	}
+	if !isValidFunctionName(fn.name) {
+		L.RaiseError("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	return fn
+}
+
+// ---------------------- DUMP/RESTORE ----------------------
+
+// dumpFunctions 序列化所有的库：每个库为 标记(1字节)+代码长度(varint)+代码，结尾为版本号(2字节)和CRC64(8字节)
+func dumpFunctions(registry *functionRegistry) []byte {
+	var buf bytes.Buffer
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	for _, lib := range registry.sortedLibraries() {
+		buf.WriteByte(functionOpcode)
+		n := binary.PutUvarint(lenBuf, uint64(len(lib.code)))
+		buf.Write(lenBuf[:n])
+		buf.Write(lib.code)
+	}
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(functionDumpVersion))
+	_ = binary.Write(&buf, binary.LittleEndian, crc64.Checksum(buf.Bytes(), crcTable))
+	return buf.Bytes()
+}
+
+// parseFunctionDump 解析 dumpFunctions 的结果，返回每个库的代码
+func parseFunctionDump(payload []byte) ([][]byte, reply.ErrorReply) {
+	errReply := reply.MakeErrReply("ERR payload version or checksum are wrong")
+	if len(payload) < 10 {
+		return nil, errReply
+	}
+	body, footer := payload[:len(payload)-10], payload[len(payload)-10:]
+	version := binary.LittleEndian.Uint16(footer[:2])
+	checksum := binary.LittleEndian.Uint64(footer[2:])
+	if version > functionDumpVersion || checksum != crc64.Checksum(payload[:len(payload)-8], crcTable) {
+		return nil, errReply
+	}
+	var codes [][]byte
+	for len(body) > 0 {
+		if body[0] != functionOpcode {
+			return nil, reply.MakeErrReply("ERR given type is not a function")
+		}
+		size, n := binary.Uvarint(body[1:])
+		if n <= 0 || uint64(len(body)-1-n) < size {
+			return nil, reply.MakeErrReply("ERR payload is corrupted")
+		}
+		body = body[1+n:]
+		codes = append(codes, body[:size])
+		body = body[size:]
+	}
+	return codes, nil
+}
+
+// ---------------------- 指令 ----------------------
+
+// execFCall FCALL/FCALL_RO function numkeys key [key ...] arg [arg ...]
+// 函数的第一个参数为key组成的table，第二个参数为其余参数组成的table，调用方需要持有事务锁
+func (e *StandaloneDatabase) execFCall(c resp.Connection, readOnly bool, args [][]byte) resp.Reply {
+	numKeys, errReply := parseNumKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	fn, ok := e.scripts.functions.functions[string(args[0])]
+	if !ok {
+		return reply.MakeErrReply("ERR Function not found")
+	}
+	if readOnly && !fn.noWrites() {
+		return reply.MakeErrReply("ERR Can not execute a script with write flag using *_ro command.")
+	}
+	lib := fn.library
+	lib.client = e.makeScriptClient(c, readOnly || fn.noWrites())
+	defer func() {
+		lib.client = nil
+	}()
+	keys := bytesToTable(lib.L, args[2:2+numKeys])
+	argv := bytesToTable(lib.L, args[2+numKeys:])
+	return e.callLua(lib.client, lib.L, fn.callback, []lua.LValue{keys, argv}, fn.name, true)
+}
+
+// execFunction FUNCTION LOAD|DELETE|FLUSH|LIST|DUMP|RESTORE|KILL，除了KILL之外调用方需要持有事务锁
+func (e *StandaloneDatabase) execFunction(c resp.Connection, args [][]byte) resp.Reply {
+	subCmd := strings.ToLower(string(args[1]))
+	var result resp.Reply
+	switch subCmd {
+	case "load":
+		result = e.functionLoad(args[2:])
+	case "delete":
+		if len(args) != 3 {
+			return functionArgNumErr(subCmd)
+		}
+		lib := e.scripts.functions.remove(string(args[2]))
+		if lib == nil {
+			return reply.MakeErrReply("ERR Library not found")
+		}
+		lib.L.Close()
+		result = reply.MakeOkReply()
+	case "flush":
+		if len(args) > 3 || (len(args) == 3 && !strings.EqualFold(string(args[2]), "sync") &&
+			!strings.EqualFold(string(args[2]), "async")) {
+			return reply.MakeErrReply("ERR FUNCTION FLUSH only supports SYNC|ASYNC option")
+		}
+		old := e.scripts.functions
+		e.scripts.functions = makeFunctionRegistry()
+		closeRemoved(old, e.scripts.functions)
+		result = reply.MakeOkReply()
+	case "restore":
+		result = e.functionRestore(args[2:])
+	case "list":
+		return e.functionList(args[2:])
+	case "dump":
+		if len(args) != 2 {
+			return functionArgNumErr(subCmd)
+		}
+		return reply.MakeBulkReply(dumpFunctions(e.scripts.functions))
+	case "kill":
+		if len(args) != 2 {
+			return functionArgNumErr(subCmd)
+		}
+		return e.scripts.kill(true)
+	default:
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[1]) + "'. Try FUNCTION HELP.")
+	}
+	// 修改了函数的指令写入aof
+	if !reply.IsErrReply(result) && e.aofHandler != nil {
+		e.aofHandler.AddAof(c.GetDBIndex(), args)
+	}
+	return result
+}
+
+func functionArgNumErr(subCmd string) reply.ErrorReply {
+	return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + subCmd + "'. Try FUNCTION HELP.")
+}
+
+// functionLoad FUNCTION LOAD [REPLACE] function-code   返回库名
+func (e *StandaloneDatabase) functionLoad(args [][]byte) resp.Reply {
+	replace := false
+	if len(args) == 2 && strings.EqualFold(string(args[0]), "replace") {
+		replace = true
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return functionArgNumErr("load")
+	}
+	lib, errReply := loadLibrary(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	old, errReply := e.scripts.functions.install(lib, replace)
+	if errReply != nil {
+		lib.L.Close()
+		return errReply
+	}
+	if old != nil {
+		old.L.Close()
+	}
+	return reply.MakeBulkReply([]byte(lib.name))
+}
+
+// functionRestore FUNCTION RESTORE serialized-value [FLUSH|APPEND|REPLACE]
+// 所有的库都加载成功之后才生效，默认为APPEND：存在同名的库时报错
+func (e *StandaloneDatabase) functionRestore(args [][]byte) resp.Reply {
+	if len(args) == 0 || len(args) > 2 {
+		return functionArgNumErr("restore")
+	}
+	policy := "append"
+	if len(args) == 2 {
+		policy = strings.ToLower(string(args[1]))
+		if policy != "flush" && policy != "append" && policy != "replace" {
+			return reply.MakeErrReply("ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.")
+		}
+	}
+	codes, errReply := parseFunctionDump(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	registry := e.scripts.functions.clone()
+	if policy == "flush" {
+		registry = makeFunctionRegistry()
+	}
+	var loaded []*luaLibrary
+	for _, code := range codes {
+		lib, errReply := loadLibrary(code)
+		if errReply == nil {
+			loaded = append(loaded, lib)
+			_, errReply = registry.install(lib, policy == "replace")
+		}
+		if errReply != nil {
+			for _, lib := range loaded {
+				lib.L.Close()
+			}
+			return errReply
+		}
+	}
+	old := e.scripts.functions
+	e.scripts.functions = registry
+	closeRemoved(old, registry)
+	return reply.MakeOkReply()
+}
+
+// functionList FUNCTION LIST [LIBRARYNAME library-name-pattern] [WITHCODE]
+func (e *StandaloneDatabase) functionList(args [][]byte) resp.Reply {
+	var pattern *wildcard.Pattern
+	withCode := false
+	for i := 0; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "withcode":
+			withCode = true
+		case "libraryname":
+			if i+1 >= len(args) || pattern != nil {
+				return reply.MakeErrReply("ERR library name argument was not given")
+			}
+			var err error
+			pattern, err = wildcard.CompilePattern(string(args[i+1]))
+			if err != nil {
+				return reply.MakeErrReply("ERR " + err.Error())
+			}
+			i++
+		default:
+			return reply.MakeErrReply("ERR Unknown argument " + string(args[i]))
+		}
+	}
+	result := make([]resp.Reply, 0)
+	for _, lib := range e.scripts.functions.sortedLibraries() {
+		if pattern != nil && !pattern.IsMatch(lib.name) {
+			continue
+		}
+		result = append(result, libraryReply(lib, withCode))
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// libraryReply [library_name, name, engine, LUA, functions, [...], library_code, code]
+func libraryReply(lib *luaLibrary, withCode bool) resp.Reply {
+	names := make([]string, 0, len(lib.functions))
+	for name := range lib.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	functions := make([]resp.Reply, len(names))
+	for i, name := range names {
+		fn := lib.functions[name]
+		var description resp.Reply = reply.MakeNullBulkReply()
+		if fn.description != "" {
+			description = reply.MakeBulkReply([]byte(fn.description))
+		}
+		flags := make([][]byte, len(fn.flags))
+		for j, flag := range fn.flags {
+			flags[j] = []byte(flag)
+		}
+		functions[i] = reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("name")),
+			reply.MakeBulkReply([]byte(fn.name)),
+			reply.MakeBulkReply([]byte("description")),
+			description,
+			reply.MakeBulkReply([]byte("flags")),
+			reply.MakeMultiBulkReply(flags),
+		})
+	}
+	result := []resp.Reply{
+		reply.MakeBulkReply([]byte("library_name")),
+		reply.MakeBulkReply([]byte(lib.name)),
+		reply.MakeBulkReply([]byte("engine")),
+		reply.MakeBulkReply([]byte("LUA")),
+		reply.MakeBulkReply([]byte("functions")),
+		reply.MakeMultiRawReply(functions),
+	}
+	if withCode {
+		result = append(result,
+			reply.MakeBulkReply([]byte("library_code")),
+			reply.MakeBulkReply(lib.code))
+	}
+	return reply.MakeMultiRawReply(result)
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"go_redis/resp/reply"
+	"strings"
+	"testing"
+)
+
+const testLibrary = `#!lua name=mylib
+redis.register_function('set_key', function(keys, args) return redis.call('set', keys[1], args[1]) end)
+redis.register_function{function_name='get_key', flags={'no-writes'}, description='read a key',
+	callback=function(keys, args) return redis.call('get', keys[1]) end}
+redis.register_function{function_name='sneaky_write', flags={'no-writes'},
+	callback=function(keys, args) return redis.call('set', keys[1], 'x') end}
+`
+
+func TestFunctionCall(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	assertReply(t, execCmd(e, c, "function", "load", testLibrary), "$5\r\nmylib\r\n")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"fcall", "set_key", "1", "k", "v"}, "OK"},
+		{[]string{"fcall", "get_key", "1", "k"}, "v"},
+		{[]string{"fcall_ro", "get_key", "1", "k"}, "v"},
+		{[]string{"fcall_ro", "set_key", "1", "k", "v"}, "ERR Can not execute a script with write flag using *_ro command."},
+		// 带有 no-writes 标志的函数即使通过FCALL调用也不能写入
+		{[]string{"fcall", "sneaky_write", "1", "k"}, "ERR Write commands are not allowed from read-only scripts."},
+		{[]string{"fcall", "missing", "0"}, "ERR Function not found"},
+		{[]string{"function", "load", testLibrary}, "ERR Library 'mylib' already exists"},
+		{[]string{"function", "load", "replace", testLibrary}, "mylib"},
+		{[]string{"function", "load", "#!lua name=other\nredis.register_function('set_key', function() return 1 end)"},
+			"ERR Function set_key already exists"},
+		{[]string{"function", "load", "return 1"}, "ERR Missing library metadata"},
+		{[]string{"function", "load", "#!lua name=empty\nlocal x = 1"}, "ERR No functions registered"},
+		// 只有加载库的时候才能注册函数，加载时不能执行指令
+		{[]string{"function", "load", "#!lua name=calls\nredis.call('ping')\nredis.register_function('f', function() return 1 end)"},
+			"ERR Error registering functions: ERR redis.call can only be called inside a script invocation"},
+	}
+	for _, tt := range tests {
+		if got := renderReply(execCmd(e, c, tt.args...)); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	assertReply(t, execCmd(e, c, "get", "k"), "$1\r\nv\r\n")
+
+	assertReply(t, execCmd(e, c, "function", "delete", "mylib"), "+OK\r\n")
+	assertReply(t, execCmd(e, c, "function", "delete", "mylib"), "-ERR Library not found\r\n")
+	assertReply(t, execCmd(e, c, "fcall", "get_key", "1", "k"), "-ERR Function not found\r\n")
+}
+
+func TestFunctionList(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "function", "load", testLibrary)
+	execCmd(e, c, "function", "load", "#!lua name=another\nredis.register_function('ping_fn', function() return 'pong' end)")
+
+	want := "[[library_name mylib engine LUA functions [" +
+		"[name get_key description read a key flags [no-writes]] " +
+		"[name set_key description nil flags []] " +
+		"[name sneaky_write description nil flags [no-writes]]]]]"
+	if got := renderReply(execCmd(e, c, "function", "list", "libraryname", "my*")); got != want {
+		t.Errorf("function list:\n got %s\nwant %s", got, want)
+	}
+	r, ok := execCmd(e, c, "function", "list", "withcode").(*reply.MultiRawReply)
+	if !ok || len(r.Replies) != 2 {
+		t.Fatalf("function list withcode: %s", renderReply(r))
+	}
+	// 按照库名排序
+	if got := renderReply(r.Replies[0]); !strings.HasPrefix(got, "[library_name another") ||
+		!strings.HasSuffix(got, "library_code #!lua name=another\nredis.register_function('ping_fn', function() return 'pong' end)]") {
+		t.Errorf("function list withcode: %s", got)
+	}
+	assertReply(t, execCmd(e, c, "function", "list", "bogus"), "-ERR Unknown argument bogus\r\n")
+}
+
+// DUMP 的结果可以通过 RESTORE 恢复到其他实例
+func TestFunctionDumpRestore(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "function", "load", testLibrary)
+	dump, ok := execCmd(e, c, "function", "dump").(*reply.BulkReply)
+	if !ok {
+		t.Fatal("function dump did not return a payload")
+	}
+	payload := string(dump.Arg)
+
+	target, tc := makeTestDatabase(t)
+	assertReply(t, execCmd(target, tc, "function", "restore", payload), "+OK\r\n")
+	execCmd(target, tc, "fcall", "set_key", "1", "k", "restored")
+	assertReply(t, execCmd(target, tc, "fcall_ro", "get_key", "1", "k"), "$8\r\nrestored\r\n")
+
+	// 默认为APPEND，存在同名的库时报错并且不做任何修改
+	assertReply(t, execCmd(target, tc, "function", "restore", payload), "-ERR Library 'mylib' already exists\r\n")
+	assertReply(t, execCmd(target, tc, "function", "restore", payload, "replace"), "+OK\r\n")
+	execCmd(target, tc, "function", "load", "#!lua name=extra\nredis.register_function('extra_fn', function() return 1 end)")
+	assertReply(t, execCmd(target, tc, "function", "restore", payload, "flush"), "+OK\r\n")
+	assertReply(t, execCmd(target, tc, "fcall", "extra_fn", "0"), "-ERR Function not found\r\n")
+	assertReply(t, execCmd(target, tc, "function", "restore", payload, "merge"),
+		"-ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.\r\n")
+
+	corrupted := []byte(payload)
+	corrupted[len(corrupted)-1] ^= 0xff
+	assertReply(t, execCmd(target, tc, "function", "restore", string(corrupted)), "-ERR payload version or checksum are wrong\r\n")
+
+	assertReply(t, execCmd(target, tc, "function", "flush"), "+OK\r\n")
+	if got := renderReply(execCmd(target, tc, "function", "list")); got != "[]" {
+		t.Errorf("function list after flush: %s", got)
+	}
+}
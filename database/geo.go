@@ -0,0 +1,492 @@
+package database
+
+import (
+	"go_redis/datastruct/geohash"
+	"go_redis/datastruct/sortedset"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 地理位置的常用指令，位置以有序集合的形式存储，score为52位的geohash
+
+// parseLonLat 解析经纬度，超出范围时返回错误
+func parseLonLat(lonArg, latArg []byte) (float64, float64, reply.ErrorReply) {
+	lon, err1 := strconv.ParseFloat(string(lonArg), 64)
+	lat, err2 := strconv.ParseFloat(string(latArg), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, reply.MakeErrReply("ERR value is not a valid float")
+	}
+	if !geohash.Valid(lon, lat) {
+		return 0, 0, reply.MakeErrReply("ERR invalid longitude,latitude pair " +
+			strconv.FormatFloat(lon, 'f', 6, 64) + "," + strconv.FormatFloat(lat, 'f', 6, 64))
+	}
+	return lon, lat, nil
+}
+
+// parseDistUnit 解析距离的单位，返回1个单位对应的米数
+func parseDistUnit(arg []byte) (float64, reply.ErrorReply) {
+	switch strings.ToLower(string(arg)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, reply.MakeErrReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+// formatCoord 格式化经纬度，最多保留17位小数并且去掉末尾的0
+func formatCoord(value float64) []byte {
+	s := strconv.FormatFloat(value, 'f', 17, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return []byte(s)
+}
+
+// formatDist 格式化距离，保留4位小数
+func formatDist(distance float64) []byte {
+	return []byte(strconv.FormatFloat(distance, 'f', 4, 64))
+}
+
+// GEOADD K [NX|XX] [CH] longitude latitude member ...
+func execGeoAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	var nx, xx, ch bool
+	i := 1
+parseFlags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "CH":
+			ch = true
+		default:
+			break parseFlags
+		}
+	}
+	if nx && xx {
+		return reply.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		return reply.MakeSyntaxErrReply()
+	}
+	elements := make([]*sortedset.Element, len(triples)/3)
+	for j := range elements {
+		lon, lat, errReply := parseLonLat(triples[3*j], triples[3*j+1])
+		if errReply != nil {
+			return errReply
+		}
+		elements[j] = &sortedset.Element{
+			Member: string(triples[3*j+2]),
+			Score:  float64(geohash.EncodeToScore(lon, lat)),
+		}
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		if xx {
+			return reply.MakeIntReply(0)
+		}
+		sortedSet, _ = db.getOrInitSortedSet(key)
+	}
+	added, changed := 0, 0
+	for _, element := range elements {
+		old, exists := sortedSet.Get(element.Member)
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if exists && old.Score == element.Score {
+			continue
+		}
+		sortedSet.Add(element.Member, element.Score)
+		if exists {
+			changed++
+		} else {
+			added++
+		}
+	}
+	if sortedSet.Len() == 0 {
+		db.Remove(key)
+	}
+	if added+changed > 0 {
+		db.addAof(utils.ToCmdLine3("geoadd", args...))
+		db.notify(notifyZSet, "zadd", key) // 和redis一样，GEOADD 通过ZADD实现
+	}
+	if ch {
+		return reply.MakeIntReply(int64(added + changed))
+	}
+	return reply.MakeIntReply(int64(added))
+}
+
+// GEOPOS K member ...
+func execGeoPos(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		var element *sortedset.Element
+		exists := false
+		if sortedSet != nil {
+			element, exists = sortedSet.Get(string(member))
+		}
+		if !exists {
+			result[i] = reply.MakeNullMultiBulkReply()
+			continue
+		}
+		lon, lat := geohash.Decode(uint64(element.Score))
+		result[i] = reply.MakeMultiBulkReply([][]byte{formatCoord(lon), formatCoord(lat)})
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// GEODIST K member1 member2 [M|KM|FT|MI]
+func execGeoDist(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 4 {
+		return reply.MakeSyntaxErrReply()
+	}
+	unit := 1.0
+	if len(args) == 4 {
+		var errReply reply.ErrorReply
+		unit, errReply = parseDistUnit(args[3])
+		if errReply != nil {
+			return errReply
+		}
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeNullBulkReply()
+	}
+	element1, ok1 := sortedSet.Get(string(args[1]))
+	element2, ok2 := sortedSet.Get(string(args[2]))
+	if !ok1 || !ok2 {
+		return reply.MakeNullBulkReply()
+	}
+	lon1, lat1 := geohash.Decode(uint64(element1.Score))
+	lon2, lat2 := geohash.Decode(uint64(element2.Score))
+	return reply.MakeBulkReply(formatDist(geohash.Distance(lon1, lat1, lon2, lat2) / unit))
+}
+
+// GEOHASH K member ...
+func execGeoHash(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	for i, member := range args[1:] {
+		if sortedSet == nil {
+			continue
+		}
+		element, exists := sortedSet.Get(string(member))
+		if !exists {
+			continue
+		}
+		result[i] = []byte(geohash.ToString(uint64(element.Score)))
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// ---------------------- GEOSEARCH ----------------------
+
+// geoSearchOptions GEOSEARCH 和 GEOSEARCHSTORE 的参数
+type geoSearchOptions struct {
+	fromMember []byte // FROMMEMBER member
+	fromLonLat bool   // FROMLONLAT lon lat
+	lon, lat   float64
+	shape      geohash.Shape
+	byRadius   bool
+	byBox      bool
+	unit       float64 // 单位对应的米数
+	sort       int     // 0 不排序 1 ASC -1 DESC
+	count      int     // 0 表示不限制
+	any        bool    // 找到count个结果之后立即返回，不保证是最近的
+	withCoord  bool
+	withDist   bool
+	withHash   bool
+	storeDist  bool // GEOSEARCHSTORE 中使用距离作为score
+}
+
+// geoPoint 搜索结果
+type geoPoint struct {
+	member   string
+	score    float64
+	lon, lat float64
+	distance float64 // 单位为米
+}
+
+func parseGeoSearchOptions(args [][]byte, store bool) (*geoSearchOptions, reply.ErrorReply) {
+	cmdName := "GEOSEARCH"
+	if store {
+		cmdName = "GEOSEARCHSTORE"
+	}
+	opts := &geoSearchOptions{unit: 1}
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		remain := len(args) - i - 1
+		switch option {
+		case "FROMMEMBER":
+			if remain < 1 {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			opts.fromMember = args[i+1]
+			i++
+		case "FROMLONLAT":
+			if remain < 2 {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			lon, lat, errReply := parseLonLat(args[i+1], args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			opts.fromLonLat = true
+			opts.lon, opts.lat = lon, lat
+			i += 2
+		case "BYRADIUS":
+			if remain < 2 {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			radius, err := strconv.ParseFloat(string(args[i+1]), 64)
+			if err != nil || radius < 0 {
+				return nil, reply.MakeErrReply("ERR need numeric radius")
+			}
+			unit, errReply := parseDistUnit(args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			opts.byRadius = true
+			opts.unit = unit
+			opts.shape.Radius = radius * unit
+			i += 2
+		case "BYBOX":
+			if remain < 3 {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			width, err1 := strconv.ParseFloat(string(args[i+1]), 64)
+			height, err2 := strconv.ParseFloat(string(args[i+2]), 64)
+			if err1 != nil || err2 != nil || width < 0 || height < 0 {
+				return nil, reply.MakeErrReply("ERR need numeric width and height")
+			}
+			unit, errReply := parseDistUnit(args[i+3])
+			if errReply != nil {
+				return nil, errReply
+			}
+			opts.byBox = true
+			opts.unit = unit
+			opts.shape.Width = width * unit
+			opts.shape.Height = height * unit
+			i += 3
+		case "ASC":
+			opts.sort = 1
+		case "DESC":
+			opts.sort = -1
+		case "COUNT":
+			if remain < 1 {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			count, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || count <= 0 {
+				return nil, reply.MakeErrReply("ERR COUNT must be > 0")
+			}
+			opts.count = count
+			i++
+			if remain >= 2 && strings.ToUpper(string(args[i+1])) == "ANY" {
+				opts.any = true
+				i++
+			}
+		case "WITHCOORD", "WITHDIST", "WITHHASH":
+			if store {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			switch option {
+			case "WITHCOORD":
+				opts.withCoord = true
+			case "WITHDIST":
+				opts.withDist = true
+			case "WITHHASH":
+				opts.withHash = true
+			}
+		case "STOREDIST":
+			if !store {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			opts.storeDist = true
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+	}
+	if (opts.fromMember == nil) == !opts.fromLonLat {
+		return nil, reply.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + cmdName)
+	}
+	if opts.byRadius == opts.byBox {
+		return nil, reply.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX arguments must be provided for " + cmdName + " command")
+	}
+	if opts.any && opts.count == 0 {
+		return nil, reply.MakeErrReply("ERR the ANY argument requires COUNT argument")
+	}
+	// 指定了COUNT但是没有ANY时，需要返回最近的count个结果
+	if opts.count > 0 && !opts.any && opts.sort == 0 {
+		opts.sort = 1
+	}
+	return opts, nil
+}
+
+// geoSearch 在有序集合中查找范围内的点
+func geoSearch(sortedSet *sortedset.SortedSet, opts *geoSearchOptions) ([]*geoPoint, reply.ErrorReply) {
+	if opts.fromMember != nil {
+		element, exists := sortedSet.Get(string(opts.fromMember))
+		if !exists {
+			return nil, reply.MakeErrReply("ERR could not decode requested zset member")
+		}
+		opts.lon, opts.lat = geohash.Decode(uint64(element.Score))
+	}
+	opts.shape.Lon, opts.shape.Lat = opts.lon, opts.lat
+
+	var points []*geoPoint
+	for _, area := range opts.shape.SearchAreas() {
+		min, max := area.RangeOfScore()
+		minBorder := &sortedset.ScoreBorder{Value: float64(min)}
+		maxBorder := &sortedset.ScoreBorder{Value: float64(max), Exclude: true}
+		sortedSet.ForEach(minBorder, maxBorder, 0, -1, false, func(element *sortedset.Element) bool {
+			lon, lat := geohash.Decode(uint64(element.Score))
+			distance, ok := opts.shape.InShape(lon, lat)
+			if !ok {
+				return true
+			}
+			points = append(points, &geoPoint{
+				member:   element.Member,
+				score:    element.Score,
+				lon:      lon,
+				lat:      lat,
+				distance: distance,
+			})
+			return !opts.any || len(points) < opts.count
+		})
+		if opts.any && len(points) >= opts.count {
+			break
+		}
+	}
+	if opts.sort != 0 {
+		sort.SliceStable(points, func(i, j int) bool {
+			if opts.sort > 0 {
+				return points[i].distance < points[j].distance
+			}
+			return points[i].distance > points[j].distance
+		})
+	}
+	if opts.count > 0 && len(points) > opts.count {
+		points = points[:opts.count]
+	}
+	return points, nil
+}
+
+// GEOSEARCH K FROMMEMBER member|FROMLONLAT lon lat BYRADIUS radius unit|BYBOX width height unit [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func execGeoSearch(db *DB, args [][]byte) resp.Reply {
+	opts, errReply := parseGeoSearchOptions(args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	points, errReply := geoSearch(sortedSet, opts)
+	if errReply != nil {
+		return errReply
+	}
+	if !opts.withCoord && !opts.withDist && !opts.withHash {
+		result := make([][]byte, len(points))
+		for i, point := range points {
+			result[i] = []byte(point.member)
+		}
+		return reply.MakeMultiBulkReply(result)
+	}
+	// 每个结果为数组: member [dist] [hash] [[lon lat]]
+	result := make([]resp.Reply, len(points))
+	for i, point := range points {
+		item := []resp.Reply{reply.MakeBulkReply([]byte(point.member))}
+		if opts.withDist {
+			item = append(item, reply.MakeBulkReply(formatDist(point.distance/opts.unit)))
+		}
+		if opts.withHash {
+			item = append(item, reply.MakeIntReply(int64(point.score)))
+		}
+		if opts.withCoord {
+			item = append(item, reply.MakeMultiBulkReply([][]byte{formatCoord(point.lon), formatCoord(point.lat)}))
+		}
+		result[i] = reply.MakeMultiRawReply(item)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// GEOSEARCHSTORE dest K FROMMEMBER member|FROMLONLAT lon lat BYRADIUS radius unit|BYBOX width height unit [ASC|DESC] [COUNT count [ANY]] [STOREDIST]
+func execGeoSearchStore(db *DB, args [][]byte) resp.Reply {
+	dest := string(args[0])
+	opts, errReply := parseGeoSearchOptions(args[2:], true)
+	if errReply != nil {
+		return errReply
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	var points []*geoPoint
+	if sortedSet != nil {
+		points, errReply = geoSearch(sortedSet, opts)
+		if errReply != nil {
+			return errReply
+		}
+	}
+	if len(points) == 0 {
+		if _, exists := db.GetEntity(dest); exists {
+			db.Remove(dest)
+			db.notify(notifyGeneric, "del", dest)
+		}
+	} else {
+		result := sortedset.MakeSortedSet()
+		for _, point := range points {
+			score := point.score
+			if opts.storeDist {
+				score = point.distance / opts.unit
+			}
+			result.Add(point.member, score)
+		}
+		db.PutEntity(dest, &database.DataEntity{
+			Data: result,
+		})
+		db.Persist(dest)
+		db.notify(notifyZSet, "geosearchstore", dest)
+	}
+	db.addAof(utils.ToCmdLine3("geosearchstore", args...))
+	return reply.MakeIntReply(int64(len(points)))
+}
+
+func init() {
+	RegisterCommand("geoadd", execGeoAdd, nil, -5, flagWrite, 1, 1, 1) // GEOADD K [NX|XX] [CH] longitude latitude member ...
+	RegisterCommand("geopos", execGeoPos, nil, -2, flagReadOnly, 1, 1, 1)
+	RegisterCommand("geodist", execGeoDist, nil, -4, flagReadOnly, 1, 1, 1) // GEODIST K member1 member2 [unit]
+	RegisterCommand("geohash", execGeoHash, nil, -2, flagReadOnly, 1, 1, 1)
+	RegisterCommand("geosearch", execGeoSearch, nil, -7, flagReadOnly, 1, 1, 1)                          // GEOSEARCH K FROMLONLAT lon lat BYRADIUS radius unit
+	RegisterCommand("geosearchstore", execGeoSearchStore, prepareGeoSearchStore, -8, flagWrite, 1, 2, 1) // GEOSEARCHSTORE dest K FROMMEMBER member BYRADIUS radius unit
+}
@@ -0,0 +1,433 @@
+package database
+
+import (
+	"go_redis/datastruct/hash"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/lib/wildcard"
+	"go_redis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// hash类型 的常用指令
+
+// getAsHash 取出key对应的hash，key不存在返回nil，类型不是hash返回WRONGTYPE错误
+func (db *DB) getAsHash(key string) (*hash.Hash, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	h, ok := entity.Data.(*hash.Hash)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return h, nil
+}
+
+// getOrInitHash 取出key对应的hash，不存在则创建
+func (db *DB) getOrInitHash(key string) (*hash.Hash, reply.ErrorReply) {
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if h == nil {
+		h = hash.MakeHash()
+		db.PutEntity(key, &database.DataEntity{
+			Data: h,
+		})
+	}
+	return h, nil
+}
+
+// HSET K f1 v1 f2 v2 ...   返回新增的field个数
+func execHSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 1 {
+		return reply.MakeArgNumErrReply("hset")
+	}
+	key := string(args[0])
+	h, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		added += h.Set(string(args[i]), args[i+1])
+	}
+	db.addAof(utils.ToCmdLine3("hset", args...))
+	db.notify(notifyHash, "hset", key)
+	return reply.MakeIntReply(int64(added))
+}
+
+// HMSET K f1 v1 f2 v2 ...   和HSET一样，只是返回OK
+func execHMSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 1 {
+		return reply.MakeArgNumErrReply("hmset")
+	}
+	r := execHSet(db, args)
+	if reply.IsErrReply(r) {
+		return r
+	}
+	return reply.MakeOkReply()
+}
+
+// HSETNX K f v   field不存在才设置
+func execHSetNX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	h, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if _, exists := h.Get(field); exists {
+		return reply.MakeIntReply(0)
+	}
+	h.Set(field, args[2])
+	db.addAof(utils.ToCmdLine3("hsetnx", args...))
+	db.notify(notifyHash, "hset", key)
+	return reply.MakeIntReply(1)
+}
+
+// HGET K f
+func execHGet(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeNullBulkReply()
+	}
+	value, exists := h.Get(string(args[1]))
+	if !exists {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply(value)
+}
+
+// HMGET K f1 f2 ...
+func execHMGet(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	if h == nil {
+		return reply.MakeMultiBulkReply(result) // 全部为nil
+	}
+	for i, field := range args[1:] {
+		value, exists := h.Get(string(field))
+		if exists {
+			result[i] = value
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// HEXISTS K f
+func execHExists(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	if _, exists := h.Get(string(args[1])); exists {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// HDEL K f1 f2 ...
+func execHDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	deleted := 0
+	for _, field := range args[1:] {
+		deleted += h.Delete(string(field))
+	}
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine3("hdel", args...))
+		db.notify(notifyHash, "hdel", key)
+	}
+	if h.Len() == 0 { // hash为空时删除该key
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(int64(deleted))
+}
+
+// HLEN K
+func execHLen(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(h.Len()))
+}
+
+// HSTRLEN K f
+func execHStrlen(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	value, _ := h.Get(string(args[1]))
+	return reply.MakeIntReply(int64(len(value)))
+}
+
+// HGETALL K   依次返回 field value
+func execHGetAll(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeBulkMapReply(nil)
+	}
+	result := make([][]byte, 0, h.Len()*2)
+	h.ForEach(func(field string, value []byte) bool {
+		result = append(result, []byte(field), value)
+		return true
+	})
+	return reply.MakeBulkMapReply(result)
+}
+
+// HKEYS K
+func execHKeys(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	result := make([][]byte, 0, h.Len())
+	h.ForEach(func(field string, value []byte) bool {
+		result = append(result, []byte(field))
+		return true
+	})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// HVALS K
+func execHVals(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	result := make([][]byte, 0, h.Len())
+	h.ForEach(func(field string, value []byte) bool {
+		result = append(result, value)
+		return true
+	})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// HINCRBY K f increment
+func execHIncrBy(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	h, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current int64
+	if value, exists := h.Get(field); exists {
+		current, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR hash value is not an integer")
+		}
+	}
+	// 溢出检查
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return reply.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	current += delta
+	h.Set(field, []byte(strconv.FormatInt(current, 10)))
+	db.addAof(utils.ToCmdLine3("hincrby", args...))
+	db.notify(notifyHash, "hincrby", key)
+	return reply.MakeIntReply(current)
+}
+
+// HINCRBYFLOAT K f increment   aof中记录为HSET最终的值，避免浮点数重放的误差
+func execHIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return reply.MakeErrReply("ERR value is not a valid float")
+	}
+	h, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current float64
+	if value, exists := h.Get(field); exists {
+		current, err = strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR hash value is not a float")
+		}
+	}
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result := []byte(strconv.FormatFloat(current, 'f', -1, 64))
+	h.Set(field, result)
+	db.addAof(utils.ToCmdLine3("hset", args[0], args[1], result))
+	db.notify(notifyHash, "hincrbyfloat", key)
+	return reply.MakeBulkReply(result)
+}
+
+// HSCAN K cursor [MATCH pattern] [COUNT count]
+func execHScan(db *DB, args [][]byte) resp.Reply {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR invalid cursor")
+	}
+	count := 10
+	var pattern *wildcard.Pattern
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return reply.MakeSyntaxErrReply()
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern, err = wildcard.CompilePattern(string(args[i+1]))
+			if err != nil {
+				return reply.MakeErrReply("ERR invalid pattern")
+			}
+		case "COUNT":
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return reply.MakeSyntaxErrReply()
+			}
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return makeScanReply(0, nil)
+	}
+	var match func(field string) bool
+	if pattern != nil {
+		match = pattern.IsMatch
+	}
+	fields, next := h.Scan(cursor, count, match)
+	result := make([][]byte, 0, len(fields)*2)
+	for _, field := range fields {
+		value, _ := h.Get(field)
+		result = append(result, []byte(field), value)
+	}
+	return makeScanReply(next, result)
+}
+
+// makeScanReply SCAN类指令的回复：[cursor, [elements...]]
+func makeScanReply(cursor uint64, elements [][]byte) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(strconv.FormatUint(cursor, 10))),
+		reply.MakeMultiBulkReply(elements),
+	})
+}
+
+// HRANDFIELD K [count [WITHVALUES]]
+func execHRandField(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 3 {
+		return reply.MakeArgNumErrReply("hrandfield")
+	}
+	withCount := len(args) >= 2
+	withValues := false
+	count := 1
+	if withCount {
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if n < -math.MaxInt64/2 { // 取反之后(WITHVALUES时再乘以2)溢出，和redis一致
+			return reply.MakeErrReply("ERR value is out of range")
+		}
+		count = n
+		if len(args) == 3 {
+			if strings.ToUpper(string(args[2])) != "WITHVALUES" {
+				return reply.MakeSyntaxErrReply()
+			}
+			withValues = true
+		}
+	}
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		if withCount {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	if !withCount {
+		return reply.MakeBulkReply([]byte(h.RandomFields(1)[0]))
+	}
+	var fields []string
+	if count >= 0 { // 正数：不重复
+		fields = h.RandomDistinctFields(count)
+	} else { // 负数：可以重复
+		fields = h.RandomFields(-count)
+	}
+	result := make([][]byte, 0, len(fields)*2)
+	for _, field := range fields {
+		result = append(result, []byte(field))
+		if withValues {
+			value, _ := h.Get(field)
+			result = append(result, value)
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+func init() {
+	RegisterCommand("hset", execHSet, nil, -4, flagWrite|flagFast, 1, 1, 1) // HSET K f1 v1 f2 v2 ...
+	RegisterCommand("hmset", execHMSet, nil, -4, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("hsetnx", execHSetNX, nil, 4, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("hget", execHGet, nil, 3, flagReadOnly|flagFast, 1, 1, 1) // HGET K f
+	RegisterCommand("hmget", execHMGet, nil, -3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("hexists", execHExists, nil, 3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("hdel", execHDel, nil, -3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("hlen", execHLen, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("hstrlen", execHStrlen, nil, 3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("hgetall", execHGetAll, nil, 2, flagReadOnly, 1, 1, 1)
+	RegisterCommand("hkeys", execHKeys, nil, 2, flagReadOnly, 1, 1, 1)
+	RegisterCommand("hvals", execHVals, nil, 2, flagReadOnly, 1, 1, 1)
+	RegisterCommand("hincrby", execHIncrBy, nil, 4, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("hincrbyfloat", execHIncrByFloat, nil, 4, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("hscan", execHScan, nil, -3, flagReadOnly, 1, 1, 1) // HSCAN K cursor [MATCH pattern] [COUNT count]
+	RegisterCommand("hrandfield", execHRandField, nil, -2, flagReadOnly, 1, 1, 1)
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"go_redis/resp/reply"
+	"testing"
+)
+
+func TestHRandFieldCount(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "hset", "h", "a", "1", "b", "2", "c", "3")
+
+	assertReply(t, execCmd(e, c, "hrandfield", "h", "-9223372036854775808"), "-ERR value is out of range\r\n")
+	assertReply(t, execCmd(e, c, "hrandfield", "h", "-9223372036854775807", "withvalues"), "-ERR value is out of range\r\n")
+	assertReply(t, execCmd(e, c, "hrandfield", "missing", "-5"), "*0\r\n")
+
+	// 正数：不重复，最多返回全部field
+	r, ok := execCmd(e, c, "hrandfield", "h", "10").(*reply.MultiBulkReply)
+	if !ok || len(r.Args) != 3 {
+		t.Fatalf("positive count returned %v", r)
+	}
+	seen := make(map[string]bool)
+	for _, arg := range r.Args {
+		if seen[string(arg)] {
+			t.Errorf("duplicate field %s", arg)
+		}
+		seen[string(arg)] = true
+	}
+
+	// 负数：可以重复，返回的个数和count一致
+	r, ok = execCmd(e, c, "hrandfield", "h", "-10").(*reply.MultiBulkReply)
+	if !ok || len(r.Args) != 10 {
+		t.Fatalf("negative count returned %v", r)
+	}
+
+	r, ok = execCmd(e, c, "hrandfield", "h", "-4", "withvalues").(*reply.MultiBulkReply)
+	if !ok || len(r.Args) != 8 {
+		t.Fatalf("withvalues returned %v", r)
+	}
+	for i := 0; i < len(r.Args); i += 2 {
+		assertReply(t, execCmd(e, c, "hget", "h", string(r.Args[i])), string(reply.MakeBulkReply(r.Args[i+1]).ToBytes()))
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"go_redis/config"
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+// HELLO 协商协议版本，RESP3 的客户端可以收到 map/set/double 等类型的回复以及推送消息
+
+const serverVersion = "7.0.0" // 兼容的redis版本
+
+// execHello HELLO [protover [AUTH username password] [SETNAME clientname]]
+// 服务器目前没有强制认证，AUTH 只校验密码是否和 requirepass 一致
+func execHello(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	protocol := c.GetProtocol()
+	if len(args) > 0 {
+		version, err := strconv.ParseInt(string(args[0]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR Protocol version is not an integer or out of range")
+		}
+		if version < 2 || version > 3 {
+			return reply.MakeErrReply("NOPROTO unsupported protocol version")
+		}
+		protocol = int(version)
+	}
+	var name string
+	setName := false
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				return reply.MakeErrReply("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+			}
+			if errReply := checkPassword(string(args[i+1]), string(args[i+2])); errReply != nil {
+				return errReply
+			}
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return reply.MakeErrReply("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+			}
+			name = string(args[i+1])
+			if errReply := validateClientName(name); errReply != nil {
+				return errReply
+			}
+			setName = true
+			i++
+		default:
+			return reply.MakeErrReply("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+		}
+	}
+	// 所有的参数都校验通过之后才修改连接的状态，回复使用新的协议版本
+	if setName {
+		c.SetName(name)
+	}
+	c.SetProtocol(protocol)
+
+	mode := config.StandaloneMode
+	if config.Properties.Self != "" && len(config.Properties.Peers) > 0 {
+		mode = config.ClusterMode
+	}
+	return reply.MakeMapReply([]resp.Reply{
+		reply.MakeBulkReply([]byte("server")), reply.MakeBulkReply([]byte("redis")),
+		reply.MakeBulkReply([]byte("version")), reply.MakeBulkReply([]byte(serverVersion)),
+		reply.MakeBulkReply([]byte("proto")), reply.MakeIntReply(int64(protocol)),
+		reply.MakeBulkReply([]byte("id")), reply.MakeIntReply(int64(c.GetID())),
+		reply.MakeBulkReply([]byte("mode")), reply.MakeBulkReply([]byte(mode)),
+		reply.MakeBulkReply([]byte("role")), reply.MakeBulkReply([]byte("master")),
+		reply.MakeBulkReply([]byte("modules")), reply.MakeEmptyMultiBulkReply(),
+	})
+}
+
+// checkPassword 没有ACL，只有 default 用户，没有配置 requirepass 时任意密码都可以通过
+func checkPassword(username string, password string) resp.Reply {
+	if username != "default" || (config.Properties.RequirePass != "" && password != config.Properties.RequirePass) {
+		return reply.MakeErrReply("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	return nil
+}
+
+// validateClientName 客户端名称不能包含空格、换行等特殊字符
+func validateClientName(name string) resp.Reply {
+	for _, ch := range []byte(name) {
+		if ch < '!' || ch > '~' {
+			return reply.MakeErrReply("ERR Client names cannot contain spaces, newlines or special characters.")
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterConnCommand("hello", execHello, noPrepare, -1, flagNoScript|flagFast, 0, 0, 0) // HELLO [protover [AUTH username password] [SETNAME clientname]]
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/connection"
+	"go_redis/resp/parser"
+	"go_redis/resp/reply"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试使用的单机数据库和伪客户端，伪客户端没有网络连接，不能用于需要写入连接的指令(订阅等)
+func makeTestDatabase(t *testing.T) (*StandaloneDatabase, *connection.Connection) {
+	e := NewStandaloneDatabase()
+	t.Cleanup(e.Close)
+	return e, &connection.Connection{}
+}
+
+func execCmd(e *StandaloneDatabase, c resp.Connection, args ...string) resp.Reply {
+	return e.Exec(c, utils.ToCmdLine(args...))
+}
+
+// assertReply 比较回复的RESP2编码
+func assertReply(t *testing.T, r resp.Reply, want string) {
+	t.Helper()
+	if got := string(r.ToBytes()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// renderReply 将回复转换为便于比较的文本，数组用[]表示，空值为nil
+func renderReply(r resp.Reply) string {
+	switch r := r.(type) {
+	case *reply.MultiRawReply:
+		return renderReplies(r.Replies)
+	case *reply.PushReply:
+		return renderReplies(r.Replies)
+	case *reply.MultiBulkReply:
+		items := make([]string, len(r.Args))
+		for i, arg := range r.Args {
+			if arg == nil {
+				items[i] = "nil"
+			} else {
+				items[i] = string(arg)
+			}
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	case *reply.EmptyMultiBulkReply:
+		return "[]"
+	case *reply.BulkReply:
+		return string(r.Arg)
+	case *reply.IntReply:
+		return strconv.FormatInt(r.Code, 10)
+	case *reply.StatusReply:
+		return r.Status
+	case *reply.NullBulkReply, *reply.NullMultiBulkReply:
+		return "nil"
+	case reply.ErrorReply:
+		return r.Error()
+	}
+	// OK/PONG 等固定的状态回复
+	return strings.TrimPrefix(strings.TrimSuffix(string(r.ToBytes()), reply.CRLF), "+")
+}
+
+func renderReplies(replies []resp.Reply) string {
+	items := make([]string, len(replies))
+	for i, item := range replies {
+		items[i] = renderReply(item)
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
+
+// makePipeClient 创建通过内存管道连接的客户端，返回的通道按顺序收到写入连接的消息(推送、订阅回复等)
+func makePipeClient(t *testing.T) (*connection.Connection, <-chan string) {
+	server, client := net.Pipe()
+	c := connection.NewConn(server)
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = client.Close()
+	})
+	messages := make(chan string, 16)
+	go func() {
+		defer close(messages)
+		for payload := range parser.ParseReplyStream(client) {
+			if payload.Err != nil {
+				return
+			}
+			messages <- renderReply(payload.Data)
+		}
+	}()
+	return c, messages
+}
+
+// nextMessage 等待连接收到下一条消息
+func nextMessage(t *testing.T, messages <-chan string) string {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"go_redis/datastruct/hyperloglog"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+)
+
+// HyperLogLog的常用指令，HyperLogLog以redis格式的字符串存储，可以直接GET/SET
+
+// getAsHyperLogLog 取出key对应的HyperLogLog，key不存在时返回nil
+func (db *DB) getAsHyperLogLog(key string) (*hyperloglog.HyperLogLog, reply.ErrorReply) {
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if value == nil {
+		return nil, nil
+	}
+	h, err := hyperloglog.Parse(value)
+	if err != nil {
+		return nil, reply.MakeErrReply(err.Error())
+	}
+	return h, nil
+}
+
+// PFADD K [element ...]   有寄存器被修改或者新建了key时返回1
+func execPFAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	h, errReply := db.getAsHyperLogLog(key)
+	if errReply != nil {
+		return errReply
+	}
+	updated := false
+	if h == nil {
+		h = hyperloglog.MakeHyperLogLog()
+		updated = true
+	}
+	for _, element := range args[1:] {
+		if h.Add(element) {
+			updated = true
+		}
+	}
+	if !updated {
+		return reply.MakeIntReply(0)
+	}
+	db.PutEntity(key, &database.DataEntity{
+		Data: h.ToBytes(),
+	})
+	db.addAof(utils.ToCmdLine3("pfadd", args...))
+	db.notify(notifyString, "pfadd", key)
+	return reply.MakeIntReply(1)
+}
+
+// PFCOUNT K1 K2 ...   多个key时返回合并之后的基数
+func execPFCount(db *DB, args [][]byte) resp.Reply {
+	if len(args) == 1 {
+		key := string(args[0])
+		h, errReply := db.getAsHyperLogLog(key)
+		if errReply != nil {
+			return errReply
+		}
+		if h == nil {
+			return reply.MakeIntReply(0)
+		}
+		if h.CacheValid() {
+			return reply.MakeIntReply(int64(h.Count()))
+		}
+		// 缓存失效时重新计算，并且写回缓存，缓存只影响性能，所以不需要写入aof
+		count := h.Count()
+		db.PutEntity(key, &database.DataEntity{
+			Data: h.ToBytes(),
+		})
+		return reply.MakeIntReply(int64(count))
+	}
+
+	merged := hyperloglog.MakeHyperLogLog()
+	for _, arg := range args {
+		h, errReply := db.getAsHyperLogLog(string(arg))
+		if errReply != nil {
+			return errReply
+		}
+		if h != nil {
+			merged.Merge(h)
+		}
+	}
+	return reply.MakeIntReply(int64(merged.Count()))
+}
+
+// PFMERGE dest K1 K2 ...   dest本身也参与合并
+func execPFMerge(db *DB, args [][]byte) resp.Reply {
+	dest := string(args[0])
+	merged := hyperloglog.MakeHyperLogLog()
+	for _, arg := range args {
+		h, errReply := db.getAsHyperLogLog(string(arg))
+		if errReply != nil {
+			return errReply
+		}
+		if h != nil {
+			merged.Merge(h)
+		}
+	}
+	db.PutEntity(dest, &database.DataEntity{
+		Data: merged.ToBytes(),
+	})
+	db.addAof(utils.ToCmdLine3("pfmerge", args...))
+	db.notify(notifyString, "pfadd", dest)
+	return reply.MakeOkReply()
+}
+
+func init() {
+	RegisterCommand("pfadd", execPFAdd, nil, -2, flagWrite|flagFast, 1, 1, 1)              // PFADD K [element ...]
+	RegisterCommand("pfcount", execPFCount, writeAllKeys, -2, flagReadOnly, 1, -1, 1)      // PFCOUNT K1 K2 ...
+	RegisterCommand("pfmerge", execPFMerge, writeFirstReadOthers, -2, flagWrite, 1, -1, 1) // PFMERGE dest K1 K2 ...
+}
@@ -1,10 +1,19 @@
 package database
 
 import (
+	"go_redis/aof"
+	"go_redis/datastruct/hash"
+	"go_redis/datastruct/list"
+	"go_redis/datastruct/set"
+	"go_redis/datastruct/sortedset"
+	"go_redis/datastruct/stream"
 	"go_redis/interface/resp"
 	"go_redis/lib/utils"
 	"go_redis/lib/wildcard"
 	"go_redis/resp/reply"
+	"math"
+	"strconv"
+	"time"
 )
 
 // 实现keys 指令操作（方法）
@@ -15,7 +24,14 @@ func execDel(db *DB, args [][]byte) resp.Reply {
 	for i, v := range args {
 		keys[i] = string(v)
 	} // 先转化为string类型，在交给下层的db函数执行
-	deleted := db.Removes(keys...)
+	deleted := 0
+	for _, key := range keys {
+		if _, ok := db.GetEntity(key); ok {
+			db.Remove(key)
+			db.notify(notifyGeneric, "del", key)
+			deleted++
+		}
+	}
 	if deleted > 0 { // 如果删除，那么aof记录
 		db.addAof(utils.ToCmdLine3("del", args...))
 	}
@@ -41,6 +57,7 @@ func execFlushDB(db *DB, args [][]byte) resp.Reply {
 	// 清空数据库
 	db.Flush()
 	db.addAof(utils.ToCmdLine3("flushdb", args...))
+	db.tracking.flush()
 	return reply.MakeOkReply()
 }
 
@@ -54,6 +71,16 @@ func execType(db *DB, args [][]byte) resp.Reply {
 	switch entity.Data.(type) { // 类型断言
 	case []byte:
 		return reply.MakeStatusReply("string")
+	case list.List:
+		return reply.MakeStatusReply("list")
+	case *hash.Hash:
+		return reply.MakeStatusReply("hash")
+	case *set.Set:
+		return reply.MakeStatusReply("set")
+	case *sortedset.SortedSet:
+		return reply.MakeStatusReply("zset")
+	case *stream.Stream:
+		return reply.MakeStatusReply("stream")
 	}
 	return &reply.UnknownErrReply{}
 }
@@ -66,9 +93,16 @@ func execRename(db *DB, args [][]byte) resp.Reply {
 	if !ok { // 不存在的key
 		return reply.MakeErrReply("on such key")
 	}
+	expireTime, hasTTL := db.TTL(src)
 	db.PutEntity(dst, v)
+	db.Persist(dst) // 覆盖dst时，dst原先的过期时间失效
 	db.Remove(src)
+	if hasTTL { // 过期时间跟随value一起转移
+		db.Expire(dst, expireTime)
+	}
 	db.addAof(utils.ToCmdLine3("rename", args...))
+	db.notify(notifyGeneric, "rename_from", src)
+	db.notify(notifyGeneric, "rename_to", dst)
 	return reply.MakeOkReply()
 }
 
@@ -84,18 +118,26 @@ func execRenamenx(db *DB, args [][]byte) resp.Reply {
 	if !ok { // 不存在的key
 		return reply.MakeErrReply("on such key")
 	}
+	expireTime, hasTTL := db.TTL(src)
 	db.PutEntity(dst, v)
+	db.Persist(dst)
 	db.Remove(src)
+	if hasTTL {
+		db.Expire(dst, expireTime)
+	}
 	db.addAof(utils.ToCmdLine3("renamenx", args...))
+	db.notify(notifyGeneric, "rename_from", src)
+	db.notify(notifyGeneric, "rename_to", dst)
 	return reply.MakeIntReply(1) // k2不存在，返回1表示操作成功
 }
 
 // KEYS *  返回所以满足要求的keys  （通配符要求）
+// 没有对key加锁，已经过期的key只过滤掉，由惰性删除和主动过期负责删除
 func execKeys(db *DB, args [][]byte) resp.Reply {
 	pattern, _ := wildcard.CompilePattern(string(args[0]))
 	result := make([][]byte, 0)
 	db.Data.ForEach(func(key string, val interface{}) bool {
-		if pattern.IsMatch(key) {
+		if pattern.IsMatch(key) && !db.ttlExpired(key) {
 			result = append(result, []byte(key))
 		}
 		return true
@@ -103,13 +145,147 @@ func execKeys(db *DB, args [][]byte) resp.Reply {
 	return reply.MakeMultiBulkReply(result)
 }
 
+// ------------------过期时间相关的指令----------------------
+
+// expireAt 给存在的key设置绝对的过期时间，过期时间已经过去则直接删除该key
+func expireAt(db *DB, key string, expireTime time.Time) resp.Reply {
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return reply.MakeIntReply(0) // key不存在，设置失败
+	}
+	if !expireTime.After(time.Now()) { // 过期时间已经过去，直接删除
+		db.Remove(key)
+		db.addAof(utils.ToCmdLine("del", key))
+		db.notify(notifyGeneric, "del", key)
+		return reply.MakeIntReply(1)
+	}
+	db.Expire(key, expireTime)
+	db.addAof(aof.MakeExpireCmd(key, expireTime)) // aof中统一记录为绝对时间
+	db.notify(notifyGeneric, "expire", key)
+	return reply.MakeIntReply(1)
+}
+
+// parseExpireArg 解析过期时间的参数
+func parseExpireArg(arg []byte) (int64, resp.Reply) {
+	val, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	return val, nil
+}
+
+// toDuration 将相对的过期时间转化为time.Duration，超出范围(转化之后溢出)时返回false
+func toDuration(val int64, unit time.Duration) (time.Duration, bool) {
+	limit := int64(math.MaxInt64 / unit)
+	if val > limit || val < -limit {
+		return 0, false
+	}
+	return time.Duration(val) * unit, true
+}
+
+// expireAfter EXPIRE/PEXPIRE 的公共逻辑
+func expireAfter(db *DB, args [][]byte, cmdName string, unit time.Duration) resp.Reply {
+	val, errReply := parseExpireArg(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	duration, ok := toDuration(val, unit)
+	if !ok {
+		return reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	}
+	return expireAt(db, string(args[0]), time.Now().Add(duration))
+}
+
+// EXPIRE k seconds
+func execExpire(db *DB, args [][]byte) resp.Reply {
+	return expireAfter(db, args, "expire", time.Second)
+}
+
+// PEXPIRE k milliseconds
+func execPExpire(db *DB, args [][]byte) resp.Reply {
+	return expireAfter(db, args, "pexpire", time.Millisecond)
+}
+
+// EXPIREAT k timestamp(秒)
+func execExpireAt(db *DB, args [][]byte) resp.Reply {
+	timestamp, errReply := parseExpireArg(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if timestamp > math.MaxInt64/1000 || timestamp < math.MinInt64/1000 { // 转化为毫秒时间戳之后溢出
+		return reply.MakeErrReply("ERR invalid expire time in 'expireat' command")
+	}
+	return expireAt(db, string(args[0]), time.Unix(timestamp, 0))
+}
+
+// PEXPIREAT k timestamp(毫秒)
+func execPExpireAt(db *DB, args [][]byte) resp.Reply {
+	timestamp, errReply := parseExpireArg(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	return expireAt(db, string(args[0]), time.UnixMilli(timestamp))
+}
+
+// ttlOf 返回key的剩余存活时间：key不存在返回-2，没有设置过期时间返回-1
+func ttlOf(db *DB, key string, unit time.Duration) resp.Reply {
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return reply.MakeIntReply(-2)
+	}
+	expireTime, ok := db.TTL(key)
+	if !ok {
+		return reply.MakeIntReply(-1)
+	}
+	// 使用毫秒时间戳计算，避免过期时间很远时time.Duration溢出
+	remain := expireTime.UnixMilli() - time.Now().UnixMilli()
+	if unit == time.Second {
+		remain = (remain + 999) / 1000 // 向上取整，和redis保持一致
+	}
+	return reply.MakeIntReply(remain)
+}
+
+// TTL k  剩余的秒数
+func execTTL(db *DB, args [][]byte) resp.Reply {
+	return ttlOf(db, string(args[0]), time.Second)
+}
+
+// PTTL k  剩余的毫秒数
+func execPTTL(db *DB, args [][]byte) resp.Reply {
+	return ttlOf(db, string(args[0]), time.Millisecond)
+}
+
+// PERSIST k  移除过期时间，移除成功返回1
+func execPersist(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+	_, ok := db.TTL(key)
+	if !ok {
+		return reply.MakeIntReply(0)
+	}
+	db.Persist(key)
+	db.addAof(utils.ToCmdLine3("persist", args...))
+	db.notify(notifyGeneric, "persist", key)
+	return reply.MakeIntReply(1)
+}
+
 // init 函数
 func init() {
-	RegisterCommand("del", execDel, -2)
-	RegisterCommand("exists", execExists, -2)
-	RegisterCommand("flushdb", execFlushDB, -1) // FLUSHDB a ,b ,c  只需要执行flush命令，不管参数的长度。所以取-1
-	RegisterCommand("type", execType, 2)        // TYPE K1
-	RegisterCommand("rename", execRename, 3)    // RENAME K1 K2
-	RegisterCommand("renamenx", execRenamenx, 3)
-	RegisterCommand("keys", execKeys, 2) // KEYS  *    只是接受两个参数，keys 通配符
+	RegisterCommand("del", execDel, nil, -2, flagWrite, 1, -1, 1)
+	RegisterCommand("exists", execExists, nil, -2, flagReadOnly|flagFast, 1, -1, 1)
+	RegisterCommand("flushdb", execFlushDB, noPrepare, -1, flagWrite, 0, 0, 0) // FLUSHDB a ,b ,c  只需要执行flush命令，不管参数的长度。所以取-1
+	RegisterCommand("type", execType, nil, 2, flagReadOnly|flagFast, 1, 1, 1)  // TYPE K1
+	RegisterCommand("rename", execRename, nil, 3, flagWrite, 1, 2, 1)          // RENAME K1 K2
+	RegisterCommand("renamenx", execRenamenx, nil, 3, flagWrite|flagFast, 1, 2, 1)
+	RegisterCommand("keys", execKeys, noPrepare, 2, flagReadOnly, 0, 0, 0)     // KEYS  *    只是接受两个参数，keys 通配符
+	RegisterCommand("expire", execExpire, nil, 3, flagWrite|flagFast, 1, 1, 1) // EXPIRE K1 10
+	RegisterCommand("pexpire", execPExpire, nil, 3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("expireat", execExpireAt, nil, 3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("pexpireat", execPExpireAt, nil, 3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("ttl", execTTL, nil, 2, flagReadOnly|flagFast, 1, 1, 1) // TTL K1
+	RegisterCommand("pttl", execPTTL, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("persist", execPersist, nil, 2, flagWrite|flagFast, 1, 1, 1)
 }
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpireOutOfRange(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "set", "k", "v")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"expire", "k", "9223372036854775807"}, "-ERR invalid expire time in 'expire' command\r\n"},
+		{[]string{"expire", "k", "-9223372036854775807"}, "-ERR invalid expire time in 'expire' command\r\n"},
+		{[]string{"pexpire", "k", "9223372036854775807"}, "-ERR invalid expire time in 'pexpire' command\r\n"},
+		{[]string{"expireat", "k", "9223372036854775807"}, "-ERR invalid expire time in 'expireat' command\r\n"},
+		{[]string{"expire", "k", "abc"}, "-ERR value is not an integer or out of range\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+	// 出错时key保持不变
+	assertReply(t, execCmd(e, c, "get", "k"), "$1\r\nv\r\n")
+	assertReply(t, execCmd(e, c, "ttl", "k"), ":-1\r\n")
+
+	assertReply(t, execCmd(e, c, "expire", "k", "9223372036"), ":1\r\n")
+	assertReply(t, execCmd(e, c, "ttl", "k"), ":9223372036\r\n")
+	assertReply(t, execCmd(e, c, "pexpire", "k", "-1"), ":1\r\n") // 过期时间已经过去，直接删除
+	assertReply(t, execCmd(e, c, "exists", "k"), ":0\r\n")
+}
+
+// KEYS 不对key加锁，过滤掉已经过期的key，但是不删除
+func TestKeysSkipsExpired(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "set", "a", "1")
+	execCmd(e, c, "set", "b", "2")
+	db := e.dbSet[0]
+	db.Expire("b", time.Now().Add(-time.Second))
+	assertReply(t, execCmd(e, c, "keys", "*"), "*1\r\n$1\r\na\r\n")
+	if _, ok := db.Data.Get("b"); !ok {
+		t.Error("KEYS should not remove expired keys")
+	}
+	assertReply(t, execCmd(e, c, "get", "b"), "$-1\r\n")
+	if _, ok := db.Data.Get("b"); ok {
+		t.Error("expired key should be removed lazily on access")
+	}
+}
@@ -0,0 +1,431 @@
+package database
+
+import (
+	"go_redis/datastruct/list"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+)
+
+// list类型 的常用指令
+
+// getAsList 取出key对应的list，key不存在返回nil，类型不是list返回WRONGTYPE错误
+func (db *DB) getAsList(key string) (list.List, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	l, ok := entity.Data.(list.List)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return l, nil
+}
+
+// getOrInitList 取出key对应的list，不存在则创建一个新的list
+func (db *DB) getOrInitList(key string) (l list.List, isNew bool, errReply reply.ErrorReply) {
+	l, errReply = db.getAsList(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	isNew = false
+	if l == nil {
+		l = list.NewQuickList()
+		db.PutEntity(key, &database.DataEntity{
+			Data: l,
+		})
+		isNew = true
+	}
+	return l, isNew, nil
+}
+
+func listEquals(value []byte) list.Expected {
+	return func(a interface{}) bool {
+		return utils.BytesEquals(a.([]byte), value)
+	}
+}
+
+// LPUSH K V1 V2 ...  依次插入到头部
+func execLPush(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	values := args[1:]
+	l, _, errReply := db.getOrInitList(key)
+	if errReply != nil {
+		return errReply
+	}
+	for _, value := range values {
+		l.Insert(0, value)
+	}
+	db.addAof(utils.ToCmdLine3("lpush", args...))
+	db.notify(notifyList, "lpush", key)
+	return reply.MakeIntReply(int64(l.Len()))
+}
+
+// RPUSH K V1 V2 ...  依次插入到尾部
+func execRPush(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	values := args[1:]
+	l, _, errReply := db.getOrInitList(key)
+	if errReply != nil {
+		return errReply
+	}
+	for _, value := range values {
+		l.Add(value)
+	}
+	db.addAof(utils.ToCmdLine3("rpush", args...))
+	db.notify(notifyList, "rpush", key)
+	return reply.MakeIntReply(int64(l.Len()))
+}
+
+// popList LPOP/RPOP 的公共逻辑   LPOP K [count]
+func popList(db *DB, args [][]byte, cmdName string, fromLeft bool) resp.Reply {
+	key := string(args[0])
+	if len(args) > 2 {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+	count := 1
+	withCount := len(args) == 2
+	if withCount {
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil || n < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		if withCount {
+			return reply.MakeNullMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	if count > l.Len() {
+		count = l.Len()
+	}
+	result := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		var val interface{}
+		if fromLeft {
+			val = l.Remove(0)
+		} else {
+			val = l.RemoveLast()
+		}
+		result = append(result, val.([]byte))
+	}
+	if count > 0 {
+		db.addAof(utils.ToCmdLine3(cmdName, args...))
+		db.notify(notifyList, cmdName, key)
+	}
+	if l.Len() == 0 { // 列表为空时删除该key
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+	if !withCount {
+		return reply.MakeBulkReply(result[0])
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// LPOP K [count]
+func execLPop(db *DB, args [][]byte) resp.Reply {
+	return popList(db, args, "lpop", true)
+}
+
+// RPOP K [count]
+func execRPop(db *DB, args [][]byte) resp.Reply {
+	return popList(db, args, "rpop", false)
+}
+
+// parseListDirection 解析 LEFT|RIGHT，LEFT返回true
+func parseListDirection(arg []byte) (bool, bool) {
+	switch strings.ToUpper(string(arg)) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// lMove 从src的一端弹出元素并插入到dest的一端，src为空时返回nil
+func lMove(db *DB, src string, dest string, fromLeft bool, toLeft bool) resp.Reply {
+	srcList, errReply := db.getAsList(src)
+	if errReply != nil {
+		return errReply
+	}
+	if srcList == nil {
+		return reply.MakeNullBulkReply()
+	}
+	// 先校验dest的类型，避免弹出元素之后无法插入
+	if _, errReply := db.getAsList(dest); errReply != nil {
+		return errReply
+	}
+	var val interface{}
+	if fromLeft {
+		val = srcList.Remove(0)
+	} else {
+		val = srcList.RemoveLast()
+	}
+	popEvent := "rpop"
+	if fromLeft {
+		popEvent = "lpop"
+	}
+	db.notify(notifyList, popEvent, src)
+	if srcList.Len() == 0 {
+		db.Remove(src)
+		db.notify(notifyGeneric, "del", src)
+	}
+	destList, _, _ := db.getOrInitList(dest)
+	pushEvent := "rpush"
+	if toLeft {
+		destList.Insert(0, val)
+		pushEvent = "lpush"
+	} else {
+		destList.Add(val)
+	}
+	db.addAof(utils.ToCmdLine("lmove", src, dest, directionName(fromLeft), directionName(toLeft)))
+	db.notify(notifyList, pushEvent, dest)
+	return reply.MakeBulkReply(val.([]byte))
+}
+
+func directionName(left bool) string {
+	if left {
+		return "LEFT"
+	}
+	return "RIGHT"
+}
+
+// LMOVE src dest LEFT|RIGHT LEFT|RIGHT
+func execLMove(db *DB, args [][]byte) resp.Reply {
+	fromLeft, ok1 := parseListDirection(args[2])
+	toLeft, ok2 := parseListDirection(args[3])
+	if !ok1 || !ok2 {
+		return reply.MakeSyntaxErrReply()
+	}
+	return lMove(db, string(args[0]), string(args[1]), fromLeft, toLeft)
+}
+
+// RPOPLPUSH src dest   等价于 LMOVE src dest RIGHT LEFT
+func execRPopLPush(db *DB, args [][]byte) resp.Reply {
+	return lMove(db, string(args[0]), string(args[1]), false, true)
+}
+
+// LRANGE K start stop
+func execLRange(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	begin, end := utils.ConvertRange(start, stop, int64(l.Len()))
+	if begin < 0 || begin >= end {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	slice := l.Range(begin, end)
+	result := make([][]byte, len(slice))
+	for i, v := range slice {
+		result[i] = v.([]byte)
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// normalizeIndex 将redis的下标(可以为负数)转化为list的下标，越界返回false
+func normalizeIndex(index int64, size int) (int, bool) {
+	if index < 0 {
+		index += int64(size)
+	}
+	if index < 0 || index >= int64(size) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// LINDEX K index
+func execLIndex(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeNullBulkReply()
+	}
+	i, ok := normalizeIndex(index, l.Len())
+	if !ok {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply(l.Get(i).([]byte))
+}
+
+// LSET K index V
+func execLSet(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	index, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeErrReply("ERR no such key")
+	}
+	i, ok := normalizeIndex(index, l.Len())
+	if !ok {
+		return reply.MakeErrReply("ERR index out of range")
+	}
+	l.Set(i, args[2])
+	db.addAof(utils.ToCmdLine3("lset", args...))
+	db.notify(notifyList, "lset", key)
+	return reply.MakeOkReply()
+}
+
+// LREM K count V   count>0 从头部删除，count<0 从尾部删除，count=0 删除全部
+func execLRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	count, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeIntReply(0)
+	}
+	var removed int
+	if count == 0 {
+		removed = l.RemoveAllByVal(listEquals(args[2]))
+	} else if count > 0 {
+		removed = l.RemoveByVal(listEquals(args[2]), count)
+	} else {
+		removed = l.ReverseRemoveByVal(listEquals(args[2]), -count)
+	}
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine3("lrem", args...))
+		db.notify(notifyList, "lrem", key)
+	}
+	if l.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(int64(removed))
+}
+
+// LTRIM K start stop  只保留 [start, stop] 的元素
+func execLTrim(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeOkReply()
+	}
+	size := l.Len()
+	begin, end := utils.ConvertRange(start, stop, int64(size))
+	if begin < 0 || begin >= end { // 范围为空，删除整个列表
+		db.Remove(key)
+	} else {
+		for i := 0; i < size-end; i++ {
+			l.RemoveLast()
+		}
+		for i := 0; i < begin; i++ {
+			l.Remove(0)
+		}
+	}
+	db.addAof(utils.ToCmdLine3("ltrim", args...))
+	db.notify(notifyList, "ltrim", key)
+	if begin < 0 || begin >= end {
+		db.notify(notifyGeneric, "del", key)
+	}
+	return reply.MakeOkReply()
+}
+
+// LINSERT K BEFORE|AFTER pivot V
+func execLInsert(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	where := strings.ToUpper(string(args[1]))
+	if where != "BEFORE" && where != "AFTER" {
+		return reply.MakeSyntaxErrReply()
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeIntReply(0)
+	}
+	pivot := -1
+	l.ForEach(func(i int, v interface{}) bool {
+		if utils.BytesEquals(v.([]byte), args[2]) {
+			pivot = i
+			return false
+		}
+		return true
+	})
+	if pivot < 0 { // 没有找到pivot
+		return reply.MakeIntReply(-1)
+	}
+	if where == "AFTER" {
+		pivot++
+	}
+	l.Insert(pivot, args[3])
+	db.addAof(utils.ToCmdLine3("linsert", args...))
+	db.notify(notifyList, "linsert", key)
+	return reply.MakeIntReply(int64(l.Len()))
+}
+
+// LLEN K
+func execLLen(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(l.Len()))
+}
+
+func init() {
+	RegisterCommand("lpush", execLPush, nil, -3, flagWrite|flagFast, 1, 1, 1) // LPUSH K V1 V2 ...
+	RegisterCommand("rpush", execRPush, nil, -3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("lpop", execLPop, nil, -2, flagWrite|flagFast, 1, 1, 1) // LPOP K [count]
+	RegisterCommand("rpop", execRPop, nil, -2, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("lrange", execLRange, nil, 4, flagReadOnly, 1, 1, 1) // LRANGE K start stop
+	RegisterCommand("lindex", execLIndex, nil, 3, flagReadOnly, 1, 1, 1)
+	RegisterCommand("lset", execLSet, nil, 4, flagWrite, 1, 1, 1)
+	RegisterCommand("lrem", execLRem, nil, 4, flagWrite, 1, 1, 1)
+	RegisterCommand("ltrim", execLTrim, nil, 4, flagWrite, 1, 1, 1)
+	RegisterCommand("linsert", execLInsert, nil, 5, flagWrite, 1, 1, 1) // LINSERT K BEFORE|AFTER pivot V
+	RegisterCommand("llen", execLLen, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("lmove", execLMove, nil, 5, flagWrite, 1, 2, 1) // LMOVE src dest LEFT|RIGHT LEFT|RIGHT
+	RegisterCommand("rpoplpush", execRPopLPush, nil, 3, flagWrite, 1, 2, 1)
+}
@@ -0,0 +1,32 @@
+package database
+
+import "testing"
+
+func TestLRangeOutOfRange(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "rpush", "l", "a", "b", "c")
+	tests := []struct {
+		start, stop string
+		want        string
+	}{
+		{"-100", "-1", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"-100", "0", "*1\r\n$1\r\na\r\n"},
+		{"0", "100", "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{"5", "10", "*0\r\n"},
+		{"0", "-100", "*0\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, "lrange", "l", tt.start, tt.stop), tt.want)
+	}
+}
+
+func TestLTrimOutOfRange(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "rpush", "l", "a", "b", "c")
+	assertReply(t, execCmd(e, c, "ltrim", "l", "-100", "-1"), "+OK\r\n")
+	assertReply(t, execCmd(e, c, "llen", "l"), ":3\r\n")
+	assertReply(t, execCmd(e, c, "ltrim", "l", "-100", "-2"), "+OK\r\n")
+	assertReply(t, execCmd(e, c, "lrange", "l", "0", "-1"), "*2\r\n$1\r\na\r\n$1\r\nb\r\n")
+	assertReply(t, execCmd(e, c, "ltrim", "l", "5", "10"), "+OK\r\n")
+	assertReply(t, execCmd(e, c, "exists", "l"), ":0\r\n")
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"errors"
+	"go_redis/pubsub"
+	"strconv"
+	"sync"
+)
+
+// 键空间通知：写指令修改数据之后，向 __keyspace@<db>__:<key> 发布事件名，向 __keyevent@<db>__:<event> 发布key
+// 通过配置 notify-keyspace-events 选择需要发布的事件类型，和redis的标志字母一致
+
+const (
+	notifyKeyspace = 1 << iota // K  __keyspace@<db>__:<key>
+	notifyKeyevent             // E  __keyevent@<db>__:<event>
+	notifyGeneric              // g  DEL/EXPIRE/RENAME 等与类型无关的指令
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZSet                 // z
+	notifyExpired              // x  key过期被删除
+	notifyStream               // t
+
+	// 没有实现maxmemory淘汰，不支持 e(evicted) 标志，A 也不包含它
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash | notifyZSet |
+		notifyExpired | notifyStream // A
+)
+
+// parseKeyspaceEvents 解析 notify-keyspace-events 的配置，例如 "KEA"、"Ex"
+func parseKeyspaceEvents(s string) (int, error) {
+	flags := 0
+	for _, ch := range s {
+		switch ch {
+		case 'A':
+			flags |= notifyAll
+		case 'g':
+			flags |= notifyGeneric
+		case '$':
+			flags |= notifyString
+		case 'l':
+			flags |= notifyList
+		case 's':
+			flags |= notifySet
+		case 'h':
+			flags |= notifyHash
+		case 'z':
+			flags |= notifyZSet
+		case 'x':
+			flags |= notifyExpired
+		case 'e':
+			return 0, errors.New("notify-keyspace-events flag 'e' is not supported: keys are never evicted")
+		case 't':
+			flags |= notifyStream
+		case 'K':
+			flags |= notifyKeyspace
+		case 'E':
+			flags |= notifyKeyevent
+		default:
+			return 0, errors.New("invalid notify-keyspace-events flag '" + string(ch) + "'")
+		}
+	}
+	// 既没有选择K也没有选择E时不会发布任何事件
+	if flags&(notifyKeyspace|notifyKeyevent) == 0 {
+		return 0, nil
+	}
+	return flags, nil
+}
+
+// makeNotifier 返回db发布事件的函数，数据已经修改之后调用，订阅者收到事件时可以读到修改后的值
+// 调用方持有key的锁(以及事务锁)，事件交给 notifyQueue 在后台发布，不会因为订阅者写入缓慢而阻塞指令
+func makeNotifier(queue *notifyQueue, flags int, index int) func(class int, event string, key string) {
+	prefix := "__keyspace@" + strconv.Itoa(index) + "__:"
+	eventPrefix := "__keyevent@" + strconv.Itoa(index) + "__:"
+	return func(class int, event string, key string) {
+		if flags&class == 0 {
+			return
+		}
+		if flags&notifyKeyspace != 0 {
+			queue.push([][]byte{[]byte(prefix + key), []byte(event)})
+		}
+		if flags&notifyKeyevent != 0 {
+			queue.push([][]byte{[]byte(eventPrefix + event), []byte(key)})
+		}
+	}
+}
+
+// notifyQueue 等待发布的键空间事件，所有的db共享，由一个后台协程按照产生的顺序发布
+type notifyQueue struct {
+	mu      sync.Mutex
+	pending [][][]byte    // PUBLISH 的参数 channel message
+	wake    chan struct{} // 有新的事件时通知后台协程
+}
+
+func makeNotifyQueue() *notifyQueue {
+	return &notifyQueue{wake: make(chan struct{}, 1)}
+}
+
+// push 添加一个事件，不会阻塞
+func (queue *notifyQueue) push(args [][]byte) {
+	queue.mu.Lock()
+	queue.pending = append(queue.pending, args)
+	queue.mu.Unlock()
+	select {
+	case queue.wake <- struct{}{}:
+	default: // 后台协程已经被唤醒，会取走这个事件
+	}
+}
+
+// start 开启后台协程发布事件，closeChan 关闭之后退出，没有发布的事件直接丢弃
+func (queue *notifyQueue) start(hub *pubsub.Hub, closeChan <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-queue.wake:
+				queue.mu.Lock()
+				pending := queue.pending
+				queue.pending = nil
+				queue.mu.Unlock()
+				for _, args := range pending {
+					pubsub.Publish(hub, args)
+				}
+			case <-closeChan:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"go_redis/config"
+	"go_redis/resp/connection"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseKeyspaceEvents(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"KEA", notifyKeyspace | notifyKeyevent | notifyAll, false},
+		{"Ex", notifyKeyevent | notifyExpired, false},
+		{"Kg$", notifyKeyspace | notifyGeneric | notifyString, false},
+		{"A", 0, false}, // 没有选择K或者E
+		{"Ke", 0, true}, // 没有实现淘汰
+		{"KQ", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseKeyspaceEvents(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseKeyspaceEvents(%q) = %d, %v, want %d, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// makeNotifyDatabase 创建开启了键空间通知的数据库
+func makeNotifyDatabase(t *testing.T, events string) *StandaloneDatabase {
+	old := config.Properties.NotifyKeyspaceEvents
+	config.Properties.NotifyKeyspaceEvents = events
+	defer func() { config.Properties.NotifyKeyspaceEvents = old }()
+	e, _ := makeTestDatabase(t)
+	return e
+}
+
+func TestKeyspaceNotification(t *testing.T) {
+	e := makeNotifyDatabase(t, "KEg$")
+	c := &connection.Connection{}
+	sub, messages := makePipeClient(t)
+	execCmd(e, sub, "subscribe", "__keyspace@0__:k", "__keyevent@0__:del")
+	nextMessage(t, messages)
+	nextMessage(t, messages)
+
+	execCmd(e, c, "set", "k", "v")
+	if got := nextMessage(t, messages); got != "[message __keyspace@0__:k set]" {
+		t.Errorf("got %q", got)
+	}
+	execCmd(e, c, "lpush", "l", "a") // 没有订阅 l 类型的事件
+	execCmd(e, c, "del", "k")
+	if got := nextMessage(t, messages); got != "[message __keyspace@0__:k del]" {
+		t.Errorf("got %q", got)
+	}
+	if got := nextMessage(t, messages); got != "[message __keyevent@0__:del k]" {
+		t.Errorf("got %q", got)
+	}
+	// 其他db的事件发布到对应编号的频道
+	execCmd(e, c, "select", "1")
+	execCmd(e, c, "set", "k", "v")
+	execCmd(e, c, "select", "0")
+	execCmd(e, c, "set", "k", "w")
+	if got := nextMessage(t, messages); got != "[message __keyspace@0__:k set]" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// 订阅者不读取消息时，写指令也不会被阻塞，积压的事件按照产生的顺序发布
+func TestKeyspaceNotificationSlowSubscriber(t *testing.T) {
+	e := makeNotifyDatabase(t, "E$")
+	sub, messages := makePipeClient(t)
+	execCmd(e, sub, "subscribe", "__keyevent@0__:incrby")
+	nextMessage(t, messages)
+
+	const count = 100 // 超过 makePipeClient 缓存的消息个数，之后发布事件的写入会阻塞
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c := &connection.Connection{}
+		for i := 0; i < count; i++ {
+			execCmd(e, c, "incr", "k"+strconv.Itoa(i))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("write commands are blocked by the subscriber")
+	}
+	for i := 0; i < count; i++ {
+		if got, want := nextMessage(t, messages), "[message __keyevent@0__:incrby k"+strconv.Itoa(i)+"]"; got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/pubsub"
+	"go_redis/resp/reply"
+)
+
+// 发布订阅的指令和db无关，由上层(StandaloneDatabase)交给 pubsub.Hub 处理，这里只登记元数据
+
+func init() {
+	registerSpecialCommand("subscribe", -2, flagPubSub|flagNoScript)
+	registerSpecialCommand("unsubscribe", -1, flagPubSub|flagNoScript)
+	registerSpecialCommand("psubscribe", -2, flagPubSub|flagNoScript)
+	registerSpecialCommand("punsubscribe", -1, flagPubSub|flagNoScript)
+	registerSpecialCommand("publish", 3, flagPubSub|flagFast)
+	registerSpecialCommand("pubsub", -2, flagPubSub)
+	// 分片频道在集群模式下和key一样根据名称分配节点，因此登记为key
+	registerCommand(&command{arity: -2, flags: flagPubSub | flagNoScript,
+		firstKey: 1, lastKey: -1, keyStep: 1}, "ssubscribe")
+	registerCommand(&command{arity: -1, flags: flagPubSub | flagNoScript,
+		firstKey: 1, lastKey: -1, keyStep: 1}, "sunsubscribe")
+	registerCommand(&command{arity: 3, flags: flagPubSub | flagFast,
+		firstKey: 1, lastKey: 1, keyStep: 1}, "spublish")
+}
+
+// isPubSubCommand 发布订阅相关的指令
+func isPubSubCommand(cmdName string) bool {
+	switch cmdName {
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe", "publish", "pubsub",
+		"ssubscribe", "sunsubscribe", "spublish":
+		return true
+	}
+	return false
+}
+
+// isSubscribeCommand 改变连接订阅状态的指令，不能在事务中执行
+func isSubscribeCommand(cmdName string) bool {
+	return isPubSubCommand(cmdName) && cmdName != "publish" && cmdName != "pubsub" && cmdName != "spublish"
+}
+
+// execPubSub 执行发布订阅相关的指令
+func (e *StandaloneDatabase) execPubSub(c resp.Connection, cmdName string, args [][]byte) resp.Reply {
+	if !validateArity(cmdTable[cmdName].arity, args) {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+	switch cmdName {
+	case "subscribe":
+		return pubsub.Subscribe(e.hub, c, args[1:])
+	case "unsubscribe":
+		return pubsub.UnSubscribe(e.hub, c, args[1:])
+	case "psubscribe":
+		return pubsub.PSubscribe(e.hub, c, args[1:])
+	case "punsubscribe":
+		return pubsub.PUnSubscribe(e.hub, c, args[1:])
+	case "publish":
+		return pubsub.Publish(e.hub, args[1:])
+	case "ssubscribe":
+		return pubsub.SSubscribe(e.hub, c, args[1:])
+	case "sunsubscribe":
+		return pubsub.SUnSubscribe(e.hub, c, args[1:])
+	case "spublish":
+		return pubsub.SPublish(e.hub, args[1:])
+	}
+	return pubsub.PubSub(e.hub, args[1:])
+}
@@ -0,0 +1,81 @@
+package database
+
+import "testing"
+
+// 分片频道的订阅和发布，与普通频道互相独立
+func TestShardPubSub(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	sub, messages := makePipeClient(t)
+
+	execCmd(e, sub, "ssubscribe", "ch1", "ch2")
+	for _, want := range []string{"[ssubscribe ch1 1]", "[ssubscribe ch2 2]"} {
+		if got := nextMessage(t, messages); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	assertReply(t, execCmd(e, c, "spublish", "ch1", "hello"), ":1\r\n")
+	if got := nextMessage(t, messages); got != "[smessage ch1 hello]" {
+		t.Errorf("got %q", got)
+	}
+	// 普通频道的 PUBLISH 不会发送给分片频道的订阅者
+	assertReply(t, execCmd(e, c, "publish", "ch1", "hello"), ":0\r\n")
+	assertReply(t, execCmd(e, c, "spublish", "other", "hello"), ":0\r\n")
+
+	if got := renderReply(execCmd(e, c, "pubsub", "shardchannels")); got != "[ch1 ch2]" {
+		t.Errorf("pubsub shardchannels: %s", got)
+	}
+	if got := renderReply(execCmd(e, c, "pubsub", "shardchannels", "*2")); got != "[ch2]" {
+		t.Errorf("pubsub shardchannels *2: %s", got)
+	}
+	if got := renderReply(execCmd(e, c, "pubsub", "channels")); got != "[]" {
+		t.Errorf("pubsub channels: %s", got)
+	}
+	if got := renderReply(execCmd(e, c, "pubsub", "shardnumsub", "ch1", "none")); got != "[ch1 1 none 0]" {
+		t.Errorf("pubsub shardnumsub: %s", got)
+	}
+
+	execCmd(e, sub, "sunsubscribe", "ch1")
+	if got := nextMessage(t, messages); got != "[sunsubscribe ch1 1]" {
+		t.Errorf("got %q", got)
+	}
+	assertReply(t, execCmd(e, c, "spublish", "ch1", "hello"), ":0\r\n")
+	// 没有参数时取消所有的分片订阅
+	execCmd(e, sub, "sunsubscribe")
+	if got := nextMessage(t, messages); got != "[sunsubscribe ch2 0]" {
+		t.Errorf("got %q", got)
+	}
+	if got := renderReply(execCmd(e, c, "pubsub", "shardchannels")); got != "[]" {
+		t.Errorf("pubsub shardchannels after sunsubscribe: %s", got)
+	}
+}
+
+// RESP2连接订阅分片频道之后只能执行订阅相关的指令
+func TestShardSubscriberMode(t *testing.T) {
+	e, _ := makeTestDatabase(t)
+	sub, messages := makePipeClient(t)
+	execCmd(e, sub, "ssubscribe", "ch")
+	nextMessage(t, messages)
+
+	assertReply(t, execCmd(e, sub, "get", "k"),
+		"-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n")
+	if got := renderReply(execCmd(e, sub, "ping", "hi")); got != "[pong hi]" {
+		t.Errorf("ping in subscriber mode: %s", got)
+	}
+	execCmd(e, sub, "sunsubscribe")
+	nextMessage(t, messages)
+	assertReply(t, execCmd(e, sub, "get", "k"), "$-1\r\n")
+}
+
+// 断开连接时取消分片频道的订阅
+func TestShardUnsubscribeOnClose(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	sub, messages := makePipeClient(t)
+	execCmd(e, sub, "ssubscribe", "ch")
+	nextMessage(t, messages)
+
+	e.AfterClientClose(sub)
+	assertReply(t, execCmd(e, c, "spublish", "ch", "hello"), ":0\r\n")
+	if got := renderReply(execCmd(e, c, "pubsub", "shardnumsub", "ch")); got != "[ch 0]" {
+		t.Errorf("pubsub shardnumsub: %s", got)
+	}
+}
@@ -0,0 +1,657 @@
+package database
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"go_redis/config"
+	"go_redis/interface/resp"
+	"go_redis/lib/logger"
+	"go_redis/lib/utils"
+	"go_redis/resp/connection"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// lua脚本 EVAL/EVALSHA/SCRIPT
+// 脚本编译之后按照SHA1缓存，执行时持有事务锁，执行期间不会穿插其他客户端的指令(FUNCTION的函数同样如此，见function.go)；
+// 脚本中的 redis.call/redis.pcall 通过伪客户端在调用方选择的db上执行指令，写指令各自写入aof(脚本本身不写入)，
+// 写入了数据的脚本在aof中以 MULTI ... EXEC 包裹。
+// 脚本执行超过 lua-time-limit 之后，其他客户端的指令直接返回BUSY错误，此时可以通过 SCRIPT KILL 中止没有写入过数据的脚本
+
+const defaultLuaTimeLimit = 5000 // 毫秒
+
+func init() {
+	registerCommand(&command{prepare: prepareEval, arity: -3, flags: flagNoScript | flagMayReplicate}, "eval")
+	registerCommand(&command{prepare: prepareEval, arity: -3, flags: flagNoScript | flagMayReplicate}, "evalsha")
+	registerSpecialCommand("script", -2, flagNoScript)
+}
+
+// prepareEval EVAL script numkeys key [key ...] arg [arg ...]
+func prepareEval(args [][]byte) ([]string, []string) {
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil || numKeys < 0 || numKeys > len(args)-2 {
+		return nil, nil
+	}
+	return toKeys(args[2 : 2+numKeys]), nil
+}
+
+// isScriptCommand 需要在上层执行的脚本和函数相关指令
+func isScriptCommand(cmdName string) bool {
+	switch cmdName {
+	case "eval", "evalsha", "script", "fcall", "fcall_ro", "function":
+		return true
+	}
+	return false
+}
+
+// runningScript 正在执行的脚本或函数
+type runningScript struct {
+	start    time.Time
+	cancel   context.CancelFunc
+	function bool // FCALL执行的函数只能通过 FUNCTION KILL 中止
+	wrote    bool // 已经执行过写指令的脚本不能被中止
+	killed   bool
+}
+
+type scriptEngine struct {
+	mu        sync.Mutex
+	scripts   map[string]*lua.FunctionProto // sha1 -> 编译之后的脚本
+	running   *runningScript
+	functions *functionRegistry // 调用方持有事务锁
+}
+
+func makeScriptEngine() *scriptEngine {
+	return &scriptEngine{
+		scripts:   make(map[string]*lua.FunctionProto),
+		functions: makeFunctionRegistry(),
+	}
+}
+
+// load 编译并缓存脚本，返回脚本的sha1
+func (engine *scriptEngine) load(body []byte) (string, *lua.FunctionProto, reply.ErrorReply) {
+	sha := sha1Hex(body)
+	engine.mu.Lock()
+	proto, ok := engine.scripts[sha]
+	engine.mu.Unlock()
+	if ok {
+		return sha, proto, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(string(body)), "@user_script")
+	if err == nil {
+		proto, err = lua.Compile(chunk, "@user_script")
+	}
+	if err != nil {
+		return "", nil, reply.MakeErrReply("ERR Error compiling script (new function): " + singleLine(err.Error()))
+	}
+	engine.mu.Lock()
+	engine.scripts[sha] = proto
+	engine.mu.Unlock()
+	return sha, proto, nil
+}
+
+func (engine *scriptEngine) get(sha string) (*lua.FunctionProto, bool) {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	proto, ok := engine.scripts[strings.ToLower(sha)]
+	return proto, ok
+}
+
+// busyReply 有脚本执行超过了时间限制时，除了 SCRIPT KILL/FUNCTION KILL 之外的指令都返回BUSY错误
+func (engine *scriptEngine) busyReply(cmdName string, args [][]byte) resp.Reply {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if engine.running == nil || time.Since(engine.running.start) <= luaTimeLimit() {
+		return nil
+	}
+	if (cmdName == "script" || cmdName == "function") && len(args) == 2 && strings.EqualFold(string(args[1]), "kill") {
+		return nil
+	}
+	return engine.busyErr()
+}
+
+func (engine *scriptEngine) busyErr() resp.Reply {
+	if engine.running.function {
+		return reply.MakeErrReply("BUSY Redis is busy running a function. You can only call FUNCTION KILL or SHUTDOWN NOSAVE.")
+	}
+	return reply.MakeErrReply("BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.")
+}
+
+// kill SCRIPT KILL/FUNCTION KILL，只能中止对应类型的脚本
+func (engine *scriptEngine) kill(function bool) resp.Reply {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if engine.running == nil {
+		return reply.MakeErrReply("NOTBUSY No scripts in execution right now.")
+	}
+	if engine.running.function != function {
+		return engine.busyErr()
+	}
+	if engine.running.wrote {
+		return reply.MakeErrReply("UNKILLABLE Sorry the script already executed write commands against the dataset. " +
+			"You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command.")
+	}
+	engine.running.killed = true
+	engine.running.cancel()
+	return reply.MakeOkReply()
+}
+
+func luaTimeLimit() time.Duration {
+	limit := config.Properties.LuaTimeLimit
+	if limit <= 0 {
+		limit = defaultLuaTimeLimit
+	}
+	return time.Duration(limit) * time.Millisecond
+}
+
+// singleLine 错误回复中不能包含换行
+func singleLine(msg string) string {
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+func sha1Hex(body []byte) string {
+	sum := sha1.Sum(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// execScriptCommand 执行脚本和函数相关的指令，除了 SCRIPT 和 FUNCTION KILL 之外都需要持有事务锁
+func (e *StandaloneDatabase) execScriptCommand(c resp.Connection, cmdName string, args [][]byte) resp.Reply {
+	cmd := cmdTable[cmdName]
+	if !validateArity(cmd.arity, args) {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+	if cmdName == "script" {
+		return e.execScript(args[1:])
+	}
+	if cmdName == "function" && strings.EqualFold(string(args[1]), "kill") {
+		return e.execFunction(c, args)
+	}
+	e.txLock.Lock()
+	defer e.txLock.Unlock()
+	return e.execLocked(c, cmdName, args)
+}
+
+// execLocked 执行 EVAL/EVALSHA/FCALL/FCALL_RO/FUNCTION，调用方需要持有事务锁
+func (e *StandaloneDatabase) execLocked(c resp.Connection, cmdName string, args [][]byte) resp.Reply {
+	switch cmdName {
+	case "eval", "evalsha":
+		return e.execEval(c, cmdName, args[1:])
+	case "fcall", "fcall_ro":
+		return e.execFCall(c, cmdName == "fcall_ro", args[1:])
+	case "function":
+		return e.execFunction(c, args)
+	}
+	return e.execScript(args[1:])
+}
+
+// execScript SCRIPT LOAD|EXISTS|FLUSH|KILL
+func (e *StandaloneDatabase) execScript(args [][]byte) resp.Reply {
+	switch strings.ToLower(string(args[0])) {
+	case "load":
+		if len(args) != 2 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'load'. Try SCRIPT HELP.")
+		}
+		sha, _, errReply := e.scripts.load(args[1])
+		if errReply != nil {
+			return errReply
+		}
+		return reply.MakeBulkReply([]byte(sha))
+	case "exists":
+		if len(args) < 2 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'exists'. Try SCRIPT HELP.")
+		}
+		result := make([]resp.Reply, len(args)-1)
+		for i, sha := range args[1:] {
+			if _, ok := e.scripts.get(string(sha)); ok {
+				result[i] = reply.MakeIntReply(1)
+			} else {
+				result[i] = reply.MakeIntReply(0)
+			}
+		}
+		return reply.MakeMultiRawReply(result)
+	case "flush":
+		if len(args) > 2 || (len(args) == 2 && !strings.EqualFold(string(args[1]), "sync") &&
+			!strings.EqualFold(string(args[1]), "async")) {
+			return reply.MakeErrReply("ERR SCRIPT FLUSH only support SYNC|ASYNC option")
+		}
+		e.scripts.mu.Lock()
+		e.scripts.scripts = make(map[string]*lua.FunctionProto)
+		e.scripts.mu.Unlock()
+		return reply.MakeOkReply()
+	case "kill":
+		if len(args) != 1 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'kill'. Try SCRIPT HELP.")
+		}
+		return e.scripts.kill(false)
+	default:
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try SCRIPT HELP.")
+	}
+}
+
+// execEval EVAL script numkeys key [key ...] arg [arg ...]
+// EVALSHA sha1 numkeys key [key ...] arg [arg ...]
+// 调用方需要持有事务锁
+func (e *StandaloneDatabase) execEval(c resp.Connection, cmdName string, args [][]byte) resp.Reply {
+	numKeys, errReply := parseNumKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	var sha string
+	var proto *lua.FunctionProto
+	if cmdName == "eval" {
+		var errReply reply.ErrorReply
+		sha, proto, errReply = e.scripts.load(args[0])
+		if errReply != nil {
+			return errReply
+		}
+	} else {
+		var ok bool
+		sha = strings.ToLower(string(args[0]))
+		proto, ok = e.scripts.get(sha)
+		if !ok {
+			return reply.MakeErrReply("NOSCRIPT No matching script. Please use EVAL.")
+		}
+	}
+	return e.runScript(c, sha, proto, args[2:2+numKeys], args[2+numKeys:])
+}
+
+// parseNumKeys 解析 EVAL/FCALL 的 numkeys 参数
+func parseNumKeys(args [][]byte) (int, reply.ErrorReply) {
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys < 0 {
+		return 0, reply.MakeErrReply("ERR Number of keys can't be negative")
+	}
+	if numKeys > len(args)-2 {
+		return 0, reply.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	return numKeys, nil
+}
+
+// runScript 在新的lua虚拟机中执行脚本，KEYS和ARGV为全局变量
+func (e *StandaloneDatabase) runScript(c resp.Connection, sha string, proto *lua.FunctionProto,
+	keys [][]byte, argv [][]byte) resp.Reply {
+	client := e.makeScriptClient(c, false)
+	L := newScriptState(func() *scriptClient { return client })
+	defer L.Close()
+	L.SetGlobal("KEYS", bytesToTable(L, keys))
+	L.SetGlobal("ARGV", bytesToTable(L, argv))
+	return e.callLua(client, L, L.NewFunctionFromProto(proto), nil, "f_"+sha, false)
+}
+
+// callLua 执行lua函数，执行期间登记为正在执行的脚本(可以被KILL)，结束之后结束aof中的事务并唤醒阻塞的客户端
+// name 为出错时提示的函数名，function 表示是否为FCALL执行的函数
+func (e *StandaloneDatabase) callLua(client *scriptClient, L *lua.LState, fn lua.LValue, args []lua.LValue,
+	name string, function bool) resp.Reply {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	running := &runningScript{start: time.Now(), cancel: cancel, function: function}
+	client.running = running
+	e.scripts.mu.Lock()
+	e.scripts.running = running
+	e.scripts.mu.Unlock()
+
+	L.SetContext(ctx)
+	L.Push(fn)
+	for _, arg := range args {
+		L.Push(arg)
+	}
+	err := L.PCall(len(args), 1, nil)
+	L.RemoveContext()
+
+	e.scripts.mu.Lock()
+	e.scripts.running = nil
+	killed := running.killed
+	e.scripts.mu.Unlock()
+	client.finish()
+
+	if killed {
+		if function {
+			return reply.MakeErrReply("ERR Script killed by user with FUNCTION KILL...")
+		}
+		return reply.MakeErrReply("ERR Script killed by user with SCRIPT KILL...")
+	}
+	if err != nil {
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			if errReply := tableToErrReply(apiErr.Object); errReply != nil { // redis.call 返回的错误
+				return errReply
+			}
+			return reply.MakeErrReply("ERR Error running script (call to " + name + "): " + singleLine(apiErr.Object.String()))
+		}
+		return reply.MakeErrReply("ERR Error running script (call to " + name + "): " + singleLine(err.Error()))
+	}
+	result := L.Get(-1)
+	L.Pop(1)
+	return luaToReply(result)
+}
+
+// scriptClient 脚本执行指令使用的伪客户端，和redis的lua client一样
+type scriptClient struct {
+	*connection.Connection
+	caller   resp.Connection // 调用脚本的客户端
+	database *StandaloneDatabase
+	running  *runningScript
+	readOnly bool // FCALL_RO 和带有 no-writes 标志的函数不能执行写指令
+	wrapAof  bool
+	inTx     bool             // 已经在aof中写入了MULTI
+	signals  map[*DB][]string // 脚本执行期间写入的key，脚本结束之后再唤醒阻塞的客户端
+}
+
+func (e *StandaloneDatabase) makeScriptClient(c resp.Connection, readOnly bool) *scriptClient {
+	client := &scriptClient{
+		Connection: &connection.Connection{},
+		caller:     c,
+		database:   e,
+		readOnly:   readOnly,
+		wrapAof:    !c.InMultiState(), // EXEC中的脚本已经被事务包裹
+	}
+	client.SelectDB(c.GetDBIndex())
+	return client
+}
+
+// call 执行脚本中的指令
+func (client *scriptClient) call(cmdLine [][]byte) resp.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return reply.MakeErrReply("ERR Unknown Redis command called from script")
+	}
+	if cmd.flags&flagNoScript > 0 {
+		return reply.MakeErrReply("ERR This Redis command is not allowed from script")
+	}
+	if cmdName == "select" {
+		if !validateArity(cmd.arity, cmdLine) {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		return execSelect(client, client.database, cmdLine[1:])
+	}
+	if isPubSubCommand(cmdName) { // PUBLISH/PUBSUB
+		return client.database.execPubSub(client, cmdName, cmdLine)
+	}
+	if cmd.flags&flagWrite > 0 {
+		if client.readOnly {
+			return reply.MakeErrReply("ERR Write commands are not allowed from read-only scripts.")
+		}
+		client.database.scripts.mu.Lock()
+		client.running.wrote = true
+		client.database.scripts.mu.Unlock()
+		if client.wrapAof && !client.inTx && client.database.aofHandler != nil {
+			client.database.aofHandler.AddAof(client.GetDBIndex(), utils.ToCmdLine("multi"))
+			client.inTx = true
+		}
+	}
+	return client.database.dbSet[client.GetDBIndex()].Exec(client, cmdLine)
+}
+
+// deferSignal 记录写入的key，脚本结束之后再唤醒阻塞在这些key上的客户端，保证脚本的原子性
+func (client *scriptClient) deferSignal(db *DB, keys []string) {
+	if client.signals == nil {
+		client.signals = make(map[*DB][]string)
+	}
+	client.signals[db] = append(client.signals[db], keys...)
+}
+
+// finish 脚本结束之后结束aof中的事务，唤醒阻塞的客户端
+func (client *scriptClient) finish() {
+	if client.inTx {
+		client.database.aofHandler.AddAof(client.GetDBIndex(), utils.ToCmdLine("exec"))
+	}
+	for db, keys := range client.signals {
+		if client.caller.InMultiState() { // EXEC中执行的脚本，等事务结束之后再唤醒
+			db.blocking.deferSignal(keys)
+		} else {
+			db.blocking.signal(keys)
+		}
+	}
+}
+
+// newScriptState 创建lua虚拟机，只开放基础库、table、string、math，并注册redis库
+// current 返回当前执行指令使用的伪客户端，FUNCTION的库加载之后会被多次调用，每次调用的伪客户端不同
+func newScriptState(current func() *scriptClient) *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// 脚本不能访问文件
+	for _, name := range []string{"dofile", "loadfile"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	redis := L.NewTable()
+	L.SetFuncs(redis, map[string]lua.LGFunction{
+		"call": func(L *lua.LState) int {
+			return redisCall(L, current(), true)
+		},
+		"pcall": func(L *lua.LState) int {
+			return redisCall(L, current(), false)
+		},
+		"error_reply": func(L *lua.LState) int {
+			L.Push(statusTable(L, "err", L.CheckString(1)))
+			return 1
+		},
+		"status_reply": func(L *lua.LState) int {
+			L.Push(statusTable(L, "ok", L.CheckString(1)))
+			return 1
+		},
+		"sha1hex": func(L *lua.LState) int {
+			L.Push(lua.LString(sha1Hex([]byte(L.CheckString(1)))))
+			return 1
+		},
+		"log": func(L *lua.LState) int {
+			level := L.CheckInt(1)
+			msgs := make([]string, 0, L.GetTop()-1)
+			for i := 2; i <= L.GetTop(); i++ {
+				msgs = append(msgs, L.Get(i).String())
+			}
+			if level >= luaLogWarning {
+				logger.Warn(strings.Join(msgs, " "))
+			} else {
+				logger.Info(strings.Join(msgs, " "))
+			}
+			return 0
+		},
+	})
+	redis.RawSetString("LOG_DEBUG", lua.LNumber(luaLogDebug))
+	redis.RawSetString("LOG_VERBOSE", lua.LNumber(luaLogVerbose))
+	redis.RawSetString("LOG_NOTICE", lua.LNumber(luaLogNotice))
+	redis.RawSetString("LOG_WARNING", lua.LNumber(luaLogWarning))
+	L.SetGlobal("redis", redis)
+	return L
+}
+
+// redis.log 的日志级别
+const (
+	luaLogDebug = iota
+	luaLogVerbose
+	luaLogNotice
+	luaLogWarning
+)
+
+// redisCall redis.call/redis.pcall   redis.call 遇到错误时抛出异常，redis.pcall 返回 {err=...}
+func redisCall(L *lua.LState, client *scriptClient, raise bool) int {
+	var result resp.Reply
+	top := L.GetTop()
+	if client == nil { // 加载FUNCTION的库时不能执行指令
+		result = reply.MakeErrReply("ERR redis.call can only be called inside a script invocation")
+	} else if top == 0 {
+		result = reply.MakeErrReply("ERR Please specify at least one argument for this redis lib call")
+	} else {
+		cmdLine := make([][]byte, 0, top)
+		for i := 1; i <= top; i++ {
+			switch arg := L.Get(i).(type) {
+			case lua.LString:
+				cmdLine = append(cmdLine, []byte(arg))
+			case lua.LNumber:
+				cmdLine = append(cmdLine, []byte(arg.String()))
+			default:
+				result = reply.MakeErrReply("ERR Lua redis lib command arguments must be strings or integers")
+			}
+			if result != nil {
+				break
+			}
+		}
+		if result == nil {
+			result = client.call(cmdLine)
+		}
+	}
+	value := replyToLua(L, result)
+	if raise && reply.IsErrReply(result) {
+		L.Error(value, 0)
+		return 0
+	}
+	L.Push(value)
+	return 1
+}
+
+// ---------------------- 类型转换 ----------------------
+
+func bytesToTable(L *lua.LState, args [][]byte) *lua.LTable {
+	table := L.CreateTable(len(args), 0)
+	for _, arg := range args {
+		table.Append(lua.LString(arg))
+	}
+	return table
+}
+
+// statusTable {ok=status} 或者 {err=status}
+func statusTable(L *lua.LState, field string, status string) *lua.LTable {
+	table := L.NewTable()
+	table.RawSetString(field, lua.LString(status))
+	return table
+}
+
+// replyToLua 指令的回复转换为lua的值：整数->number，字符串->string，空值->false，数组->table，
+// 状态->{ok=...}，错误->{err=...}
+func replyToLua(L *lua.LState, r resp.Reply) lua.LValue {
+	switch r := r.(type) {
+	case *reply.IntReply:
+		return lua.LNumber(r.Code)
+	case *reply.BulkReply:
+		if r.Arg == nil {
+			return lua.LFalse
+		}
+		return lua.LString(r.Arg)
+	case *reply.MultiBulkReply:
+		table := L.CreateTable(len(r.Args), 0)
+		for _, arg := range r.Args {
+			if arg == nil {
+				table.Append(lua.LFalse)
+			} else {
+				table.Append(lua.LString(arg))
+			}
+		}
+		return table
+	case *reply.MultiRawReply:
+		table := L.CreateTable(len(r.Replies), 0)
+		for _, re := range r.Replies {
+			table.Append(replyToLua(L, re))
+		}
+		return table
+	case *reply.EmptyMultiBulkReply:
+		return L.NewTable()
+	// 脚本使用RESP2，RESP3的回复转换为对应的RESP2类型：map/pair array->扁平的数组，set->数组，double->字符串，boolean->整数
+	case *reply.MapReply:
+		table := L.CreateTable(len(r.Pairs), 0)
+		for _, re := range r.Pairs {
+			table.Append(replyToLua(L, re))
+		}
+		return table
+	case *reply.PairArrayReply:
+		table := L.CreateTable(len(r.Pairs), 0)
+		for _, re := range r.Pairs {
+			table.Append(replyToLua(L, re))
+		}
+		return table
+	case *reply.SetReply:
+		table := L.CreateTable(len(r.Members), 0)
+		for _, re := range r.Members {
+			table.Append(replyToLua(L, re))
+		}
+		return table
+	case *reply.DoubleReply:
+		return lua.LString(formatScore(r.Value))
+	case *reply.BooleanReply:
+		if r.Value {
+			return lua.LNumber(1)
+		}
+		return lua.LNumber(0)
+	case *reply.VerbatimReply:
+		return lua.LString(r.Text)
+	case *reply.NullBulkReply, *reply.NullMultiBulkReply:
+		return lua.LFalse
+	case *reply.StatusReply:
+		return statusTable(L, "ok", r.Status)
+	case reply.ErrorReply:
+		return statusTable(L, "err", r.Error())
+	}
+	// OK/PONG 等固定的状态回复
+	raw := r.ToBytes()
+	if len(raw) > 0 && raw[0] == '+' {
+		return statusTable(L, "ok", strings.TrimSuffix(string(raw[1:]), reply.CRLF))
+	}
+	return lua.LFalse
+}
+
+// luaToReply 脚本的返回值转换为回复：number->整数(去掉小数部分)，string->字符串，true->1，false/nil->空值，
+// {ok=...}->状态，{err=...}->错误，table->数组(到第一个nil为止)
+func luaToReply(value lua.LValue) resp.Reply {
+	switch value := value.(type) {
+	case lua.LNumber:
+		return reply.MakeIntReply(int64(value))
+	case lua.LString:
+		return reply.MakeBulkReply([]byte(value))
+	case lua.LBool:
+		if value {
+			return reply.MakeIntReply(1)
+		}
+		return reply.MakeNullBulkReply()
+	case *lua.LTable:
+		if errReply := tableToErrReply(value); errReply != nil {
+			return errReply
+		}
+		if status, ok := value.RawGetString("ok").(lua.LString); ok {
+			return reply.MakeStatusReply(string(status))
+		}
+		replies := make([]resp.Reply, 0, value.Len())
+		for i := 1; ; i++ {
+			item := value.RawGetInt(i)
+			if item == lua.LNil {
+				break
+			}
+			replies = append(replies, luaToReply(item))
+		}
+		return reply.MakeMultiRawReply(replies)
+	}
+	return reply.MakeNullBulkReply()
+}
+
+// tableToErrReply {err=...} 转换为错误回复，不是错误时返回nil
+func tableToErrReply(value lua.LValue) reply.ErrorReply {
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil
+	}
+	msg, ok := table.RawGetString("err").(lua.LString)
+	if !ok {
+		return nil
+	}
+	return reply.MakeErrReply(string(msg))
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"bytes"
+	"go_redis/config"
+	"go_redis/interface/resp"
+	"go_redis/resp/connection"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"eval", "return {KEYS[1], ARGV[1], 3, true, false}", "1", "k", "v"}, "[k v 3 1 nil]"},
+		{[]string{"eval", "return redis.call('set', KEYS[1], ARGV[1])", "1", "k", "v"}, "OK"},
+		{[]string{"eval", "return redis.call('get', KEYS[1])", "1", "k"}, "v"},
+		{[]string{"eval", "return redis.call('get', 'missing')", "0"}, "nil"},
+		{[]string{"eval", "return 1.9", "0"}, "1"}, // 数字去掉小数部分
+		{[]string{"eval", "return redis.status_reply('DONE')", "0"}, "DONE"},
+		{[]string{"eval", "return redis.error_reply('MY failure')", "0"}, "MY failure"},
+		// redis.call 遇到错误时中止脚本，redis.pcall 将错误作为返回值
+		{[]string{"eval", "redis.call('incr', KEYS[1]); return 1", "1", "k"}, "ERR value is not an integer or out of range"},
+		{[]string{"eval", "local r = redis.pcall('incr', KEYS[1]); return r['err']", "1", "k"}, "ERR value is not an integer or out of range"},
+		{[]string{"eval", "return redis.call('multi')", "0"}, "ERR This Redis command is not allowed from script"},
+		{[]string{"eval", "return redis.call('nosuch')", "0"}, "ERR Unknown Redis command called from script"},
+		{[]string{"eval", "return 1", "-1"}, "ERR Number of keys can't be negative"},
+		{[]string{"eval", "return 1", "2", "k"}, "ERR Number of keys can't be greater than number of args"},
+	}
+	for _, tt := range tests {
+		if got := renderReply(execCmd(e, c, tt.args...)); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	if got := renderReply(execCmd(e, c, "eval", "return (", "0")); !strings.HasPrefix(got, "ERR Error compiling script") {
+		t.Errorf("compile error: %q", got)
+	}
+}
+
+// 脚本中的指令在调用方选择的db上执行
+func TestEvalSelectedDB(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "select", "1")
+	execCmd(e, c, "eval", "redis.call('set', KEYS[1], 'v'); redis.call('select', 2); redis.call('set', KEYS[1], 'w')", "1", "k")
+	assertReply(t, execCmd(e, c, "get", "k"), "$1\r\nv\r\n") // 脚本中的SELECT不影响调用方
+	execCmd(e, c, "select", "2")
+	assertReply(t, execCmd(e, c, "get", "k"), "$1\r\nw\r\n")
+	execCmd(e, c, "select", "0")
+	assertReply(t, execCmd(e, c, "exists", "k"), ":0\r\n")
+}
+
+func TestScriptCache(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	const body = "return ARGV[1]"
+	sha := sha1Hex([]byte(body))
+	assertReply(t, execCmd(e, c, "evalsha", sha, "0", "x"), "-NOSCRIPT No matching script. Please use EVAL.\r\n")
+	assertReply(t, execCmd(e, c, "script", "load", body), "$40\r\n"+sha+"\r\n")
+	assertReply(t, execCmd(e, c, "evalsha", strings.ToUpper(sha), "0", "x"), "$1\r\nx\r\n")
+	if got := renderReply(execCmd(e, c, "script", "exists", sha, "0000")); got != "[1 0]" {
+		t.Errorf("script exists: %s", got)
+	}
+	assertReply(t, execCmd(e, c, "script", "flush"), "+OK\r\n")
+	if got := renderReply(execCmd(e, c, "script", "exists", sha)); got != "[0]" {
+		t.Errorf("script exists after flush: %s", got)
+	}
+	// EVAL 同样会缓存脚本
+	execCmd(e, c, "eval", body, "0", "y")
+	assertReply(t, execCmd(e, c, "evalsha", sha, "0", "z"), "$1\r\nz\r\n")
+}
+
+// 脚本本身不写入aof，写入的是脚本中执行的写指令(以及它们改写之后的形式)
+func TestEvalAof(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	var aof []string
+	e.dbSet[0].writeAof = func(line CmdLine) {
+		aof = append(aof, string(bytes.Join(line, []byte(" "))))
+	}
+	execCmd(e, c, "eval", "redis.call('set', KEYS[1], '1'); redis.call('incrbyfloat', KEYS[1], '0.5'); "+
+		"return redis.call('get', KEYS[1])", "1", "k")
+	want := []string{"set k 1", "set k 1.5 KEEPTTL"}
+	if strings.Join(aof, "|") != strings.Join(want, "|") {
+		t.Errorf("aof = %q, want %q", aof, want)
+	}
+}
+
+// 超过时间限制的脚本：其他客户端的指令返回BUSY，SCRIPT KILL 可以中止没有写入过数据的脚本
+func TestScriptKill(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	limit := config.Properties.LuaTimeLimit
+	config.Properties.LuaTimeLimit = 20
+	t.Cleanup(func() { config.Properties.LuaTimeLimit = limit })
+
+	assertReply(t, execCmd(e, c, "script", "kill"), "-NOTBUSY No scripts in execution right now.\r\n")
+	done := make(chan resp.Reply, 1)
+	go func() {
+		done <- execCmd(e, &connection.Connection{}, "eval", "while true do end", "0")
+	}()
+	// 超过时间限制之前其他客户端的指令会等待脚本结束
+	deadline := time.Now().Add(time.Second)
+	for {
+		e.scripts.mu.Lock()
+		running := e.scripts.running
+		e.scripts.mu.Unlock()
+		if running != nil && time.Since(running.start) > 2*luaTimeLimit() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("script is not running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	other := &connection.Connection{}
+	assertReply(t, execCmd(e, other, "ping"),
+		"-BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.\r\n")
+	assertReply(t, execCmd(e, other, "function", "kill"),
+		"-BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.\r\n")
+	assertReply(t, execCmd(e, other, "script", "kill"), "+OK\r\n")
+	select {
+	case r := <-done:
+		assertReply(t, r, "-ERR Script killed by user with SCRIPT KILL...\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("script is not killed")
+	}
+	assertReply(t, execCmd(e, other, "ping"), "+PONG\r\n")
+}
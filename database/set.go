@@ -0,0 +1,369 @@
+package database
+
+import (
+	"go_redis/datastruct/set"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"math"
+	"strconv"
+)
+
+// set类型 的常用指令
+
+// getAsSet 取出key对应的set，key不存在返回nil，类型不是set返回WRONGTYPE错误
+func (db *DB) getAsSet(key string) (*set.Set, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*set.Set)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return s, nil
+}
+
+// getOrInitSet 取出key对应的set，不存在则创建
+func (db *DB) getOrInitSet(key string) (*set.Set, reply.ErrorReply) {
+	s, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if s == nil {
+		s = set.MakeSet()
+		db.PutEntity(key, &database.DataEntity{
+			Data: s,
+		})
+	}
+	return s, nil
+}
+
+func setToReply(s *set.Set) resp.Reply {
+	members := s.Members()
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		result[i] = []byte(member)
+	}
+	return reply.MakeBulkSetReply(result)
+}
+
+// SADD K m1 m2 ...   返回新增的成员个数
+func execSAdd(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getOrInitSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for _, member := range args[1:] {
+		added += s.Add(string(member))
+	}
+	if added > 0 {
+		db.addAof(utils.ToCmdLine3("sadd", args...))
+		db.notify(notifySet, "sadd", string(args[0]))
+	}
+	return reply.MakeIntReply(int64(added))
+}
+
+// SREM K m1 m2 ...
+func execSRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	s, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		removed += s.Remove(string(member))
+	}
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine3("srem", args...))
+		db.notify(notifySet, "srem", key)
+	}
+	if s.Len() == 0 { // set为空时删除该key
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(int64(removed))
+}
+
+// SISMEMBER K m
+func execSIsMember(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s.Has(string(args[1])) {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// SMISMEMBER K m1 m2 ...
+func execSMIsMember(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		if s.Has(string(member)) {
+			result[i] = reply.MakeIntReply(1)
+		} else {
+			result[i] = reply.MakeIntReply(0)
+		}
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// SMEMBERS K
+func execSMembers(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeBulkSetReply(nil)
+	}
+	return setToReply(s)
+}
+
+// SCARD K
+func execSCard(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeIntReply(int64(s.Len()))
+}
+
+// getSets 取出多个key对应的set，不存在的key对应nil(空集合)
+func (db *DB) getSets(keys [][]byte) ([]*set.Set, reply.ErrorReply) {
+	sets := make([]*set.Set, len(keys))
+	for i, key := range keys {
+		s, errReply := db.getAsSet(string(key))
+		if errReply != nil {
+			return nil, errReply
+		}
+		sets[i] = s
+	}
+	return sets, nil
+}
+
+// setAlgebra 集合运算的公共逻辑
+func setAlgebra(db *DB, keys [][]byte, op func(sets ...*set.Set) *set.Set) (*set.Set, reply.ErrorReply) {
+	sets, errReply := db.getSets(keys)
+	if errReply != nil {
+		return nil, errReply
+	}
+	return op(sets...), nil
+}
+
+// storeSet 将集合运算的结果写入dest，结果为空时删除dest
+func storeSet(db *DB, cmdName string, args [][]byte, op func(sets ...*set.Set) *set.Set) resp.Reply {
+	dest := string(args[0])
+	result, errReply := setAlgebra(db, args[1:], op)
+	if errReply != nil {
+		return errReply
+	}
+	if result.Len() == 0 {
+		if _, exists := db.GetEntity(dest); exists {
+			db.Remove(dest)
+			db.notify(notifyGeneric, "del", dest)
+		}
+	} else {
+		db.PutEntity(dest, &database.DataEntity{
+			Data: result,
+		})
+		db.Persist(dest) // 覆盖dest时，dest原先的过期时间失效
+		db.notify(notifySet, cmdName, dest)
+	}
+	db.addAof(utils.ToCmdLine3(cmdName, args...))
+	return reply.MakeIntReply(int64(result.Len()))
+}
+
+// SINTER K1 K2 ...
+func execSInter(db *DB, args [][]byte) resp.Reply {
+	result, errReply := setAlgebra(db, args, set.Intersect)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(result)
+}
+
+// SUNION K1 K2 ...
+func execSUnion(db *DB, args [][]byte) resp.Reply {
+	result, errReply := setAlgebra(db, args, set.Union)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(result)
+}
+
+// SDIFF K1 K2 ...
+func execSDiff(db *DB, args [][]byte) resp.Reply {
+	result, errReply := setAlgebra(db, args, set.Diff)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(result)
+}
+
+// SINTERSTORE dest K1 K2 ...
+func execSInterStore(db *DB, args [][]byte) resp.Reply {
+	return storeSet(db, "sinterstore", args, set.Intersect)
+}
+
+// SUNIONSTORE dest K1 K2 ...
+func execSUnionStore(db *DB, args [][]byte) resp.Reply {
+	return storeSet(db, "sunionstore", args, set.Union)
+}
+
+// SDIFFSTORE dest K1 K2 ...
+func execSDiffStore(db *DB, args [][]byte) resp.Reply {
+	return storeSet(db, "sdiffstore", args, set.Diff)
+}
+
+// SPOP K [count]   随机删除成员，aof中记录为SREM被删除的成员，保证重放的结果一致
+func execSPop(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return reply.MakeArgNumErrReply("spop")
+	}
+	key := string(args[0])
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil || n < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+	s, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if withCount {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	members := s.RandomDistinctMembers(count)
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		s.Remove(member)
+		result[i] = []byte(member)
+	}
+	if len(result) > 0 {
+		db.addAof(utils.ToCmdLine3("srem", append([][]byte{args[0]}, result...)...))
+		db.notify(notifySet, "spop", key)
+	}
+	if s.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+	if !withCount {
+		return reply.MakeBulkReply(result[0])
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// SRANDMEMBER K [count]   count为正数时不重复，为负数时可以重复
+func execSRandMember(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return reply.MakeArgNumErrReply("srandmember")
+	}
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if n == math.MinInt64 { // 取反之后溢出
+			return reply.MakeErrReply("ERR value is out of range")
+		}
+		count = n
+	}
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if withCount {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	if !withCount {
+		return reply.MakeBulkReply([]byte(s.RandomMembers(1)[0]))
+	}
+	var members []string
+	if count >= 0 {
+		members = s.RandomDistinctMembers(count)
+	} else {
+		members = s.RandomMembers(-count)
+	}
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		result[i] = []byte(member)
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// SMOVE src dest m   将成员从src移动到dest
+func execSMove(db *DB, args [][]byte) resp.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	member := string(args[2])
+	srcSet, errReply := db.getAsSet(src)
+	if errReply != nil {
+		return errReply
+	}
+	destSet, errReply := db.getAsSet(dest)
+	if errReply != nil {
+		return errReply
+	}
+	if !srcSet.Has(member) {
+		return reply.MakeIntReply(0)
+	}
+	if src == dest { // 源和目标相同，不需要移动
+		return reply.MakeIntReply(1)
+	}
+	srcSet.Remove(member)
+	db.notify(notifySet, "srem", src)
+	if srcSet.Len() == 0 {
+		db.Remove(src)
+		db.notify(notifyGeneric, "del", src)
+	}
+	if destSet == nil {
+		destSet, _ = db.getOrInitSet(dest)
+	}
+	destSet.Add(member)
+	db.addAof(utils.ToCmdLine3("smove", args...))
+	db.notify(notifySet, "sadd", dest)
+	return reply.MakeIntReply(1)
+}
+
+func init() {
+	RegisterCommand("sadd", execSAdd, nil, -3, flagWrite|flagFast, 1, 1, 1) // SADD K m1 m2 ...
+	RegisterCommand("srem", execSRem, nil, -3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("sismember", execSIsMember, nil, 3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("smismember", execSMIsMember, nil, -3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("smembers", execSMembers, nil, 2, flagReadOnly, 1, 1, 1)
+	RegisterCommand("scard", execSCard, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("sinter", execSInter, nil, -2, flagReadOnly, 1, -1, 1) // SINTER K1 K2 ...
+	RegisterCommand("sunion", execSUnion, nil, -2, flagReadOnly, 1, -1, 1)
+	RegisterCommand("sdiff", execSDiff, nil, -2, flagReadOnly, 1, -1, 1)
+	RegisterCommand("sinterstore", execSInterStore, writeFirstReadOthers, -3, flagWrite, 1, -1, 1) // SINTERSTORE dest K1 K2 ...
+	RegisterCommand("sunionstore", execSUnionStore, writeFirstReadOthers, -3, flagWrite, 1, -1, 1)
+	RegisterCommand("sdiffstore", execSDiffStore, writeFirstReadOthers, -3, flagWrite, 1, -1, 1)
+	RegisterCommand("spop", execSPop, nil, -2, flagWrite|flagFast, 1, 1, 1) // SPOP K [count]
+	RegisterCommand("srandmember", execSRandMember, nil, -2, flagReadOnly, 1, 1, 1)
+	RegisterCommand("smove", execSMove, nil, 4, flagWrite|flagFast, 1, 2, 1) // SMOVE src dest m
+}
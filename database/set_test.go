@@ -0,0 +1,38 @@
+package database
+
+import (
+	"go_redis/resp/reply"
+	"testing"
+)
+
+func TestSRandMemberCount(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "sadd", "s", "a", "b", "c")
+
+	assertReply(t, execCmd(e, c, "srandmember", "s", "-9223372036854775808"), "-ERR value is out of range\r\n")
+	assertReply(t, execCmd(e, c, "srandmember", "s", "abc"), "-ERR value is not an integer or out of range\r\n")
+	assertReply(t, execCmd(e, c, "srandmember", "missing", "-5"), "*0\r\n")
+	assertReply(t, execCmd(e, c, "srandmember", "missing"), "$-1\r\n")
+
+	// 正数：不重复，最多返回全部成员
+	r, ok := execCmd(e, c, "srandmember", "s", "10").(*reply.MultiBulkReply)
+	if !ok || len(r.Args) != 3 {
+		t.Fatalf("positive count returned %v", r)
+	}
+	seen := make(map[string]bool)
+	for _, arg := range r.Args {
+		if seen[string(arg)] {
+			t.Errorf("duplicate member %s", arg)
+		}
+		seen[string(arg)] = true
+	}
+
+	// 负数：可以重复，返回的个数和count一致
+	r, ok = execCmd(e, c, "srandmember", "s", "-10").(*reply.MultiBulkReply)
+	if !ok || len(r.Args) != 10 {
+		t.Fatalf("negative count returned %v", r)
+	}
+	for _, arg := range r.Args {
+		assertReply(t, execCmd(e, c, "sismember", "s", string(arg)), ":1\r\n")
+	}
+}
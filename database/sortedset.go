@@ -0,0 +1,765 @@
+package database
+
+import (
+	"go_redis/datastruct/set"
+	"go_redis/datastruct/sortedset"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// zset(有序集合)类型 的常用指令
+
+// getAsSortedSet 取出key对应的有序集合，key不存在返回nil，类型不是zset返回WRONGTYPE错误
+func (db *DB) getAsSortedSet(key string) (*sortedset.SortedSet, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	sortedSet, ok := entity.Data.(*sortedset.SortedSet)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return sortedSet, nil
+}
+
+// getOrInitSortedSet 取出key对应的有序集合，不存在则创建
+func (db *DB) getOrInitSortedSet(key string) (*sortedset.SortedSet, reply.ErrorReply) {
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if sortedSet == nil {
+		sortedSet = sortedset.MakeSortedSet()
+		db.PutEntity(key, &database.DataEntity{
+			Data: sortedSet,
+		})
+	}
+	return sortedSet, nil
+}
+
+// formatScore 将score转化为字符串，和redis的格式保持一致
+func formatScore(score float64) []byte {
+	if math.IsInf(score, 1) {
+		return []byte("inf")
+	}
+	if math.IsInf(score, -1) {
+		return []byte("-inf")
+	}
+	return []byte(strconv.FormatFloat(score, 'f', -1, 64))
+}
+
+// parseScore 解析score，支持 inf/+inf/-inf
+func parseScore(arg []byte) (float64, bool) {
+	score, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false
+	}
+	return score, true
+}
+
+// elementsToReply WITHSCORES 时 RESP2 为扁平的 member score 列表，RESP3 为 [member, double] 组成的数组
+func elementsToReply(elements []*sortedset.Element, withScores bool) resp.Reply {
+	if !withScores {
+		result := make([][]byte, len(elements))
+		for i, element := range elements {
+			result[i] = []byte(element.Member)
+		}
+		return reply.MakeMultiBulkReply(result)
+	}
+	pairs := make([]resp.Reply, 0, 2*len(elements))
+	for _, element := range elements {
+		pairs = append(pairs, reply.MakeBulkReply([]byte(element.Member)), reply.MakeDoubleReply(element.Score))
+	}
+	return reply.MakePairArrayReply(pairs)
+}
+
+// ZADD K [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func execZAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+parseFlags:
+	for ; i < len(args); i++ {
+		flag := strings.ToUpper(string(args[i]))
+		switch flag {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break parseFlags // 第一个不是选项的参数，后面都是 score member
+		}
+	}
+	if nx && xx {
+		return reply.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		return reply.MakeErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return reply.MakeSyntaxErrReply()
+	}
+	if incr && len(pairs) != 2 {
+		return reply.MakeErrReply("ERR INCR option supports a single increment-element pair")
+	}
+	elements := make([]*sortedset.Element, len(pairs)/2)
+	for j := 0; j < len(pairs)/2; j++ {
+		score, ok := parseScore(pairs[2*j])
+		if !ok {
+			return reply.MakeErrReply("ERR value is not a valid float")
+		}
+		elements[j] = &sortedset.Element{
+			Member: string(pairs[2*j+1]),
+			Score:  score,
+		}
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		if xx { // XX 只更新已经存在的member，key不存在时什么都不做
+			if incr {
+				return reply.MakeNullBulkReply()
+			}
+			return reply.MakeIntReply(0)
+		}
+		sortedSet, _ = db.getOrInitSortedSet(key)
+	}
+
+	added, changed := 0, 0
+	aofLine := [][]byte{[]byte("zadd"), args[0]} // aof中只记录真正发生变化的member及其最终的score
+	var incrResult *float64
+	for _, element := range elements {
+		old, exists := sortedSet.Get(element.Member)
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		score := element.Score
+		if incr && exists {
+			score += old.Score
+			if math.IsNaN(score) {
+				return reply.MakeErrReply("ERR resulting score is not a number (NaN)")
+			}
+		}
+		if exists && ((gt && score <= old.Score) || (lt && score >= old.Score)) {
+			continue
+		}
+		if exists && score == old.Score {
+			if incr {
+				incrResult = &score
+			}
+			continue
+		}
+		sortedSet.Add(element.Member, score)
+		if exists {
+			changed++
+		} else {
+			added++
+		}
+		if incr {
+			incrResult = &score
+		}
+		aofLine = append(aofLine, formatScore(score), []byte(element.Member))
+	}
+	if sortedSet.Len() == 0 { // 没有插入任何元素，不保留空的key
+		db.Remove(key)
+	}
+	if len(aofLine) > 2 {
+		db.addAof(aofLine)
+		event := "zadd"
+		if incr {
+			event = "zincr"
+		}
+		db.notify(notifyZSet, event, key)
+	}
+	if incr {
+		if incrResult == nil {
+			return reply.MakeNullBulkReply()
+		}
+		return reply.MakeDoubleReply(*incrResult)
+	}
+	if ch {
+		return reply.MakeIntReply(int64(added + changed))
+	}
+	return reply.MakeIntReply(int64(added))
+}
+
+// ZINCRBY K increment member   aof中记录为ZADD最终的score
+func execZIncrBy(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	delta, ok := parseScore(args[1])
+	if !ok {
+		return reply.MakeErrReply("ERR value is not a valid float")
+	}
+	member := string(args[2])
+	sortedSet, errReply := db.getOrInitSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	score := delta
+	if element, exists := sortedSet.Get(member); exists {
+		score += element.Score
+		if math.IsNaN(score) {
+			return reply.MakeErrReply("ERR resulting score is not a number (NaN)")
+		}
+	}
+	sortedSet.Add(member, score)
+	db.addAof(utils.ToCmdLine3("zadd", args[0], formatScore(score), args[2]))
+	db.notify(notifyZSet, "zincr", key)
+	return reply.MakeDoubleReply(score)
+}
+
+// ZSCORE K member
+func execZScore(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeNullBulkReply()
+	}
+	element, exists := sortedSet.Get(string(args[1]))
+	if !exists {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeDoubleReply(element.Score)
+}
+
+// ZCARD K
+func execZCard(db *DB, args [][]byte) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(sortedSet.Len())
+}
+
+// ZREM K m1 m2 ...
+func execZRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+	var removed int64
+	for _, member := range args[1:] {
+		if sortedSet.Remove(string(member)) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine3("zrem", args...))
+		db.notify(notifyZSet, "zrem", key)
+	}
+	if sortedSet.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(removed)
+}
+
+// rankOf ZRANK/ZREVRANK 的公共逻辑   ZRANK K member [WITHSCORE]
+func rankOf(db *DB, args [][]byte, desc bool) resp.Reply {
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHSCORE" {
+			return reply.MakeSyntaxErrReply()
+		}
+		withScore = true
+	} else if len(args) > 3 {
+		return reply.MakeSyntaxErrReply()
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		if withScore {
+			return reply.MakeNullMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	member := string(args[1])
+	rank := sortedSet.GetRank(member, desc)
+	if rank < 0 {
+		if withScore {
+			return reply.MakeNullMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	if withScore {
+		element, _ := sortedSet.Get(member)
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeIntReply(rank),
+			reply.MakeDoubleReply(element.Score),
+		})
+	}
+	return reply.MakeIntReply(rank)
+}
+
+// ZRANK K member [WITHSCORE]
+func execZRank(db *DB, args [][]byte) resp.Reply {
+	return rankOf(db, args, false)
+}
+
+// ZREVRANK K member [WITHSCORE]
+func execZRevRank(db *DB, args [][]byte) resp.Reply {
+	return rankOf(db, args, true)
+}
+
+// ZCOUNT K min max
+func execZCount(db *DB, args [][]byte) resp.Reply {
+	min, err := sortedset.ParseScoreBorder(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	max, err := sortedset.ParseScoreBorder(string(args[2]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(sortedSet.RangeCount(min, max))
+}
+
+// ZRANGE 的查询方式
+const (
+	rangeByRank = iota
+	rangeByScore
+	rangeByLex
+)
+
+// rangeQuery ZRANGE 类指令解析之后的参数
+type rangeQuery struct {
+	key        string
+	by         int
+	start      []byte // BYSCORE/BYLEX 时为min(REV时为max)
+	stop       []byte
+	rev        bool
+	withScores bool
+	hasLimit   bool
+	offset     int64
+	count      int64
+}
+
+// parseRangeOptions 解析 [REV] [BYSCORE|BYLEX] [LIMIT offset count] [WITHSCORES]
+func parseRangeOptions(query *rangeQuery, options [][]byte, allowBy bool) reply.ErrorReply {
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(string(options[i])) {
+		case "BYSCORE":
+			if !allowBy {
+				return reply.MakeSyntaxErrReply()
+			}
+			query.by = rangeByScore
+		case "BYLEX":
+			if !allowBy {
+				return reply.MakeSyntaxErrReply()
+			}
+			query.by = rangeByLex
+		case "REV":
+			if !allowBy {
+				return reply.MakeSyntaxErrReply()
+			}
+			query.rev = true
+		case "WITHSCORES":
+			query.withScores = true
+		case "LIMIT":
+			if i+2 >= len(options) {
+				return reply.MakeSyntaxErrReply()
+			}
+			offset, err1 := strconv.ParseInt(string(options[i+1]), 10, 64)
+			count, err2 := strconv.ParseInt(string(options[i+2]), 10, 64)
+			if err1 != nil || err2 != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			query.hasLimit = true
+			query.offset = offset
+			query.count = count
+			i += 2
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	if query.hasLimit && query.by == rangeByRank {
+		return reply.MakeErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if query.withScores && query.by == rangeByLex {
+		return reply.MakeErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	return nil
+}
+
+// doRange 执行范围查询
+func doRange(db *DB, query *rangeQuery) resp.Reply {
+	sortedSet, errReply := db.getAsSortedSet(query.key)
+	if errReply != nil {
+		return errReply
+	}
+	var elements []*sortedset.Element
+	switch query.by {
+	case rangeByRank:
+		start, err1 := strconv.ParseInt(string(query.start), 10, 64)
+		stop, err2 := strconv.ParseInt(string(query.stop), 10, 64)
+		if err1 != nil || err2 != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if sortedSet == nil {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		begin, end := utils.ConvertRange(start, stop, sortedSet.Len())
+		if begin < 0 || begin >= end {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		elements = sortedSet.RangeByRank(int64(begin), int64(end), query.rev)
+	case rangeByScore, rangeByLex:
+		parse := sortedset.ParseScoreBorder
+		if query.by == rangeByLex {
+			parse = sortedset.ParseLexBorder
+		}
+		minArg, maxArg := query.start, query.stop
+		if query.rev { // REV 时参数的顺序为 max min
+			minArg, maxArg = maxArg, minArg
+		}
+		min, err := parse(string(minArg))
+		if err != nil {
+			return reply.MakeErrReply(err.Error())
+		}
+		max, err := parse(string(maxArg))
+		if err != nil {
+			return reply.MakeErrReply(err.Error())
+		}
+		if sortedSet == nil {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		offset, count := int64(0), int64(-1)
+		if query.hasLimit {
+			offset, count = query.offset, query.count
+		}
+		elements = sortedSet.Range(min, max, offset, count, query.rev)
+	}
+	return elementsToReply(elements, query.withScores)
+}
+
+// ZRANGE K start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func execZRange(db *DB, args [][]byte) resp.Reply {
+	query := &rangeQuery{key: string(args[0]), start: args[1], stop: args[2]}
+	if errReply := parseRangeOptions(query, args[3:], true); errReply != nil {
+		return errReply
+	}
+	return doRange(db, query)
+}
+
+// ZREVRANGE K start stop [WITHSCORES]
+func execZRevRange(db *DB, args [][]byte) resp.Reply {
+	query := &rangeQuery{key: string(args[0]), start: args[1], stop: args[2], rev: true}
+	if errReply := parseRangeOptions(query, args[3:], false); errReply != nil {
+		return errReply
+	}
+	return doRange(db, query)
+}
+
+// ZRANGEBYSCORE K min max [WITHSCORES] [LIMIT offset count]
+func execZRangeByScore(db *DB, args [][]byte) resp.Reply {
+	query := &rangeQuery{key: string(args[0]), start: args[1], stop: args[2], by: rangeByScore}
+	if errReply := parseRangeOptions(query, args[3:], false); errReply != nil {
+		return errReply
+	}
+	return doRange(db, query)
+}
+
+// ZREVRANGEBYSCORE K max min [WITHSCORES] [LIMIT offset count]
+func execZRevRangeByScore(db *DB, args [][]byte) resp.Reply {
+	query := &rangeQuery{key: string(args[0]), start: args[1], stop: args[2], by: rangeByScore, rev: true}
+	if errReply := parseRangeOptions(query, args[3:], false); errReply != nil {
+		return errReply
+	}
+	return doRange(db, query)
+}
+
+// ZRANGEBYLEX K min max [LIMIT offset count]
+func execZRangeByLex(db *DB, args [][]byte) resp.Reply {
+	query := &rangeQuery{key: string(args[0]), start: args[1], stop: args[2], by: rangeByLex}
+	if errReply := parseRangeOptions(query, args[3:], false); errReply != nil {
+		return errReply
+	}
+	return doRange(db, query)
+}
+
+// ZREVRANGEBYLEX K max min [LIMIT offset count]
+func execZRevRangeByLex(db *DB, args [][]byte) resp.Reply {
+	query := &rangeQuery{key: string(args[0]), start: args[1], stop: args[2], by: rangeByLex, rev: true}
+	if errReply := parseRangeOptions(query, args[3:], false); errReply != nil {
+		return errReply
+	}
+	return doRange(db, query)
+}
+
+// popSortedSet ZPOPMIN/ZPOPMAX 的公共逻辑，aof中记录为ZREM被删除的member
+func popSortedSet(db *DB, args [][]byte, cmdName string, max bool) resp.Reply {
+	if len(args) > 2 {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+	key := string(args[0])
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil || n < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	var removed []*sortedset.Element
+	if max {
+		removed = sortedSet.PopMax(count)
+	} else {
+		removed = sortedSet.PopMin(count)
+	}
+	if len(removed) > 0 {
+		aofLine := utils.ToCmdLine3("zrem", args[0])
+		for _, element := range removed {
+			aofLine = append(aofLine, []byte(element.Member))
+		}
+		db.addAof(aofLine)
+		db.notify(notifyZSet, cmdName, key)
+	}
+	if sortedSet.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+	if len(args) == 1 { // 没有指定count时回复 [member, score]，和redis一致
+		if len(removed) == 0 {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(removed[0].Member)),
+			reply.MakeDoubleReply(removed[0].Score),
+		})
+	}
+	return elementsToReply(removed, true)
+}
+
+// ZPOPMIN K [count]
+func execZPopMin(db *DB, args [][]byte) resp.Reply {
+	return popSortedSet(db, args, "zpopmin", false)
+}
+
+// ZPOPMAX K [count]
+func execZPopMax(db *DB, args [][]byte) resp.Reply {
+	return popSortedSet(db, args, "zpopmax", true)
+}
+
+// getAsWeightedSet 取出ZUNIONSTORE/ZINTERSTORE的输入，可以是有序集合，也可以是普通集合(score为1)
+func (db *DB) getAsWeightedSet(key string) (map[string]float64, bool, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, false, nil
+	}
+	result := make(map[string]float64)
+	switch data := entity.Data.(type) {
+	case *sortedset.SortedSet:
+		if data.Len() > 0 {
+			data.ForEachByRank(0, data.Len(), false, func(element *sortedset.Element) bool {
+				result[element.Member] = element.Score
+				return true
+			})
+		}
+	case *set.Set:
+		data.ForEach(func(member string) bool {
+			result[member] = 1
+			return true
+		})
+	default:
+		return nil, false, &reply.WrongTypeErrReply{}
+	}
+	return result, true, nil
+}
+
+// aggregate 按照 SUM/MIN/MAX 合并score
+func aggregate(method string, a float64, b float64) float64 {
+	switch method {
+	case "MIN":
+		return math.Min(a, b)
+	case "MAX":
+		return math.Max(a, b)
+	}
+	sum := a + b
+	if math.IsNaN(sum) { // inf + -inf
+		return 0
+	}
+	return sum
+}
+
+// zStore ZUNIONSTORE/ZINTERSTORE 的公共逻辑
+// ZUNIONSTORE dest numkeys K1 K2 ... [WEIGHTS w1 w2 ...] [AGGREGATE SUM|MIN|MAX]
+func zStore(db *DB, args [][]byte, cmdName string, inter bool) resp.Reply {
+	dest := string(args[0])
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys < 1 {
+		return reply.MakeErrReply("ERR at least 1 input key is needed for '" + cmdName + "' command")
+	}
+	if len(args) < 2+numKeys {
+		return reply.MakeSyntaxErrReply()
+	}
+	keys := args[2 : 2+numKeys]
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	method := "SUM"
+	options := args[2+numKeys:]
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(string(options[i])) {
+		case "WEIGHTS":
+			if i+numKeys >= len(options) {
+				return reply.MakeSyntaxErrReply()
+			}
+			for j := 0; j < numKeys; j++ {
+				weight, ok := parseScore(options[i+1+j])
+				if !ok {
+					return reply.MakeErrReply("ERR weight value is not a float")
+				}
+				weights[j] = weight
+			}
+			i += numKeys
+		case "AGGREGATE":
+			if i+1 >= len(options) {
+				return reply.MakeSyntaxErrReply()
+			}
+			method = strings.ToUpper(string(options[i+1]))
+			if method != "SUM" && method != "MIN" && method != "MAX" {
+				return reply.MakeSyntaxErrReply()
+			}
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	var result map[string]float64
+	for i, key := range keys {
+		members, exists, errReply := db.getAsWeightedSet(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		weighted := make(map[string]float64, len(members))
+		for member, score := range members {
+			weighted[member] = score * weights[i]
+			if math.IsNaN(weighted[member]) { // 0 * inf
+				weighted[member] = 0
+			}
+		}
+		if i == 0 {
+			result = weighted
+			continue
+		}
+		if inter {
+			if !exists {
+				result = map[string]float64{}
+				continue
+			}
+			for member, score := range result {
+				other, ok := weighted[member]
+				if !ok {
+					delete(result, member)
+					continue
+				}
+				result[member] = aggregate(method, score, other)
+			}
+		} else {
+			for member, score := range weighted {
+				if old, ok := result[member]; ok {
+					result[member] = aggregate(method, old, score)
+				} else {
+					result[member] = score
+				}
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		if _, exists := db.GetEntity(dest); exists {
+			db.Remove(dest)
+			db.notify(notifyGeneric, "del", dest)
+		}
+	} else {
+		sortedSet := sortedset.MakeSortedSet()
+		for member, score := range result {
+			sortedSet.Add(member, score)
+		}
+		db.PutEntity(dest, &database.DataEntity{
+			Data: sortedSet,
+		})
+		db.Persist(dest)
+		db.notify(notifyZSet, cmdName, dest)
+	}
+	db.addAof(utils.ToCmdLine3(cmdName, args...))
+	return reply.MakeIntReply(int64(len(result)))
+}
+
+// ZUNIONSTORE dest numkeys K1 K2 ... [WEIGHTS w1 w2 ...] [AGGREGATE SUM|MIN|MAX]
+func execZUnionStore(db *DB, args [][]byte) resp.Reply {
+	return zStore(db, args, "zunionstore", false)
+}
+
+// ZINTERSTORE dest numkeys K1 K2 ... [WEIGHTS w1 w2 ...] [AGGREGATE SUM|MIN|MAX]
+func execZInterStore(db *DB, args [][]byte) resp.Reply {
+	return zStore(db, args, "zinterstore", true)
+}
+
+func init() {
+	RegisterCommand("zadd", execZAdd, nil, -4, flagWrite|flagFast, 1, 1, 1) // ZADD K [NX|XX] [GT|LT] [CH] [INCR] score member ...
+	RegisterCommand("zincrby", execZIncrBy, nil, 4, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("zscore", execZScore, nil, 3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("zcard", execZCard, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("zrem", execZRem, nil, -3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("zrank", execZRank, nil, -3, flagReadOnly|flagFast, 1, 1, 1) // ZRANK K member [WITHSCORE]
+	RegisterCommand("zrevrank", execZRevRank, nil, -3, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("zcount", execZCount, nil, 4, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("zrange", execZRange, nil, -4, flagReadOnly, 1, 1, 1) // ZRANGE K start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+	RegisterCommand("zrevrange", execZRevRange, nil, -4, flagReadOnly, 1, 1, 1)
+	RegisterCommand("zrangebyscore", execZRangeByScore, nil, -4, flagReadOnly, 1, 1, 1)
+	RegisterCommand("zrevrangebyscore", execZRevRangeByScore, nil, -4, flagReadOnly, 1, 1, 1)
+	RegisterCommand("zrangebylex", execZRangeByLex, nil, -4, flagReadOnly, 1, 1, 1)
+	RegisterCommand("zrevrangebylex", execZRevRangeByLex, nil, -4, flagReadOnly, 1, 1, 1)
+	RegisterCommand("zpopmin", execZPopMin, nil, -2, flagWrite|flagFast, 1, 1, 1) // ZPOPMIN K [count]
+	RegisterCommand("zpopmax", execZPopMax, nil, -2, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("zunionstore", execZUnionStore, prepareZStore, -4, flagWrite, 1, 1, 1) // ZUNIONSTORE dest numkeys K1 K2 ...
+	RegisterCommand("zinterstore", execZInterStore, prepareZStore, -4, flagWrite, 1, 1, 1)
+}
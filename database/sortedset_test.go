@@ -0,0 +1,69 @@
+package database
+
+import (
+	"go_redis/resp/reply"
+	"testing"
+)
+
+func TestZRangeByScoreLimit(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "zadd", "z", "1", "a", "2", "b", "3", "c")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"zrangebyscore", "z", "1", "2", "limit", "2", "1"}, "*0\r\n"},
+		{[]string{"zrangebyscore", "z", "1", "2", "limit", "1", "1"}, "*1\r\n$1\r\nb\r\n"},
+		{[]string{"zrevrangebyscore", "z", "3", "2", "limit", "2", "1"}, "*0\r\n"},
+		{[]string{"zrevrangebyscore", "z", "3", "2", "limit", "1", "5"}, "*1\r\n$1\r\nb\r\n"},
+		{[]string{"zrange", "z", "-100", "-1"}, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"zrange", "z", "-100", "0"}, "*1\r\n$1\r\na\r\n"},
+		{[]string{"zrange", "z", "5", "10"}, "*0\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+}
+
+// 带score的回复在RESP2中为扁平的字符串列表，RESP3中score为double，WITHSCORES 为 [member, score] 组成的数组
+func TestZSetScoreReplies(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args  []string
+		resp2 string
+		resp3 string
+	}{
+		{[]string{"zrange", "z", "0", "1", "withscores"},
+			"*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$3\r\n2.5\r\n",
+			"*2\r\n*2\r\n$1\r\na\r\n,1\r\n*2\r\n$1\r\nb\r\n,2.5\r\n"},
+		{[]string{"zrevrangebyscore", "z", "+inf", "2", "withscores"},
+			"*4\r\n$1\r\nc\r\n$3\r\ninf\r\n$1\r\nb\r\n$3\r\n2.5\r\n",
+			"*2\r\n*2\r\n$1\r\nc\r\n,inf\r\n*2\r\n$1\r\nb\r\n,2.5\r\n"},
+		{[]string{"zrank", "z", "b", "withscore"},
+			"*2\r\n:1\r\n$3\r\n2.5\r\n",
+			"*2\r\n:1\r\n,2.5\r\n"},
+		{[]string{"zpopmin", "z"},
+			"*2\r\n$1\r\na\r\n$1\r\n1\r\n",
+			"*2\r\n$1\r\na\r\n,1\r\n"},
+		{[]string{"zpopmax", "z", "1"},
+			"*2\r\n$1\r\nc\r\n$3\r\ninf\r\n",
+			"*1\r\n*2\r\n$1\r\nc\r\n,inf\r\n"},
+		{[]string{"bzpopmin", "z", "0"},
+			"*3\r\n$1\r\nz\r\n$1\r\nb\r\n$3\r\n2.5\r\n",
+			"*3\r\n$1\r\nz\r\n$1\r\nb\r\n,2.5\r\n"},
+	}
+	for _, protocol := range []int{2, 3} {
+		execCmd(e, c, "del", "z")
+		execCmd(e, c, "zadd", "z", "1", "a", "2.5", "b", "+inf", "c")
+		for _, tt := range tests {
+			want := tt.resp2
+			if protocol == 3 {
+				want = tt.resp3
+			}
+			r := execCmd(e, c, tt.args...)
+			if got := string(reply.ToProtocolBytes(r, protocol)); got != want {
+				t.Errorf("RESP%d %v: got %q, want %q", protocol, tt.args, got, want)
+			}
+		}
+	}
+}
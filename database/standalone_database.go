@@ -5,21 +5,36 @@ import (
 	"go_redis/config"
 	"go_redis/interface/resp"
 	"go_redis/lib/logger"
+	"go_redis/pubsub"
 	"go_redis/resp/reply"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // 真正的database 内核业务
 type StandaloneDatabase struct {
 	dbSet      []*DB           // 多个redis数据库组成
 	aofHandler *aof.AofHandler //aof持久化技术
+	closeChan  chan struct{}   // 关闭后台协程(主动过期)的信号
+	closeOnce  sync.Once
+	txLock     sync.RWMutex // EXEC和脚本执行时独占，保证事务执行期间不会穿插其他的指令
+	scripts    *scriptEngine
+	hub        *pubsub.Hub // 发布订阅，所有的db共享
+	tracking   *trackingTable
+	watches    *watchTable // 被WATCH的key，所有的db共享
 }
 
 // 初始化 database
 func NewStandaloneDatabase() *StandaloneDatabase {
 	//主要是根据初始化文件来设置database
-	database := &StandaloneDatabase{}
+	database := &StandaloneDatabase{
+		closeChan: make(chan struct{}),
+		scripts:   makeScriptEngine(),
+		hub:       pubsub.MakeHub(),
+		tracking:  makeTrackingTable(),
+		watches:   makeWatchTable(),
+	}
 	if config.Properties.Databases == 0 { // 没有指定参数使用默认参数16
 		config.Properties.Databases = 16
 	}
@@ -28,6 +43,9 @@ func NewStandaloneDatabase() *StandaloneDatabase {
 	for i := range database.dbSet {
 		db := makeDB()
 		db.index = i
+		db.tracking = database.tracking
+		db.watches = database.watches
+		db.blocking.txLock = &database.txLock
 		database.dbSet[i] = db
 	}
 	// 初始化aofhandler   // aof机制
@@ -37,16 +55,27 @@ func NewStandaloneDatabase() *StandaloneDatabase {
 			panic(err) // redis业务还没有启动，可以panic
 		}
 		database.aofHandler = aofHandler
-		// 给每一个db初始化addaof方法   ---- 注意闭包问题
+		// 给每一个db初始化writeAof方法   ---- 注意闭包问题
 		for _, db := range database.dbSet {
 			ldb := db
-			ldb.addAof = func(line CmdLine) {
+			ldb.writeAof = func(line CmdLine) {
 				database.aofHandler.AddAof(ldb.index, line) // 函数内部的变量引用函数外部的变量会引发闭包问题
 			}
 		}
 
 	}
-
+	// 键空间通知
+	if flags, err := parseKeyspaceEvents(config.Properties.NotifyKeyspaceEvents); err != nil {
+		logger.Error(err)
+	} else if flags != 0 {
+		queue := makeNotifyQueue()
+		queue.start(database.hub, database.closeChan)
+		for _, db := range database.dbSet {
+			db.notify = makeNotifier(queue, flags, db.index)
+		}
+	}
+	// 开启后台主动过期
+	database.startActiveExpire()
 	return database
 }
 
@@ -62,25 +91,58 @@ func (e *StandaloneDatabase) Exec(client resp.Connection, args [][]byte) resp.Re
 	}()
 	// 需要单独处理select命令,底层db是没有实现select处理的
 	cmdName := strings.ToLower(string(args[0]))
+	defer e.tracking.afterCommand(client, args) // CLIENT CACHING 只对下一条指令有效
+	// 脚本执行超时之后只能执行 SCRIPT KILL/FUNCTION KILL
+	if errReply := e.scripts.busyReply(cmdName, args); errReply != nil {
+		return errReply
+	}
+	// 订阅模式下只能执行订阅相关的指令
+	if errReply := pubsub.CheckSubscriberMode(client, cmdName, args); errReply != nil {
+		return errReply
+	}
+	// 事务相关的指令需要访问多个db，同样单独处理
+	if isTxCommand(cmdName) {
+		return e.execTxCommand(client, cmdName, args)
+	}
+	if client.InMultiState() {
+		return enqueueCmd(client, args)
+	}
 	if cmdName == "select" { // 是的话
 		if len(args) != 2 { // 校验
 			return reply.MakeArgNumErrReply("select")
 		}
 		return execSelect(client, e, args[1:]) // 处理selct指令
 	}
+	if isScriptCommand(cmdName) {
+		return e.execScriptCommand(client, cmdName, args)
+	}
+	if isPubSubCommand(cmdName) {
+		return e.execPubSub(client, cmdName, args)
+	}
 	// 一般的语句--- 发配给具体的db, db的index就是记录在封装的用户的结构体中
 	dbindex := client.GetDBIndex()
 	db := e.dbSet[dbindex]
+	// 所有的指令都持有事务锁的读锁执行，不会穿插在EXEC和脚本中间；阻塞指令在等待期间释放读锁(见 blockingTable.block)
+	e.txLock.RLock()
+	defer e.txLock.RUnlock()
 	return db.Exec(client, args)
 
 }
 
 func (e *StandaloneDatabase) Close() {
-
+	e.closeOnce.Do(func() { // Close可能被调用多次，只关闭一次
+		close(e.closeChan)
+	})
 }
 
+// AfterClientClose 客户端断开连接之后清理它的状态：阻塞中的指令直接结束，取消所有的订阅和WATCH
 func (e *StandaloneDatabase) AfterClientClose(c resp.Connection) {
-
+	pubsub.UnsubscribeAll(e.hub, c)
+	e.watches.release(c)
+	e.tracking.removeClient(c)
+	for _, db := range e.dbSet {
+		db.blocking.removeClient(c)
+	}
 }
 
 // 执行用户选择数据库的指令
@@ -0,0 +1,491 @@
+package database
+
+import (
+	"go_redis/datastruct/stream"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stream 的常用指令
+
+const streamDefaultTrimLimit = 100 * 100 // 近似裁剪时默认最多删除的消息数，100倍的宏节点容量
+
+func (db *DB) getAsStream(key string) (*stream.Stream, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*stream.Stream)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return s, nil
+}
+
+func (db *DB) getOrInitStream(key string) (*stream.Stream, reply.ErrorReply) {
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if s == nil {
+		s = stream.MakeStream()
+		db.PutEntity(key, &database.DataEntity{
+			Data: s,
+		})
+	}
+	return s, nil
+}
+
+// entryToReply 单条消息的回复  [id, [field value ...]]
+func entryToReply(entry *stream.Entry) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(entry.ID.String())),
+		reply.MakeMultiBulkReply(entry.Fields),
+	})
+}
+
+func entriesToReply(entries []*stream.Entry) resp.Reply {
+	result := make([]resp.Reply, len(entries))
+	for i, entry := range entries {
+		result[i] = entryToReply(entry)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// parseStreamID 解析完整的ID，只有毫秒部分时序列号为0
+func parseStreamID(arg []byte) (stream.ID, reply.ErrorReply) {
+	id, err := stream.ParseID(string(arg), 0)
+	if err != nil {
+		return id, reply.MakeErrReply(err.Error())
+	}
+	return id, nil
+}
+
+// parseRangeID 解析范围查询的边界，支持 - + 以及 ( 表示不包含边界
+func parseRangeID(arg []byte, isStart bool) (stream.ID, reply.ErrorReply) {
+	s := string(arg)
+	switch s {
+	case "-":
+		return stream.MinID, nil
+	case "+":
+		return stream.MaxID, nil
+	}
+	exclude := strings.HasPrefix(s, "(")
+	if exclude {
+		s = s[1:]
+	}
+	var missingSeq uint64 // 只有毫秒部分时，起始边界的序列号为0，结束边界的序列号为最大值
+	if !isStart {
+		missingSeq = stream.MaxID.Seq
+	}
+	id, err := stream.ParseID(s, missingSeq)
+	if err != nil {
+		return id, reply.MakeErrReply(err.Error())
+	}
+	if !exclude {
+		return id, nil
+	}
+	var ok bool
+	if isStart {
+		id, ok = id.Incr()
+		if !ok {
+			return id, reply.MakeErrReply("ERR invalid start ID for the interval")
+		}
+	} else {
+		id, ok = id.Decr()
+		if !ok {
+			return id, reply.MakeErrReply("ERR invalid end ID for the interval")
+		}
+	}
+	return id, nil
+}
+
+// parseTrimOptions 解析 MAXLEN|MINID [=|~] threshold [LIMIT count]，args[0]为MAXLEN或者MINID，返回消耗的参数个数
+func parseTrimOptions(args [][]byte) (*stream.TrimOptions, int, reply.ErrorReply) {
+	opts := &stream.TrimOptions{}
+	if strings.ToUpper(string(args[0])) == "MAXLEN" {
+		opts.Strategy = stream.TrimMaxLen
+	} else {
+		opts.Strategy = stream.TrimMinID
+	}
+	i := 1
+	if i < len(args) && (string(args[i]) == "~" || string(args[i]) == "=") {
+		opts.Approx = string(args[i]) == "~"
+		i++
+	}
+	if i >= len(args) {
+		return nil, 0, reply.MakeSyntaxErrReply()
+	}
+	if opts.Strategy == stream.TrimMaxLen {
+		maxLen, err := strconv.ParseInt(string(args[i]), 10, 64)
+		if err != nil {
+			return nil, 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if maxLen < 0 {
+			return nil, 0, reply.MakeErrReply("ERR The MAXLEN argument must be >= 0.")
+		}
+		opts.MaxLen = maxLen
+	} else {
+		minID, errReply := parseStreamID(args[i])
+		if errReply != nil {
+			return nil, 0, errReply
+		}
+		opts.MinID = minID
+	}
+	i++
+	if opts.Approx {
+		opts.Limit = streamDefaultTrimLimit
+	}
+	if i+1 < len(args) && strings.ToUpper(string(args[i])) == "LIMIT" {
+		if !opts.Approx {
+			return nil, 0, reply.MakeErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		limit, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil || limit < 0 {
+			return nil, 0, reply.MakeErrReply("ERR The LIMIT argument must be >= 0.")
+		}
+		opts.Limit = limit
+		i += 2
+	}
+	return opts, i, nil
+}
+
+// makeTrimAof 裁剪之后记录到aof中的命令，统一转化为精确裁剪，保证重放的结果一致
+func makeTrimAof(key []byte, s *stream.Stream) CmdLine {
+	first := s.First()
+	if first == nil {
+		return utils.ToCmdLine3("xtrim", key, []byte("MAXLEN"), []byte("0"))
+	}
+	return utils.ToCmdLine3("xtrim", key, []byte("MINID"), []byte(first.ID.String()))
+}
+
+// XADD K [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+func execXAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	noMkStream := false
+	var trimOpts *stream.TrimOptions
+	i := 1
+parseOptions:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NOMKSTREAM":
+			noMkStream = true
+		case "MAXLEN", "MINID":
+			opts, n, errReply := parseTrimOptions(args[i:])
+			if errReply != nil {
+				return errReply
+			}
+			trimOpts = opts
+			i += n - 1
+		default:
+			break parseOptions
+		}
+	}
+	if i >= len(args) {
+		return reply.MakeSyntaxErrReply()
+	}
+	idArg := string(args[i])
+	fields := args[i+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return reply.MakeArgNumErrReply("xadd")
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil && noMkStream {
+		return reply.MakeNullBulkReply()
+	}
+	// 先校验ID，再创建stream，避免出错时留下空的stream
+	current := s
+	if current == nil {
+		current = stream.MakeStream()
+	}
+	var id stream.ID
+	if idArg == "*" {
+		var ok bool
+		id, ok = current.NextID(uint64(time.Now().UnixMilli()))
+		if !ok {
+			return reply.MakeErrReply("ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+	} else if msPart, found := strings.CutSuffix(idArg, "-*"); found {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return reply.MakeErrReply(stream.ErrInvalidID.Error())
+		}
+		var ok bool
+		id, ok = current.NextSeq(ms)
+		if !ok {
+			return reply.MakeErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+	} else {
+		id, errReply = parseStreamID(args[i])
+		if errReply != nil {
+			return errReply
+		}
+		if id == stream.MinID {
+			return reply.MakeErrReply("ERR The ID specified in XADD must be greater than 0-0")
+		}
+		if !current.LastID().Less(id) {
+			return reply.MakeErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+	}
+	if s == nil {
+		s, _ = db.getOrInitStream(key)
+	}
+	s.Add(id, fields)
+	// aof中记录生成的ID，保证重放之后ID不变
+	db.addAof(utils.ToCmdLine3("xadd", append([][]byte{args[0], []byte(id.String())}, fields...)...))
+	db.notify(notifyStream, "xadd", key)
+	if trimOpts != nil && s.Trim(trimOpts) > 0 {
+		db.addAof(makeTrimAof(args[0], s))
+		db.notify(notifyStream, "xtrim", key)
+	}
+	return reply.MakeBulkReply([]byte(id.String()))
+}
+
+// XTRIM K MAXLEN|MINID [=|~] threshold [LIMIT count]   返回删除的消息数
+func execXTrim(db *DB, args [][]byte) resp.Reply {
+	strategy := strings.ToUpper(string(args[1]))
+	if strategy != "MAXLEN" && strategy != "MINID" {
+		return reply.MakeSyntaxErrReply()
+	}
+	opts, n, errReply := parseTrimOptions(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	if 1+n != len(args) {
+		return reply.MakeSyntaxErrReply()
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	deleted := s.Trim(opts)
+	if deleted > 0 {
+		db.addAof(makeTrimAof(args[0], s))
+		db.notify(notifyStream, "xtrim", string(args[0]))
+	}
+	return reply.MakeIntReply(deleted)
+}
+
+// XDEL K id ...   返回删除的消息数
+func execXDel(db *DB, args [][]byte) resp.Reply {
+	ids := make([]stream.ID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, errReply := parseStreamID(arg)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = id
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	deleted := 0
+	for _, id := range ids {
+		if s.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine3("xdel", args...))
+		db.notify(notifyStream, "xdel", string(args[0]))
+	}
+	return reply.MakeIntReply(int64(deleted))
+}
+
+// XLEN K
+func execXLen(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(s.Len())
+}
+
+// xRange XRANGE K start end [COUNT count]   XREVRANGE K end start [COUNT count]
+func xRange(db *DB, args [][]byte, desc bool) resp.Reply {
+	startArg, endArg := args[1], args[2]
+	if desc {
+		startArg, endArg = endArg, startArg
+	}
+	start, errReply := parseRangeID(startArg, true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(endArg, false)
+	if errReply != nil {
+		return errReply
+	}
+	count := -1
+	if len(args) > 3 {
+		if len(args) != 5 || strings.ToUpper(string(args[3])) != "COUNT" {
+			return reply.MakeSyntaxErrReply()
+		}
+		var err error
+		count, err = strconv.Atoi(string(args[4]))
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if count <= 0 {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	return entriesToReply(s.Range(start, end, count, desc))
+}
+
+// XRANGE K start end [COUNT count]
+func execXRange(db *DB, args [][]byte) resp.Reply {
+	return xRange(db, args, false)
+}
+
+// XREVRANGE K end start [COUNT count]
+func execXRevRange(db *DB, args [][]byte) resp.Reply {
+	return xRange(db, args, true)
+}
+
+// resolveXReadIDs 解析XREAD的起始ID，$ 表示调用时stream中最大的ID，需要在阻塞之前确定
+func resolveXReadIDs(db *DB, keys []string, idArgs [][]byte) ([]stream.ID, reply.ErrorReply) {
+	db.RWLocks(nil, keys)
+	defer db.RWUnLocks(nil, keys)
+	ids := make([]stream.ID, len(keys))
+	for j, key := range keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if string(idArgs[j]) == "$" {
+			if s != nil {
+				ids[j] = s.LastID()
+			}
+			continue
+		}
+		ids[j], errReply = parseStreamID(idArgs[j])
+		if errReply != nil {
+			return nil, errReply
+		}
+	}
+	return ids, nil
+}
+
+// XREAD [COUNT count] [BLOCK ms] STREAMS K1 K2 ... id1 id2 ...
+func execXRead(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	count := -1
+	blocking := false
+	var timeout time.Duration
+	i := 0
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "STREAMS" {
+			break
+		}
+		if i+1 >= len(args) {
+			return reply.MakeSyntaxErrReply()
+		}
+		switch option {
+		case "COUNT":
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if n > 0 {
+				count = n
+			}
+		case "BLOCK":
+			var errReply reply.ErrorReply
+			timeout, errReply = parseBlockMillis(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			blocking = true
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+		i++
+	}
+	if i >= len(args) {
+		return reply.MakeSyntaxErrReply()
+	}
+	keys, idArgs, errReply := parseStreamsArgs(args[i+1:], "xread")
+	if errReply != nil {
+		return errReply
+	}
+	keyNames := toKeys(keys)
+	ids, errReply := resolveXReadIDs(db, keyNames, idArgs)
+	if errReply != nil {
+		return errReply
+	}
+	read := func() (resp.Reply, bool) {
+		db.RWLocks(nil, keyNames)
+		defer db.RWUnLocks(nil, keyNames)
+		var result []resp.Reply
+		for j, key := range keys {
+			s, errReply := db.getAsStream(string(key))
+			if errReply != nil {
+				return errReply, true
+			}
+			if s == nil {
+				continue
+			}
+			start, ok := ids[j].Incr()
+			if !ok {
+				continue
+			}
+			entries := s.Range(start, stream.MaxID, count, false)
+			if len(entries) == 0 {
+				continue
+			}
+			result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeBulkReply(key),
+				entriesToReply(entries),
+			}))
+		}
+		if len(result) == 0 {
+			return nil, false
+		}
+		return reply.MakeMultiRawReply(result), true
+	}
+	if !blocking {
+		if result, ok := read(); ok {
+			return result
+		}
+		return reply.MakeNullMultiBulkReply()
+	}
+	return db.blocking.block(c, keyNames, nil, timeout, func(string) (resp.Reply, bool) {
+		return read()
+	}, reply.MakeNullMultiBulkReply())
+}
+
+func init() {
+	RegisterCommand("xadd", execXAdd, nil, -5, flagWrite|flagFast, 1, 1, 1) // XADD K [options] *|id field value ...
+	RegisterCommand("xtrim", execXTrim, nil, -4, flagWrite, 1, 1, 1)
+	RegisterCommand("xdel", execXDel, nil, -3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("xlen", execXLen, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("xrange", execXRange, nil, -4, flagReadOnly, 1, 1, 1) // XRANGE K start end [COUNT count]
+	RegisterCommand("xrevrange", execXRevRange, nil, -4, flagReadOnly, 1, 1, 1)
+	RegisterConnCommand("xread", execXRead, readStreamKeys, -4, flagReadOnly|flagBlocking, 0, 0, 0) // XREAD [COUNT count] [BLOCK ms] STREAMS K id
+}
@@ -0,0 +1,994 @@
+package database
+
+import (
+	"go_redis/datastruct/stream"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stream 消费者组的相关指令
+// 消费者组的状态变化统一以 XCLAIM(带有 TIME RETRYCOUNT FORCE JUSTID LASTID) 和 XGROUP SETID 的形式记录到aof中，
+// 与redis的主从复制保持一致，保证重放之后分发时间、分发次数和最后分发的ID都不变
+
+const xautoclaimDefaultCount = 100
+
+func makeNoGroupErr(key string, group string) reply.ErrorReply {
+	return reply.MakeErrReply("NOGROUP No such consumer group '" + group + "' for key name '" + key + "'")
+}
+
+// getStreamGroup 取出stream和消费者组，stream或者消费者组不存在时返回 NOGROUP 错误
+func (db *DB) getStreamGroup(key string, groupName string) (*stream.Stream, *stream.Group, reply.ErrorReply) {
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if s == nil {
+		return nil, nil, makeNoGroupErr(key, groupName)
+	}
+	g := s.Group(groupName)
+	if g == nil {
+		return nil, nil, makeNoGroupErr(key, groupName)
+	}
+	return s, g, nil
+}
+
+// makeClaimAof 将待确认消息的当前状态记录为XCLAIM指令
+func makeClaimAof(key []byte, g *stream.Group, pe *stream.PendingEntry) CmdLine {
+	return utils.ToCmdLine("xclaim", string(key), g.Name, pe.Consumer.Name, "0", pe.ID.String(),
+		"TIME", strconv.FormatInt(pe.DeliveryTime, 10),
+		"RETRYCOUNT", strconv.FormatInt(pe.DeliveryCount, 10),
+		"FORCE", "JUSTID", "LASTID", g.LastID.String())
+}
+
+// makeSetIDAof 将消费者组最后分发的ID和已读消息数记录为 XGROUP SETID 指令
+func makeSetIDAof(key []byte, g *stream.Group) CmdLine {
+	return utils.ToCmdLine("xgroup", "setid", string(key), g.Name, g.LastID.String(),
+		"ENTRIESREAD", strconv.FormatInt(g.EntriesRead, 10))
+}
+
+// getOrCreateConsumer 查找消费者，不存在时创建并记录到aof
+func (db *DB) getOrCreateConsumer(key []byte, g *stream.Group, name string, now int64) *stream.Consumer {
+	c, created := g.CreateConsumer(name, now)
+	if created {
+		db.addAof(utils.ToCmdLine("xgroup", "createconsumer", string(key), g.Name, name))
+		db.notify(notifyStream, "xgroup-createconsumer", string(key))
+	}
+	return c
+}
+
+// parseGroupID 解析 XGROUP CREATE/SETID 中的ID，$ 表示stream中最后一条消息的ID
+func parseGroupID(arg []byte, s *stream.Stream) (stream.ID, reply.ErrorReply) {
+	if string(arg) == "$" {
+		if s == nil {
+			return stream.MinID, nil
+		}
+		return s.LastID(), nil
+	}
+	return parseStreamID(arg)
+}
+
+// parseEntriesRead 解析 ENTRIESREAD n
+func parseEntriesRead(args [][]byte) (int64, reply.ErrorReply) {
+	if len(args) != 2 || strings.ToUpper(string(args[0])) != "ENTRIESREAD" {
+		return 0, reply.MakeSyntaxErrReply()
+	}
+	n, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if n < stream.InvalidEntriesRead {
+		return 0, reply.MakeErrReply("ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return n, nil
+}
+
+// XGROUP CREATE|SETID|DESTROY|CREATECONSUMER|DELCONSUMER K group ...
+func execXGroup(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	if len(args) < 3 {
+		return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + string(args[0]) + "'")
+	}
+	key := string(args[1])
+	groupName := string(args[2])
+	switch subCmd {
+	case "CREATE":
+		return xGroupCreate(db, args[1:])
+	case "SETID":
+		// XGROUP SETID K group id|$ [ENTRIESREAD n]
+		if len(args) != 4 && len(args) != 6 {
+			return reply.MakeArgNumErrReply("xgroup|setid")
+		}
+		s, g, errReply := db.getStreamGroup(key, groupName)
+		if errReply != nil {
+			return errReply
+		}
+		id, errReply := parseGroupID(args[3], s)
+		if errReply != nil {
+			return errReply
+		}
+		entriesRead := int64(stream.InvalidEntriesRead)
+		if len(args) == 6 {
+			entriesRead, errReply = parseEntriesRead(args[4:])
+			if errReply != nil {
+				return errReply
+			}
+		}
+		s.SetGroupID(g, id, entriesRead)
+		db.addAof(makeSetIDAof(args[1], g))
+		db.notify(notifyStream, "xgroup-setid", key)
+		return reply.MakeOkReply()
+	case "DESTROY":
+		// XGROUP DESTROY K group
+		if len(args) != 3 {
+			return reply.MakeArgNumErrReply("xgroup|destroy")
+		}
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			return reply.MakeErrReply("ERR The XGROUP subcommand requires the key to exist.")
+		}
+		if !s.DestroyGroup(groupName) {
+			return reply.MakeIntReply(0)
+		}
+		db.addAof(utils.ToCmdLine3("xgroup", args...))
+		db.notify(notifyStream, "xgroup-destroy", key)
+		return reply.MakeIntReply(1)
+	case "CREATECONSUMER":
+		// XGROUP CREATECONSUMER K group consumer
+		if len(args) != 4 {
+			return reply.MakeArgNumErrReply("xgroup|createconsumer")
+		}
+		_, g, errReply := db.getStreamGroup(key, groupName)
+		if errReply != nil {
+			return errReply
+		}
+		if _, created := g.CreateConsumer(string(args[3]), time.Now().UnixMilli()); !created {
+			return reply.MakeIntReply(0)
+		}
+		db.addAof(utils.ToCmdLine3("xgroup", args...))
+		db.notify(notifyStream, "xgroup-createconsumer", key)
+		return reply.MakeIntReply(1)
+	case "DELCONSUMER":
+		// XGROUP DELCONSUMER K group consumer   返回该消费者待确认的消息数
+		if len(args) != 4 {
+			return reply.MakeArgNumErrReply("xgroup|delconsumer")
+		}
+		_, g, errReply := db.getStreamGroup(key, groupName)
+		if errReply != nil {
+			return errReply
+		}
+		pending, deleted := g.DeleteConsumer(string(args[3]))
+		if deleted {
+			db.addAof(utils.ToCmdLine3("xgroup", args...))
+			db.notify(notifyStream, "xgroup-delconsumer", key)
+		}
+		return reply.MakeIntReply(int64(pending))
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XGROUP HELP.")
+}
+
+// xGroupCreate XGROUP CREATE K group id|$ [MKSTREAM] [ENTRIESREAD n]
+func xGroupCreate(db *DB, args [][]byte) resp.Reply {
+	if len(args) < 3 {
+		return reply.MakeArgNumErrReply("xgroup|create")
+	}
+	key := string(args[0])
+	groupName := string(args[1])
+	mkStream := false
+	entriesRead := int64(stream.InvalidEntriesRead)
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "MKSTREAM":
+			mkStream = true
+		case "ENTRIESREAD":
+			n, errReply := parseEntriesRead(args[i:min(i+2, len(args))])
+			if errReply != nil {
+				return errReply
+			}
+			entriesRead = n
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	id, errReply := parseGroupID(args[2], s)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if !mkStream {
+			return reply.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. " +
+				"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+		}
+		s, _ = db.getOrInitStream(key)
+	}
+	if _, created := s.CreateGroup(groupName, id, entriesRead); !created {
+		return reply.MakeErrReply("BUSYGROUP Consumer Group name already exists")
+	}
+	db.addAof(utils.ToCmdLine("xgroup", "create", key, groupName, id.String(), "MKSTREAM",
+		"ENTRIESREAD", strconv.FormatInt(entriesRead, 10)))
+	db.notify(notifyStream, "xgroup-create", key)
+	return reply.MakeOkReply()
+}
+
+// parseStreamsArgs 解析 STREAMS K1 K2 ... id1 id2 ...，返回key和对应的ID参数
+func parseStreamsArgs(args [][]byte, cmdName string) ([][]byte, [][]byte, reply.ErrorReply) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, nil, reply.MakeErrReply("ERR Unbalanced '" + cmdName + "' list of streams: " +
+			"for each stream key an ID or '$' must be specified.")
+	}
+	half := len(args) / 2
+	return args[:half], args[half:], nil
+}
+
+// parseBlockMillis 解析 BLOCK 的毫秒数
+func parseBlockMillis(arg []byte) (time.Duration, reply.ErrorReply) {
+	ms, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR timeout is not an integer or out of range")
+	}
+	if ms < 0 {
+		return 0, reply.MakeErrReply("ERR timeout is negative")
+	}
+	if ms > int64(maxBlockTimeout/time.Millisecond) {
+		return 0, reply.MakeErrReply("ERR timeout is out of range")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// XREADGROUP GROUP group consumer [COUNT count] [BLOCK ms] [NOACK] STREAMS K1 K2 ... id1 id2 ...
+func execXReadGroup(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	if strings.ToUpper(string(args[0])) != "GROUP" {
+		return reply.MakeSyntaxErrReply()
+	}
+	groupName := string(args[1])
+	consumerName := string(args[2])
+	count := 0
+	noAck := false
+	blocking := false
+	var timeout time.Duration
+	i := 3
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "STREAMS" {
+			break
+		}
+		switch option {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if n > 0 {
+				count = n
+			}
+			i++
+		case "BLOCK":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			var errReply reply.ErrorReply
+			timeout, errReply = parseBlockMillis(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			blocking = true
+			i++
+		case "NOACK":
+			noAck = true
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	if i >= len(args) {
+		return reply.MakeSyntaxErrReply()
+	}
+	keys, idArgs, errReply := parseStreamsArgs(args[i+1:], "xreadgroup")
+	if errReply != nil {
+		return errReply
+	}
+	// 先校验所有的stream和消费者组，再进行读取
+	onlyNew := true // 只有所有的ID都是 > 时才会阻塞
+	for j, key := range keys {
+		if _, _, errReply := db.getReadGroupTarget(key, groupName); errReply != nil {
+			return errReply
+		}
+		if string(idArgs[j]) == "$" {
+			return reply.MakeErrReply("ERR The $ ID is meaningful only for XREAD, not for XREADGROUP")
+		}
+		if string(idArgs[j]) != ">" {
+			if _, errReply := parseStreamID(idArgs[j]); errReply != nil {
+				return errReply
+			}
+			onlyNew = false
+		}
+	}
+	keyNames := toKeys(keys)
+	read := func() resp.Reply {
+		db.RWLocks(keyNames, nil)
+		defer db.RWUnLocks(keyNames, nil)
+		return xReadGroup(db, keys, idArgs, groupName, consumerName, count, noAck)
+	}
+	if !blocking || !onlyNew {
+		return read()
+	}
+	try := func(string) (resp.Reply, bool) {
+		result := read()
+		if _, isNull := result.(*reply.NullMultiBulkReply); isNull {
+			return nil, false
+		}
+		return result, true
+	}
+	return db.blocking.block(c, keyNames, nil, timeout, try, reply.MakeNullMultiBulkReply())
+}
+
+// getReadGroupTarget XREADGROUP 读取的stream和消费者组
+func (db *DB) getReadGroupTarget(key []byte, groupName string) (*stream.Stream, *stream.Group, reply.ErrorReply) {
+	s, errReply := db.getAsStream(string(key))
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if s == nil || s.Group(groupName) == nil {
+		return nil, nil, reply.MakeErrReply("NOGROUP No such key '" + string(key) + "' or consumer group '" +
+			groupName + "' in XREADGROUP with GROUP option")
+	}
+	return s, s.Group(groupName), nil
+}
+
+// xReadGroup 读取消费者组的消息，没有读到任何新消息时返回空
+func xReadGroup(db *DB, keys [][]byte, idArgs [][]byte, groupName string, consumerName string,
+	count int, noAck bool) resp.Reply {
+	streams := make([]*stream.Stream, len(keys))
+	groups := make([]*stream.Group, len(keys))
+	for j, key := range keys {
+		// 阻塞期间stream或者消费者组可能已经被删除
+		s, g, errReply := db.getReadGroupTarget(key, groupName)
+		if errReply != nil {
+			return errReply
+		}
+		streams[j], groups[j] = s, g
+	}
+
+	now := time.Now().UnixMilli()
+	var result []resp.Reply
+	for j, key := range keys {
+		s, g := streams[j], groups[j]
+		c := db.getOrCreateConsumer(key, g, consumerName, now)
+		c.SeenTime = now
+		if string(idArgs[j]) == ">" {
+			entries := s.ReadGroup(g, c, count, noAck, now)
+			if len(entries) == 0 {
+				continue
+			}
+			c.ActiveTime = now
+			if !noAck {
+				for _, entry := range entries {
+					db.addAof(makeClaimAof(key, g, g.Pending(entry.ID)))
+				}
+			}
+			db.addAof(makeSetIDAof(key, g))
+			result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeBulkReply(key),
+				entriesToReply(entries),
+			}))
+			continue
+		}
+		// 读取消费者自己的待确认消息，已经被删除的消息返回 [id, nil]
+		start, _ := parseStreamID(idArgs[j])
+		start, ok := start.Incr()
+		var history []resp.Reply
+		if ok {
+			c.ForEachPending(start, func(pe *stream.PendingEntry) bool {
+				entry, exists := s.Get(pe.ID)
+				if !exists {
+					history = append(history, reply.MakeMultiRawReply([]resp.Reply{
+						reply.MakeBulkReply([]byte(pe.ID.String())),
+						reply.MakeNullMultiBulkReply(),
+					}))
+				} else {
+					g.Claim(pe, c, now, pe.DeliveryCount+1)
+					db.addAof(makeClaimAof(key, g, pe))
+					history = append(history, entryToReply(entry))
+				}
+				return count <= 0 || len(history) < count
+			})
+		}
+		result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply(key),
+			reply.MakeMultiRawReply(history),
+		}))
+	}
+	if len(result) == 0 {
+		return reply.MakeNullMultiBulkReply()
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// XACK K group id ...   返回确认的消息数
+func execXAck(db *DB, args [][]byte) resp.Reply {
+	ids := make([]stream.ID, len(args)-2)
+	for i, arg := range args[2:] {
+		id, errReply := parseStreamID(arg)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = id
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	g := s.Group(string(args[1]))
+	if g == nil {
+		return reply.MakeIntReply(0)
+	}
+	acked := 0
+	for _, id := range ids {
+		if g.Ack(id) {
+			acked++
+		}
+	}
+	if acked > 0 {
+		db.addAof(utils.ToCmdLine3("xack", args...))
+	}
+	return reply.MakeIntReply(int64(acked))
+}
+
+// XPENDING K group [[IDLE min-idle-time] start end count [consumer]]
+func execXPending(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	groupName := string(args[1])
+	_, g, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	now := time.Now().UnixMilli()
+	if len(args) == 2 {
+		// 概要：待确认消息数 最小ID 最大ID [[消费者 待确认消息数] ...]
+		if g.PendingCount() == 0 {
+			return reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeIntReply(0),
+				reply.MakeNullBulkReply(),
+				reply.MakeNullBulkReply(),
+				reply.MakeNullMultiBulkReply(),
+			})
+		}
+		var minID, maxID stream.ID
+		first := true
+		g.ForEachPending(stream.MinID, stream.MaxID, func(pe *stream.PendingEntry) bool {
+			if first {
+				minID = pe.ID
+				first = false
+			}
+			maxID = pe.ID
+			return true
+		})
+		var consumers []resp.Reply
+		for _, c := range g.Consumers() {
+			if c.PendingCount() == 0 {
+				continue
+			}
+			consumers = append(consumers, reply.MakeMultiBulkReply([][]byte{
+				[]byte(c.Name),
+				[]byte(strconv.Itoa(c.PendingCount())),
+			}))
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeIntReply(int64(g.PendingCount())),
+			reply.MakeBulkReply([]byte(minID.String())),
+			reply.MakeBulkReply([]byte(maxID.String())),
+			reply.MakeMultiRawReply(consumers),
+		})
+	}
+
+	options := args[2:]
+	var minIdle int64
+	if strings.ToUpper(string(options[0])) == "IDLE" {
+		if len(options) < 2 {
+			return reply.MakeSyntaxErrReply()
+		}
+		var err error
+		minIdle, err = strconv.ParseInt(string(options[1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		options = options[2:]
+	}
+	if len(options) != 3 && len(options) != 4 {
+		return reply.MakeSyntaxErrReply()
+	}
+	start, errReply := parseRangeID(options[0], true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(options[1], false)
+	if errReply != nil {
+		return errReply
+	}
+	count, err := strconv.Atoi(string(options[2]))
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	var consumer *stream.Consumer
+	if len(options) == 4 {
+		consumer = g.Consumer(string(options[3]))
+		if consumer == nil {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+	}
+	result := make([]resp.Reply, 0)
+	if count <= 0 || end.Less(start) {
+		return reply.MakeMultiRawReply(result)
+	}
+	appendPending := func(pe *stream.PendingEntry) bool {
+		if end.Less(pe.ID) {
+			return false
+		}
+		idle := now - pe.DeliveryTime
+		if idle < minIdle {
+			return true
+		}
+		result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(pe.ID.String())),
+			reply.MakeBulkReply([]byte(pe.Consumer.Name)),
+			reply.MakeIntReply(idle),
+			reply.MakeIntReply(pe.DeliveryCount),
+		}))
+		return len(result) < count
+	}
+	if consumer != nil {
+		consumer.ForEachPending(start, appendPending)
+	} else {
+		g.ForEachPending(start, end, appendPending)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// claimOptions XCLAIM 的可选参数
+type claimOptions struct {
+	deliveryTime int64
+	retryCount   int64 // -1 表示没有指定
+	force        bool
+	justID       bool
+	lastID       *stream.ID
+}
+
+// XCLAIM K group consumer min-idle-time id ... [IDLE ms] [TIME ms] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID id]
+func execXClaim(db *DB, args [][]byte) resp.Reply {
+	key := args[0]
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+	// 第一个不能解析为ID的参数之后都是可选参数
+	var ids []stream.ID
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := stream.ParseID(string(args[i]), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return reply.MakeErrReply(stream.ErrInvalidID.Error())
+	}
+	now := time.Now().UnixMilli()
+	opts := &claimOptions{deliveryTime: now, retryCount: -1}
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "FORCE":
+			opts.force = true
+		case "JUSTID":
+			opts.justID = true
+		case "IDLE", "TIME", "RETRYCOUNT":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR Invalid " + option + " option argument for XCLAIM")
+			}
+			switch option {
+			case "IDLE":
+				opts.deliveryTime = now - n
+			case "TIME":
+				opts.deliveryTime = n
+			case "RETRYCOUNT":
+				opts.retryCount = n
+			}
+			i++
+		case "LASTID":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			id, errReply := parseStreamID(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			opts.lastID = &id
+			i++
+		default:
+			return reply.MakeErrReply("ERR Unrecognized XCLAIM option '" + string(args[i]) + "'")
+		}
+	}
+	if opts.deliveryTime > now {
+		opts.deliveryTime = now
+	}
+
+	s, g, errReply := db.getStreamGroup(string(key), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	if opts.lastID != nil && g.LastID.Less(*opts.lastID) {
+		g.LastID = *opts.lastID
+		db.addAof(makeSetIDAof(key, g))
+	}
+	var consumer *stream.Consumer
+	var result []resp.Reply
+	for _, id := range ids {
+		pe := g.Pending(id)
+		_, exists := s.Get(id)
+		if pe == nil {
+			// FORCE：消息存在于stream中但是不在PEL中时，也加入PEL
+			if !opts.force || !exists {
+				continue
+			}
+			if consumer == nil {
+				consumer = db.getOrCreateConsumer(key, g, string(args[2]), now)
+			}
+			pe = g.Deliver(id, consumer, now)
+		} else if minIdle > 0 && now-pe.DeliveryTime < minIdle {
+			continue
+		}
+		if !exists { // 消息已经被删除，从PEL中移除
+			g.Ack(id)
+			db.addAof(utils.ToCmdLine("xack", string(key), g.Name, id.String()))
+			continue
+		}
+		if consumer == nil {
+			consumer = db.getOrCreateConsumer(key, g, string(args[2]), now)
+		}
+		deliveryCount := pe.DeliveryCount
+		if opts.retryCount >= 0 {
+			deliveryCount = opts.retryCount
+		} else if !opts.justID {
+			deliveryCount++
+		}
+		g.Claim(pe, consumer, opts.deliveryTime, deliveryCount)
+		consumer.ActiveTime = now
+		db.addAof(makeClaimAof(key, g, pe))
+		if opts.justID {
+			result = append(result, reply.MakeBulkReply([]byte(id.String())))
+		} else {
+			entry, _ := s.Get(id)
+			result = append(result, entryToReply(entry))
+		}
+	}
+	if consumer == nil {
+		consumer = db.getOrCreateConsumer(key, g, string(args[2]), now)
+	}
+	consumer.SeenTime = now
+	return reply.MakeMultiRawReply(result)
+}
+
+// XAUTOCLAIM K group consumer min-idle-time start [COUNT count] [JUSTID]
+// 返回 [下一次扫描的起始ID, 转移的消息, 已经被删除的消息ID]
+func execXAutoClaim(db *DB, args [][]byte) resp.Reply {
+	key := args[0]
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+	start, errReply := parseRangeID(args[4], true)
+	if errReply != nil {
+		return errReply
+	}
+	count := xautoclaimDefaultCount
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n < 1 {
+				return reply.MakeErrReply("ERR COUNT must be > 0")
+			}
+			count = n
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	s, g, errReply := db.getStreamGroup(string(key), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	now := time.Now().UnixMilli()
+	consumer := db.getOrCreateConsumer(key, g, string(args[2]), now)
+	consumer.SeenTime = now
+
+	attempts := count * 10 // 最多扫描的待确认消息数
+	next := stream.MinID
+	claimed := make([]resp.Reply, 0)
+	deleted := make([][]byte, 0)
+	var scanned []*stream.PendingEntry
+	g.ForEachPending(start, stream.MaxID, func(pe *stream.PendingEntry) bool {
+		if attempts == 0 || count == 0 {
+			next = pe.ID
+			return false
+		}
+		attempts--
+		scanned = append(scanned, pe)
+		return true
+	})
+	for _, pe := range scanned {
+		if minIdle > 0 && now-pe.DeliveryTime < minIdle {
+			continue
+		}
+		entry, exists := s.Get(pe.ID)
+		if !exists {
+			g.Ack(pe.ID)
+			db.addAof(utils.ToCmdLine("xack", string(key), g.Name, pe.ID.String()))
+			deleted = append(deleted, []byte(pe.ID.String()))
+			continue
+		}
+		deliveryCount := pe.DeliveryCount
+		if !justID {
+			deliveryCount++
+		}
+		g.Claim(pe, consumer, now, deliveryCount)
+		consumer.ActiveTime = now
+		db.addAof(makeClaimAof(key, g, pe))
+		if justID {
+			claimed = append(claimed, reply.MakeBulkReply([]byte(pe.ID.String())))
+		} else {
+			claimed = append(claimed, entryToReply(entry))
+		}
+		count--
+		if count == 0 {
+			// 还有没有扫描的待确认消息时从下一条继续
+			next = stream.MinID
+			if n, ok := pe.ID.Incr(); ok {
+				g.ForEachPending(n, stream.MaxID, func(pe *stream.PendingEntry) bool {
+					next = pe.ID
+					return false
+				})
+			}
+			break
+		}
+	}
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(next.String())),
+		reply.MakeMultiRawReply(claimed),
+		reply.MakeMultiBulkReply(deleted),
+	})
+}
+
+// ---------------------- XINFO ----------------------
+
+// nullableEntriesRead 已读消息数未知时返回nil
+func nullableEntriesRead(n int64) resp.Reply {
+	if n == stream.InvalidEntriesRead {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeIntReply(n)
+}
+
+func groupLagReply(s *stream.Stream, g *stream.Group) resp.Reply {
+	lag, ok := s.Lag(g)
+	if !ok {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeIntReply(lag)
+}
+
+func idReply(id stream.ID) resp.Reply {
+	return reply.MakeBulkReply([]byte(id.String()))
+}
+
+func fieldName(name string) resp.Reply {
+	return reply.MakeBulkReply([]byte(name))
+}
+
+// XINFO STREAM K [FULL [COUNT count]] | XINFO GROUPS K | XINFO CONSUMERS K group
+func execXInfo(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	if len(args) < 2 {
+		return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + string(args[0]) + "'")
+	}
+	key := string(args[1])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	switch subCmd {
+	case "STREAM":
+		if s == nil {
+			return reply.MakeErrReply("ERR no such key")
+		}
+		return xInfoStream(s, args[2:])
+	case "GROUPS":
+		if len(args) != 2 {
+			return reply.MakeArgNumErrReply("xinfo|groups")
+		}
+		if s == nil {
+			return reply.MakeErrReply("ERR no such key")
+		}
+		result := make([]resp.Reply, 0)
+		for _, g := range s.Groups() {
+			result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+				fieldName("name"), reply.MakeBulkReply([]byte(g.Name)),
+				fieldName("consumers"), reply.MakeIntReply(int64(len(g.Consumers()))),
+				fieldName("pending"), reply.MakeIntReply(int64(g.PendingCount())),
+				fieldName("last-delivered-id"), idReply(g.LastID),
+				fieldName("entries-read"), nullableEntriesRead(g.EntriesRead),
+				fieldName("lag"), groupLagReply(s, g),
+			}))
+		}
+		return reply.MakeMultiRawReply(result)
+	case "CONSUMERS":
+		if len(args) != 3 {
+			return reply.MakeArgNumErrReply("xinfo|consumers")
+		}
+		if s == nil {
+			return reply.MakeErrReply("ERR no such key")
+		}
+		g := s.Group(string(args[2]))
+		if g == nil {
+			return makeNoGroupErr(key, string(args[2]))
+		}
+		now := time.Now().UnixMilli()
+		result := make([]resp.Reply, 0)
+		for _, c := range g.Consumers() {
+			inactive := int64(-1)
+			if c.ActiveTime >= 0 {
+				inactive = now - c.ActiveTime
+			}
+			result = append(result, reply.MakeMultiRawReply([]resp.Reply{
+				fieldName("name"), reply.MakeBulkReply([]byte(c.Name)),
+				fieldName("pending"), reply.MakeIntReply(int64(c.PendingCount())),
+				fieldName("idle"), reply.MakeIntReply(now - c.SeenTime),
+				fieldName("inactive"), reply.MakeIntReply(inactive),
+			}))
+		}
+		return reply.MakeMultiRawReply(result)
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XINFO HELP.")
+}
+
+// xInfoStream XINFO STREAM K [FULL [COUNT count]]
+func xInfoStream(s *stream.Stream, options [][]byte) resp.Reply {
+	full := false
+	count := 10 // FULL 模式下默认最多返回的消息数和待确认消息数，0表示不限制
+	if len(options) > 0 {
+		if strings.ToUpper(string(options[0])) != "FULL" {
+			return reply.MakeSyntaxErrReply()
+		}
+		full = true
+		if len(options) > 1 {
+			if len(options) != 3 || strings.ToUpper(string(options[1])) != "COUNT" {
+				return reply.MakeSyntaxErrReply()
+			}
+			n, err := strconv.Atoi(string(options[2]))
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if n < 0 {
+				n = 0
+			}
+			count = n
+		}
+	}
+	keys, nodes := s.NodeCount()
+	result := []resp.Reply{
+		fieldName("length"), reply.MakeIntReply(s.Len()),
+		fieldName("radix-tree-keys"), reply.MakeIntReply(int64(keys)),
+		fieldName("radix-tree-nodes"), reply.MakeIntReply(int64(nodes)),
+		fieldName("last-generated-id"), idReply(s.LastID()),
+		fieldName("max-deleted-entry-id"), idReply(s.MaxDeletedID()),
+		fieldName("entries-added"), reply.MakeIntReply(s.EntriesAdded()),
+		fieldName("recorded-first-entry-id"), idReply(s.FirstID()),
+	}
+	if !full {
+		groups := s.Groups()
+		result = append(result, fieldName("groups"), reply.MakeIntReply(int64(len(groups))))
+		for _, field := range []string{"first-entry", "last-entry"} {
+			var entry *stream.Entry
+			if field == "first-entry" {
+				entry = s.First()
+			} else {
+				entry = s.Last()
+			}
+			result = append(result, fieldName(field))
+			if entry == nil {
+				result = append(result, reply.MakeNullBulkReply())
+			} else {
+				result = append(result, entryToReply(entry))
+			}
+		}
+		return reply.MakeMultiRawReply(result)
+	}
+
+	result = append(result, fieldName("entries"), entriesToReply(s.Range(stream.MinID, stream.MaxID, count, false)))
+	groups := make([]resp.Reply, 0)
+	for _, g := range s.Groups() {
+		pending := make([]resp.Reply, 0)
+		g.ForEachPending(stream.MinID, stream.MaxID, func(pe *stream.PendingEntry) bool {
+			pending = append(pending, reply.MakeMultiRawReply([]resp.Reply{
+				idReply(pe.ID),
+				reply.MakeBulkReply([]byte(pe.Consumer.Name)),
+				reply.MakeIntReply(pe.DeliveryTime),
+				reply.MakeIntReply(pe.DeliveryCount),
+			}))
+			return count == 0 || len(pending) < count
+		})
+		consumers := make([]resp.Reply, 0)
+		for _, c := range g.Consumers() {
+			consumerPending := make([]resp.Reply, 0)
+			c.ForEachPending(stream.MinID, func(pe *stream.PendingEntry) bool {
+				consumerPending = append(consumerPending, reply.MakeMultiRawReply([]resp.Reply{
+					idReply(pe.ID),
+					reply.MakeIntReply(pe.DeliveryTime),
+					reply.MakeIntReply(pe.DeliveryCount),
+				}))
+				return count == 0 || len(consumerPending) < count
+			})
+			consumers = append(consumers, reply.MakeMultiRawReply([]resp.Reply{
+				fieldName("name"), reply.MakeBulkReply([]byte(c.Name)),
+				fieldName("seen-time"), reply.MakeIntReply(c.SeenTime),
+				fieldName("active-time"), reply.MakeIntReply(c.ActiveTime),
+				fieldName("pel-count"), reply.MakeIntReply(int64(c.PendingCount())),
+				fieldName("pending"), reply.MakeMultiRawReply(consumerPending),
+			}))
+		}
+		groups = append(groups, reply.MakeMultiRawReply([]resp.Reply{
+			fieldName("name"), reply.MakeBulkReply([]byte(g.Name)),
+			fieldName("last-delivered-id"), idReply(g.LastID),
+			fieldName("entries-read"), nullableEntriesRead(g.EntriesRead),
+			fieldName("lag"), groupLagReply(s, g),
+			fieldName("pel-count"), reply.MakeIntReply(int64(g.PendingCount())),
+			fieldName("pending"), reply.MakeMultiRawReply(pending),
+			fieldName("consumers"), reply.MakeMultiRawReply(consumers),
+		}))
+	}
+	result = append(result, fieldName("groups"), reply.MakeMultiRawReply(groups))
+	return reply.MakeMultiRawReply(result)
+}
+
+func init() {
+	RegisterCommand("xgroup", execXGroup, nil, -2, flagWrite, 2, 2, 1)                                      // XGROUP CREATE|SETID|DESTROY|CREATECONSUMER|DELCONSUMER K group ...
+	RegisterConnCommand("xreadgroup", execXReadGroup, writeStreamKeys, -7, flagWrite|flagBlocking, 0, 0, 0) // XREADGROUP GROUP group consumer [options] STREAMS K id
+	RegisterCommand("xack", execXAck, nil, -4, flagWrite|flagFast, 1, 1, 1)                                 // XACK K group id ...
+	RegisterCommand("xpending", execXPending, nil, -3, flagReadOnly, 1, 1, 1)                               // XPENDING K group [[IDLE min-idle-time] start end count [consumer]]
+	RegisterCommand("xclaim", execXClaim, nil, -6, flagWrite|flagFast, 1, 1, 1)                             // XCLAIM K group consumer min-idle-time id ... [options]
+	RegisterCommand("xautoclaim", execXAutoClaim, nil, -6, flagWrite|flagFast, 1, 1, 1)                     // XAUTOCLAIM K group consumer min-idle-time start [COUNT count] [JUSTID]
+	RegisterCommand("xinfo", execXInfo, nil, -2, flagReadOnly, 2, 2, 1)                                     // XINFO STREAM|GROUPS|CONSUMERS K ...
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"strings"
+	"testing"
+)
+
+func TestStreamGroupLifecycle(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		execCmd(e, c, "xadd", "s", id, "f", "v"+id[:1])
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"xgroup", "create", "s", "g", "0"}, "OK"},
+		{[]string{"xgroup", "create", "s", "g", "0"}, "BUSYGROUP Consumer Group name already exists"},
+		{[]string{"xgroup", "create", "s", "g2", "$"}, "OK"},
+		{[]string{"xreadgroup", "group", "g", "alice", "count", "2", "streams", "s", ">"}, "[[s [[1-0 [f v1]] [2-0 [f v2]]]]]"},
+		{[]string{"xreadgroup", "group", "g", "bob", "streams", "s", ">"}, "[[s [[3-0 [f v3]]]]]"},
+		{[]string{"xreadgroup", "group", "g", "bob", "streams", "s", ">"}, "nil"},
+		{[]string{"xreadgroup", "group", "g2", "bob", "streams", "s", ">"}, "nil"}, // $ 只读取之后的新消息
+		// 读取自己的历史消息，不会分发新消息
+		{[]string{"xreadgroup", "group", "g", "alice", "streams", "s", "0"}, "[[s [[1-0 [f v1]] [2-0 [f v2]]]]]"},
+		{[]string{"xpending", "s", "g"}, "[3 1-0 3-0 [[alice 2] [bob 1]]]"},
+		{[]string{"xack", "s", "g", "1-0", "9-0"}, "1"},
+		{[]string{"xack", "s", "g", "1-0"}, "0"},
+		{[]string{"xpending", "s", "g"}, "[2 2-0 3-0 [[alice 1] [bob 1]]]"},
+		{[]string{"xinfo", "groups", "s"}, "[[name g consumers 2 pending 2 last-delivered-id 3-0 entries-read 3 lag 0] " +
+			"[name g2 consumers 1 pending 0 last-delivered-id 3-0 entries-read nil lag 0]]"},
+		{[]string{"xgroup", "delconsumer", "s", "g", "alice"}, "1"},
+		{[]string{"xpending", "s", "g"}, "[1 3-0 3-0 [[bob 1]]]"},
+		// SETID 之后重新分发，已经在PEL中的消息转移给新的消费者
+		{[]string{"xgroup", "setid", "s", "g", "0", "entriesread", "0"}, "OK"},
+		{[]string{"xreadgroup", "group", "g", "carol", "streams", "s", ">"}, "[[s [[1-0 [f v1]] [2-0 [f v2]] [3-0 [f v3]]]]]"},
+		{[]string{"xpending", "s", "g"}, "[3 1-0 3-0 [[carol 3]]]"},
+		{[]string{"xgroup", "destroy", "s", "g"}, "1"},
+		{[]string{"xreadgroup", "group", "g", "carol", "streams", "s", ">"},
+			"NOGROUP No such key 's' or consumer group 'g' in XREADGROUP with GROUP option"},
+		{[]string{"xgroup", "create", "nos", "g", "0"}, "ERR The XGROUP subcommand requires the key to exist. " +
+			"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."},
+		{[]string{"xgroup", "create", "nos", "g", "$", "mkstream"}, "OK"},
+		{[]string{"xlen", "nos"}, "0"},
+	}
+	for _, tt := range tests {
+		if got := renderReply(execCmd(e, c, tt.args...)); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+// pendingOf 返回XPENDING扩展格式中每条消息的 ID 消费者 分发次数，忽略空闲时间
+func pendingOf(t *testing.T, e *StandaloneDatabase, c resp.Connection, args ...string) string {
+	t.Helper()
+	entries := strings.Fields(strings.NewReplacer("[", " ", "]", " ").Replace(renderReply(execCmd(e, c, args...))))
+	if len(entries)%4 != 0 {
+		t.Fatalf("unexpected XPENDING reply %v", entries)
+	}
+	var result []string
+	for i := 0; i < len(entries); i += 4 {
+		result = append(result, entries[i]+" "+entries[i+1]+" "+entries[i+3])
+	}
+	return strings.Join(result, ", ")
+}
+
+func TestStreamClaim(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0"} {
+		execCmd(e, c, "xadd", "s", id, "f", "v"+id[:1])
+	}
+	execCmd(e, c, "xgroup", "create", "s", "g", "0")
+	execCmd(e, c, "xreadgroup", "group", "g", "alice", "streams", "s", ">")
+
+	// 空闲时间不足时不认领
+	assertReply(t, execCmd(e, c, "xclaim", "s", "g", "bob", "3600000", "1-0"), "*0\r\n")
+	if got := renderReply(execCmd(e, c, "xclaim", "s", "g", "bob", "0", "1-0")); got != "[[1-0 [f v1]]]" {
+		t.Errorf("xclaim returned %s", got)
+	}
+	// JUSTID 不增加分发次数
+	if got := renderReply(execCmd(e, c, "xclaim", "s", "g", "bob", "0", "2-0", "justid")); got != "[2-0]" {
+		t.Errorf("xclaim justid returned %s", got)
+	}
+	if got := pendingOf(t, e, c, "xpending", "s", "g", "-", "+", "10"); got != "1-0 bob 2, 2-0 bob 1, 3-0 alice 1, 4-0 alice 1" {
+		t.Errorf("pending after xclaim: %s", got)
+	}
+	if got := pendingOf(t, e, c, "xpending", "s", "g", "-", "+", "10", "alice"); got != "3-0 alice 1, 4-0 alice 1" {
+		t.Errorf("pending of alice: %s", got)
+	}
+
+	// 已经删除的消息从PEL中移除，并在第三个元素中返回
+	execCmd(e, c, "xdel", "s", "3-0")
+	if got := renderReply(execCmd(e, c, "xautoclaim", "s", "g", "carol", "0", "0", "count", "2")); got != "[3-0 [[1-0 [f v1]] [2-0 [f v2]]] []]" {
+		t.Errorf("xautoclaim returned %s", got)
+	}
+	if got := renderReply(execCmd(e, c, "xautoclaim", "s", "g", "carol", "0", "3-0", "justid")); got != "[0-0 [4-0] [3-0]]" {
+		t.Errorf("xautoclaim from cursor returned %s", got)
+	}
+	if got := pendingOf(t, e, c, "xpending", "s", "g", "-", "+", "10"); got != "1-0 carol 3, 2-0 carol 2, 4-0 carol 1" {
+		t.Errorf("pending after xautoclaim: %s", got)
+	}
+	assertReply(t, execCmd(e, c, "xclaim", "s", "g", "bob", "-1", "1-0", "idle", "abc"), "-ERR Invalid IDLE option argument for XCLAIM\r\n")
+}
+
+// 消费者组的状态通过aof恢复之后和原先一致
+func TestStreamGroupAof(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	var lines []CmdLine
+	e.dbSet[0].writeAof = func(line CmdLine) {
+		lines = append(lines, line)
+	}
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		execCmd(e, c, "xadd", "s", id, "f", "v")
+	}
+	execCmd(e, c, "xgroup", "create", "s", "g", "0")
+	execCmd(e, c, "xreadgroup", "group", "g", "alice", "count", "2", "streams", "s", ">")
+	execCmd(e, c, "xreadgroup", "group", "g", "bob", "streams", "s", ">")
+	execCmd(e, c, "xack", "s", "g", "1-0")
+	execCmd(e, c, "xclaim", "s", "g", "bob", "0", "2-0")
+	execCmd(e, c, "xgroup", "createconsumer", "s", "g", "idle")
+
+	replayed, c2 := makeTestDatabase(t)
+	for _, line := range lines {
+		replayed.Exec(c2, line)
+	}
+	for _, args := range [][]string{
+		{"xpending", "s", "g"},
+		{"xinfo", "groups", "s"},
+		{"xlen", "s"},
+	} {
+		want := renderReply(execCmd(e, c, args...))
+		if got := renderReply(execCmd(replayed, c2, args...)); got != want {
+			t.Errorf("%v after replay: got %s, want %s", args, got, want)
+		}
+	}
+	want := pendingOf(t, e, c, "xpending", "s", "g", "-", "+", "10")
+	if got := pendingOf(t, replayed, c2, "xpending", "s", "g", "-", "+", "10"); got != want || want != "2-0 bob 2, 3-0 bob 1" {
+		t.Errorf("pending after replay: got %s, want %s", got, want)
+	}
+}
@@ -1,36 +1,188 @@
 package database
 
 import (
+	"go_redis/aof"
 	"go_redis/interface/database"
 	"go_redis/interface/resp"
 	"go_redis/lib/utils"
 	"go_redis/resp/reply"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 //string类型 的常用指令
 
+// getAsString 取出key对应的字符串，key不存在返回nil，类型不是string返回WRONGTYPE错误
+func (db *DB) getAsString(key string) ([]byte, reply.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	bytes, ok := entity.Data.([]byte) // 进行类型断言，value可能是其他的数据类型
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return bytes, nil
+}
+
 // GET k1
 func execGet(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
-	v, ok := db.GetEntity(key)
-	if !ok { //k 不存在
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil { //k 不存在
 		return reply.MakeNullBulkReply()
 	}
-	bytes, _ := v.Data.([]byte) // 进行类型断言，只是实现了string，所以一定可以断言成功，不需要进一步判断
 	return reply.MakeBulkReply(bytes)
 }
 
-// SET K V
+// SET 指令的可选参数
+const (
+	upsertPolicy = iota // 默认：存在则覆盖，不存在则插入
+	insertPolicy        // NX：只有不存在才插入
+	updatePolicy        // XX：只有存在才更新
+)
+
+// SET K V [NX|XX] [GET] [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp|KEEPTTL]
 func execSet(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
 	value := args[1]
+	policy := upsertPolicy
+	ttlSet := false // 是否已经指定了过期相关的参数，EX/PX/EXAT/PXAT/KEEPTTL 只能出现一个
+	keepTTL := false
+	withGet := false
+	var expireTime time.Time
+
+	// 解析可选参数
+	for i := 2; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch arg {
+		case "NX":
+			if policy == updatePolicy {
+				return reply.MakeSyntaxErrReply()
+			}
+			policy = insertPolicy
+		case "XX":
+			if policy == insertPolicy {
+				return reply.MakeSyntaxErrReply()
+			}
+			policy = updatePolicy
+		case "GET":
+			withGet = true
+		case "KEEPTTL":
+			if ttlSet {
+				return reply.MakeSyntaxErrReply()
+			}
+			ttlSet = true
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if ttlSet || i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			ttlSet = true
+			var errReply resp.Reply
+			expireTime, errReply = parseExpireOption(arg, args[i+1], "set")
+			if errReply != nil {
+				return errReply
+			}
+			i++ // 跳过时间参数
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	// 已经过期但是还没有删除的key先删除，否则 KEEPTTL 会保留已经过去的过期时间
+	db.IsExpired(key)
+
+	// GET 参数需要返回原先的值
+	var oldValue []byte
+	if withGet {
+		bytes, errReply := db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+		oldValue = bytes
+	}
+
 	// 就是将value包装一下即可
 	entity := &database.DataEntity{
 		Data: value,
 	}
-	db.PutEntity(key, entity)
-	db.addAof(utils.ToCmdLine3("set", args...))
-	return reply.MakeOkReply()
+	var result int
+	switch policy {
+	case upsertPolicy:
+		result = db.PutEntity(key, entity) + 1 // 保证result>0，表示写入成功
+	case insertPolicy:
+		result = db.PutIfAbsent(key, entity)
+	case updatePolicy:
+		result = db.PutIfExits(key, entity)
+	}
+	if result > 0 {
+		// aof统一记录为 SET k v [KEEPTTL] + PEXPIREAT，重放时不依赖NX/XX的判断结果以及执行时的时间
+		if keepTTL {
+			db.addAof(utils.ToCmdLine3("set", args[0], args[1], []byte("KEEPTTL")))
+		} else {
+			db.Persist(key) // SET 会覆盖原先的过期时间
+			db.addAof(utils.ToCmdLine3("set", args[0], args[1]))
+		}
+		db.notify(notifyString, "set", key)
+		if !expireTime.IsZero() {
+			if expireTime.After(time.Now()) {
+				db.Expire(key, expireTime)
+				db.addAof(aof.MakeExpireCmd(key, expireTime))
+				db.notify(notifyGeneric, "expire", key)
+			} else { // 指定的绝对时间已经过去，写入后立即过期
+				db.Remove(key)
+				db.addAof(utils.ToCmdLine("del", key))
+				db.notify(notifyGeneric, "del", key)
+			}
+		}
+	}
+
+	if withGet {
+		if oldValue == nil {
+			return reply.MakeNullBulkReply()
+		}
+		return reply.MakeBulkReply(oldValue)
+	}
+	if result > 0 {
+		return reply.MakeOkReply()
+	}
+	return reply.MakeNullBulkReply() // NX/XX 条件不满足
+}
+
+// parseExpireOption 解析 SET/GETEX 的 EX/PX/EXAT/PXAT 参数，返回过期的绝对时间
+func parseExpireOption(option string, arg []byte, cmdName string) (time.Time, resp.Reply) {
+	num, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return time.Time{}, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	invalid := reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	if num <= 0 {
+		return time.Time{}, invalid
+	}
+	switch option {
+	case "EX", "PX":
+		unit := time.Second
+		if option == "PX" {
+			unit = time.Millisecond
+		}
+		duration, ok := toDuration(num, unit)
+		if !ok {
+			return time.Time{}, invalid
+		}
+		return time.Now().Add(duration), nil
+	case "EXAT":
+		if num > math.MaxInt64/1000 { // 转化为毫秒时间戳之后溢出
+			return time.Time{}, invalid
+		}
+		return time.Unix(num, 0), nil
+	}
+	return time.UnixMilli(num), nil
 }
 
 // SETNX K1 V1   k1存在则返回0 不操作；不存在 1 则插入
@@ -42,7 +194,10 @@ func execSetnx(db *DB, args [][]byte) resp.Reply {
 		Data: value,
 	}
 	result := db.PutIfAbsent(key, entity)
-	db.addAof(utils.ToCmdLine3("setnx", args...))
+	if result > 0 { // 没有写入时不记录aof
+		db.addAof(utils.ToCmdLine3("setnx", args...))
+		db.notify(notifyString, "set", key)
+	}
 	return reply.MakeIntReply(int64(result))
 }
 
@@ -50,34 +205,232 @@ func execSetnx(db *DB, args [][]byte) resp.Reply {
 func execGetSet(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
 	value := args[1]
-	oldvalue, ok := db.GetEntity(key)
+	oldvalue, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
 	db.PutEntity(key, &database.DataEntity{
 		Data: value,
 	})
+	db.Persist(key)
 	db.addAof(utils.ToCmdLine3("getset", args...))
-	if !ok { //原先key不存在
+	db.notify(notifyString, "set", key)
+	if oldvalue == nil { //原先key不存在
 		return reply.MakeNullBulkReply()
 	}
 	//存在，返回原来的值，包装为resp协议的格式
-	result, _ := oldvalue.Data.([]byte)
-	return reply.MakeBulkReply(result)
+	return reply.MakeBulkReply(oldvalue)
 }
 
 // STRLEN k -> 'value'   返回key指向的值的字符串的长度
 func execStrlen(db *DB, args [][]byte) resp.Reply {
 	key := string(args[0])
-	value, ok := db.GetEntity(key)
-	if !ok {
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if value == nil {
 		return reply.MakeNullBulkReply()
 	}
-	result, _ := value.Data.([]byte)
-	return reply.MakeIntReply(int64(len(result)))
+	return reply.MakeIntReply(int64(len(value)))
+}
+
+// incrBy INCR/DECR/INCRBY/DECRBY 的公共逻辑，执行器已经持有key的写锁，读取-计算-写入是原子的
+func incrBy(db *DB, key string, delta int64) resp.Reply {
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current int64
+	if value != nil {
+		var err error
+		current, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+	}
+	// 溢出检查
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return reply.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	current += delta
+	db.PutEntity(key, &database.DataEntity{ // 只修改value，保留原先的过期时间
+		Data: []byte(strconv.FormatInt(current, 10)),
+	})
+	db.notify(notifyString, "incrby", key)
+	return reply.MakeIntReply(current)
+}
+
+// parseDelta 解析增量参数
+func parseDelta(arg []byte) (int64, reply.ErrorReply) {
+	delta, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	return delta, nil
+}
+
+// INCR K
+func execIncr(db *DB, args [][]byte) resp.Reply {
+	result := incrBy(db, string(args[0]), 1)
+	if !reply.IsErrReply(result) {
+		db.addAof(utils.ToCmdLine3("incr", args...))
+	}
+	return result
+}
+
+// DECR K
+func execDecr(db *DB, args [][]byte) resp.Reply {
+	result := incrBy(db, string(args[0]), -1)
+	if !reply.IsErrReply(result) {
+		db.addAof(utils.ToCmdLine3("decr", args...))
+	}
+	return result
+}
+
+// INCRBY K increment
+func execIncrBy(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseDelta(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	result := incrBy(db, string(args[0]), delta)
+	if !reply.IsErrReply(result) {
+		db.addAof(utils.ToCmdLine3("incrby", args...))
+	}
+	return result
+}
+
+// DECRBY K decrement
+func execDecrBy(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseDelta(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if delta == math.MinInt64 { // 取反会溢出
+		return reply.MakeErrReply("ERR decrement would overflow")
+	}
+	result := incrBy(db, string(args[0]), -delta)
+	if !reply.IsErrReply(result) {
+		db.addAof(utils.ToCmdLine3("decrby", args...))
+	}
+	return result
+}
+
+// INCRBYFLOAT K increment   aof中记录为SET最终的值(保留过期时间)，避免浮点数重放的误差
+func execIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	delta, err := strconv.ParseFloat(string(args[1]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return reply.MakeErrReply("ERR value is not a valid float")
+	}
+
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current float64
+	if value != nil {
+		current, err = strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not a valid float")
+		}
+	}
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result := []byte(strconv.FormatFloat(current, 'f', -1, 64))
+	db.PutEntity(key, &database.DataEntity{
+		Data: result,
+	})
+	db.addAof(utils.ToCmdLine3("set", args[0], result, []byte("KEEPTTL")))
+	db.notify(notifyString, "incrbyfloat", key)
+	return reply.MakeBulkReply(result)
+}
+
+// MSET K1 V1 K2 V2 ...
+func execMSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 0 {
+		return reply.MakeArgNumErrReply("mset")
+	}
+	size := len(args) / 2
+	keys := make([]string, size)
+	for i := 0; i < size; i++ {
+		keys[i] = string(args[2*i])
+	}
+
+	for i, key := range keys {
+		db.PutEntity(key, &database.DataEntity{
+			Data: args[2*i+1],
+		})
+		db.Persist(key)
+	}
+	db.addAof(utils.ToCmdLine3("mset", args...))
+	for _, key := range keys {
+		db.notify(notifyString, "set", key)
+	}
+	return reply.MakeOkReply()
+}
+
+// MGET K1 K2 ...   不存在或者类型不是string的key返回nil
+func execMGet(db *DB, args [][]byte) resp.Reply {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+
+	result := make([][]byte, len(keys))
+	for i, key := range keys {
+		bytes, errReply := db.getAsString(key)
+		if errReply != nil {
+			continue
+		}
+		result[i] = bytes
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// MSETNX K1 V1 K2 V2 ...   只有所有的key都不存在时才写入，返回1；否则什么都不做，返回0
+func execMSetNX(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 0 {
+		return reply.MakeArgNumErrReply("msetnx")
+	}
+	size := len(args) / 2
+	keys := make([]string, size)
+	for i := 0; i < size; i++ {
+		keys[i] = string(args[2*i])
+	}
+
+	for _, key := range keys {
+		if _, exists := db.GetEntity(key); exists {
+			return reply.MakeIntReply(0)
+		}
+	}
+	for i, key := range keys {
+		db.PutEntity(key, &database.DataEntity{
+			Data: args[2*i+1],
+		})
+	}
+	db.addAof(utils.ToCmdLine3("msetnx", args...))
+	for _, key := range keys {
+		db.notify(notifyString, "set", key)
+	}
+	return reply.MakeIntReply(1)
 }
 
 func init() {
-	RegisterCommand("get", execGet, 2)
-	RegisterCommand("set", execSet, 3)
-	RegisterCommand("setnx", execSetnx, 3)
-	RegisterCommand("getset", execGetSet, 3)
-	RegisterCommand("strlen", execStrlen, 2)
+	RegisterCommand("get", execGet, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("set", execSet, nil, -3, flagWrite, 1, 1, 1) // SET K V [NX|XX] [GET] [EX|PX|EXAT|PXAT|KEEPTTL]
+	RegisterCommand("setnx", execSetnx, nil, 3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("getset", execGetSet, nil, 3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("strlen", execStrlen, nil, 2, flagReadOnly|flagFast, 1, 1, 1)
+	RegisterCommand("incr", execIncr, nil, 2, flagWrite|flagFast, 1, 1, 1) // INCR K
+	RegisterCommand("decr", execDecr, nil, 2, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("incrby", execIncrBy, nil, 3, flagWrite|flagFast, 1, 1, 1) // INCRBY K increment
+	RegisterCommand("decrby", execDecrBy, nil, 3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("incrbyfloat", execIncrByFloat, nil, 3, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("mset", execMSet, nil, -3, flagWrite, 1, -1, 2) // MSET K1 V1 K2 V2 ...
+	RegisterCommand("mget", execMGet, nil, -2, flagReadOnly|flagFast, 1, -1, 1)
+	RegisterCommand("msetnx", execMSetNX, nil, -3, flagWrite, 1, -1, 2)
 }
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"go_redis/aof"
+	"go_redis/interface/database"
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 字符串的子串操作：APPEND GETRANGE SETRANGE GETDEL GETEX LCS
+
+const maxStringSize = 512 * 1024 * 1024 // 字符串的最大长度 512MB
+
+// APPEND K V   返回追加之后的长度
+func execAppend(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if len(value)+len(args[1]) > maxStringSize {
+		return reply.MakeErrReply("ERR string exceeds maximum allowed size (512MB)")
+	}
+	// 重新分配内存，避免修改到其他地方引用的切片
+	result := make([]byte, 0, len(value)+len(args[1]))
+	result = append(result, value...)
+	result = append(result, args[1]...)
+	db.PutEntity(key, &database.DataEntity{
+		Data: result,
+	})
+	db.addAof(utils.ToCmdLine3("append", args...))
+	db.notify(notifyString, "append", key)
+	return reply.MakeIntReply(int64(len(result)))
+}
+
+// GETRANGE K start end   start和end都是闭区间，可以为负数
+func execGetRange(db *DB, args [][]byte) resp.Reply {
+	start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
+	end, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	if err1 != nil || err2 != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(value) == 0 {
+		return reply.MakeBulkReply([]byte{})
+	}
+	size := int64(len(value))
+	// 和redis保持一致：超出范围的下标截断到边界
+	if start < 0 && start < -size {
+		start = -size
+	}
+	if end >= size {
+		end = size - 1
+	}
+	begin, stop := utils.ConvertRange(start, end, size)
+	if begin < 0 || begin >= stop {
+		return reply.MakeBulkReply([]byte{})
+	}
+	return reply.MakeBulkReply(value[begin:stop])
+}
+
+// SETRANGE K offset V   从offset开始覆盖，长度不足时用0填充，返回修改之后的长度
+func execSetRange(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || offset < 0 {
+		return reply.MakeErrReply("ERR offset is out of range")
+	}
+	patch := args[2]
+	if offset+int64(len(patch)) > maxStringSize {
+		return reply.MakeErrReply("ERR string exceeds maximum allowed size (512MB)")
+	}
+
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if len(patch) == 0 { // 不修改，直接返回原先的长度
+		return reply.MakeIntReply(int64(len(value)))
+	}
+	size := len(value)
+	if end := int(offset) + len(patch); end > size {
+		size = end
+	}
+	result := make([]byte, size)
+	copy(result, value)
+	copy(result[offset:], patch)
+	db.PutEntity(key, &database.DataEntity{
+		Data: result,
+	})
+	db.addAof(utils.ToCmdLine3("setrange", args...))
+	db.notify(notifyString, "setrange", key)
+	return reply.MakeIntReply(int64(len(result)))
+}
+
+// GETDEL K   返回value并删除key
+func execGetDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if value == nil {
+		return reply.MakeNullBulkReply()
+	}
+	db.Remove(key)
+	db.addAof(utils.ToCmdLine3("del", args...))
+	db.notify(notifyGeneric, "del", key)
+	return reply.MakeBulkReply(value)
+}
+
+// GETEX K [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp|PERSIST]
+func execGetEX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	var expireTime time.Time
+	persist := false
+	for i := 1; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		switch arg {
+		case "PERSIST":
+			if persist || !expireTime.IsZero() {
+				return reply.MakeSyntaxErrReply()
+			}
+			persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if persist || !expireTime.IsZero() || i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			var errReply resp.Reply
+			expireTime, errReply = parseExpireOption(arg, args[i+1], "getex")
+			if errReply != nil {
+				return errReply
+			}
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if value == nil {
+		return reply.MakeNullBulkReply()
+	}
+	if persist {
+		if _, hasTTL := db.TTL(key); hasTTL {
+			db.Persist(key)
+			db.addAof(utils.ToCmdLine("persist", key))
+			db.notify(notifyGeneric, "persist", key)
+		}
+	} else if !expireTime.IsZero() {
+		if expireTime.After(time.Now()) {
+			db.Expire(key, expireTime)
+			db.addAof(aof.MakeExpireCmd(key, expireTime))
+			db.notify(notifyGeneric, "expire", key)
+		} else {
+			db.Remove(key)
+			db.addAof(utils.ToCmdLine("del", key))
+			db.notify(notifyGeneric, "del", key)
+		}
+	}
+	return reply.MakeBulkReply(value)
+}
+
+// lcsMatch LCS IDX 返回的一段匹配：a[aStart:aEnd+1] == b[bStart:bEnd+1]
+type lcsMatch struct {
+	aStart, aEnd int
+	bStart, bEnd int
+}
+
+// computeLCS 动态规划求最长公共子序列，返回公共子序列以及每一段连续的匹配(从后往前)
+func computeLCS(a []byte, b []byte) ([]byte, []lcsMatch) {
+	// dp[i][j] 表示 a[:i] 和 b[:j] 的最长公共子序列的长度
+	dp := make([][]uint32, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]uint32, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else if dp[i-1][j] > dp[i][j-1] {
+				dp[i][j] = dp[i-1][j]
+			} else {
+				dp[i][j] = dp[i][j-1]
+			}
+		}
+	}
+
+	// 从后往前回溯，得到公共子序列以及连续匹配的区间
+	lcs := make([]byte, dp[len(a)][len(b)])
+	idx := len(lcs)
+	var matches []lcsMatch
+	var current *lcsMatch
+	i, j := len(a), len(b)
+	for i > 0 && j > 0 {
+		if a[i-1] == b[j-1] {
+			idx--
+			lcs[idx] = a[i-1]
+			// 和当前的匹配区间连续，则扩展区间，否则开始新的区间
+			if current != nil && current.aStart == i && current.bStart == j {
+				current.aStart = i - 1
+				current.bStart = j - 1
+			} else {
+				if current != nil {
+					matches = append(matches, *current)
+				}
+				current = &lcsMatch{aStart: i - 1, aEnd: i - 1, bStart: j - 1, bEnd: j - 1}
+			}
+			i--
+			j--
+		} else if dp[i-1][j] >= dp[i][j-1] {
+			i--
+		} else {
+			j--
+		}
+	}
+	if current != nil {
+		matches = append(matches, *current)
+	}
+	return lcs, matches
+}
+
+// LCS K1 K2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+func execLCS(db *DB, args [][]byte) resp.Reply {
+	var getLen, getIdx, withMatchLen bool
+	var minMatchLen int64
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "LEN":
+			getLen = true
+		case "IDX":
+			getIdx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if n < 0 {
+				n = 0
+			}
+			minMatchLen = n
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	if getLen && getIdx {
+		return reply.MakeErrReply("ERR If you want both the length and indexes, please just use IDX.")
+	}
+	a, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return reply.MakeErrReply("ERR The specified keys must contain string values")
+	}
+	b, errReply := db.getAsString(string(args[1]))
+	if errReply != nil {
+		return reply.MakeErrReply("ERR The specified keys must contain string values")
+	}
+
+	lcs, matches := computeLCS(a, b)
+	if getLen {
+		return reply.MakeIntReply(int64(len(lcs)))
+	}
+	if !getIdx {
+		return reply.MakeBulkReply(lcs)
+	}
+
+	// IDX：返回每一段匹配在两个字符串中的位置
+	matchReplies := make([]resp.Reply, 0, len(matches))
+	for _, match := range matches {
+		matchLen := match.aEnd - match.aStart + 1
+		if int64(matchLen) < minMatchLen {
+			continue
+		}
+		item := []resp.Reply{
+			reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeIntReply(int64(match.aStart)),
+				reply.MakeIntReply(int64(match.aEnd)),
+			}),
+			reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeIntReply(int64(match.bStart)),
+				reply.MakeIntReply(int64(match.bEnd)),
+			}),
+		}
+		if withMatchLen {
+			item = append(item, reply.MakeIntReply(int64(matchLen)))
+		}
+		matchReplies = append(matchReplies, reply.MakeMultiRawReply(item))
+	}
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte("matches")),
+		reply.MakeMultiRawReply(matchReplies),
+		reply.MakeBulkReply([]byte("len")),
+		reply.MakeIntReply(int64(len(lcs))),
+	})
+}
+
+func init() {
+	RegisterCommand("append", execAppend, nil, 3, flagWrite|flagFast, 1, 1, 1) // APPEND K V
+	RegisterCommand("getrange", execGetRange, nil, 4, flagReadOnly, 1, 1, 1)
+	RegisterCommand("setrange", execSetRange, nil, 4, flagWrite, 1, 1, 1)
+	RegisterCommand("getdel", execGetDel, nil, 2, flagWrite|flagFast, 1, 1, 1)
+	RegisterCommand("getex", execGetEX, nil, -2, flagWrite|flagFast, 1, 1, 1) // GETEX K [EX|PX|EXAT|PXAT|PERSIST]
+	RegisterCommand("lcs", execLCS, nil, -3, flagReadOnly, 1, 2, 1)           // LCS K1 K2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+}
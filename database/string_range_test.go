@@ -0,0 +1,105 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppendRange(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"append", "k", "Hello"}, ":5\r\n"},
+		{[]string{"append", "k", " World"}, ":11\r\n"},
+		{[]string{"getrange", "k", "0", "4"}, "$5\r\nHello\r\n"},
+		{[]string{"getrange", "k", "-5", "-1"}, "$5\r\nWorld\r\n"},
+		{[]string{"getrange", "k", "-100", "100"}, "$11\r\nHello World\r\n"}, // 超出范围时截断
+		{[]string{"getrange", "k", "5", "3"}, "$0\r\n\r\n"},
+		{[]string{"getrange", "missing", "0", "-1"}, "$0\r\n\r\n"},
+		{[]string{"getrange", "k", "a", "1"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"setrange", "k", "6", "Redis"}, ":11\r\n"},
+		{[]string{"get", "k"}, "$11\r\nHello Redis\r\n"},
+		{[]string{"setrange", "pad", "3", "ab"}, ":5\r\n"}, // 长度不足时用0填充
+		{[]string{"get", "pad"}, "$5\r\n\x00\x00\x00ab\r\n"},
+		{[]string{"setrange", "empty", "3", ""}, ":0\r\n"}, // 空字符串不创建key
+		{[]string{"exists", "empty"}, ":0\r\n"},
+		{[]string{"setrange", "k", "-1", "x"}, "-ERR offset is out of range\r\n"},
+		{[]string{"setrange", "k", "536870911", "xx"}, "-ERR string exceeds maximum allowed size (512MB)\r\n"},
+		{[]string{"lpush", "l", "a"}, ":1\r\n"},
+		{[]string{"append", "l", "a"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+}
+
+func TestGetDelGetEX(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"set", "k", "v"}, "+OK\r\n"},
+		{[]string{"getdel", "k"}, "$1\r\nv\r\n"},
+		{[]string{"exists", "k"}, ":0\r\n"},
+		{[]string{"getdel", "k"}, "$-1\r\n"},
+		{[]string{"set", "k", "v"}, "+OK\r\n"},
+		{[]string{"getex", "k", "ex", "100"}, "$1\r\nv\r\n"},
+		{[]string{"ttl", "k"}, ":100\r\n"},
+		{[]string{"getex", "k"}, "$1\r\nv\r\n"}, // 不带参数时不修改过期时间
+		{[]string{"ttl", "k"}, ":100\r\n"},
+		{[]string{"getex", "k", "persist"}, "$1\r\nv\r\n"},
+		{[]string{"ttl", "k"}, ":-1\r\n"},
+		{[]string{"getex", "k", "ex", "10", "persist"}, "-Err syntax error\r\n"},
+		{[]string{"getex", "k", "ex"}, "-Err syntax error\r\n"},
+		{[]string{"getex", "k", "ex", "0"}, "-ERR invalid expire time in 'getex' command\r\n"},
+		{[]string{"getex", "k", "ex", "9223372036854775807"}, "-ERR invalid expire time in 'getex' command\r\n"},
+		{[]string{"getex", "k", "px", "9223372036854775807"}, "-ERR invalid expire time in 'getex' command\r\n"},
+		{[]string{"ttl", "k"}, ":-1\r\n"},
+		{[]string{"getex", "k", "pxat", "1"}, "$1\r\nv\r\n"}, // 绝对时间已经过去，返回value之后删除
+		{[]string{"exists", "k"}, ":0\r\n"},
+		{[]string{"getex", "missing", "ex", "10"}, "$-1\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+
+	// 已经过期的key视为不存在
+	execCmd(e, c, "set", "old", "v")
+	e.dbSet[0].Expire("old", time.Now().Add(-time.Second))
+	assertReply(t, execCmd(e, c, "getex", "old", "persist"), "$-1\r\n")
+	assertReply(t, execCmd(e, c, "getdel", "old"), "$-1\r\n")
+}
+
+func TestLCS(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "set", "key1", "ohmytext")
+	execCmd(e, c, "set", "key2", "mynewtext")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"lcs", "key1", "key2"}, "$6\r\nmytext\r\n"},
+		{[]string{"lcs", "key1", "key2", "len"}, ":6\r\n"},
+		{[]string{"lcs", "key1", "missing"}, "$0\r\n\r\n"},
+		{[]string{"lcs", "key1", "key2", "idx"},
+			"*4\r\n$7\r\nmatches\r\n*2\r\n" +
+				"*2\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n" +
+				"*2\r\n*2\r\n:2\r\n:3\r\n*2\r\n:0\r\n:1\r\n" +
+				"$3\r\nlen\r\n:6\r\n"},
+		{[]string{"lcs", "key1", "key2", "idx", "minmatchlen", "4", "withmatchlen"},
+			"*4\r\n$7\r\nmatches\r\n*1\r\n" +
+				"*3\r\n*2\r\n:4\r\n:7\r\n*2\r\n:5\r\n:8\r\n:4\r\n" +
+				"$3\r\nlen\r\n:6\r\n"},
+		{[]string{"lcs", "key1", "key2", "len", "idx"}, "-ERR If you want both the length and indexes, please just use IDX.\r\n"},
+		{[]string{"lcs", "key1", "key2", "minmatchlen"}, "-Err syntax error\r\n"},
+		{[]string{"lcs", "key1", "key2", "foo"}, "-Err syntax error\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+	execCmd(e, c, "lpush", "l", "a")
+	assertReply(t, execCmd(e, c, "lcs", "key1", "l"), "-ERR The specified keys must contain string values\r\n")
+}
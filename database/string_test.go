@@ -0,0 +1,134 @@
+package database
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetOptions(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"set", "k", "v1", "xx"}, "$-1\r\n"},
+		{[]string{"set", "k", "v1", "nx"}, "+OK\r\n"},
+		{[]string{"set", "k", "v2", "nx"}, "$-1\r\n"},
+		{[]string{"set", "k", "v2", "xx", "get"}, "$2\r\nv1\r\n"},
+		{[]string{"set", "k", "v3", "nx", "get"}, "$2\r\nv2\r\n"}, // 条件不满足时也返回原先的值
+		{[]string{"get", "k"}, "$2\r\nv2\r\n"},
+		{[]string{"set", "new", "v", "get"}, "$-1\r\n"},
+		{[]string{"set", "k", "v", "nx", "xx"}, "-Err syntax error\r\n"},
+		{[]string{"set", "k", "v", "ex", "10", "px", "100"}, "-Err syntax error\r\n"},
+		{[]string{"set", "k", "v", "ex", "10", "keepttl"}, "-Err syntax error\r\n"},
+		{[]string{"set", "k", "v", "ex"}, "-Err syntax error\r\n"},
+		{[]string{"set", "k", "v", "ex", "abc"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"set", "k", "v", "ex", "0"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"set", "k", "v", "ex", "9223372036854775807"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"set", "k", "v", "px", "9223372036854775807"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"set", "k", "v", "exat", "9223372036854775807"}, "-ERR invalid expire time in 'set' command\r\n"},
+		{[]string{"get", "k"}, "$2\r\nv2\r\n"},
+		{[]string{"set", "k", "v", "ex", "100"}, "+OK\r\n"},
+		{[]string{"ttl", "k"}, ":100\r\n"},
+		{[]string{"set", "k", "v", "keepttl"}, "+OK\r\n"},
+		{[]string{"ttl", "k"}, ":100\r\n"},
+		{[]string{"set", "k", "v"}, "+OK\r\n"}, // 不带过期参数时清除过期时间
+		{[]string{"ttl", "k"}, ":-1\r\n"},
+		{[]string{"set", "k", "v", "px", "5000"}, "+OK\r\n"},
+		{[]string{"ttl", "k"}, ":5\r\n"},
+		{[]string{"set", "k", "v", "pxat", "1"}, "+OK\r\n"}, // 绝对时间已经过去，写入后立即过期
+		{[]string{"exists", "k"}, ":0\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+	at := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	assertReply(t, execCmd(e, c, "set", "k", "v", "exat", at), "+OK\r\n")
+	assertReply(t, execCmd(e, c, "ttl", "k"), ":3600\r\n")
+}
+
+// 已经过期但是还没有被删除的key，KEEPTTL 不能保留原先的过期时间
+func TestSetKeepTTLOnExpiredKey(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	execCmd(e, c, "set", "k", "old")
+	e.dbSet[0].Expire("k", time.Now().Add(-time.Second))
+	assertReply(t, execCmd(e, c, "set", "k", "new", "keepttl", "get"), "$-1\r\n")
+	assertReply(t, execCmd(e, c, "get", "k"), "$3\r\nnew\r\n")
+	assertReply(t, execCmd(e, c, "ttl", "k"), ":-1\r\n")
+}
+
+func TestIncrDecr(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"incr", "n"}, ":1\r\n"}, // 不存在的key视为0
+		{[]string{"incrby", "n", "10"}, ":11\r\n"},
+		{[]string{"decr", "n"}, ":10\r\n"},
+		{[]string{"decrby", "n", "-5"}, ":15\r\n"},
+		{[]string{"incrby", "n", "1.5"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"set", "n", "9223372036854775806"}, "+OK\r\n"},
+		{[]string{"incr", "n"}, ":9223372036854775807\r\n"},
+		{[]string{"incr", "n"}, "-ERR increment or decrement would overflow\r\n"},
+		{[]string{"get", "n"}, "$19\r\n9223372036854775807\r\n"}, // 溢出时不修改
+		{[]string{"set", "n", "-9223372036854775808"}, "+OK\r\n"},
+		{[]string{"decr", "n"}, "-ERR increment or decrement would overflow\r\n"},
+		{[]string{"set", "n", "0"}, "+OK\r\n"},
+		{[]string{"decrby", "n", "-9223372036854775808"}, "-ERR decrement would overflow\r\n"},
+		{[]string{"set", "s", "abc"}, "+OK\r\n"},
+		{[]string{"incr", "s"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"set", "s", " 1"}, "+OK\r\n"},
+		{[]string{"incr", "s"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"lpush", "l", "a"}, ":1\r\n"},
+		{[]string{"incr", "l"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+
+	// 修改value时保留过期时间
+	execCmd(e, c, "set", "t", "1", "ex", "100")
+	assertReply(t, execCmd(e, c, "incr", "t"), ":2\r\n")
+	assertReply(t, execCmd(e, c, "ttl", "t"), ":100\r\n")
+}
+
+func TestIncrByFloat(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	var aof []string
+	e.dbSet[0].writeAof = func(line CmdLine) {
+		aof = append(aof, string(bytes.Join(line, []byte(" "))))
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"incrbyfloat", "f", "0.1"}, "$3\r\n0.1\r\n"},
+		{[]string{"incrbyfloat", "f", "0.2"}, "$19\r\n0.30000000000000004\r\n"},
+		{[]string{"incrbyfloat", "f", "abc"}, "-ERR value is not a valid float\r\n"},
+		{[]string{"incrbyfloat", "f", "inf"}, "-ERR value is not a valid float\r\n"},
+		{[]string{"set", "f", "1.7976931348623157e308"}, "+OK\r\n"},
+		{[]string{"incrbyfloat", "f", "1.7976931348623157e308"}, "-ERR increment would produce NaN or Infinity\r\n"},
+		{[]string{"set", "s", "abc"}, "+OK\r\n"},
+		{[]string{"incrbyfloat", "s", "1"}, "-ERR value is not a valid float\r\n"},
+	}
+	for _, tt := range tests {
+		assertReply(t, execCmd(e, c, tt.args...), tt.want)
+	}
+	// aof中记录为SET最终的值，失败的指令不写入aof
+	want := []string{
+		"set f 0.1 KEEPTTL",
+		"set f 0.30000000000000004 KEEPTTL",
+	}
+	if len(aof) < 2 || aof[0] != want[0] || aof[1] != want[1] {
+		t.Errorf("aof = %q", aof)
+	}
+	for _, line := range aof {
+		if strings.HasPrefix(line, "incrbyfloat") {
+			t.Errorf("incrbyfloat should be rewritten as set: %q", line)
+		}
+	}
+}
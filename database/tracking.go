@@ -0,0 +1,460 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/connection"
+	"go_redis/resp/reply"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 客户端缓存 CLIENT TRACKING：
+// 默认模式下记录客户端读取过的key，key被修改之后向读取过的客户端发送一次失效消息，之后需要重新读取才会再次记录；
+// BCAST 模式不记录读取的key，匹配前缀的key被修改时都会发送失效消息。
+// RESP3 的客户端直接收到 >2 invalidate [key ...] 推送，RESP2 的客户端需要 REDIRECT 到订阅了 __redis__:invalidate 的连接。
+// 和redis一样，记录的key不区分db
+
+const trackingChannel = "__redis__:invalidate"
+
+var (
+	invalidateBytes      = []byte("invalidate")
+	redirBrokenBytes     = []byte("tracking-redir-broken")
+	trackingMessageBytes = []byte("message")
+)
+
+// trackingClient 开启了 CLIENT TRACKING 的客户端的选项
+type trackingClient struct {
+	redirect uint64 // 失效消息发送给该ID的客户端，0 表示发送给自己
+	bcast    bool
+	optIn    bool // 只记录 CLIENT CACHING yes 之后的下一条指令读取的key
+	optOut   bool // 不记录 CLIENT CACHING no 之后的下一条指令读取的key
+	noLoop   bool // 不接收自己修改的key的失效消息
+	prefixes []string
+
+	cachingSet bool // 执行过 CLIENT CACHING，只对下一条指令有效
+	caching    bool
+}
+
+type trackingTable struct {
+	mu       sync.Mutex
+	clients  map[resp.Connection]*trackingClient
+	keys     map[string]map[resp.Connection]struct{} // key -> 读取过该key的客户端
+	readKeys map[resp.Connection]map[string]struct{} // 客户端 -> 记录在keys中的key，断开连接时据此清理
+	prefixes map[string]map[resp.Connection]struct{} // BCAST 的前缀 -> 客户端
+}
+
+func makeTrackingTable() *trackingTable {
+	return &trackingTable{
+		clients:  make(map[resp.Connection]*trackingClient),
+		keys:     make(map[string]map[resp.Connection]struct{}),
+		readKeys: make(map[resp.Connection]map[string]struct{}),
+		prefixes: make(map[string]map[resp.Connection]struct{}),
+	}
+}
+
+// trackingOwner 脚本中执行的指令属于调用脚本的客户端
+func trackingOwner(c resp.Connection) resp.Connection {
+	if client, ok := c.(*scriptClient); ok {
+		return client.caller
+	}
+	return c
+}
+
+// enable CLIENT TRACKING ON，已经开启时可以修改REDIRECT/NOLOOP以及追加BCAST的前缀，但是不能切换模式
+func (table *trackingTable) enable(c resp.Connection, options *trackingClient) reply.ErrorReply {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	tc, ok := table.clients[c]
+	if !ok {
+		tc = &trackingClient{bcast: options.bcast, optIn: options.optIn, optOut: options.optOut}
+	} else if tc.bcast != options.bcast {
+		return reply.MakeErrReply("ERR You can't switch BCAST mode on/off before disabling tracking " +
+			"for this client, and then re-enabling it with a different mode.")
+	} else if tc.optIn != options.optIn || tc.optOut != options.optOut {
+		return reply.MakeErrReply("ERR You can't switch OPTIN/OPTOUT mode before disabling tracking " +
+			"for this client, and then re-enabling it with a different mode.")
+	}
+	prefixes := options.prefixes
+	if tc.bcast && len(prefixes) == 0 && len(tc.prefixes) == 0 {
+		prefixes = []string{""} // 没有指定前缀时匹配所有的key
+	}
+	// 同一个客户端的前缀之间不能互相包含，否则一个key会收到重复的消息
+	for i, prefix := range prefixes {
+		for _, other := range append(prefixes[i+1:len(prefixes):len(prefixes)], tc.prefixes...) {
+			if prefix != other && (strings.HasPrefix(prefix, other) || strings.HasPrefix(other, prefix)) {
+				return reply.MakeErrReply("ERR Prefix '" + prefix + "' overlaps with an existing prefix '" + other +
+					"'. Prefixes for a single client must not overlap.")
+			}
+		}
+	}
+	tc.redirect = options.redirect
+	tc.noLoop = options.noLoop
+	table.clients[c] = tc
+	for _, prefix := range prefixes {
+		subscribers, exists := table.prefixes[prefix]
+		if !exists {
+			subscribers = make(map[resp.Connection]struct{})
+			table.prefixes[prefix] = subscribers
+		}
+		if _, exists := subscribers[c]; exists {
+			continue
+		}
+		subscribers[c] = struct{}{}
+		tc.prefixes = append(tc.prefixes, prefix)
+	}
+	return nil
+}
+
+// disable CLIENT TRACKING OFF，默认模式下记录的key在失效时再清理
+func (table *trackingTable) disable(c resp.Connection) {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	tc, ok := table.clients[c]
+	if !ok {
+		return
+	}
+	for _, prefix := range tc.prefixes {
+		subscribers := table.prefixes[prefix]
+		delete(subscribers, c)
+		if len(subscribers) == 0 {
+			delete(table.prefixes, prefix)
+		}
+	}
+	delete(table.clients, c)
+}
+
+// setCaching CLIENT CACHING yes|no
+func (table *trackingTable) setCaching(c resp.Connection, caching bool) reply.ErrorReply {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	tc, ok := table.clients[c]
+	if !ok || (!tc.optIn && !tc.optOut) {
+		return reply.MakeErrReply("ERR CLIENT CACHING can be called only when the client is in tracking mode " +
+			"with OPTIN or OPTOUT mode enabled")
+	}
+	if caching && !tc.optIn {
+		return reply.MakeErrReply("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+	}
+	if !caching && !tc.optOut {
+		return reply.MakeErrReply("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+	}
+	tc.cachingSet = true
+	tc.caching = caching
+	return nil
+}
+
+// afterCommand 指令执行完毕之后清除 CLIENT CACHING 的设置，事务中的指令都使用MULTI之前的设置
+func (table *trackingTable) afterCommand(c resp.Connection, cmdLine [][]byte) {
+	if c.InMultiState() {
+		return
+	}
+	if len(cmdLine) > 1 && strings.EqualFold(string(cmdLine[0]), "client") &&
+		strings.EqualFold(string(cmdLine[1]), "caching") {
+		return
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	if tc, ok := table.clients[c]; ok {
+		tc.cachingSet = false
+	}
+}
+
+// getRedirect CLIENT GETREDIR：没有开启返回-1，没有重定向返回0
+func (table *trackingTable) getRedirect(c resp.Connection) int64 {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	tc, ok := table.clients[c]
+	if !ok {
+		return -1
+	}
+	return int64(tc.redirect)
+}
+
+// removeClient 客户端断开连接，除了关闭TRACKING之外还要清理它读取过的key
+func (table *trackingTable) removeClient(c resp.Connection) {
+	table.disable(c)
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	for key := range table.readKeys[c] {
+		readers := table.keys[key]
+		delete(readers, c)
+		if len(readers) == 0 {
+			delete(table.keys, key)
+		}
+	}
+	delete(table.readKeys, c)
+}
+
+// track 记录只读指令读取的key
+func (table *trackingTable) track(c resp.Connection, keys []string) {
+	owner := trackingOwner(c)
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	tc, ok := table.clients[owner]
+	if !ok || tc.bcast {
+		return
+	}
+	if tc.optIn && !(tc.cachingSet && tc.caching) {
+		return
+	}
+	if tc.optOut && tc.cachingSet && !tc.caching {
+		return
+	}
+	for _, key := range keys {
+		readers, exists := table.keys[key]
+		if !exists {
+			readers = make(map[resp.Connection]struct{})
+			table.keys[key] = readers
+		}
+		readers[owner] = struct{}{}
+		read, exists := table.readKeys[owner]
+		if !exists {
+			read = make(map[string]struct{})
+			table.readKeys[owner] = read
+		}
+		read[key] = struct{}{}
+	}
+}
+
+// invalidate key被修改之后通知读取过该key的客户端，以及前缀匹配的BCAST客户端；c为nil表示key过期
+func (table *trackingTable) invalidate(c resp.Connection, keys []string) {
+	owner := trackingOwner(c)
+	table.mu.Lock()
+	if len(table.clients) == 0 && len(table.keys) == 0 {
+		table.mu.Unlock()
+		return
+	}
+	pending := make(map[resp.Connection][]string)
+	seen := make(map[resp.Connection]map[string]struct{})
+	var order []resp.Connection
+	add := func(client resp.Connection, key string) {
+		tc, ok := table.clients[client]
+		if !ok || (tc.noLoop && client == owner) {
+			return
+		}
+		if _, exists := seen[client]; !exists {
+			seen[client] = make(map[string]struct{})
+			order = append(order, client)
+		}
+		if _, exists := seen[client][key]; exists {
+			return
+		}
+		seen[client][key] = struct{}{}
+		pending[client] = append(pending[client], key)
+	}
+	for _, key := range keys {
+		for client := range table.keys[key] {
+			add(client, key)
+			table.forget(client, key)
+		}
+		delete(table.keys, key) // 只通知一次，客户端重新读取之后再记录
+		for prefix, subscribers := range table.prefixes {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			for client := range subscribers {
+				add(client, key)
+			}
+		}
+	}
+	redirects := make(map[resp.Connection]uint64, len(order))
+	for _, client := range order {
+		redirects[client] = table.clients[client].redirect
+	}
+	table.mu.Unlock()
+
+	for _, client := range order {
+		sendInvalidation(client, redirects[client], pending[client])
+	}
+}
+
+// forget 从客户端读取过的key中删除key，调用方持有table的锁
+func (table *trackingTable) forget(c resp.Connection, key string) {
+	read := table.readKeys[c]
+	delete(read, key)
+	if len(read) == 0 {
+		delete(table.readKeys, c)
+	}
+}
+
+// flush FLUSHDB 之后通知所有开启了 TRACKING 的客户端丢弃全部的缓存
+func (table *trackingTable) flush() {
+	table.mu.Lock()
+	table.keys = make(map[string]map[resp.Connection]struct{})
+	table.readKeys = make(map[resp.Connection]map[string]struct{})
+	redirects := make(map[resp.Connection]uint64, len(table.clients))
+	for client, tc := range table.clients {
+		redirects[client] = tc.redirect
+	}
+	table.mu.Unlock()
+
+	for client, redirect := range redirects {
+		sendInvalidation(client, redirect, nil)
+	}
+}
+
+// sendInvalidation 发送失效消息，keys为nil表示所有的key都失效
+func sendInvalidation(c resp.Connection, redirect uint64, keys []string) {
+	target := c
+	if redirect != 0 {
+		conn, ok := connection.GetConn(redirect)
+		if !ok { // 重定向的客户端已经断开
+			if c.GetProtocol() >= 3 {
+				_ = c.Write(reply.MakePushReply([]resp.Reply{
+					reply.MakeBulkReply(redirBrokenBytes),
+					reply.MakeIntReply(int64(redirect)),
+				}).ToRESP3Bytes())
+			}
+			return
+		}
+		target = conn
+	}
+	var keysReply resp.Reply = reply.MakeNullMultiBulkReply() // RESP3中为 _
+	if keys != nil {
+		args := make([][]byte, len(keys))
+		for i, key := range keys {
+			args[i] = []byte(key)
+		}
+		keysReply = reply.MakeMultiBulkReply(args)
+	}
+	if target.GetProtocol() >= 3 {
+		_ = target.Write(reply.MakePushReply([]resp.Reply{reply.MakeBulkReply(invalidateBytes), keysReply}).ToRESP3Bytes())
+		return
+	}
+	// RESP2 只能通过重定向到订阅了 __redis__:invalidate 的连接接收失效消息，没有订阅的连接不会收到
+	if redirect == 0 || !slices.Contains(target.GetChannels(), trackingChannel) {
+		return
+	}
+	_ = target.Write(reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply(trackingMessageBytes),
+		reply.MakeBulkReply([]byte(trackingChannel)),
+		keysReply,
+	}).ToBytes())
+}
+
+// ---------------------- CLIENT ----------------------
+
+// execClient CLIENT ID | SETNAME | GETNAME | TRACKING | CACHING | GETREDIR
+func execClient(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	switch subCmd {
+	case "id":
+		if len(args) != 1 {
+			return clientArgNumErr(subCmd)
+		}
+		return reply.MakeIntReply(int64(c.GetID()))
+	case "setname":
+		if len(args) != 2 {
+			return clientArgNumErr(subCmd)
+		}
+		if errReply := validateClientName(string(args[1])); errReply != nil {
+			return errReply
+		}
+		c.SetName(string(args[1]))
+		return reply.MakeOkReply()
+	case "getname":
+		if len(args) != 1 {
+			return clientArgNumErr(subCmd)
+		}
+		if c.GetName() == "" {
+			return reply.MakeNullBulkReply()
+		}
+		return reply.MakeBulkReply([]byte(c.GetName()))
+	case "tracking":
+		return execClientTracking(db, c, args[1:])
+	case "caching":
+		if len(args) != 2 {
+			return clientArgNumErr(subCmd)
+		}
+		var caching bool
+		switch strings.ToLower(string(args[1])) {
+		case "yes":
+			caching = true
+		case "no":
+			caching = false
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+		if errReply := db.tracking.setCaching(c, caching); errReply != nil {
+			return errReply
+		}
+		return reply.MakeOkReply()
+	case "getredir":
+		if len(args) != 1 {
+			return clientArgNumErr(subCmd)
+		}
+		return reply.MakeIntReply(db.tracking.getRedirect(c))
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try CLIENT HELP.")
+}
+
+// execClientTracking CLIENT TRACKING ON|OFF [REDIRECT id] [PREFIX prefix ...] [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+func execClientTracking(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		return clientArgNumErr("tracking")
+	}
+	options := &trackingClient{}
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "REDIRECT":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			id, err := strconv.ParseUint(string(args[i+1]), 10, 64)
+			if err != nil {
+				return reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if _, ok := connection.GetConn(id); !ok && id != c.GetID() {
+				return reply.MakeErrReply("ERR The client ID you want redirect to does not exist")
+			}
+			options.redirect = id
+			i++
+		case "PREFIX":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			options.prefixes = append(options.prefixes, string(args[i+1]))
+			i++
+		case "BCAST":
+			options.bcast = true
+		case "OPTIN":
+			options.optIn = true
+		case "OPTOUT":
+			options.optOut = true
+		case "NOLOOP":
+			options.noLoop = true
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "ON":
+		if len(options.prefixes) > 0 && !options.bcast {
+			return reply.MakeErrReply("ERR PREFIX option requires BCAST mode to be enabled")
+		}
+		if options.optIn && options.optOut {
+			return reply.MakeErrReply("ERR You can't use both OPTIN and OPTOUT")
+		}
+		if options.bcast && (options.optIn || options.optOut) {
+			return reply.MakeErrReply("ERR OPTIN and OPTOUT are not compatible with BCAST")
+		}
+		if errReply := db.tracking.enable(c, options); errReply != nil {
+			return errReply
+		}
+	case "OFF":
+		db.tracking.disable(c)
+	default:
+		return reply.MakeSyntaxErrReply()
+	}
+	return reply.MakeOkReply()
+}
+
+func clientArgNumErr(subCmd string) resp.Reply {
+	return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + subCmd + "'. Try CLIENT HELP.")
+}
+
+func init() {
+	RegisterConnCommand("client", execClient, noPrepare, -2, flagNoScript, 0, 0, 0) // CLIENT ID|SETNAME|GETNAME|TRACKING|CACHING|GETREDIR
+}
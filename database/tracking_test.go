@@ -0,0 +1,105 @@
+package database
+
+import (
+	"go_redis/resp/connection"
+	"strconv"
+	"testing"
+)
+
+// RESP2 的客户端通过 REDIRECT 把失效消息交给订阅了 __redis__:invalidate 的连接
+func TestTrackingRedirect(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	redirect, messages := makePipeClient(t)
+	execCmd(e, redirect, "subscribe", trackingChannel)
+	nextMessage(t, messages)
+
+	reader := &connection.Connection{}
+	assertReply(t, execCmd(e, reader, "client", "tracking", "on", "redirect", strconv.FormatUint(redirect.GetID(), 10)), "+OK\r\n")
+	execCmd(e, reader, "get", "k")
+	execCmd(e, c, "set", "k", "1")
+	if got := nextMessage(t, messages); got != "[message __redis__:invalidate [k]]" {
+		t.Errorf("got %q", got)
+	}
+	execCmd(e, c, "set", "k", "2") // 只通知一次，重新读取之后才会再次记录
+
+	// 重定向的连接没有订阅 __redis__:invalidate 时丢弃失效消息
+	execCmd(e, redirect, "unsubscribe")
+	nextMessage(t, messages)
+	execCmd(e, reader, "get", "k")
+	execCmd(e, c, "set", "k", "3")
+
+	execCmd(e, redirect, "subscribe", trackingChannel)
+	nextMessage(t, messages)
+	execCmd(e, reader, "get", "other")
+	execCmd(e, c, "set", "other", "1")
+	if got := nextMessage(t, messages); got != "[message __redis__:invalidate [other]]" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// BCAST 模式下前缀匹配的key被修改都会收到失效消息，不需要读取
+func TestTrackingBcast(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	client, messages := makePipeClient(t)
+	client.SetProtocol(3)
+	assertReply(t, execCmd(e, client, "client", "tracking", "on", "bcast", "prefix", "user:"), "+OK\r\n")
+
+	execCmd(e, c, "set", "other", "1")
+	execCmd(e, c, "mset", "user:1", "a", "user:2", "b")
+	if got := nextMessage(t, messages); got != "[invalidate [user:1 user:2]]" {
+		t.Errorf("got %q", got)
+	}
+	execCmd(e, c, "set", "user:1", "c")
+	if got := nextMessage(t, messages); got != "[invalidate [user:1]]" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// OPTIN 模式下只记录 CLIENT CACHING yes 之后的下一条指令读取的key
+func TestTrackingOptIn(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	client, messages := makePipeClient(t)
+	client.SetProtocol(3)
+	assertReply(t, execCmd(e, client, "client", "tracking", "on", "optin"), "+OK\r\n")
+
+	execCmd(e, client, "get", "a")
+	execCmd(e, c, "set", "a", "1")
+
+	assertReply(t, execCmd(e, client, "client", "caching", "yes"), "+OK\r\n")
+	execCmd(e, client, "get", "b")
+	execCmd(e, client, "get", "c") // CLIENT CACHING 只对下一条指令有效
+	execCmd(e, c, "mset", "a", "2", "b", "2", "c", "2")
+	if got := nextMessage(t, messages); got != "[invalidate [b]]" {
+		t.Errorf("got %q", got)
+	}
+	assertReply(t, execCmd(e, client, "client", "caching", "no"),
+		"-ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.\r\n")
+}
+
+// 客户端断开连接之后清理它读取过的key
+func TestTrackingClientClose(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	reader := &connection.Connection{}
+	execCmd(e, reader, "client", "tracking", "on")
+	execCmd(e, reader, "mget", "k1", "k2")
+	other := &connection.Connection{}
+	execCmd(e, other, "client", "tracking", "on")
+	execCmd(e, other, "get", "k1")
+
+	e.AfterClientClose(reader)
+	e.tracking.mu.Lock()
+	keys, readers := len(e.tracking.keys), len(e.tracking.keys["k1"])
+	_, closed := e.tracking.readKeys[reader]
+	e.tracking.mu.Unlock()
+	if keys != 1 || readers != 1 || closed {
+		t.Errorf("tracked keys after close: %d keys, %d readers of k1, closed client kept %v", keys, readers, closed)
+	}
+	// 失效之后同样清理
+	execCmd(e, c, "set", "k1", "v")
+	e.tracking.mu.Lock()
+	keys, clients := len(e.tracking.keys), len(e.tracking.readKeys)
+	e.tracking.mu.Unlock()
+	if keys != 0 || clients != 0 {
+		t.Errorf("tracked keys after invalidation: %d keys, %d clients", keys, clients)
+	}
+}
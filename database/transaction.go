@@ -0,0 +1,158 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/lib/utils"
+	"go_redis/resp/reply"
+	"strings"
+)
+
+// 事务 MULTI/EXEC/DISCARD
+// MULTI之后的指令只做语法检查(指令是否存在、参数个数)然后排队，EXEC时持有事务锁依次执行，执行期间不会穿插其他客户端的指令；
+// 排队时出现错误的事务在EXEC时直接放弃，WATCH的key被修改过的事务EXEC时返回空
+
+func init() {
+	registerSpecialCommand("select", 2, flagFast)
+	registerSpecialCommand("multi", 1, flagNoScript|flagFast)
+	registerSpecialCommand("exec", 1, flagNoScript)
+	registerSpecialCommand("discard", 1, flagNoScript|flagFast)
+}
+
+// isTxCommand 控制事务本身的指令，事务中不排队直接执行
+func isTxCommand(cmdName string) bool {
+	return cmdName == "multi" || cmdName == "exec" || cmdName == "discard" || cmdName == "watch"
+}
+
+// execTxCommand 执行 MULTI/EXEC/DISCARD/WATCH
+func (e *StandaloneDatabase) execTxCommand(c resp.Connection, cmdName string, args [][]byte) resp.Reply {
+	switch cmdName {
+	case "multi":
+		if len(args) != 1 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		if c.InMultiState() {
+			return reply.MakeErrReply("ERR MULTI calls can not be nested")
+		}
+		c.SetMultiState(true)
+		return reply.MakeOkReply()
+	case "exec":
+		if len(args) != 1 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		if !c.InMultiState() {
+			return reply.MakeErrReply("ERR EXEC without MULTI")
+		}
+		return e.execMulti(c)
+	case "discard":
+		if len(args) != 1 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		if !c.InMultiState() {
+			return reply.MakeErrReply("ERR DISCARD without MULTI")
+		}
+		c.SetMultiState(false)
+		e.watches.release(c)
+		return reply.MakeOkReply()
+	default: // watch
+		if c.InMultiState() {
+			return reply.MakeErrReply("ERR WATCH inside MULTI is not allowed")
+		}
+		return e.dbSet[c.GetDBIndex()].Exec(c, args)
+	}
+}
+
+// enqueueCmd 事务中的指令检查语法之后排队
+func enqueueCmd(c resp.Connection, args [][]byte) resp.Reply {
+	cmdName := strings.ToLower(string(args[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		errReply := reply.MakeErrReply("ERR unknown command '" + string(args[0]) + "'")
+		c.AddTxError(errReply)
+		return errReply
+	}
+	if !validateArity(cmd.arity, args) {
+		errReply := reply.MakeArgNumErrReply(cmdName)
+		c.AddTxError(errReply)
+		return errReply
+	}
+	if isSubscribeCommand(cmdName) {
+		errReply := reply.MakeErrReply("ERR Command not allowed inside a transaction")
+		c.AddTxError(errReply)
+		return errReply
+	}
+	c.EnqueueCmd(args)
+	return reply.MakeQueuedReply()
+}
+
+// isWatchingChanged 判断WATCH的key是否被修改过
+func (e *StandaloneDatabase) isWatchingChanged(c resp.Connection) bool {
+	for wk, version := range c.GetWatching() {
+		dbIndex, key := parseWatchKey(wk)
+		db := e.dbSet[dbIndex]
+		db.RWLocks([]string{key}, nil)
+		db.IsExpired(key) // WATCH之后过期的key也视为被修改
+		db.RWUnLocks([]string{key}, nil)
+		if current, ok := e.watches.version(wk); !ok || current != version {
+			return true
+		}
+	}
+	return false
+}
+
+// execMulti 原子地执行排队的指令
+func (e *StandaloneDatabase) execMulti(c resp.Connection) resp.Reply {
+	// 结束事务之前阻塞指令不会阻塞
+	defer func() {
+		c.SetMultiState(false)
+		e.watches.release(c)
+	}()
+	if len(c.GetTxErrors()) > 0 {
+		return reply.MakeErrReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+	e.txLock.Lock()
+	defer e.txLock.Unlock()
+	if e.isWatchingChanged(c) {
+		return reply.MakeNullMultiBulkReply()
+	}
+
+	cmdLines := c.GetQueuedCmdLine()
+	// 包含写指令(以及可能执行写指令的脚本和函数)的事务在aof中以 MULTI ... EXEC 包裹，加载时可以发现不完整的事务
+	hasWrite := false
+	for _, cmdLine := range cmdLines {
+		cmdName := strings.ToLower(string(cmdLine[0]))
+		if mayReplicate(cmdName) {
+			hasWrite = true
+			break
+		}
+	}
+	if hasWrite && e.aofHandler != nil {
+		e.aofHandler.AddAof(c.GetDBIndex(), utils.ToCmdLine("multi"))
+	}
+	results := make([]resp.Reply, 0, len(cmdLines))
+	for _, cmdLine := range cmdLines {
+		results = append(results, e.execQueued(c, cmdLine))
+	}
+	// 所有的指令执行完毕之后再唤醒阻塞在写入的key上的客户端，阻塞的客户端不会看到事务的中间状态
+	for _, db := range e.dbSet {
+		db.blocking.signalDeferred()
+	}
+	if hasWrite && e.aofHandler != nil {
+		e.aofHandler.AddAof(c.GetDBIndex(), utils.ToCmdLine("exec"))
+	}
+	return reply.MakeMultiRawReply(results)
+}
+
+// execQueued 执行排队的指令，调用方持有事务锁
+func (e *StandaloneDatabase) execQueued(c resp.Connection, cmdLine [][]byte) resp.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	if cmdName == "select" {
+		return execSelect(c, e, cmdLine[1:])
+	}
+	if isScriptCommand(cmdName) {
+		return e.execLocked(c, cmdName, cmdLine)
+	}
+	if isPubSubCommand(cmdName) {
+		return e.execPubSub(c, cmdName, cmdLine)
+	}
+	return e.dbSet[c.GetDBIndex()].Exec(c, cmdLine)
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/connection"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitBlocked 等待客户端进入阻塞状态
+func waitBlocked(t *testing.T, db *DB, count int32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&db.blocking.count) != count {
+		if time.Now().After(deadline) {
+			t.Fatal("client is not blocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExecWakesBlockedClientAfterTransaction(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	blocked := &connection.Connection{}
+	done := make(chan resp.Reply, 1)
+	go func() {
+		done <- execCmd(e, blocked, "blpop", "q", "0")
+	}()
+	waitBlocked(t, e.dbSet[0], 1)
+
+	execCmd(e, c, "multi")
+	execCmd(e, c, "rpush", "q", "x")
+	execCmd(e, c, "llen", "q")
+	// 事务执行期间阻塞的客户端不能取走元素
+	assertReply(t, execCmd(e, c, "exec"), "*2\r\n:1\r\n:1\r\n")
+	select {
+	case r := <-done:
+		assertReply(t, r, "*2\r\n$1\r\nq\r\n$1\r\nx\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("blocked client is not woken after EXEC")
+	}
+	assertReply(t, execCmd(e, c, "llen", "q"), ":0\r\n")
+}
+
+func TestExecWithScriptWakesAfterTransaction(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	blocked := &connection.Connection{}
+	done := make(chan resp.Reply, 1)
+	go func() {
+		done <- execCmd(e, blocked, "blpop", "q", "0")
+	}()
+	waitBlocked(t, e.dbSet[0], 1)
+
+	execCmd(e, c, "multi")
+	execCmd(e, c, "eval", "return redis.call('rpush', KEYS[1], 'x')", "1", "q")
+	execCmd(e, c, "llen", "q")
+	assertReply(t, execCmd(e, c, "exec"), "*2\r\n:1\r\n:1\r\n")
+	select {
+	case r := <-done:
+		assertReply(t, r, "*2\r\n$1\r\nq\r\n$1\r\nx\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("blocked client is not woken after EXEC")
+	}
+}
+
+func TestBlockedClientDoesNotHoldTxLock(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	blocked := &connection.Connection{}
+	done := make(chan resp.Reply, 1)
+	go func() {
+		done <- execCmd(e, blocked, "blpop", "q", "0")
+	}()
+	waitBlocked(t, e.dbSet[0], 1)
+	// 阻塞等待期间释放了事务锁，EXEC可以执行
+	execCmd(e, c, "multi")
+	execCmd(e, c, "set", "k", "v")
+	assertReply(t, execCmd(e, c, "exec"), "*1\r\n+OK\r\n")
+	execCmd(e, c, "rpush", "q", "y")
+	select {
+	case r := <-done:
+		assertReply(t, r, "*2\r\n$1\r\nq\r\n$1\r\ny\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("blocked client is not woken")
+	}
+}
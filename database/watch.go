@@ -0,0 +1,149 @@
+package database
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/resp/reply"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// WATCH 乐观锁：被WATCH的key维护一个版本号，修改key的指令在持有key的写锁时增加其版本号，
+// EXEC时发现任意一个WATCH的key的版本号发生了变化就放弃整个事务。
+// 版本号只在有客户端WATCH该key期间存在，所有WATCH该key的客户端都结束WATCH之后删除
+
+// watchEntry 被WATCH的key
+type watchEntry struct {
+	version uint32 // 原子操作，修改不同key的指令可能并发地增加版本号
+	refs    int    // WATCH该key的客户端个数，由 watchTable.mu 保护
+}
+
+// watchTable 被WATCH的key，所有的db共享，key由 watchKey 生成
+type watchTable struct {
+	mu      sync.RWMutex
+	entries map[string]*watchEntry
+	count   int32 // entries 的个数，为0时修改key不需要查找
+}
+
+func makeWatchTable() *watchTable {
+	return &watchTable{
+		entries: make(map[string]*watchEntry),
+	}
+}
+
+// watchKey WATCH的key带上db的编号，EXEC之前切换db也能检查到正确的key
+func watchKey(dbIndex int, key string) string {
+	return strconv.Itoa(dbIndex) + " " + key
+}
+
+// parseWatchKey 拆分 watchKey 生成的key
+func parseWatchKey(watchKey string) (int, string) {
+	i := strings.IndexByte(watchKey, ' ')
+	dbIndex, _ := strconv.Atoi(watchKey[:i])
+	return dbIndex, watchKey[i+1:]
+}
+
+// watch 客户端开始WATCH key并记录当前的版本号，已经WATCH的key保留原先的版本号，和redis一致
+func (table *watchTable) watch(c resp.Connection, dbIndex int, key string) {
+	wk := watchKey(dbIndex, key)
+	watching := c.GetWatching()
+	if _, ok := watching[wk]; ok {
+		return
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	entry, ok := table.entries[wk]
+	if !ok {
+		entry = &watchEntry{}
+		table.entries[wk] = entry
+		atomic.AddInt32(&table.count, 1)
+	}
+	entry.refs++
+	watching[wk] = atomic.LoadUint32(&entry.version)
+}
+
+// touch 增加被WATCH的key的版本号，修改key时调用，调用方需要持有key的写锁
+func (table *watchTable) touch(dbIndex int, keys ...string) {
+	if atomic.LoadInt32(&table.count) == 0 {
+		return
+	}
+	table.mu.RLock()
+	defer table.mu.RUnlock()
+	for _, key := range keys {
+		if entry, ok := table.entries[watchKey(dbIndex, key)]; ok {
+			atomic.AddUint32(&entry.version, 1)
+		}
+	}
+}
+
+// touchDB FLUSHDB 清空db之前增加该db中存在的被WATCH的key的版本号
+func (table *watchTable) touchDB(dbIndex int, exists func(key string) bool) {
+	if atomic.LoadInt32(&table.count) == 0 {
+		return
+	}
+	prefix := watchKey(dbIndex, "")
+	table.mu.RLock()
+	defer table.mu.RUnlock()
+	for wk, entry := range table.entries {
+		if strings.HasPrefix(wk, prefix) && exists(wk[len(prefix):]) {
+			atomic.AddUint32(&entry.version, 1)
+		}
+	}
+}
+
+// version 返回被WATCH的key当前的版本号
+func (table *watchTable) version(wk string) (uint32, bool) {
+	table.mu.RLock()
+	defer table.mu.RUnlock()
+	entry, ok := table.entries[wk]
+	if !ok {
+		return 0, false
+	}
+	return atomic.LoadUint32(&entry.version), true
+}
+
+// release 取消客户端所有的WATCH，EXEC/DISCARD/UNWATCH 以及客户端断开连接时调用
+func (table *watchTable) release(c resp.Connection) {
+	watching := c.GetWatching()
+	if len(watching) > 0 {
+		table.mu.Lock()
+		for wk := range watching {
+			entry, ok := table.entries[wk]
+			if !ok {
+				continue
+			}
+			entry.refs--
+			if entry.refs <= 0 {
+				delete(table.entries, wk)
+				atomic.AddInt32(&table.count, -1)
+			}
+		}
+		table.mu.Unlock()
+	}
+	c.ClearWatching()
+}
+
+// execWatch WATCH K1 K2 ...
+func execWatch(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	keys := toKeys(args)
+	// 持有写锁，WATCH记录的版本号和key当前的值一致
+	db.RWLocks(keys, nil)
+	defer db.RWUnLocks(keys, nil)
+	for _, key := range keys {
+		db.IsExpired(key) // 已经过期的key先删除，避免之后的惰性删除被当作修改
+		db.watches.watch(c, db.index, key)
+	}
+	return reply.MakeOkReply()
+}
+
+// execUnwatch UNWATCH
+func execUnwatch(db *DB, c resp.Connection, args [][]byte) resp.Reply {
+	db.watches.release(c)
+	return reply.MakeOkReply()
+}
+
+func init() {
+	RegisterConnCommand("watch", execWatch, nil, -2, flagNoScript|flagFast, 1, -1, 1) // WATCH K1 K2 ...
+	RegisterConnCommand("unwatch", execUnwatch, noPrepare, 1, flagNoScript|flagFast, 0, 0, 0)
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"go_redis/resp/connection"
+	"go_redis/resp/reply"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// watchRefs 返回key的WATCH计数，没有客户端WATCH时返回0
+func watchRefs(e *StandaloneDatabase, dbIndex int, key string) int {
+	e.watches.mu.RLock()
+	defer e.watches.mu.RUnlock()
+	if entry, ok := e.watches.entries[watchKey(dbIndex, key)]; ok {
+		return entry.refs
+	}
+	return 0
+}
+
+// 所有的客户端结束WATCH之后删除key的版本号
+func TestWatchRelease(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	other := &connection.Connection{}
+
+	execCmd(e, c, "watch", "a", "b")
+	execCmd(e, c, "watch", "a") // 重复WATCH不增加计数
+	execCmd(e, other, "watch", "a")
+	if refs := watchRefs(e, 0, "a"); refs != 2 {
+		t.Errorf("refs of a = %d, want 2", refs)
+	}
+	execCmd(e, c, "multi")
+	execCmd(e, c, "get", "a")
+	execCmd(e, c, "exec")
+	if refs := watchRefs(e, 0, "a"); refs != 1 {
+		t.Errorf("refs of a after EXEC = %d, want 1", refs)
+	}
+	e.AfterClientClose(other)
+
+	execCmd(e, c, "watch", "a")
+	execCmd(e, c, "multi")
+	execCmd(e, c, "discard")
+	execCmd(e, c, "watch", "a")
+	execCmd(e, c, "select", "1")
+	execCmd(e, c, "watch", "a")
+	if refs := watchRefs(e, 1, "a"); refs != 1 {
+		t.Errorf("refs of a in db 1 = %d, want 1", refs)
+	}
+	execCmd(e, c, "unwatch")
+	if n := len(e.watches.entries); n != 0 {
+		t.Errorf("%d watched keys left", n)
+	}
+}
+
+func TestWatchInvalidation(t *testing.T) {
+	e, c := makeTestDatabase(t)
+	other := &connection.Connection{}
+	tests := []struct {
+		name    string
+		key     string
+		modify  func()
+		changed bool
+	}{
+		{"del", "k", func() { execCmd(e, other, "del", "k") }, true},
+		{"del missing", "missing", func() { execCmd(e, other, "del", "missing") }, false},
+		{"rename dest", "dst", func() { execCmd(e, other, "rename", "k", "dst") }, true},
+		{"expire", "k", func() { execCmd(e, other, "expire", "k", "100") }, true},
+		{"expired", "k", func() { e.dbSet[0].Expire("k", time.Now().Add(-time.Second)) }, true},
+		{"other db", "k", func() { execCmd(e, other, "select", "1"); execCmd(e, other, "set", "k", "x") }, false},
+		{"flushdb", "k", func() { execCmd(e, other, "select", "0"); execCmd(e, other, "flushdb") }, true},
+		{"flushdb missing", "missing", func() { execCmd(e, other, "select", "0"); execCmd(e, other, "flushdb") }, false},
+	}
+	for _, tt := range tests {
+		execCmd(e, c, "set", "k", "v")
+		execCmd(e, c, "watch", tt.key)
+		tt.modify()
+		execCmd(e, c, "multi")
+		execCmd(e, c, "ping")
+		r := execCmd(e, c, "exec")
+		if _, aborted := r.(*reply.NullMultiBulkReply); aborted != tt.changed {
+			t.Errorf("%s: exec returned %q", tt.name, r.ToBytes())
+		}
+	}
+}
+
+// 版本号在持有key的写锁时增加：并发地用 WATCH/MULTI/EXEC 实现的自增不会丢失更新
+func TestWatchConcurrentIncr(t *testing.T) {
+	e, _ := makeTestDatabase(t)
+	const clients, incrs = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := &connection.Connection{}
+			for done := 0; done < incrs; {
+				execCmd(e, c, "watch", "counter")
+				n := 0
+				if r, ok := execCmd(e, c, "get", "counter").(*reply.BulkReply); ok {
+					n, _ = strconv.Atoi(string(r.Arg))
+				}
+				execCmd(e, c, "multi")
+				execCmd(e, c, "set", "counter", strconv.Itoa(n+1))
+				if _, aborted := execCmd(e, c, "exec").(*reply.NullMultiBulkReply); !aborted {
+					done++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assertReply(t, execCmd(e, &connection.Connection{}, "get", "counter"), "$3\r\n200\r\n")
+	if n := len(e.watches.entries); n != 0 {
+		t.Errorf("%d watched keys left", n)
+	}
+}
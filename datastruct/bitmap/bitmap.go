@@ -0,0 +1,119 @@
+package bitmap
+
+// BitMap 基于字节数组的位图，和redis保持一致：第0位为第一个字节的最高位
+
+type BitMap []byte
+
+// FromBytes 将字符串的值转化为位图，不拷贝
+func FromBytes(bytes []byte) *BitMap {
+	bm := BitMap(bytes)
+	return &bm
+}
+
+// ToBytes 返回位图底层的字节数组
+func (b *BitMap) ToBytes() []byte {
+	return *b
+}
+
+// BitSize 返回位图的位数
+func (b *BitMap) BitSize() int64 {
+	return int64(len(*b)) * 8
+}
+
+// grow 扩容到至少可以存储 bitSize 位，新增的位为0
+func (b *BitMap) grow(bitSize int64) {
+	byteSize := (bitSize + 7) / 8
+	gap := byteSize - int64(len(*b))
+	if gap <= 0 {
+		return
+	}
+	*b = append(*b, make([]byte, gap)...)
+}
+
+// SetBit 设置offset位的值，位图长度不足时自动扩容，返回原先的值
+func (b *BitMap) SetBit(offset int64, val byte) byte {
+	b.grow(offset + 1)
+	byteIndex := offset / 8
+	bitOffset := 7 - offset%8
+	mask := byte(1 << bitOffset)
+	old := ((*b)[byteIndex] & mask) >> bitOffset
+	if val > 0 {
+		(*b)[byteIndex] |= mask
+	} else {
+		(*b)[byteIndex] &^= mask
+	}
+	return old
+}
+
+// GetBit 获取offset位的值，超出长度的位为0
+func (b *BitMap) GetBit(offset int64) byte {
+	byteIndex := offset / 8
+	if byteIndex >= int64(len(*b)) {
+		return 0
+	}
+	bitOffset := 7 - offset%8
+	return ((*b)[byteIndex] >> bitOffset) & 0x01
+}
+
+// GetBits 读取从offset开始的bits位(最多64位)，按照大端序组成无符号整数
+func (b *BitMap) GetBits(offset int64, bits int) uint64 {
+	var result uint64
+	for i := 0; i < bits; i++ {
+		result = (result << 1) | uint64(b.GetBit(offset+int64(i)))
+	}
+	return result
+}
+
+// SetBits 将val的低bits位写入到从offset开始的位置，位图长度不足时自动扩容
+func (b *BitMap) SetBits(offset int64, bits int, val uint64) {
+	b.grow(offset + int64(bits))
+	for i := 0; i < bits; i++ {
+		bit := byte((val >> uint(bits-1-i)) & 0x01)
+		b.SetBit(offset+int64(i), bit)
+	}
+}
+
+// popCount 每个字节中1的个数
+var popCount = func() [256]byte {
+	var table [256]byte
+	for i := range table {
+		table[i] = table[i/2] + byte(i&1)
+	}
+	return table
+}()
+
+// CountBytes 统计 [begin, end) 字节范围内1的个数
+func (b *BitMap) CountBytes(begin int64, end int64) int64 {
+	var count int64
+	for _, v := range (*b)[begin:end] {
+		count += int64(popCount[v])
+	}
+	return count
+}
+
+// CountBits 统计 [begin, end) 位范围内1的个数
+func (b *BitMap) CountBits(begin int64, end int64) int64 {
+	var count int64
+	for i := begin; i < end; i++ {
+		count += int64(b.GetBit(i))
+	}
+	return count
+}
+
+// FindBit 在 [begin, end) 位范围内查找第一个值为bit的位置，不存在返回-1
+func (b *BitMap) FindBit(bit byte, begin int64, end int64) int64 {
+	for i := begin; i < end; i++ {
+		// 整个字节都不满足时跳过该字节
+		if i%8 == 0 && i+8 <= end {
+			v := (*b)[i/8]
+			if (bit == 1 && v == 0) || (bit == 0 && v == 0xff) {
+				i += 7
+				continue
+			}
+		}
+		if b.GetBit(i) == bit {
+			return i
+		}
+	}
+	return -1
+}
@@ -109,7 +109,7 @@ func (dict *SyncDict) RandomDistinctKeys(limit int) []string {
 		}
 		return true
 	})
-	return result
+	return result[:i] // key的数量不足limit时，只返回实际取到的key
 }
 
 func (dict *SyncDict) Clear() {
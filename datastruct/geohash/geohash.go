@@ -0,0 +1,269 @@
+package geohash
+
+import "math"
+
+// geohash编码，和redis保持一致：
+// 经度和纬度分别按照二分法编码为26位整数，交错排列为52位的整数(纬度在偶数位，经度在奇数位)，
+// 作为有序集合的score，相近的位置score也相近；纬度使用墨卡托投影的范围 [-85.05112878, 85.05112878]
+
+const (
+	MaxStep = 26 // 经度和纬度各自编码的位数
+
+	LonMin = -180.0
+	LonMax = 180.0
+	LatMin = -85.05112878
+	LatMax = 85.05112878
+
+	EarthRadius = 6372797.560856 // 地球半径(米)，和redis保持一致
+	mercatorMax = 20037726.37    // 墨卡托投影中赤道长度的一半(米)
+	base32      = "0123456789bcdefghjkmnpqrstuvwxyz"
+	standardLat = 90.0 // GEOHASH指令返回的字符串使用标准的纬度范围
+)
+
+// Area geohash对应的矩形区域
+type Area struct {
+	LonMin, LonMax float64
+	LatMin, LatMax float64
+}
+
+// HashBits 指定精度的geohash，step为经度和纬度各自的位数
+type HashBits struct {
+	Bits uint64
+	Step uint
+}
+
+// interleave 将x放在偶数位，y放在奇数位
+func interleave(x, y uint32) uint64 {
+	var result uint64
+	for i := uint(0); i < 32; i++ {
+		result |= uint64(x>>i&1) << (2 * i)
+		result |= uint64(y>>i&1) << (2*i + 1)
+	}
+	return result
+}
+
+// deinterleave interleave的逆运算，返回偶数位和奇数位
+func deinterleave(bits uint64) (uint32, uint32) {
+	var x, y uint32
+	for i := uint(0); i < 32; i++ {
+		x |= uint32(bits>>(2*i)&1) << i
+		y |= uint32(bits>>(2*i+1)&1) << i
+	}
+	return x, y
+}
+
+func encode(lon, lat, latMin, latMax float64, step uint) HashBits {
+	latOffset := (lat - latMin) / (latMax - latMin) * float64(uint64(1)<<step)
+	lonOffset := (lon - LonMin) / (LonMax - LonMin) * float64(uint64(1)<<step)
+	// 恰好位于上边界时归入最后一个区域
+	maxOffset := float64(uint64(1)<<step - 1)
+	latOffset = math.Min(latOffset, maxOffset)
+	lonOffset = math.Min(lonOffset, maxOffset)
+	return HashBits{
+		Bits: interleave(uint32(latOffset), uint32(lonOffset)),
+		Step: step,
+	}
+}
+
+// Valid 经纬度是否在可以编码的范围内
+func Valid(lon, lat float64) bool {
+	return lon >= LonMin && lon <= LonMax && lat >= LatMin && lat <= LatMax
+}
+
+// Encode 以指定的精度编码经纬度
+func Encode(lon, lat float64, step uint) HashBits {
+	return encode(lon, lat, LatMin, LatMax, step)
+}
+
+// EncodeToScore 以最高精度编码经纬度，作为有序集合的score
+func EncodeToScore(lon, lat float64) uint64 {
+	return Encode(lon, lat, MaxStep).Bits
+}
+
+// Area 返回geohash对应的矩形区域
+func (hash HashBits) Area() Area {
+	latIndex, lonIndex := deinterleave(hash.Bits)
+	scale := float64(uint64(1) << hash.Step)
+	return Area{
+		LatMin: LatMin + float64(latIndex)/scale*(LatMax-LatMin),
+		LatMax: LatMin + float64(latIndex+1)/scale*(LatMax-LatMin),
+		LonMin: LonMin + float64(lonIndex)/scale*(LonMax-LonMin),
+		LonMax: LonMin + float64(lonIndex+1)/scale*(LonMax-LonMin),
+	}
+}
+
+// Decode 将score解码为经纬度，返回所在区域的中心点
+func Decode(score uint64) (lon float64, lat float64) {
+	area := HashBits{Bits: score, Step: MaxStep}.Area()
+	lon = math.Max(LonMin, math.Min(LonMax, (area.LonMin+area.LonMax)/2))
+	lat = math.Max(LatMin, math.Min(LatMax, (area.LatMin+area.LatMax)/2))
+	return lon, lat
+}
+
+// ToString 返回标准的11位geohash字符串，和redis的GEOHASH指令保持一致
+func ToString(score uint64) string {
+	lon, lat := Decode(score)
+	bits := encode(lon, lat, -standardLat, standardLat, MaxStep).Bits
+	buf := make([]byte, 11)
+	for i := 0; i < 11; i++ {
+		idx := 0
+		if i < 10 { // 52位只能编码10个半字符，最后一个字符补0
+			idx = int(bits >> (52 - uint((i+1)*5)) & 0x1f)
+		}
+		buf[i] = base32[idx]
+	}
+	return string(buf)
+}
+
+// RangeOfScore 返回geohash区域内所有的点在有序集合中的score范围 [min, max)
+func (hash HashBits) RangeOfScore() (uint64, uint64) {
+	shift := 2 * (MaxStep - hash.Step)
+	return hash.Bits << shift, (hash.Bits + 1) << shift
+}
+
+// move 返回在经度和纬度方向上移动dx和dy个区域之后的geohash，经度超过边界时回绕，纬度超出边界时返回false
+func (hash HashBits) move(dx, dy int64) (HashBits, bool) {
+	latIndex, lonIndex := deinterleave(hash.Bits)
+	size := int64(1) << hash.Step
+	lat := int64(latIndex) + dy
+	if lat < 0 || lat >= size {
+		return HashBits{}, false
+	}
+	lon := ((int64(lonIndex)+dx)%size + size) % size
+	return HashBits{
+		Bits: interleave(uint32(lat), uint32(lon)),
+		Step: hash.Step,
+	}, true
+}
+
+// Neighbors 返回自身以及周围8个区域(去除了超出纬度边界和重复的区域)
+func (hash HashBits) Neighbors() []HashBits {
+	result := make([]HashBits, 0, 9)
+	seen := make(map[uint64]struct{})
+	for dy := int64(-1); dy <= 1; dy++ {
+		for dx := int64(-1); dx <= 1; dx++ {
+			neighbor, ok := hash.move(dx, dy)
+			if !ok {
+				continue
+			}
+			if _, exists := seen[neighbor.Bits]; exists {
+				continue
+			}
+			seen[neighbor.Bits] = struct{}{}
+			result = append(result, neighbor)
+		}
+	}
+	return result
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Distance 使用haversine公式计算两点之间的距离(米)
+func Distance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r := degToRad(lat1)
+	lat2r := degToRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin(degToRad(lon2-lon1) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * EarthRadius * math.Asin(math.Sqrt(a))
+}
+
+// latDistance 计算同一经线上两点之间的距离(米)
+func latDistance(lat1, lat2 float64) float64 {
+	return EarthRadius * math.Abs(degToRad(lat2)-degToRad(lat1))
+}
+
+// Shape 搜索的范围，圆形或者矩形
+type Shape struct {
+	Lon, Lat      float64 // 中心点
+	Radius        float64 // 圆形的半径(米)，大于0表示圆形
+	Width, Height float64 // 矩形的宽度和高度(米)
+}
+
+// InShape 判断点是否在搜索范围内，返回点到中心点的距离
+func (shape *Shape) InShape(lon, lat float64) (float64, bool) {
+	if shape.Radius > 0 {
+		distance := Distance(shape.Lon, shape.Lat, lon, lat)
+		return distance, distance <= shape.Radius
+	}
+	if latDistance(shape.Lat, lat) > shape.Height/2 {
+		return 0, false
+	}
+	if Distance(shape.Lon, lat, lon, lat) > shape.Width/2 {
+		return 0, false
+	}
+	return Distance(shape.Lon, shape.Lat, lon, lat), true
+}
+
+// boundingBox 返回搜索范围的外接矩形
+func (shape *Shape) boundingBox() Area {
+	width, height := shape.Width, shape.Height
+	if shape.Radius > 0 {
+		width, height = shape.Radius*2, shape.Radius*2
+	}
+	latDelta := radToDeg(height / 2 / EarthRadius)
+	lonDeltaTop := radToDeg(width / 2 / EarthRadius / math.Cos(degToRad(shape.Lat+latDelta)))
+	lonDeltaBottom := radToDeg(width / 2 / EarthRadius / math.Cos(degToRad(shape.Lat-latDelta)))
+	lonDelta := math.Max(lonDeltaTop, lonDeltaBottom)
+	return Area{
+		LonMin: shape.Lon - lonDelta,
+		LonMax: shape.Lon + lonDelta,
+		LatMin: shape.Lat - latDelta,
+		LatMax: shape.Lat + latDelta,
+	}
+}
+
+// estimateStep 根据搜索半径估算geohash的精度，使得周围9个区域可以覆盖搜索范围
+func estimateStep(radius float64, lat float64) uint {
+	if radius == 0 {
+		return MaxStep
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2 // 确保搜索范围在周围9个区域内
+	// 高纬度地区经线之间的距离更近，需要降低精度
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	if step > MaxStep {
+		step = MaxStep
+	}
+	return uint(step)
+}
+
+// SearchAreas 返回覆盖搜索范围的geohash区域，在这些区域内查找之后再使用InShape过滤
+func (shape *Shape) SearchAreas() []HashBits {
+	radius := shape.Radius
+	if radius <= 0 {
+		radius = math.Sqrt(shape.Width*shape.Width/4 + shape.Height*shape.Height/4)
+	}
+	step := estimateStep(radius, shape.Lat)
+	box := shape.boundingBox()
+	center := Encode(shape.Lon, shape.Lat, step)
+	// 精度过高时周围的区域可能无法完全覆盖外接矩形，此时降低一级精度
+	if step > 1 {
+		area := center.Area()
+		latSize := area.LatMax - area.LatMin
+		lonSize := area.LonMax - area.LonMin
+		if area.LatMax+latSize < box.LatMax || area.LatMin-latSize > box.LatMin ||
+			area.LonMax+lonSize < box.LonMax || area.LonMin-lonSize > box.LonMin {
+			center = Encode(shape.Lon, shape.Lat, step-1)
+		}
+	}
+	return center.Neighbors()
+}
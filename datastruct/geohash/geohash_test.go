@@ -0,0 +1,91 @@
+package geohash
+
+import (
+	"math"
+	"testing"
+)
+
+// 和redis文档中 GEOADD Sicily 的结果保持一致
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		lon, lat float64
+		score    uint64
+		hash     string
+	}{
+		{"Palermo", 13.361389, 38.115556, 3479099956230698, "sqc8b49rny0"},
+		{"Catania", 15.087269, 37.502669, 3479447370796909, "sqdtr74hyu0"},
+	}
+	for _, tt := range tests {
+		score := EncodeToScore(tt.lon, tt.lat)
+		if score != tt.score {
+			t.Errorf("%s: score %d, want %d", tt.name, score, tt.score)
+		}
+		if hash := ToString(score); hash != tt.hash {
+			t.Errorf("%s: geohash %s, want %s", tt.name, hash, tt.hash)
+		}
+		lon, lat := Decode(score)
+		if math.Abs(lon-tt.lon) > 1e-5 || math.Abs(lat-tt.lat) > 1e-5 {
+			t.Errorf("%s: decoded (%f, %f), want (%f, %f)", tt.name, lon, lat, tt.lon, tt.lat)
+		}
+		// 解码之后再编码得到同一个score
+		if again := EncodeToScore(lon, lat); again != score {
+			t.Errorf("%s: re-encoded score %d, want %d", tt.name, again, score)
+		}
+	}
+}
+
+func TestEncodeBorder(t *testing.T) {
+	points := [][2]float64{
+		{LonMin, LatMin}, {LonMax, LatMax}, {LonMin, LatMax}, {LonMax, LatMin}, {0, 0},
+	}
+	for _, p := range points {
+		score := EncodeToScore(p[0], p[1])
+		if score >= 1<<(2*MaxStep) {
+			t.Errorf("(%f, %f): score %d out of 52 bits", p[0], p[1], score)
+		}
+		area := HashBits{Bits: score, Step: MaxStep}.Area()
+		if p[0] < area.LonMin || p[0] > area.LonMax || p[1] < area.LatMin || p[1] > area.LatMax {
+			t.Errorf("(%f, %f): not in area %+v", p[0], p[1], area)
+		}
+	}
+	if Valid(0, 86) || Valid(181, 0) || !Valid(LonMax, LatMin) {
+		t.Error("unexpected Valid result")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// GEODIST Sicily Palermo Catania => 166274.1516，使用解码之后的位置计算
+	lon1, lat1 := Decode(EncodeToScore(13.361389, 38.115556))
+	lon2, lat2 := Decode(EncodeToScore(15.087269, 37.502669))
+	distance := Distance(lon1, lat1, lon2, lat2)
+	if math.Abs(distance-166274.1516) > 0.0001 {
+		t.Errorf("distance %f, want 166274.1516", distance)
+	}
+}
+
+// 搜索的区域需要覆盖范围内所有的点
+func TestSearchAreas(t *testing.T) {
+	shape := &Shape{Lon: 15, Lat: 37, Radius: 200 * 1000}
+	areas := shape.SearchAreas()
+	covered := func(score uint64) bool {
+		for _, area := range areas {
+			min, max := area.RangeOfScore()
+			if score >= min && score < max {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range [][2]float64{{13.361389, 38.115556}, {15.087269, 37.502669}, {15, 37}, {14, 36}} {
+		if _, in := shape.InShape(p[0], p[1]); !in {
+			t.Fatalf("(%f, %f) should be in shape", p[0], p[1])
+		}
+		if !covered(EncodeToScore(p[0], p[1])) {
+			t.Errorf("(%f, %f) not covered by search areas", p[0], p[1])
+		}
+	}
+	if _, in := shape.InShape(10, 37); in {
+		t.Error("(10, 37) should not be in shape")
+	}
+}
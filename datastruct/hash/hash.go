@@ -0,0 +1,221 @@
+package hash
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+)
+
+// redis hash 数据结构
+// 元素较少时使用紧凑的切片存储(类似redis的listpack)，节约内存；
+// 元素个数或者value长度超过阈值之后，升级为真正的map，之后不再降级
+
+const (
+	maxListpackEntries = 128  // 紧凑编码最多存储的field个数
+	maxListpackValue   = 64   // 紧凑编码中field/value的最大长度
+	randomPrealloc     = 1024 // 可以重复的随机field预先分配的个数
+)
+
+// 编码方式
+const (
+	EncodingListpack  = "listpack"
+	EncodingHashtable = "hashtable"
+)
+
+type entry struct {
+	field string
+	value []byte
+}
+
+type Hash struct {
+	entries []entry           // 紧凑编码
+	dict    map[string][]byte // 升级之后的map，不为nil表示已经升级
+}
+
+func MakeHash() *Hash {
+	return &Hash{}
+}
+
+// Encoding 返回当前的编码方式
+func (h *Hash) Encoding() string {
+	if h.dict != nil {
+		return EncodingHashtable
+	}
+	return EncodingListpack
+}
+
+// upgrade 从紧凑编码升级为map
+func (h *Hash) upgrade() {
+	h.dict = make(map[string][]byte, len(h.entries))
+	for _, e := range h.entries {
+		h.dict[e.field] = e.value
+	}
+	h.entries = nil
+}
+
+func (h *Hash) indexOf(field string) int {
+	for i, e := range h.entries {
+		if e.field == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get 获取field对应的value
+func (h *Hash) Get(field string) ([]byte, bool) {
+	if h.dict != nil {
+		val, ok := h.dict[field]
+		return val, ok
+	}
+	i := h.indexOf(field)
+	if i < 0 {
+		return nil, false
+	}
+	return h.entries[i].value, true
+}
+
+// Set 设置field的value，返回1表示新增field，返回0表示更新
+func (h *Hash) Set(field string, value []byte) int {
+	if h.dict == nil && (len(field) > maxListpackValue || len(value) > maxListpackValue) {
+		h.upgrade()
+	}
+	if h.dict != nil {
+		_, exists := h.dict[field]
+		h.dict[field] = value
+		if exists {
+			return 0
+		}
+		return 1
+	}
+	i := h.indexOf(field)
+	if i >= 0 {
+		h.entries[i].value = value
+		return 0
+	}
+	h.entries = append(h.entries, entry{field: field, value: value})
+	if len(h.entries) > maxListpackEntries {
+		h.upgrade()
+	}
+	return 1
+}
+
+// Delete 删除field，返回删除的个数
+func (h *Hash) Delete(field string) int {
+	if h.dict != nil {
+		_, exists := h.dict[field]
+		delete(h.dict, field)
+		if exists {
+			return 1
+		}
+		return 0
+	}
+	i := h.indexOf(field)
+	if i < 0 {
+		return 0
+	}
+	h.entries = append(h.entries[:i], h.entries[i+1:]...)
+	return 1
+}
+
+// Len 返回field的个数
+func (h *Hash) Len() int {
+	if h.dict != nil {
+		return len(h.dict)
+	}
+	return len(h.entries)
+}
+
+// ForEach 遍历所有的field，consumer返回false则停止遍历
+func (h *Hash) ForEach(consumer func(field string, value []byte) bool) {
+	if h.dict != nil {
+		for field, value := range h.dict {
+			if !consumer(field, value) {
+				return
+			}
+		}
+		return
+	}
+	for _, e := range h.entries {
+		if !consumer(e.field, e.value) {
+			return
+		}
+	}
+}
+
+// Fields 返回所有的field
+func (h *Hash) Fields() []string {
+	fields := make([]string, 0, h.Len())
+	h.ForEach(func(field string, value []byte) bool {
+		fields = append(fields, field)
+		return true
+	})
+	return fields
+}
+
+// RandomFields 随机返回limit个field，可能重复
+func (h *Hash) RandomFields(limit int) []string {
+	fields := h.Fields()
+	if len(fields) == 0 {
+		return nil
+	}
+	// limit 由客户端指定，先分配一部分，其余的随着元素的加入增长
+	result := make([]string, 0, min(limit, randomPrealloc))
+	for i := 0; i < limit; i++ {
+		result = append(result, fields[rand.Intn(len(fields))])
+	}
+	return result
+}
+
+// RandomDistinctFields 随机返回limit个不重复的field，field不足时返回全部
+func (h *Hash) RandomDistinctFields(limit int) []string {
+	fields := h.Fields()
+	rand.Shuffle(len(fields), func(i, j int) {
+		fields[i], fields[j] = fields[j], fields[i]
+	})
+	if limit < len(fields) {
+		fields = fields[:limit]
+	}
+	return fields
+}
+
+// scanHash 计算field在遍历(HSCAN)时的顺序
+func scanHash(field string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(field))
+	return h.Sum32()
+}
+
+// Scan 从cursor开始遍历至少count个field，返回下一次遍历的cursor，返回0表示遍历结束
+// field按照hash值排序，cursor为下一个要返回的hash值，因此遍历期间的插入和删除不会导致已存在的field被跳过
+func (h *Hash) Scan(cursor uint64, count int, match func(field string) bool) ([]string, uint64) {
+	type scanItem struct {
+		hash  uint32
+		field string
+	}
+	items := make([]scanItem, 0, h.Len())
+	h.ForEach(func(field string, value []byte) bool {
+		hash := scanHash(field)
+		if uint64(hash) >= cursor {
+			items = append(items, scanItem{hash: hash, field: field})
+		}
+		return true
+	})
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].hash != items[j].hash {
+			return items[i].hash < items[j].hash
+		}
+		return items[i].field < items[j].field
+	})
+	result := make([]string, 0, count)
+	for i, item := range items {
+		// hash值相同的field必须在同一次遍历中返回
+		if i >= count && item.hash != items[i-1].hash {
+			return result, uint64(item.hash)
+		}
+		if match == nil || match(item.field) {
+			result = append(result, item.field)
+		}
+	}
+	return result, 0
+}
@@ -0,0 +1,376 @@
+package hyperloglog
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// redis HyperLogLog，序列化之后的格式和redis完全一致，可以通过GET/SET互相导入导出
+//
+// 头部16个字节：
+//   "HYLL" | 编码方式(1字节) | 保留(3字节) | 缓存的基数(8字节，小端序，最高字节的最高位为1表示缓存失效)
+// 之后是 16384 个寄存器，有两种编码方式：
+//   dense  每个寄存器6位，依次紧密排列，共12288字节
+//   sparse 对寄存器进行游程编码，有三种操作码：
+//     ZERO   00xxxxxx          连续 xxxxxx+1 个寄存器为0 (1~64)
+//     XZERO  01xxxxxx yyyyyyyy 连续 xxxxxxyyyyyyyy+1 个寄存器为0 (1~16384)
+//     VAL    1vvvvvxx          连续 xx+1 个寄存器的值为 vvvvv+1 (值为1~32，个数为1~4)
+// 新建时使用sparse编码，当寄存器的值超过32或者sparse编码之后的长度超过阈值时转化为dense编码，之后不再转回
+
+const (
+	hllP         = 14                 // 使用hash的低14位作为寄存器的下标
+	hllQ         = 64 - hllP          // 剩余的50位用于计算连续0的个数
+	hllRegisters = 1 << hllP          // 寄存器个数 16384
+	hllPMask     = hllRegisters - 1   // 计算下标的掩码
+	hllBits      = 6                  // dense编码中每个寄存器的位数
+	hllRegMax    = (1 << hllBits) - 1 // 寄存器的最大值
+	hllHdrSize   = 16                 // 头部的长度
+	hllDenseSize = hllHdrSize + (hllRegisters*hllBits+7)/8
+	hllAlphaInf  = 0.721347520444481703680 // 估算基数时使用的常数
+
+	hllSparseValMax    = 32    // sparse编码可以表示的寄存器最大值
+	hllSparseValLen    = 4     // VAL操作码最多表示的寄存器个数
+	hllSparseZeroLen   = 64    // ZERO操作码最多表示的寄存器个数
+	hllSparseXZeroLen  = 16384 // XZERO操作码最多表示的寄存器个数
+	hllSparseMaxBytes  = 3000  // sparse编码的最大长度，对应redis的 hll-sparse-max-bytes
+	hllSparseZeroBit   = 0x00
+	hllSparseXZeroBit  = 0x40
+	hllSparseValBit    = 0x80
+	hllEncodingDense   = 0
+	hllEncodingSparse  = 1
+	hllCardInvalidMask = 1 << 7 // 缓存基数最高字节中表示失效的标志位
+)
+
+var hllMagic = []byte("HYLL")
+
+var (
+	// ErrInvalid 不是合法的HyperLogLog字符串
+	ErrInvalid = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	// ErrCorrupted HyperLogLog的sparse编码已经损坏
+	ErrCorrupted = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+type HyperLogLog struct {
+	registers [hllRegisters]uint8
+	dense     bool   // 是否已经转化为dense编码
+	card      uint64 // 缓存的基数
+	cardValid bool   // 缓存的基数是否有效
+}
+
+// MakeHyperLogLog 新建一个空的HyperLogLog，使用sparse编码
+func MakeHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{cardValid: true}
+}
+
+// IsHyperLogLog 判断字符串是否为HyperLogLog的格式，只检查头部
+func IsHyperLogLog(data []byte) bool {
+	if len(data) < hllHdrSize || string(data[:4]) != string(hllMagic) {
+		return false
+	}
+	switch data[4] {
+	case hllEncodingDense:
+		return len(data) == hllDenseSize
+	case hllEncodingSparse:
+		return true
+	}
+	return false
+}
+
+// Parse 将redis格式的字符串解析为HyperLogLog，不会引用原先的字节数组
+func Parse(data []byte) (*HyperLogLog, error) {
+	if !IsHyperLogLog(data) {
+		return nil, ErrInvalid
+	}
+	h := &HyperLogLog{}
+	cardBytes := data[8:hllHdrSize]
+	if cardBytes[7]&hllCardInvalidMask == 0 {
+		h.cardValid = true
+		h.card = binary.LittleEndian.Uint64(cardBytes)
+	}
+	if data[4] == hllEncodingDense {
+		h.dense = true
+		registers := data[hllHdrSize:]
+		for i := 0; i < hllRegisters; i++ {
+			h.registers[i] = getDenseRegister(registers, i)
+		}
+		return h, nil
+	}
+	if !decodeSparse(data[hllHdrSize:], &h.registers) {
+		return nil, ErrCorrupted
+	}
+	return h, nil
+}
+
+// getDenseRegister 读取dense编码中第i个寄存器，寄存器可能跨越两个字节，低位在前
+func getDenseRegister(registers []byte, i int) uint8 {
+	pos := i * hllBits
+	b0 := pos / 8
+	fb := uint(pos % 8)
+	v := uint16(registers[b0]) >> fb
+	if b0+1 < len(registers) {
+		v |= uint16(registers[b0+1]) << (8 - fb)
+	}
+	return uint8(v & hllRegMax)
+}
+
+// setDenseRegister 设置dense编码中第i个寄存器的值
+func setDenseRegister(registers []byte, i int, val uint8) {
+	pos := i * hllBits
+	b0 := pos / 8
+	fb := uint(pos % 8)
+	v := uint16(val) << fb
+	mask := uint16(hllRegMax) << fb
+	registers[b0] = registers[b0]&^byte(mask) | byte(v)
+	if b0+1 < len(registers) {
+		registers[b0+1] = registers[b0+1]&^byte(mask>>8) | byte(v>>8)
+	}
+}
+
+// decodeSparse 解码sparse编码的寄存器，寄存器总数不等于16384时返回false
+func decodeSparse(data []byte, registers *[hllRegisters]uint8) bool {
+	index := 0
+	for i := 0; i < len(data); i++ {
+		op := data[i]
+		switch {
+		case op&0xc0 == hllSparseZeroBit:
+			index += int(op&0x3f) + 1
+		case op&0xc0 == hllSparseXZeroBit:
+			if i+1 >= len(data) {
+				return false
+			}
+			index += (int(op&0x3f)<<8 | int(data[i+1])) + 1
+			i++
+		default: // VAL
+			runLen := int(op&0x3) + 1
+			val := (op>>2)&0x1f + 1
+			if index+runLen > hllRegisters {
+				return false
+			}
+			for j := 0; j < runLen; j++ {
+				registers[index+j] = val
+			}
+			index += runLen
+		}
+		if index > hllRegisters {
+			return false
+		}
+	}
+	return index == hllRegisters
+}
+
+// encodeSparse 对寄存器进行sparse编码，寄存器的值超过32时返回false
+func encodeSparse(registers *[hllRegisters]uint8) ([]byte, bool) {
+	var result []byte
+	for i := 0; i < hllRegisters; {
+		val := registers[i]
+		j := i + 1
+		for j < hllRegisters && registers[j] == val {
+			j++
+		}
+		runLen := j - i
+		if val == 0 {
+			for runLen > 0 {
+				n := runLen
+				if n > hllSparseZeroLen {
+					if n > hllSparseXZeroLen {
+						n = hllSparseXZeroLen
+					}
+					result = append(result, hllSparseXZeroBit|byte((n-1)>>8), byte((n-1)&0xff))
+				} else {
+					result = append(result, hllSparseZeroBit|byte(n-1))
+				}
+				runLen -= n
+			}
+		} else {
+			if val > hllSparseValMax {
+				return nil, false
+			}
+			for runLen > 0 {
+				n := runLen
+				if n > hllSparseValLen {
+					n = hllSparseValLen
+				}
+				result = append(result, hllSparseValBit|(val-1)<<2|byte(n-1))
+				runLen -= n
+			}
+		}
+		i = j
+	}
+	return result, true
+}
+
+// murmurHash64A redis使用的hash函数，按照小端序读取
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+	n := len(key) / 8 * 8
+	for i := 0; i < n; i += 8 {
+		k := binary.LittleEndian.Uint64(key[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	tail := key[n:]
+	switch len(tail) {
+	case 7:
+		h ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(tail[0])
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// patLen 返回元素对应的寄存器下标，以及hash剩余位中第一个1出现的位置(从1开始)
+func patLen(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, 0xadc83b19)
+	index := int(hash & hllPMask)
+	hash >>= hllP
+	hash |= 1 << hllQ // 保证循环一定会结束
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// Add 添加元素，有寄存器被修改时返回true
+func (h *HyperLogLog) Add(element []byte) bool {
+	index, count := patLen(element)
+	if h.registers[index] >= count {
+		return false
+	}
+	h.registers[index] = count
+	h.cardValid = false
+	return true
+}
+
+// Merge 将other合并进来，每个寄存器取两者的最大值
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	for i, val := range other.registers {
+		if val > h.registers[i] {
+			h.registers[i] = val
+			h.cardValid = false
+		}
+	}
+	if other.dense {
+		h.dense = true
+	}
+}
+
+// Count 估算基数，缓存有效时直接返回缓存的值
+func (h *HyperLogLog) Count() uint64 {
+	if h.cardValid {
+		return h.card
+	}
+	h.card = h.estimate()
+	h.cardValid = true
+	return h.card
+}
+
+// CacheValid 缓存的基数是否有效
+func (h *HyperLogLog) CacheValid() bool {
+	return h.cardValid
+}
+
+// estimate 使用 Otmar Ertl 提出的改进算法估算基数，和redis保持一致
+func (h *HyperLogLog) estimate() uint64 {
+	var histogram [64]int
+	for _, val := range h.registers {
+		histogram[val]++
+	}
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// ToBytes 序列化为redis格式的字符串，sparse编码放不下时转化为dense编码
+func (h *HyperLogLog) ToBytes() []byte {
+	var result []byte
+	if !h.dense {
+		if sparse, ok := encodeSparse(&h.registers); ok && hllHdrSize+len(sparse) <= hllSparseMaxBytes {
+			result = make([]byte, hllHdrSize, hllHdrSize+len(sparse))
+			result[4] = hllEncodingSparse
+			result = append(result, sparse...)
+		} else {
+			h.dense = true
+		}
+	}
+	if h.dense {
+		result = make([]byte, hllDenseSize)
+		result[4] = hllEncodingDense
+		registers := result[hllHdrSize:]
+		for i, val := range h.registers {
+			setDenseRegister(registers, i, val)
+		}
+	}
+	copy(result, hllMagic)
+	if h.cardValid {
+		binary.LittleEndian.PutUint64(result[8:hllHdrSize], h.card)
+	} else {
+		result[hllHdrSize-1] = hllCardInvalidMask
+	}
+	return result
+}
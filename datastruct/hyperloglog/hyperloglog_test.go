@@ -0,0 +1,129 @@
+package hyperloglog
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func makeWithElements(prefix string, n int) *HyperLogLog {
+	h := MakeHyperLogLog()
+	for i := 0; i < n; i++ {
+		h.Add([]byte(prefix + strconv.Itoa(i)))
+	}
+	return h
+}
+
+// 空的HyperLogLog和redis的 PFADD k 创建的字符串一致：sparse编码，一个XZERO覆盖全部寄存器
+func TestEmptyEncoding(t *testing.T) {
+	want := append([]byte("HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), 0x7f, 0xff)
+	if got := MakeHyperLogLog().ToBytes(); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		n        int
+		encoding byte
+	}{
+		{0, hllEncodingSparse},
+		{100, hllEncodingSparse},
+		{20000, hllEncodingDense}, // sparse编码超过长度限制，转化为dense
+	}
+	for _, tt := range tests {
+		h := makeWithElements("e", tt.n)
+		data := h.ToBytes()
+		if data[4] != tt.encoding {
+			t.Errorf("n=%d: encoding %d, want %d", tt.n, data[4], tt.encoding)
+		}
+		if tt.encoding == hllEncodingDense && len(data) != hllDenseSize {
+			t.Errorf("n=%d: dense size %d, want %d", tt.n, len(data), hllDenseSize)
+		}
+		parsed, err := Parse(data)
+		if err != nil {
+			t.Fatalf("n=%d: %v", tt.n, err)
+		}
+		if parsed.registers != h.registers {
+			t.Errorf("n=%d: registers changed after round trip", tt.n)
+		}
+		if parsed.Count() != h.Count() {
+			t.Errorf("n=%d: count %d, want %d", tt.n, parsed.Count(), h.Count())
+		}
+	}
+}
+
+// 缓存的基数写在头部，修改之后标记为失效
+func TestCardCache(t *testing.T) {
+	h := makeWithElements("e", 10)
+	if h.CacheValid() {
+		t.Error("cache should be invalid after Add")
+	}
+	parsed, _ := Parse(h.ToBytes())
+	if parsed.CacheValid() {
+		t.Error("invalid cache should be kept in header")
+	}
+	count := h.Count()
+	parsed, _ = Parse(h.ToBytes())
+	if !parsed.CacheValid() || parsed.card != count {
+		t.Errorf("cached card %d valid=%v, want %d", parsed.card, parsed.CacheValid(), count)
+	}
+}
+
+func TestCountError(t *testing.T) {
+	for _, n := range []int{10, 1000, 100000} {
+		count := makeWithElements("e", n).Count()
+		if diff := math.Abs(float64(count)-float64(n)) / float64(n); diff > 0.02 {
+			t.Errorf("n=%d: count %d, error %.3f", n, count, diff)
+		}
+	}
+}
+
+// 合并的结果和将所有的元素添加到同一个HyperLogLog中一致
+func TestMerge(t *testing.T) {
+	a := makeWithElements("a", 5000)
+	b := makeWithElements("b", 5000)
+	all := makeWithElements("a", 5000)
+	for i := 0; i < 5000; i++ {
+		all.Add([]byte("b" + strconv.Itoa(i)))
+	}
+	a.Merge(b)
+	if a.registers != all.registers {
+		t.Error("merged registers differ from union")
+	}
+	if a.Count() != all.Count() {
+		t.Errorf("merged count %d, want %d", a.Count(), all.Count())
+	}
+
+	// 和dense编码合并之后保持dense编码
+	sparse := makeWithElements("s", 10)
+	dense, _ := Parse(makeWithElements("d", 20000).ToBytes())
+	sparse.Merge(dense)
+	if data := sparse.ToBytes(); data[4] != hllEncodingDense {
+		t.Error("merge with dense should produce dense encoding")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := makeWithElements("e", 100).ToBytes()
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"short", []byte("HYLL"), ErrInvalid},
+		{"magic", append([]byte("HYLX"), valid[4:]...), ErrInvalid},
+		{"encoding", append(append([]byte{}, valid[:4]...), append([]byte{2}, valid[5:]...)...), ErrInvalid},
+		{"dense size", append(append([]byte{}, valid[:4]...), append([]byte{hllEncodingDense}, valid[5:]...)...), ErrInvalid},
+		// XZERO 覆盖全部寄存器之后还有数据
+		{"sparse overflow", append(MakeHyperLogLog().ToBytes(), 0x00), ErrCorrupted},
+		// 寄存器个数不足
+		{"sparse short", valid[:len(valid)-2], ErrCorrupted},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.data); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
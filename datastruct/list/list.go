@@ -0,0 +1,24 @@
+package list
+
+// Expected 判断元素是否为期望的元素
+type Expected func(a interface{}) bool
+
+// Consumer 遍历列表时对每个元素执行的方法，返回false则停止遍历
+type Consumer func(i int, v interface{}) bool
+
+// redis list 数据结构的核心业务
+type List interface {
+	Add(val interface{})                                 // 尾部插入
+	Get(index int) (val interface{})                     // 获取指定下标的元素
+	Set(index int, val interface{})                      // 修改指定下标的元素
+	Insert(index int, val interface{})                   // 在指定下标插入元素，原先的元素后移
+	Remove(index int) (val interface{})                  // 删除指定下标的元素
+	RemoveLast() (val interface{})                       // 删除最后一个元素
+	RemoveAllByVal(expected Expected) int                // 删除所有满足条件的元素
+	RemoveByVal(expected Expected, count int) int        // 从头部开始删除count个满足条件的元素
+	ReverseRemoveByVal(expected Expected, count int) int // 从尾部开始删除count个满足条件的元素
+	Len() int
+	ForEach(consumer Consumer)
+	Contains(expected Expected) bool
+	Range(start int, stop int) []interface{} // 返回 [start, stop) 的元素
+}
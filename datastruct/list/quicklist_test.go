@@ -0,0 +1,144 @@
+package list
+
+import (
+	"slices"
+	"testing"
+)
+
+// 和切片的结果对比，元素个数超过pageSize以覆盖page的分裂和删除
+func toSlice(ql *QuickList) []interface{} {
+	var result []interface{}
+	ql.ForEach(func(i int, v interface{}) bool {
+		result = append(result, v)
+		return true
+	})
+	return result
+}
+
+func assertList(t *testing.T, ql *QuickList, want []interface{}) {
+	t.Helper()
+	if ql.Len() != len(want) {
+		t.Fatalf("len %d, want %d", ql.Len(), len(want))
+	}
+	if got := toSlice(ql); !slices.Equal(got, want) {
+		t.Fatalf("list differs from expected at len %d", len(want))
+	}
+	for i := range want {
+		if ql.Get(i) != want[i] {
+			t.Fatalf("Get(%d) = %v, want %v", i, ql.Get(i), want[i])
+		}
+	}
+}
+
+func TestQuickListAddInsert(t *testing.T) {
+	ql := NewQuickList()
+	var want []interface{}
+	for i := 0; i < 3*pageSize; i++ {
+		ql.Add(i)
+		want = append(want, i)
+	}
+	assertList(t, ql, want)
+
+	// 插入到已满的page的前半部分、后半部分、头部和尾部
+	for _, index := range []int{10, pageSize - 1, pageSize + pageSize/2, 0, len(want)} {
+		ql.Insert(index, -index)
+		want = slices.Insert(want, index, interface{}(-index))
+		assertList(t, ql, want)
+	}
+
+	ql.Set(pageSize, "x")
+	want[pageSize] = "x"
+	assertList(t, ql, want)
+}
+
+func TestQuickListRemove(t *testing.T) {
+	ql := NewQuickList()
+	var want []interface{}
+	for i := 0; i < 2*pageSize+10; i++ {
+		ql.Add(i % 3)
+		want = append(want, i%3)
+	}
+	if v := ql.Remove(pageSize); v != want[pageSize] {
+		t.Fatalf("Remove returned %v, want %v", v, want[pageSize])
+	}
+	want = slices.Delete(want, pageSize, pageSize+1)
+	assertList(t, ql, want)
+
+	if v := ql.RemoveLast(); v != want[len(want)-1] {
+		t.Fatalf("RemoveLast returned %v", v)
+	}
+	want = want[:len(want)-1]
+	assertList(t, ql, want)
+
+	isZero := func(a interface{}) bool { return a == 0 }
+	removeFirst := func(s []interface{}, n int) []interface{} {
+		var result []interface{}
+		for _, v := range s {
+			if v == 0 && n > 0 {
+				n--
+				continue
+			}
+			result = append(result, v)
+		}
+		return result
+	}
+	if n := ql.RemoveByVal(isZero, 5); n != 5 {
+		t.Fatalf("RemoveByVal removed %d", n)
+	}
+	want = removeFirst(want, 5)
+	assertList(t, ql, want)
+
+	if n := ql.ReverseRemoveByVal(isZero, 5); n != 5 {
+		t.Fatalf("ReverseRemoveByVal removed %d", n)
+	}
+	slices.Reverse(want)
+	want = removeFirst(want, 5)
+	slices.Reverse(want)
+	assertList(t, ql, want)
+
+	zeros := 0
+	for _, v := range want {
+		if v == 0 {
+			zeros++
+		}
+	}
+	if n := ql.RemoveAllByVal(isZero); n != zeros {
+		t.Fatalf("RemoveAllByVal removed %d, want %d", n, zeros)
+	}
+	want = removeFirst(want, zeros)
+	assertList(t, ql, want)
+	if ql.Contains(isZero) {
+		t.Fatal("list still contains removed value")
+	}
+
+	// 删除全部元素
+	all := func(a interface{}) bool { return true }
+	if n := ql.RemoveAllByVal(all); n != len(want) {
+		t.Fatalf("RemoveAllByVal removed %d, want %d", n, len(want))
+	}
+	assertList(t, ql, nil)
+	if ql.RemoveLast() != nil {
+		t.Fatal("RemoveLast on empty list should return nil")
+	}
+}
+
+func TestQuickListRange(t *testing.T) {
+	ql := NewQuickList()
+	for i := 0; i < 2*pageSize; i++ {
+		ql.Add(i)
+	}
+	tests := []struct{ start, stop int }{
+		{0, 1}, {0, 2 * pageSize}, {pageSize - 2, pageSize + 2}, {2*pageSize - 1, 2 * pageSize}, {5, 5},
+	}
+	for _, tt := range tests {
+		got := ql.Range(tt.start, tt.stop)
+		if len(got) != tt.stop-tt.start {
+			t.Fatalf("Range(%d, %d) returned %d elements", tt.start, tt.stop, len(got))
+		}
+		for i, v := range got {
+			if v != tt.start+i {
+				t.Fatalf("Range(%d, %d)[%d] = %v", tt.start, tt.stop, i, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package set
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// redis set 数据结构
+// 所有的成员都是整数并且个数较少时使用有序的整数数组(intset)存储，节约内存；
+// 插入了非整数成员或者成员个数超过阈值之后，升级为map，之后不再降级
+
+const (
+	maxIntsetEntries = 512  // intset最多存储的成员个数
+	randomPrealloc   = 1024 // 可以重复的随机成员预先分配的个数
+)
+
+// 编码方式
+const (
+	EncodingIntset    = "intset"
+	EncodingHashtable = "hashtable"
+)
+
+type Set struct {
+	intset []int64             // 有序的整数数组
+	dict   map[string]struct{} // 升级之后的map，不为nil表示已经升级
+}
+
+func MakeSet(members ...string) *Set {
+	s := &Set{}
+	for _, member := range members {
+		s.Add(member)
+	}
+	return s
+}
+
+// Encoding 返回当前的编码方式
+func (s *Set) Encoding() string {
+	if s.dict != nil {
+		return EncodingHashtable
+	}
+	return EncodingIntset
+}
+
+// toInt 判断成员是否可以使用intset存储，要求是规范的整数形式(例如 "01" 不是)
+func toInt(member string) (int64, bool) {
+	val, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(val, 10) != member {
+		return 0, false
+	}
+	return val, true
+}
+
+// upgrade 从intset升级为map
+func (s *Set) upgrade() {
+	s.dict = make(map[string]struct{}, len(s.intset))
+	for _, val := range s.intset {
+		s.dict[strconv.FormatInt(val, 10)] = struct{}{}
+	}
+	s.intset = nil
+}
+
+// search 在intset中二分查找，返回插入的位置以及是否存在
+func (s *Set) search(val int64) (int, bool) {
+	i := sort.Search(len(s.intset), func(i int) bool {
+		return s.intset[i] >= val
+	})
+	return i, i < len(s.intset) && s.intset[i] == val
+}
+
+// Add 添加成员，返回1表示新增，0表示已经存在
+func (s *Set) Add(member string) int {
+	if s.dict == nil {
+		val, ok := toInt(member)
+		if !ok || len(s.intset) >= maxIntsetEntries {
+			s.upgrade()
+		} else {
+			i, exists := s.search(val)
+			if exists {
+				return 0
+			}
+			s.intset = append(s.intset, 0)
+			copy(s.intset[i+1:], s.intset[i:])
+			s.intset[i] = val
+			return 1
+		}
+	}
+	if _, exists := s.dict[member]; exists {
+		return 0
+	}
+	s.dict[member] = struct{}{}
+	return 1
+}
+
+// Remove 删除成员，返回删除的个数
+func (s *Set) Remove(member string) int {
+	if s.dict != nil {
+		if _, exists := s.dict[member]; !exists {
+			return 0
+		}
+		delete(s.dict, member)
+		return 1
+	}
+	val, ok := toInt(member)
+	if !ok {
+		return 0
+	}
+	i, exists := s.search(val)
+	if !exists {
+		return 0
+	}
+	s.intset = append(s.intset[:i], s.intset[i+1:]...)
+	return 1
+}
+
+// Has 判断是否存在该成员
+func (s *Set) Has(member string) bool {
+	if s == nil {
+		return false
+	}
+	if s.dict != nil {
+		_, exists := s.dict[member]
+		return exists
+	}
+	val, ok := toInt(member)
+	if !ok {
+		return false
+	}
+	_, exists := s.search(val)
+	return exists
+}
+
+// Len 返回成员的个数
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	if s.dict != nil {
+		return len(s.dict)
+	}
+	return len(s.intset)
+}
+
+// ForEach 遍历所有的成员，consumer返回false则停止遍历
+func (s *Set) ForEach(consumer func(member string) bool) {
+	if s == nil {
+		return
+	}
+	if s.dict != nil {
+		for member := range s.dict {
+			if !consumer(member) {
+				return
+			}
+		}
+		return
+	}
+	for _, val := range s.intset {
+		if !consumer(strconv.FormatInt(val, 10)) {
+			return
+		}
+	}
+}
+
+// Members 返回所有的成员
+func (s *Set) Members() []string {
+	members := make([]string, 0, s.Len())
+	s.ForEach(func(member string) bool {
+		members = append(members, member)
+		return true
+	})
+	return members
+}
+
+// RandomMembers 随机返回limit个成员，可能重复
+func (s *Set) RandomMembers(limit int) []string {
+	members := s.Members()
+	if len(members) == 0 {
+		return nil
+	}
+	// limit 由客户端指定，先分配一部分，其余的随着元素的加入增长
+	result := make([]string, 0, min(limit, randomPrealloc))
+	for i := 0; i < limit; i++ {
+		result = append(result, members[rand.Intn(len(members))])
+	}
+	return result
+}
+
+// RandomDistinctMembers 随机返回limit个不重复的成员，成员不足时返回全部
+func (s *Set) RandomDistinctMembers(limit int) []string {
+	members := s.Members()
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+	if limit < len(members) {
+		members = members[:limit]
+	}
+	return members
+}
+
+// Intersect 求多个集合的交集，任意一个集合为空则结果为空
+func Intersect(sets ...*Set) *Set {
+	result := MakeSet()
+	if len(sets) == 0 {
+		return result
+	}
+	// 从最小的集合开始遍历
+	smallest := sets[0]
+	for _, s := range sets {
+		if s.Len() < smallest.Len() {
+			smallest = s
+		}
+	}
+	smallest.ForEach(func(member string) bool {
+		for _, s := range sets {
+			if !s.Has(member) {
+				return true
+			}
+		}
+		result.Add(member)
+		return true
+	})
+	return result
+}
+
+// Union 求多个集合的并集
+func Union(sets ...*Set) *Set {
+	result := MakeSet()
+	for _, s := range sets {
+		s.ForEach(func(member string) bool {
+			result.Add(member)
+			return true
+		})
+	}
+	return result
+}
+
+// Diff 求第一个集合与其他集合的差集
+func Diff(sets ...*Set) *Set {
+	result := MakeSet()
+	if len(sets) == 0 {
+		return result
+	}
+	sets[0].ForEach(func(member string) bool {
+		for _, s := range sets[1:] {
+			if s.Has(member) {
+				return true
+			}
+		}
+		result.Add(member)
+		return true
+	})
+	return result
+}
@@ -0,0 +1,198 @@
+package sortedset
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Border 范围查询的边界，支持按照score(ZRANGEBYSCORE)和按照member(ZRANGEBYLEX)两种方式
+
+const (
+	scoreNegativeInf int8 = -1
+	scorePositiveInf int8 = 1
+	lexNegativeInf   int8 = '-'
+	lexPositiveInf   int8 = '+'
+)
+
+type Border interface {
+	greater(element *Element) bool // 作为上界时，元素是否在范围内
+	less(element *Element) bool    // 作为下界时，元素是否在范围内
+	getValue() interface{}
+	getExclude() bool
+	isIntersected(max Border) bool // 作为下界时，和上界max组成的范围是否为空
+}
+
+// ScoreBorder 按照score的边界   (1.5  [1.5  1.5  -inf  +inf
+type ScoreBorder struct {
+	Inf     int8
+	Value   float64
+	Exclude bool
+}
+
+func (border *ScoreBorder) greater(element *Element) bool {
+	value := element.Score
+	if border.Inf == scoreNegativeInf {
+		return false
+	} else if border.Inf == scorePositiveInf {
+		return true
+	}
+	if border.Exclude {
+		return border.Value > value
+	}
+	return border.Value >= value
+}
+
+func (border *ScoreBorder) less(element *Element) bool {
+	value := element.Score
+	if border.Inf == scoreNegativeInf {
+		return true
+	} else if border.Inf == scorePositiveInf {
+		return false
+	}
+	if border.Exclude {
+		return border.Value < value
+	}
+	return border.Value <= value
+}
+
+func (border *ScoreBorder) getValue() interface{} {
+	return border.Value
+}
+
+func (border *ScoreBorder) getExclude() bool {
+	return border.Exclude
+}
+
+func (border *ScoreBorder) isIntersected(max Border) bool {
+	minValue := border.Value
+	maxBorder := max.(*ScoreBorder)
+	maxValue := maxBorder.Value
+	if border.Inf == scorePositiveInf || maxBorder.Inf == scoreNegativeInf {
+		return true
+	}
+	if border.Inf == scoreNegativeInf || maxBorder.Inf == scorePositiveInf {
+		return false
+	}
+	return minValue > maxValue || (minValue == maxValue && (border.getExclude() || max.getExclude()))
+}
+
+var scorePositiveInfBorder = &ScoreBorder{
+	Inf: scorePositiveInf,
+}
+
+var scoreNegativeInfBorder = &ScoreBorder{
+	Inf: scoreNegativeInf,
+}
+
+// ParseScoreBorder 解析score的边界
+func ParseScoreBorder(s string) (Border, error) {
+	if s == "inf" || s == "+inf" {
+		return scorePositiveInfBorder, nil
+	}
+	if s == "-inf" {
+		return scoreNegativeInfBorder, nil
+	}
+	if len(s) > 0 && s[0] == '(' {
+		value, err := strconv.ParseFloat(s[1:], 64)
+		if err != nil {
+			return nil, errors.New("ERR min or max is not a float")
+		}
+		return &ScoreBorder{
+			Value:   value,
+			Exclude: true,
+		}, nil
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, errors.New("ERR min or max is not a float")
+	}
+	return &ScoreBorder{
+		Value:   value,
+		Exclude: false,
+	}, nil
+}
+
+// LexBorder 按照member字典序的边界   (a  [a  -  +
+type LexBorder struct {
+	Inf     int8
+	Value   string
+	Exclude bool
+}
+
+func (border *LexBorder) greater(element *Element) bool {
+	value := element.Member
+	if border.Inf == lexNegativeInf {
+		return false
+	} else if border.Inf == lexPositiveInf {
+		return true
+	}
+	if border.Exclude {
+		return border.Value > value
+	}
+	return border.Value >= value
+}
+
+func (border *LexBorder) less(element *Element) bool {
+	value := element.Member
+	if border.Inf == lexNegativeInf {
+		return true
+	} else if border.Inf == lexPositiveInf {
+		return false
+	}
+	if border.Exclude {
+		return border.Value < value
+	}
+	return border.Value <= value
+}
+
+func (border *LexBorder) getValue() interface{} {
+	return border.Value
+}
+
+func (border *LexBorder) getExclude() bool {
+	return border.Exclude
+}
+
+func (border *LexBorder) isIntersected(max Border) bool {
+	minValue := border.Value
+	maxBorder := max.(*LexBorder)
+	maxValue := maxBorder.Value
+	if border.Inf == lexPositiveInf || maxBorder.Inf == lexNegativeInf {
+		return true
+	}
+	if border.Inf == lexNegativeInf || maxBorder.Inf == lexPositiveInf {
+		return false
+	}
+	return minValue > maxValue || (minValue == maxValue && (border.getExclude() || max.getExclude()))
+}
+
+var lexPositiveInfBorder = &LexBorder{
+	Inf: lexPositiveInf,
+}
+
+var lexNegativeInfBorder = &LexBorder{
+	Inf: lexNegativeInf,
+}
+
+// ParseLexBorder 解析字典序的边界
+func ParseLexBorder(s string) (Border, error) {
+	if s == "+" {
+		return lexPositiveInfBorder, nil
+	}
+	if s == "-" {
+		return lexNegativeInfBorder, nil
+	}
+	if len(s) > 0 && s[0] == '(' {
+		return &LexBorder{
+			Value:   s[1:],
+			Exclude: true,
+		}, nil
+	}
+	if len(s) > 0 && s[0] == '[' {
+		return &LexBorder{
+			Value:   s[1:],
+			Exclude: false,
+		}, nil
+	}
+	return nil, errors.New("ERR min or max not valid string range item")
+}
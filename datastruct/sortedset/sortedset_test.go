@@ -0,0 +1,103 @@
+package sortedset
+
+import (
+	"strings"
+	"testing"
+)
+
+func members(elements []*Element) string {
+	result := make([]string, len(elements))
+	for i, element := range elements {
+		result[i] = element.Member
+	}
+	return strings.Join(result, ",")
+}
+
+func mustScoreBorder(t *testing.T, s string) Border {
+	t.Helper()
+	border, err := ParseScoreBorder(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return border
+}
+
+func TestRangeByScoreOffset(t *testing.T) {
+	set := MakeSortedSet()
+	set.Add("a", 1)
+	set.Add("b", 2)
+	set.Add("c", 3)
+	tests := []struct {
+		min, max      string
+		offset, limit int64
+		desc          bool
+		want          string
+	}{
+		{"1", "2", 0, -1, false, "a,b"},
+		{"1", "2", 1, 1, false, "b"},
+		{"1", "2", 2, 1, false, ""}, // 跳过offset之后的第一个元素已经超出范围
+		{"-inf", "+inf", 1, -1, false, "b,c"},
+		{"(1", "3", 0, -1, false, "b,c"},
+		{"2", "3", 0, -1, true, "c,b"},
+		{"2", "3", 2, 1, true, ""},
+		{"(2", "3", 1, -1, true, ""},
+		{"4", "5", 0, -1, false, ""},
+	}
+	for _, tt := range tests {
+		got := members(set.Range(mustScoreBorder(t, tt.min), mustScoreBorder(t, tt.max), tt.offset, tt.limit, tt.desc))
+		if got != tt.want {
+			t.Errorf("Range(%s, %s, %d, %d, %v) = %q, want %q", tt.min, tt.max, tt.offset, tt.limit, tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestRangeByLex(t *testing.T) {
+	set := MakeSortedSet()
+	for _, member := range []string{"a", "b", "c", "d"} {
+		set.Add(member, 0)
+	}
+	tests := []struct {
+		min, max      string
+		offset, limit int64
+		want          string
+	}{
+		{"-", "+", 0, -1, "a,b,c,d"},
+		{"[b", "(d", 0, -1, "b,c"},
+		{"[b", "[c", 2, -1, ""},
+		{"(a", "+", 1, 2, "c,d"},
+	}
+	for _, tt := range tests {
+		min, _ := ParseLexBorder(tt.min)
+		max, _ := ParseLexBorder(tt.max)
+		got := members(set.Range(min, max, tt.offset, tt.limit, false))
+		if got != tt.want {
+			t.Errorf("Range(%s, %s, %d, %d) = %q, want %q", tt.min, tt.max, tt.offset, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestRankAndRemove(t *testing.T) {
+	set := MakeSortedSet()
+	for i, member := range []string{"a", "b", "c", "d", "e"} {
+		set.Add(member, float64(i))
+	}
+	set.Add("a", 10) // 更新score之后移动到末尾
+	if got := members(set.RangeByRank(0, set.Len(), false)); got != "b,c,d,e,a" {
+		t.Errorf("RangeByRank = %q", got)
+	}
+	if got := members(set.RangeByRank(0, 2, true)); got != "a,e" {
+		t.Errorf("RangeByRank desc = %q", got)
+	}
+	if rank := set.GetRank("a", false); rank != 4 {
+		t.Errorf("GetRank(a) = %d, want 4", rank)
+	}
+	if !set.Remove("c") || set.Remove("c") {
+		t.Error("Remove(c) should succeed only once")
+	}
+	if rank := set.GetRank("d", false); rank != 1 {
+		t.Errorf("GetRank(d) = %d, want 1", rank)
+	}
+	if got := members(set.PopMin(2)); got != "b,d" || set.Len() != 2 {
+		t.Errorf("PopMin = %q, len %d", got, set.Len())
+	}
+}
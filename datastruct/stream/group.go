@@ -0,0 +1,284 @@
+package stream
+
+import "sort"
+
+// 消费者组
+// 每个消费者组记录最后分发的消息ID(last_id)和待确认消息列表(PEL)，
+// PEL中的每条消息属于某个消费者，同时记录在消费者自己的PEL中，两者共享同一个PendingEntry
+
+// InvalidEntriesRead 消费者组已读消息数未知
+const InvalidEntriesRead = -1
+
+// PendingEntry 已经分发但是没有确认(XACK)的消息
+type PendingEntry struct {
+	ID            ID
+	Consumer      *Consumer
+	DeliveryTime  int64 // 最后一次分发的时间(毫秒时间戳)
+	DeliveryCount int64 // 分发的次数
+}
+
+// Consumer 消费者
+type Consumer struct {
+	Name       string
+	SeenTime   int64      // 最后一次尝试交互的时间(XREADGROUP XCLAIM XAUTOCLAIM)
+	ActiveTime int64      // 最后一次成功交互的时间，-1表示从未成功交互
+	pel        *RadixTree // ID -> *PendingEntry
+}
+
+// PendingCount 返回消费者待确认的消息数
+func (c *Consumer) PendingCount() int {
+	return c.pel.Len()
+}
+
+// ForEachPending 按照ID从小到大遍历消费者的 ID >= start 的待确认消息
+func (c *Consumer) ForEachPending(start ID, consumer func(pe *PendingEntry) bool) {
+	c.pel.Ascend(start.Encode(), func(key []byte, value interface{}) bool {
+		return consumer(value.(*PendingEntry))
+	})
+}
+
+type Group struct {
+	Name        string
+	LastID      ID    // 最后分发给消费者的消息ID
+	EntriesRead int64 // 已经分发的消息数，用于计算lag
+	pel         *RadixTree
+	consumers   map[string]*Consumer
+}
+
+// Consumer 查找消费者
+func (g *Group) Consumer(name string) *Consumer {
+	return g.consumers[name]
+}
+
+// CreateConsumer 创建消费者，已经存在时返回false
+func (g *Group) CreateConsumer(name string, now int64) (*Consumer, bool) {
+	if c, ok := g.consumers[name]; ok {
+		return c, false
+	}
+	c := &Consumer{
+		Name:       name,
+		SeenTime:   now,
+		ActiveTime: -1,
+		pel:        MakeRadixTree(),
+	}
+	g.consumers[name] = c
+	return c, true
+}
+
+// DeleteConsumer 删除消费者以及它的待确认消息，返回删除的待确认消息数
+func (g *Group) DeleteConsumer(name string) (int, bool) {
+	c, ok := g.consumers[name]
+	if !ok {
+		return 0, false
+	}
+	count := c.pel.Len()
+	c.pel.Ascend(nil, func(key []byte, value interface{}) bool {
+		g.pel.Remove(key)
+		return true
+	})
+	delete(g.consumers, name)
+	return count, true
+}
+
+// Consumers 返回按照名字排序的消费者
+func (g *Group) Consumers() []*Consumer {
+	result := make([]*Consumer, 0, len(g.consumers))
+	for _, c := range g.consumers {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Pending 查找待确认消息
+func (g *Group) Pending(id ID) *PendingEntry {
+	value, ok := g.pel.Get(id.Encode())
+	if !ok {
+		return nil
+	}
+	return value.(*PendingEntry)
+}
+
+// PendingCount 返回消费者组待确认的消息数
+func (g *Group) PendingCount() int {
+	return g.pel.Len()
+}
+
+// ForEachPending 按照ID从小到大遍历 [start, end] 范围内的待确认消息
+func (g *Group) ForEachPending(start ID, end ID, consumer func(pe *PendingEntry) bool) {
+	g.pel.Ascend(start.Encode(), func(key []byte, value interface{}) bool {
+		pe := value.(*PendingEntry)
+		if end.Less(pe.ID) {
+			return false
+		}
+		return consumer(pe)
+	})
+}
+
+// Ack 确认消息，从PEL中删除
+func (g *Group) Ack(id ID) bool {
+	pe := g.Pending(id)
+	if pe == nil {
+		return false
+	}
+	key := id.Encode()
+	g.pel.Remove(key)
+	pe.Consumer.pel.Remove(key)
+	return true
+}
+
+// Deliver 将消息分发给消费者：不在PEL中时新增，否则转移给该消费者，并重置分发次数
+func (g *Group) Deliver(id ID, c *Consumer, now int64) *PendingEntry {
+	pe := g.Pending(id)
+	if pe == nil {
+		pe = &PendingEntry{ID: id}
+		g.pel.Insert(id.Encode(), pe)
+	}
+	g.Claim(pe, c, now, 1)
+	return pe
+}
+
+// Claim 将待确认消息转移给消费者，并设置分发的时间和次数
+func (g *Group) Claim(pe *PendingEntry, c *Consumer, deliveryTime int64, deliveryCount int64) {
+	if pe.Consumer != c {
+		key := pe.ID.Encode()
+		if pe.Consumer != nil {
+			pe.Consumer.pel.Remove(key)
+		}
+		c.pel.Insert(key, pe)
+		pe.Consumer = c
+	}
+	pe.DeliveryTime = deliveryTime
+	pe.DeliveryCount = deliveryCount
+}
+
+// ------------------- stream 中与消费者组相关的方法 -------------------
+
+// Group 查找消费者组
+func (s *Stream) Group(name string) *Group {
+	return s.groups[name]
+}
+
+// CreateGroup 创建消费者组，已经存在时返回false
+func (s *Stream) CreateGroup(name string, lastID ID, entriesRead int64) (*Group, bool) {
+	if g, ok := s.groups[name]; ok {
+		return g, false
+	}
+	if s.groups == nil {
+		s.groups = make(map[string]*Group)
+	}
+	g := &Group{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		pel:         MakeRadixTree(),
+		consumers:   make(map[string]*Consumer),
+	}
+	s.groups[name] = g
+	return g, true
+}
+
+// DestroyGroup 删除消费者组
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups 返回按照名字排序的消费者组
+func (s *Stream) Groups() []*Group {
+	result := make([]*Group, 0, len(s.groups))
+	for _, g := range s.groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// FirstID 返回第一条消息的ID，stream为空时返回 0-0
+func (s *Stream) FirstID() ID {
+	if first := s.First(); first != nil {
+		return first.ID
+	}
+	return MinID
+}
+
+// hasTombstones ID >= start 的范围内是否可能有被删除的消息
+func (s *Stream) hasTombstones(start ID) bool {
+	if s.length == 0 || s.maxDeletedID == MinID {
+		return false
+	}
+	return !s.maxDeletedID.Less(start)
+}
+
+// estimateEntriesRead 估算ID为id的消息是第几条添加的消息，无法估算时返回 InvalidEntriesRead
+func (s *Stream) estimateEntriesRead(id ID) int64 {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	if s.length == 0 && !s.lastID.Less(id) {
+		return s.entriesAdded
+	}
+	if !id.Less(s.lastID) { // id >= lastID
+		return s.entriesAdded
+	}
+	firstID := s.FirstID()
+	// 第一条消息之前没有被删除的消息时才能估算
+	if s.maxDeletedID == MinID || s.maxDeletedID.Less(firstID) {
+		switch id.Compare(firstID) {
+		case -1:
+			return s.entriesAdded - s.length
+		case 0:
+			return s.entriesAdded - s.length + 1
+		}
+	}
+	return InvalidEntriesRead
+}
+
+// Lag 返回消费者组还没有读取的消息数，无法计算时返回false
+func (s *Stream) Lag(g *Group) (int64, bool) {
+	if s.entriesAdded == 0 {
+		return 0, true
+	}
+	if g.EntriesRead != InvalidEntriesRead && !s.hasTombstones(g.LastID) {
+		return s.entriesAdded - g.EntriesRead, true
+	}
+	entriesRead := s.estimateEntriesRead(g.LastID)
+	if entriesRead == InvalidEntriesRead {
+		return 0, false
+	}
+	return s.entriesAdded - entriesRead, true
+}
+
+// SetGroupID 设置消费者组最后分发的消息ID，entriesRead为 InvalidEntriesRead 表示已读消息数未知
+func (s *Stream) SetGroupID(g *Group, id ID, entriesRead int64) {
+	g.LastID = id
+	g.EntriesRead = entriesRead
+}
+
+// ReadGroup 将 last_id 之后的最多count条新消息分发给消费者，noAck为true时不加入PEL
+func (s *Stream) ReadGroup(g *Group, c *Consumer, count int, noAck bool, now int64) []*Entry {
+	start, ok := g.LastID.Incr()
+	if !ok {
+		return nil
+	}
+	entries := s.Range(start, MaxID, count, false)
+	for _, entry := range entries {
+		if g.EntriesRead != InvalidEntriesRead && !s.hasTombstones(entry.ID) {
+			g.EntriesRead++
+		} else if s.entriesAdded > 0 {
+			g.EntriesRead = s.estimateEntriesRead(entry.ID)
+		}
+		g.LastID = entry.ID
+		if !noAck {
+			g.Deliver(entry.ID, c, now)
+		}
+	}
+	return entries
+}
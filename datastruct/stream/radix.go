@@ -0,0 +1,232 @@
+package stream
+
+import "bytes"
+
+// RadixTree 压缩前缀树(rax)，按照key的字典序有序，支持从任意位置开始正向或者反向遍历
+// stream中以16字节大端序的ID作为key，相邻的ID共享大部分前缀，比普通的map更节约内存并且天然有序
+
+type radixNode struct {
+	prefix   []byte       // 从父节点到当前节点的路径
+	children []*radixNode // 按照prefix的第一个字节有序
+	isKey    bool         // 从根节点到当前节点的路径是否为一个key
+	value    interface{}
+}
+
+type RadixTree struct {
+	root *radixNode
+	size int
+}
+
+func MakeRadixTree() *RadixTree {
+	return &RadixTree{root: &radixNode{}}
+}
+
+// Len 返回key的个数
+func (tree *RadixTree) Len() int {
+	return tree.size
+}
+
+// findChild 二分查找第一个字节为b的子节点，不存在时返回应该插入的位置
+func (n *radixNode) findChild(b byte) (int, *radixNode) {
+	lo, hi := 0, len(n.children)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if n.children[mid].prefix[0] < b {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(n.children) && n.children[lo].prefix[0] == b {
+		return lo, n.children[lo]
+	}
+	return lo, nil
+}
+
+// mergeChild 当前节点不是key并且只有一个子节点时，和子节点合并
+func (n *radixNode) mergeChild() {
+	child := n.children[0]
+	prefix := make([]byte, 0, len(n.prefix)+len(child.prefix))
+	prefix = append(prefix, n.prefix...)
+	n.prefix = append(prefix, child.prefix...)
+	n.children = child.children
+	n.isKey = child.isKey
+	n.value = child.value
+}
+
+func commonPrefixLen(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// Get 查找key对应的值
+func (tree *RadixTree) Get(key []byte) (interface{}, bool) {
+	n := tree.root
+	for {
+		if !bytes.HasPrefix(key, n.prefix) {
+			return nil, false
+		}
+		key = key[len(n.prefix):]
+		if len(key) == 0 {
+			return n.value, n.isKey
+		}
+		_, n = n.findChild(key[0])
+		if n == nil {
+			return nil, false
+		}
+	}
+}
+
+// Insert 插入或者更新key，新插入时返回true
+func (tree *RadixTree) Insert(key []byte, value interface{}) bool {
+	key = append([]byte(nil), key...) // 拷贝一份，避免调用方修改
+	n := tree.root
+	for {
+		key = key[len(n.prefix):]
+		if len(key) == 0 {
+			isNew := !n.isKey
+			n.isKey = true
+			n.value = value
+			if isNew {
+				tree.size++
+			}
+			return isNew
+		}
+		index, child := n.findChild(key[0])
+		if child == nil { // 没有公共前缀的子节点，直接插入新的叶子节点
+			leaf := &radixNode{prefix: key, isKey: true, value: value}
+			n.children = append(n.children, nil)
+			copy(n.children[index+1:], n.children[index:])
+			n.children[index] = leaf
+			tree.size++
+			return true
+		}
+		common := commonPrefixLen(child.prefix, key)
+		if common < len(child.prefix) { // 子节点的路径只匹配了一部分，需要分裂
+			split := &radixNode{
+				prefix:   child.prefix[:common],
+				children: []*radixNode{child},
+			}
+			child.prefix = child.prefix[common:]
+			n.children[index] = split
+			child = split
+		}
+		n = child
+	}
+}
+
+// Remove 删除key，key存在时返回true
+func (tree *RadixTree) Remove(key []byte) bool {
+	var parent *radixNode
+	parentIndex := 0
+	n := tree.root
+	for {
+		if !bytes.HasPrefix(key, n.prefix) {
+			return false
+		}
+		key = key[len(n.prefix):]
+		if len(key) == 0 {
+			break
+		}
+		index, child := n.findChild(key[0])
+		if child == nil {
+			return false
+		}
+		parent, parentIndex, n = n, index, child
+	}
+	if !n.isKey {
+		return false
+	}
+	n.isKey = false
+	n.value = nil
+	tree.size--
+	if parent == nil { // 根节点
+		return true
+	}
+	switch len(n.children) {
+	case 0: // 叶子节点直接删除，父节点只剩一个子节点时和子节点合并
+		parent.children = append(parent.children[:parentIndex], parent.children[parentIndex+1:]...)
+		if parent != tree.root && !parent.isKey && len(parent.children) == 1 {
+			parent.mergeChild()
+		}
+	case 1:
+		n.mergeChild()
+	}
+	return true
+}
+
+// Ascend 按照字典序从小到大遍历 >= from 的key，from为nil时从第一个key开始，consumer返回false时停止遍历
+// 传给consumer的key在遍历过程中会被复用，需要保存时应当拷贝
+func (tree *RadixTree) Ascend(from []byte, consumer func(key []byte, value interface{}) bool) {
+	tree.root.ascend(nil, from, from == nil, consumer)
+}
+
+func (n *radixNode) ascend(path []byte, from []byte, all bool, consumer func(key []byte, value interface{}) bool) bool {
+	path = append(path, n.prefix...)
+	if !all {
+		cmp := bytes.Compare(path, from[:min(len(path), len(from))])
+		if cmp < 0 { // 子树中所有的key都小于from
+			return true
+		}
+		if cmp > 0 { // 子树中所有的key都大于from
+			all = true
+		}
+	}
+	// 此时path是from的前缀，或者子树中所有的key都大于from
+	if n.isKey && (all || len(path) >= len(from)) {
+		if !consumer(path, n.value) {
+			return false
+		}
+	}
+	for _, child := range n.children {
+		if !child.ascend(path, from, all, consumer) {
+			return false
+		}
+	}
+	return true
+}
+
+// Descend 按照字典序从大到小遍历 <= from 的key，from为nil时从最后一个key开始，consumer返回false时停止遍历
+func (tree *RadixTree) Descend(from []byte, consumer func(key []byte, value interface{}) bool) {
+	tree.root.descend(nil, from, from == nil, consumer)
+}
+
+func (n *radixNode) descend(path []byte, from []byte, all bool, consumer func(key []byte, value interface{}) bool) bool {
+	path = append(path, n.prefix...)
+	if !all {
+		cmp := bytes.Compare(path, from[:min(len(path), len(from))])
+		if cmp > 0 { // 子树中所有的key都大于from
+			return true
+		}
+		if cmp < 0 { // 子树中所有的key都小于from
+			all = true
+		}
+	}
+	for i := len(n.children) - 1; i >= 0; i-- {
+		if !n.children[i].descend(path, from, all, consumer) {
+			return false
+		}
+	}
+	if n.isKey && (all || len(path) <= len(from)) {
+		if !consumer(path, n.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// NodeCount 返回树中节点的个数，包括根节点
+func (tree *RadixTree) NodeCount() int {
+	return tree.root.count()
+}
+
+func (n *radixNode) count() int {
+	total := 1
+	for _, child := range n.children {
+		total += child.count()
+	}
+	return total
+}
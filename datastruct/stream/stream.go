@@ -0,0 +1,390 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// redis stream 数据结构
+// 消息按照ID有序，每个宏节点(node)存储若干条连续的消息，宏节点以第一条消息的ID(master ID)作为key存储在压缩前缀树中；
+// 追加消息时优先写入最后一个宏节点，写满之后再新建宏节点；近似裁剪(~)时只删除完整的宏节点
+
+const nodeMaxEntries = 100 // 每个宏节点最多存储的消息数，对应redis的 stream-node-max-entries
+
+// ID 消息ID，由毫秒时间戳和序列号组成  <ms>-<seq>
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var (
+	MinID = ID{}
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+	ErrInvalidID = errors.New("ERR Invalid stream ID specified as stream command argument")
+)
+
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare 比较两个ID的大小，返回 -1 0 1
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+func (id ID) Less(other ID) bool {
+	return id.Compare(other) < 0
+}
+
+// Incr 返回下一个ID，已经是最大的ID时返回false
+func (id ID) Incr() (ID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return ID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Decr 返回上一个ID，已经是最小的ID时返回false
+func (id ID) Decr() (ID, bool) {
+	if id.Seq > 0 {
+		return ID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// Encode 编码为16字节的大端序，使得字典序和ID的大小顺序一致
+func (id ID) Encode() []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, id.Ms)
+	binary.BigEndian.PutUint64(buf[8:], id.Seq)
+	return buf
+}
+
+// DecodeID Encode的逆运算
+func DecodeID(buf []byte) ID {
+	return ID{
+		Ms:  binary.BigEndian.Uint64(buf),
+		Seq: binary.BigEndian.Uint64(buf[8:]),
+	}
+}
+
+// ParseID 解析ID，只有毫秒部分时序列号使用missingSeq
+func ParseID(s string, missingSeq uint64) (ID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	if !hasSeq {
+		return ID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	return ID{Ms: ms, Seq: seq}, nil
+}
+
+// Entry 一条消息
+type Entry struct {
+	ID     ID
+	Fields [][]byte // field value field value ...
+}
+
+// node 宏节点，消息按照ID有序
+type node struct {
+	entries []*Entry
+}
+
+// search 二分查找第一个ID >= id 的消息的下标
+func (n *node) search(id ID) int {
+	return sort.Search(len(n.entries), func(i int) bool {
+		return !n.entries[i].ID.Less(id)
+	})
+}
+
+type Stream struct {
+	rax          *RadixTree // master ID -> *node
+	length       int64
+	lastID       ID    // 最后添加的消息的ID，删除消息之后也不会变小
+	maxDeletedID ID    // 被删除的消息中最大的ID
+	entriesAdded int64 // 累计添加的消息数
+	groups       map[string]*Group
+}
+
+func MakeStream() *Stream {
+	return &Stream{rax: MakeRadixTree()}
+}
+
+// Len 返回消息的条数
+func (s *Stream) Len() int64 {
+	return s.length
+}
+
+// LastID 返回最后添加的消息的ID
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+// MaxDeletedID 返回被删除的消息中最大的ID
+func (s *Stream) MaxDeletedID() ID {
+	return s.maxDeletedID
+}
+
+// NodeCount 返回宏节点的个数以及压缩前缀树的节点个数
+func (s *Stream) NodeCount() (int, int) {
+	return s.rax.Len(), s.rax.NodeCount()
+}
+
+// EntriesAdded 返回累计添加的消息数
+func (s *Stream) EntriesAdded() int64 {
+	return s.entriesAdded
+}
+
+// NextID 根据当前时间生成自增的ID，时间回拨时沿用最后一个ID的时间戳，序列号用完时返回false
+func (s *Stream) NextID(nowMs uint64) (ID, bool) {
+	if nowMs > s.lastID.Ms {
+		return ID{Ms: nowMs}, true
+	}
+	return s.lastID.Incr()
+}
+
+// NextSeq 为指定的毫秒时间戳生成序列号 (<ms>-*)，无法生成比lastID更大的ID时返回false
+func (s *Stream) NextSeq(ms uint64) (ID, bool) {
+	if ms > s.lastID.Ms {
+		if ms == 0 {
+			return ID{Seq: 1}, true // 0-0 不是合法的ID
+		}
+		return ID{Ms: ms}, true
+	}
+	if ms == s.lastID.Ms && s.lastID.Seq < math.MaxUint64 {
+		return ID{Ms: ms, Seq: s.lastID.Seq + 1}, true
+	}
+	return ID{}, false
+}
+
+// lastNode 返回最后一个宏节点
+func (s *Stream) lastNode() *node {
+	var result *node
+	s.rax.Descend(nil, func(key []byte, value interface{}) bool {
+		result = value.(*node)
+		return false
+	})
+	return result
+}
+
+// Add 追加一条消息，调用方需要保证 id 大于 LastID
+func (s *Stream) Add(id ID, fields [][]byte) *Entry {
+	entry := &Entry{ID: id, Fields: fields}
+	n := s.lastNode()
+	if n == nil || len(n.entries) >= nodeMaxEntries {
+		n = &node{}
+		s.rax.Insert(id.Encode(), n)
+	}
+	n.entries = append(n.entries, entry)
+	s.length++
+	s.entriesAdded++
+	s.lastID = id
+	return entry
+}
+
+// First 返回第一条消息
+func (s *Stream) First() *Entry {
+	entries := s.Range(MinID, MaxID, 1, false)
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries[0]
+}
+
+// Last 返回最后一条消息
+func (s *Stream) Last() *Entry {
+	entries := s.Range(MinID, MaxID, 1, true)
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries[0]
+}
+
+// Get 查找指定ID的消息
+func (s *Stream) Get(id ID) (*Entry, bool) {
+	entries := s.Range(id, id, 1, false)
+	if len(entries) == 0 {
+		return nil, false
+	}
+	return entries[0], true
+}
+
+// ForEach 遍历 [start, end] 范围内的消息，desc为true时从大到小遍历，consumer返回false时停止
+func (s *Stream) ForEach(start ID, end ID, desc bool, consumer func(entry *Entry) bool) {
+	if end.Less(start) {
+		return
+	}
+	if !desc {
+		// 从包含start的宏节点开始遍历，即master ID <= start 的最后一个宏节点
+		var from []byte
+		s.rax.Descend(start.Encode(), func(key []byte, value interface{}) bool {
+			from = append([]byte(nil), key...)
+			return false
+		})
+		s.rax.Ascend(from, func(key []byte, value interface{}) bool {
+			n := value.(*node)
+			for _, entry := range n.entries[n.search(start):] {
+				if end.Less(entry.ID) {
+					return false
+				}
+				if !consumer(entry) {
+					return false
+				}
+			}
+			return true
+		})
+		return
+	}
+	s.rax.Descend(end.Encode(), func(key []byte, value interface{}) bool {
+		n := value.(*node)
+		for i := n.search(end); i >= 0; i-- {
+			if i >= len(n.entries) || end.Less(n.entries[i].ID) {
+				continue
+			}
+			entry := n.entries[i]
+			if entry.ID.Less(start) {
+				return false
+			}
+			if !consumer(entry) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Range 返回 [start, end] 范围内最多count条消息(count<=0表示不限制)
+func (s *Stream) Range(start ID, end ID, count int, desc bool) []*Entry {
+	var result []*Entry
+	s.ForEach(start, end, desc, func(entry *Entry) bool {
+		result = append(result, entry)
+		return count <= 0 || len(result) < count
+	})
+	return result
+}
+
+// Delete 删除指定ID的消息，宏节点为空时删除宏节点
+func (s *Stream) Delete(id ID) bool {
+	var masterKey []byte
+	var n *node
+	s.rax.Descend(id.Encode(), func(key []byte, value interface{}) bool {
+		masterKey = append([]byte(nil), key...)
+		n = value.(*node)
+		return false
+	})
+	if n == nil {
+		return false
+	}
+	i := n.search(id)
+	if i >= len(n.entries) || n.entries[i].ID != id {
+		return false
+	}
+	n.entries = append(n.entries[:i], n.entries[i+1:]...)
+	if len(n.entries) == 0 {
+		s.rax.Remove(masterKey)
+	}
+	s.length--
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+	return true
+}
+
+// 裁剪策略
+const (
+	TrimMaxLen = iota + 1 // 保留最新的 threshold 条消息
+	TrimMinID             // 删除ID小于 threshold 的消息
+)
+
+// TrimOptions XADD 和 XTRIM 中的裁剪参数
+type TrimOptions struct {
+	Strategy int
+	MaxLen   int64
+	MinID    ID
+	Approx   bool  // ~ 近似裁剪，只删除完整的宏节点
+	Limit    int64 // 近似裁剪时最多删除的消息数，0表示不限制
+}
+
+// Trim 从头部开始删除消息，返回删除的条数
+func (s *Stream) Trim(opts *TrimOptions) int64 {
+	var deleted int64
+	var removedKeys [][]byte
+	var lastRemoved ID
+	s.rax.Ascend(nil, func(key []byte, value interface{}) bool {
+		if opts.Strategy == TrimMaxLen && s.length <= opts.MaxLen {
+			return false
+		}
+		n := value.(*node)
+		entries := int64(len(n.entries))
+		if opts.Limit > 0 && deleted+entries > opts.Limit {
+			return false
+		}
+		// 整个宏节点都可以删除
+		var removeNode bool
+		if opts.Strategy == TrimMaxLen {
+			removeNode = s.length-entries >= opts.MaxLen
+		} else {
+			removeNode = n.entries[len(n.entries)-1].ID.Less(opts.MinID)
+		}
+		if removeNode {
+			removedKeys = append(removedKeys, append([]byte(nil), key...))
+			lastRemoved = n.entries[len(n.entries)-1].ID
+			s.length -= entries
+			deleted += entries
+			return true
+		}
+		// 近似裁剪时不删除宏节点中的部分消息
+		if opts.Approx {
+			return false
+		}
+		i := 0
+		for i < len(n.entries) {
+			if opts.Strategy == TrimMaxLen && s.length <= opts.MaxLen {
+				break
+			}
+			if opts.Strategy == TrimMinID && !n.entries[i].ID.Less(opts.MinID) {
+				break
+			}
+			lastRemoved = n.entries[i].ID
+			s.length--
+			deleted++
+			i++
+		}
+		n.entries = n.entries[i:]
+		return false
+	})
+	for _, key := range removedKeys {
+		s.rax.Remove(key)
+	}
+	if deleted > 0 && s.maxDeletedID.Less(lastRemoved) {
+		s.maxDeletedID = lastRemoved
+	}
+	return deleted
+}
@@ -0,0 +1,129 @@
+package stream
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want ID
+		err  bool
+	}{
+		{"1-2", ID{1, 2}, false},
+		{"5", ID{5, 7}, false}, // 缺少序列号时使用missingSeq
+		{"0-0", ID{}, false},
+		{"18446744073709551615-18446744073709551615", MaxID, false},
+		{"18446744073709551616-0", ID{}, true},
+		{"1-", ID{}, true},
+		{"-1", ID{}, true},
+		{"a-1", ID{}, true},
+		{"1-2-3", ID{}, true},
+	}
+	for _, tt := range tests {
+		id, err := ParseID(tt.s, 7)
+		if (err != nil) != tt.err || (!tt.err && id != tt.want) {
+			t.Errorf("ParseID(%q) = %v, %v", tt.s, id, err)
+		}
+		if !tt.err && id.String() != tt.want.String() {
+			t.Errorf("ParseID(%q).String() = %s", tt.s, id.String())
+		}
+	}
+}
+
+func TestIDOrder(t *testing.T) {
+	ids := []ID{MinID, {0, 1}, {0, math.MaxUint64}, {1, 0}, {1, 1}, {math.MaxUint64, 0}, MaxID}
+	for i := 0; i+1 < len(ids); i++ {
+		a, b := ids[i], ids[i+1]
+		if !a.Less(b) || b.Less(a) || a.Compare(b) != -1 || b.Compare(a) != 1 || a.Compare(a) != 0 {
+			t.Errorf("%v should be less than %v", a, b)
+		}
+		// 编码之后的字典序和ID的顺序一致
+		if bytes.Compare(a.Encode(), b.Encode()) >= 0 {
+			t.Errorf("encoded %v should be less than encoded %v", a, b)
+		}
+		if DecodeID(a.Encode()) != a {
+			t.Errorf("decode(encode(%v)) = %v", a, DecodeID(a.Encode()))
+		}
+		// Incr 的结果大于当前的ID，并且不会越过下一个更大的ID
+		if next, ok := a.Incr(); !ok || !a.Less(next) || b.Less(next) {
+			t.Errorf("%v.Incr() = %v, %v", a, next, ok)
+		}
+	}
+	if _, ok := MaxID.Incr(); ok {
+		t.Error("MaxID.Incr() should fail")
+	}
+	if _, ok := MinID.Decr(); ok {
+		t.Error("MinID.Decr() should fail")
+	}
+	if id, _ := (ID{1, 0}).Decr(); id != (ID{0, math.MaxUint64}) {
+		t.Errorf("1-0 Decr() = %v", id)
+	}
+	if id, _ := (ID{0, math.MaxUint64}).Incr(); id != (ID{1, 0}) {
+		t.Errorf("0-max Incr() = %v", id)
+	}
+}
+
+func TestNextID(t *testing.T) {
+	s := MakeStream()
+	if id, ok := s.NextSeq(0); !ok || id != (ID{0, 1}) { // 0-0 不是合法的ID
+		t.Errorf("NextSeq(0) on empty stream = %v, %v", id, ok)
+	}
+	if id, ok := s.NextID(100); !ok || id != (ID{100, 0}) {
+		t.Errorf("NextID(100) = %v, %v", id, ok)
+	}
+	s.Add(ID{100, 5}, [][]byte{[]byte("f"), []byte("v")})
+	if id, _ := s.NextID(100); id != (ID{100, 6}) {
+		t.Errorf("NextID with same ms = %v", id)
+	}
+	if id, _ := s.NextID(50); id != (ID{100, 6}) { // 时间回拨
+		t.Errorf("NextID with smaller ms = %v", id)
+	}
+	if id, ok := s.NextSeq(100); !ok || id != (ID{100, 6}) {
+		t.Errorf("NextSeq(100) = %v, %v", id, ok)
+	}
+	if _, ok := s.NextSeq(99); ok {
+		t.Error("NextSeq with smaller ms should fail")
+	}
+	s.Add(ID{200, math.MaxUint64}, nil)
+	if _, ok := s.NextSeq(200); ok {
+		t.Error("NextSeq should fail when seq is exhausted")
+	}
+	if id, _ := s.NextID(200); id != (ID{201, 0}) {
+		t.Errorf("NextID after exhausted seq = %v", id)
+	}
+}
+
+// 跨越多个宏节点的范围查询和删除
+func TestRangeAcrossNodes(t *testing.T) {
+	s := MakeStream()
+	n := 3*nodeMaxEntries + 7
+	for i := 1; i <= n; i++ {
+		s.Add(ID{uint64(i), 0}, nil)
+	}
+	if s.Len() != int64(n) {
+		t.Fatalf("len %d", s.Len())
+	}
+	entries := s.Range(ID{50, 1}, ID{250, 0}, 0, false)
+	if len(entries) != 200 || entries[0].ID != (ID{51, 0}) || entries[199].ID != (ID{250, 0}) {
+		t.Errorf("range returned %d entries", len(entries))
+	}
+	entries = s.Range(ID{50, 0}, ID{250, 0}, 3, true)
+	if len(entries) != 3 || entries[0].ID != (ID{250, 0}) || entries[2].ID != (ID{248, 0}) {
+		t.Errorf("reverse range returned %v", entries)
+	}
+	if !s.Delete(ID{100, 0}) || s.Delete(ID{100, 0}) {
+		t.Error("delete should succeed exactly once")
+	}
+	if _, ok := s.Get(ID{100, 0}); ok {
+		t.Error("deleted entry still found")
+	}
+	if s.MaxDeletedID() != (ID{100, 0}) {
+		t.Errorf("max deleted id %v", s.MaxDeletedID())
+	}
+	if entries := s.Range(ID{99, 0}, ID{101, 0}, 0, false); len(entries) != 2 {
+		t.Errorf("range over deleted entry returned %d entries", len(entries))
+	}
+}
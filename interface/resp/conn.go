@@ -4,4 +4,32 @@ type Connection interface { // resp通信协议   客户端的相关操作
 	Write([]byte) error
 	GetDBIndex() int
 	SelectDB(int)
+	GetID() uint64
+	GetProtocol() int // 2 或 3
+	SetProtocol(int)
+	GetName() string
+	SetName(string)
+
+	// 事务 MULTI/EXEC/WATCH
+	InMultiState() bool
+	SetMultiState(bool)
+	GetQueuedCmdLine() [][][]byte
+	EnqueueCmd([][]byte)
+	AddTxError(err error)
+	GetTxErrors() []error
+	GetWatching() map[string]uint32
+	ClearWatching()
+
+	// 发布订阅
+	Subscribe(channel string)
+	UnSubscribe(channel string)
+	PSubscribe(pattern string)
+	PUnSubscribe(pattern string)
+	SSubscribe(channel string)
+	SUnSubscribe(channel string)
+	SubsCount() int      // 订阅的频道和模式的个数
+	ShardSubsCount() int // 订阅的分片频道的个数，和 SubsCount 有一个大于0时处于订阅模式
+	GetChannels() []string
+	GetPatterns() []string
+	GetShardChannels() []string
 }
@@ -0,0 +1,128 @@
+package lock
+
+import (
+	"sort"
+	"sync"
+)
+
+// Locks 分段锁：根据key的hash值选择对应的读写锁，避免为每一个key都创建一把锁
+type Locks struct {
+	table []*sync.RWMutex
+}
+
+const prime32 = uint32(16777619)
+
+// fnv32 计算key的hash值
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash *= prime32
+		hash ^= uint32(key[i])
+	}
+	return hash
+}
+
+// Make 创建分段锁，tableSize为锁的个数
+func Make(tableSize int) *Locks {
+	table := make([]*sync.RWMutex, tableSize)
+	for i := 0; i < tableSize; i++ {
+		table[i] = &sync.RWMutex{}
+	}
+	return &Locks{
+		table: table,
+	}
+}
+
+func (locks *Locks) spread(hashCode uint32) uint32 {
+	if locks == nil {
+		panic("locks is nil")
+	}
+	tableSize := uint32(len(locks.table))
+	return hashCode % tableSize
+}
+
+// Lock 获取key对应的写锁
+func (locks *Locks) Lock(key string) {
+	index := locks.spread(fnv32(key))
+	mu := locks.table[index]
+	mu.Lock()
+}
+
+// RLock 获取key对应的读锁
+func (locks *Locks) RLock(key string) {
+	index := locks.spread(fnv32(key))
+	mu := locks.table[index]
+	mu.RLock()
+}
+
+// UnLock 释放key对应的写锁
+func (locks *Locks) UnLock(key string) {
+	index := locks.spread(fnv32(key))
+	mu := locks.table[index]
+	mu.Unlock()
+}
+
+// RUnLock 释放key对应的读锁
+func (locks *Locks) RUnLock(key string) {
+	index := locks.spread(fnv32(key))
+	mu := locks.table[index]
+	mu.RUnlock()
+}
+
+// toLockIndices 计算多个key对应的锁的下标，去重之后排序，保证所有协程加锁的顺序一致，避免死锁
+func (locks *Locks) toLockIndices(keys []string, reverse bool) []uint32 {
+	indexMap := make(map[uint32]struct{})
+	for _, key := range keys {
+		index := locks.spread(fnv32(key))
+		indexMap[index] = struct{}{}
+	}
+	indices := make([]uint32, 0, len(indexMap))
+	for index := range indexMap {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		if !reverse {
+			return indices[i] < indices[j]
+		}
+		return indices[i] > indices[j]
+	})
+	return indices
+}
+
+// RWLocks 获取写入的key的写锁和读取的key的读锁，同一个key(或者同一把锁)既读又写时只获取写锁
+func (locks *Locks) RWLocks(writeKeys []string, readKeys []string) {
+	keys := append(append([]string{}, writeKeys...), readKeys...)
+	indices := locks.toLockIndices(keys, false)
+	writeIndices := locks.toWriteIndexSet(writeKeys)
+	for _, index := range indices {
+		mu := locks.table[index]
+		if _, isWrite := writeIndices[index]; isWrite {
+			mu.Lock()
+		} else {
+			mu.RLock()
+		}
+	}
+}
+
+// RWUnLocks 释放 RWLocks 获取的锁
+func (locks *Locks) RWUnLocks(writeKeys []string, readKeys []string) {
+	keys := append(append([]string{}, writeKeys...), readKeys...)
+	indices := locks.toLockIndices(keys, true)
+	writeIndices := locks.toWriteIndexSet(writeKeys)
+	for _, index := range indices {
+		mu := locks.table[index]
+		if _, isWrite := writeIndices[index]; isWrite {
+			mu.Unlock()
+		} else {
+			mu.RUnlock()
+		}
+	}
+}
+
+func (locks *Locks) toWriteIndexSet(writeKeys []string) map[uint32]struct{} {
+	indexSet := make(map[uint32]struct{}, len(writeKeys))
+	for _, key := range writeKeys {
+		indexSet[locks.spread(fnv32(key))] = struct{}{}
+	}
+	return indexSet
+}
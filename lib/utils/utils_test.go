@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestConvertRange(t *testing.T) {
+	tests := []struct {
+		start, end, size int64
+		begin, stop      int
+	}{
+		{0, -1, 5, 0, 5},
+		{1, 3, 5, 1, 4},
+		{-2, -1, 5, 3, 5},
+		{-100, -1, 5, 0, 5}, // 超出范围的负数下标截断到0
+		{-100, 2, 5, 0, 3},
+		{0, 100, 5, 0, 5},
+		{5, 10, 5, -1, -1},
+		{3, 1, 5, -1, -1},
+		{0, -100, 5, -1, -1},
+		{-100, -1, 0, -1, -1},
+	}
+	for _, tt := range tests {
+		begin, stop := ConvertRange(tt.start, tt.end, tt.size)
+		if begin != tt.begin || stop != tt.stop {
+			t.Errorf("ConvertRange(%d, %d, %d) = (%d, %d), want (%d, %d)",
+				tt.start, tt.end, tt.size, begin, stop, tt.begin, tt.stop)
+		}
+	}
+}
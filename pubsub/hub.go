@@ -0,0 +1,163 @@
+package pubsub
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/lib/wildcard"
+	"sync"
+)
+
+// Hub 记录频道和模式的订阅者，所有的db共享
+type Hub struct {
+	mu            sync.RWMutex
+	channels      map[string]map[resp.Connection]struct{} // 频道 -> 订阅者
+	patterns      map[string]*patternSubscribers          // 模式 -> 订阅者
+	shardChannels map[string]map[resp.Connection]struct{} // 分片频道 -> 订阅者，和普通频道互不影响，模式不会匹配分片频道
+}
+
+type patternSubscribers struct {
+	pattern     *wildcard.Pattern
+	subscribers map[resp.Connection]struct{}
+}
+
+// MakeHub 创建Hub
+func MakeHub() *Hub {
+	return &Hub{
+		channels:      make(map[string]map[resp.Connection]struct{}),
+		patterns:      make(map[string]*patternSubscribers),
+		shardChannels: make(map[string]map[resp.Connection]struct{}),
+	}
+}
+
+// subscribe 订阅频道，shard为true时订阅分片频道
+func (hub *Hub) subscribe(c resp.Connection, channel string, shard bool) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	channels := hub.channelTable(shard)
+	subscribers, ok := channels[channel]
+	if !ok {
+		subscribers = make(map[resp.Connection]struct{})
+		channels[channel] = subscribers
+	}
+	subscribers[c] = struct{}{}
+}
+
+// unsubscribe 取消订阅频道，没有订阅者的频道直接删除
+func (hub *Hub) unsubscribe(c resp.Connection, channel string, shard bool) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	channels := hub.channelTable(shard)
+	subscribers, ok := channels[channel]
+	if !ok {
+		return
+	}
+	delete(subscribers, c)
+	if len(subscribers) == 0 {
+		delete(channels, channel)
+	}
+}
+
+// channelTable 调用方需要持有锁
+func (hub *Hub) channelTable(shard bool) map[string]map[resp.Connection]struct{} {
+	if shard {
+		return hub.shardChannels
+	}
+	return hub.channels
+}
+
+// psubscribe 订阅模式，模式不合法时返回错误
+func (hub *Hub) psubscribe(c resp.Connection, pattern string) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	subs, ok := hub.patterns[pattern]
+	if !ok {
+		compiled, err := wildcard.CompilePattern(pattern)
+		if err != nil {
+			return err
+		}
+		subs = &patternSubscribers{
+			pattern:     compiled,
+			subscribers: make(map[resp.Connection]struct{}),
+		}
+		hub.patterns[pattern] = subs
+	}
+	subs.subscribers[c] = struct{}{}
+	return nil
+}
+
+// punsubscribe 取消订阅模式
+func (hub *Hub) punsubscribe(c resp.Connection, pattern string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	subs, ok := hub.patterns[pattern]
+	if !ok {
+		return
+	}
+	delete(subs.subscribers, c)
+	if len(subs.subscribers) == 0 {
+		delete(hub.patterns, pattern)
+	}
+}
+
+// message 待发送的消息，pattern为空表示通过频道订阅
+type message struct {
+	conn    resp.Connection
+	pattern string
+}
+
+// receivers 返回频道的订阅者，以及通过模式订阅了该频道的订阅者
+func (hub *Hub) receivers(channel string) []message {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	var result []message
+	for c := range hub.channels[channel] {
+		result = append(result, message{conn: c})
+	}
+	for pattern, subs := range hub.patterns {
+		if !subs.pattern.IsMatch(channel) {
+			continue
+		}
+		for c := range subs.subscribers {
+			result = append(result, message{conn: c, pattern: pattern})
+		}
+	}
+	return result
+}
+
+// shardReceivers 返回分片频道的订阅者
+func (hub *Hub) shardReceivers(channel string) []resp.Connection {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	var result []resp.Connection
+	for c := range hub.shardChannels[channel] {
+		result = append(result, c)
+	}
+	return result
+}
+
+// activeChannels 至少有一个订阅者的频道
+func (hub *Hub) activeChannels(pattern *wildcard.Pattern, shard bool) []string {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	channels := hub.channelTable(shard)
+	result := make([]string, 0, len(channels))
+	for channel := range channels {
+		if pattern == nil || pattern.IsMatch(channel) {
+			result = append(result, channel)
+		}
+	}
+	return result
+}
+
+// numSub 频道的订阅者个数
+func (hub *Hub) numSub(channel string, shard bool) int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return len(hub.channelTable(shard)[channel])
+}
+
+// numPat 被订阅的模式的个数
+func (hub *Hub) numPat() int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return len(hub.patterns)
+}
@@ -0,0 +1,257 @@
+package pubsub
+
+import (
+	"go_redis/interface/resp"
+	"go_redis/lib/wildcard"
+	"go_redis/resp/reply"
+	"sort"
+	"strings"
+)
+
+// 发布订阅 SUBSCRIBE/UNSUBSCRIBE/PSUBSCRIBE/PUNSUBSCRIBE/PUBLISH/PUBSUB
+// 以及分片频道 SSUBSCRIBE/SUNSUBSCRIBE/SPUBLISH(集群模式下只在频道所属的节点上订阅和发布)
+// 订阅类的指令每个频道回复一条消息，直接写入连接，指令本身返回空回复；
+// PUBLISH 在发布者的协程中把消息写入订阅者的连接(Connection.Write 并发安全)
+
+var (
+	subscribeBytes    = []byte("subscribe")
+	unsubscribeBytes  = []byte("unsubscribe")
+	psubscribeBytes   = []byte("psubscribe")
+	punsubscribeBytes = []byte("punsubscribe")
+	ssubscribeBytes   = []byte("ssubscribe")
+	sunsubscribeBytes = []byte("sunsubscribe")
+	messageBytes      = []byte("message")
+	pmessageBytes     = []byte("pmessage")
+	smessageBytes     = []byte("smessage")
+)
+
+// makeSubsReply [kind, channel, 订阅的个数]，RESP3的客户端收到的是推送消息
+func makeSubsReply(c resp.Connection, kind []byte, channel []byte, count int) []byte {
+	var channelReply resp.Reply = reply.MakeNullBulkReply()
+	if channel != nil {
+		channelReply = reply.MakeBulkReply(channel)
+	}
+	return reply.ToProtocolBytes(reply.MakePushReply([]resp.Reply{
+		reply.MakeBulkReply(kind),
+		channelReply,
+		reply.MakeIntReply(int64(count)),
+	}), c.GetProtocol())
+}
+
+// makeMessage 发布的消息，RESP2中为数组，RESP3中为推送消息
+func makeMessage(c resp.Connection, args ...[]byte) []byte {
+	replies := make([]resp.Reply, len(args))
+	for i, arg := range args {
+		replies[i] = reply.MakeBulkReply(arg)
+	}
+	return reply.ToProtocolBytes(reply.MakePushReply(replies), c.GetProtocol())
+}
+
+// Subscribe SUBSCRIBE channel [channel ...]
+func Subscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	for _, arg := range args {
+		channel := string(arg)
+		hub.subscribe(c, channel, false)
+		c.Subscribe(channel)
+		_ = c.Write(makeSubsReply(c, subscribeBytes, arg, c.SubsCount()))
+	}
+	return reply.MakeNoRply()
+}
+
+// UnSubscribe UNSUBSCRIBE [channel [channel ...]]   没有参数时取消订阅所有的频道
+func UnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	channels := toStrings(args)
+	if len(channels) == 0 {
+		channels = c.GetChannels()
+		sort.Strings(channels)
+		if len(channels) == 0 {
+			_ = c.Write(makeSubsReply(c, unsubscribeBytes, nil, c.SubsCount()))
+		}
+	}
+	for _, channel := range channels {
+		hub.unsubscribe(c, channel, false)
+		c.UnSubscribe(channel)
+		_ = c.Write(makeSubsReply(c, unsubscribeBytes, []byte(channel), c.SubsCount()))
+	}
+	return reply.MakeNoRply()
+}
+
+// PSubscribe PSUBSCRIBE pattern [pattern ...]
+func PSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	for _, arg := range args {
+		pattern := string(arg)
+		if err := hub.psubscribe(c, pattern); err != nil {
+			_ = c.Write(reply.MakeErrReply("ERR invalid pattern '" + pattern + "': " + err.Error()).ToBytes())
+			continue
+		}
+		c.PSubscribe(pattern)
+		_ = c.Write(makeSubsReply(c, psubscribeBytes, arg, c.SubsCount()))
+	}
+	return reply.MakeNoRply()
+}
+
+// PUnSubscribe PUNSUBSCRIBE [pattern [pattern ...]]   没有参数时取消订阅所有的模式
+func PUnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	patterns := toStrings(args)
+	if len(patterns) == 0 {
+		patterns = c.GetPatterns()
+		sort.Strings(patterns)
+		if len(patterns) == 0 {
+			_ = c.Write(makeSubsReply(c, punsubscribeBytes, nil, c.SubsCount()))
+		}
+	}
+	for _, pattern := range patterns {
+		hub.punsubscribe(c, pattern)
+		c.PUnSubscribe(pattern)
+		_ = c.Write(makeSubsReply(c, punsubscribeBytes, []byte(pattern), c.SubsCount()))
+	}
+	return reply.MakeNoRply()
+}
+
+// SSubscribe SSUBSCRIBE shardchannel [shardchannel ...]   回复中为订阅的分片频道的个数
+func SSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	for _, arg := range args {
+		channel := string(arg)
+		hub.subscribe(c, channel, true)
+		c.SSubscribe(channel)
+		_ = c.Write(makeSubsReply(c, ssubscribeBytes, arg, c.ShardSubsCount()))
+	}
+	return reply.MakeNoRply()
+}
+
+// SUnSubscribe SUNSUBSCRIBE [shardchannel [shardchannel ...]]   没有参数时取消订阅所有的分片频道
+func SUnSubscribe(hub *Hub, c resp.Connection, args [][]byte) resp.Reply {
+	channels := toStrings(args)
+	if len(channels) == 0 {
+		channels = c.GetShardChannels()
+		sort.Strings(channels)
+		if len(channels) == 0 {
+			_ = c.Write(makeSubsReply(c, sunsubscribeBytes, nil, c.ShardSubsCount()))
+		}
+	}
+	for _, channel := range channels {
+		hub.unsubscribe(c, channel, true)
+		c.SUnSubscribe(channel)
+		_ = c.Write(makeSubsReply(c, sunsubscribeBytes, []byte(channel), c.ShardSubsCount()))
+	}
+	return reply.MakeNoRply()
+}
+
+// UnsubscribeAll 客户端断开连接时取消所有的订阅
+func UnsubscribeAll(hub *Hub, c resp.Connection) {
+	for _, channel := range c.GetChannels() {
+		hub.unsubscribe(c, channel, false)
+		c.UnSubscribe(channel)
+	}
+	for _, pattern := range c.GetPatterns() {
+		hub.punsubscribe(c, pattern)
+		c.PUnSubscribe(pattern)
+	}
+	for _, channel := range c.GetShardChannels() {
+		hub.unsubscribe(c, channel, true)
+		c.SUnSubscribe(channel)
+	}
+}
+
+// Publish PUBLISH channel message   返回收到消息的订阅者个数
+func Publish(hub *Hub, args [][]byte) resp.Reply {
+	channel, msg := args[0], args[1]
+	receivers := hub.receivers(string(channel))
+	for _, receiver := range receivers {
+		var data []byte
+		if receiver.pattern == "" {
+			data = makeMessage(receiver.conn, messageBytes, channel, msg)
+		} else {
+			data = makeMessage(receiver.conn, pmessageBytes, []byte(receiver.pattern), channel, msg)
+		}
+		_ = receiver.conn.Write(data)
+	}
+	return reply.MakeIntReply(int64(len(receivers)))
+}
+
+// SPublish SPUBLISH shardchannel message   返回收到消息的订阅者个数
+func SPublish(hub *Hub, args [][]byte) resp.Reply {
+	channel, msg := args[0], args[1]
+	receivers := hub.shardReceivers(string(channel))
+	for _, c := range receivers {
+		_ = c.Write(makeMessage(c, smessageBytes, channel, msg))
+	}
+	return reply.MakeIntReply(int64(len(receivers)))
+}
+
+// PubSub PUBSUB CHANNELS [pattern] | NUMSUB [channel [channel ...]] | NUMPAT
+// | SHARDCHANNELS [pattern] | SHARDNUMSUB [shardchannel [shardchannel ...]]
+func PubSub(hub *Hub, args [][]byte) resp.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	shard := subCmd == "shardchannels" || subCmd == "shardnumsub"
+	switch subCmd {
+	case "channels", "shardchannels":
+		if len(args) > 2 {
+			return argNumErr(subCmd)
+		}
+		var pattern *wildcard.Pattern
+		if len(args) == 2 {
+			var err error
+			pattern, err = wildcard.CompilePattern(string(args[1]))
+			if err != nil {
+				return reply.MakeErrReply("ERR " + err.Error())
+			}
+		}
+		channels := hub.activeChannels(pattern, shard)
+		sort.Strings(channels)
+		result := make([][]byte, len(channels))
+		for i, channel := range channels {
+			result[i] = []byte(channel)
+		}
+		return reply.MakeMultiBulkReply(result)
+	case "numsub", "shardnumsub":
+		result := make([]resp.Reply, 0, 2*(len(args)-1))
+		for _, channel := range args[1:] {
+			result = append(result,
+				reply.MakeBulkReply(channel),
+				reply.MakeIntReply(int64(hub.numSub(string(channel), shard))))
+		}
+		return reply.MakeMultiRawReply(result)
+	case "numpat":
+		if len(args) != 1 {
+			return argNumErr(subCmd)
+		}
+		return reply.MakeIntReply(int64(hub.numPat()))
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try PUBSUB HELP.")
+}
+
+func argNumErr(subCmd string) resp.Reply {
+	return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + subCmd + "'. Try PUBSUB HELP.")
+}
+
+// CheckSubscriberMode 处于订阅模式的RESP2连接只能执行订阅相关的指令和PING，返回nil表示可以正常执行
+// RESP3的消息通过推送类型和普通回复区分，订阅之后仍然可以执行任意指令
+func CheckSubscriberMode(c resp.Connection, cmdName string, args [][]byte) resp.Reply {
+	if c.GetProtocol() >= 3 || (c.SubsCount() == 0 && c.ShardSubsCount() == 0) {
+		return nil
+	}
+	switch cmdName {
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ssubscribe", "sunsubscribe":
+		return nil
+	case "ping": // 订阅模式下的PING回复 [pong, message]
+		if len(args) > 2 {
+			return reply.MakeArgNumErrReply(cmdName)
+		}
+		msg := []byte("")
+		if len(args) == 2 {
+			msg = args[1]
+		}
+		return reply.MakeMultiBulkReply([][]byte{[]byte("pong"), msg})
+	}
+	return reply.MakeErrReply("ERR Can't execute '" + cmdName +
+		"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+}
+
+func toStrings(args [][]byte) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = string(arg)
+	}
+	return result
+}
@@ -9,6 +9,7 @@ import (
 	"go_redis/resp/reply"
 	"net"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -29,8 +30,9 @@ type Client struct {
 	ticker      *time.Ticker
 	addr        string
 
-	status  int32
-	working *sync.WaitGroup // its counter presents unfinished requests(pending and waiting)
+	status   int32
+	protocol int32           // protocol negotiated by HELLO, a new connection starts with RESP2
+	working  *sync.WaitGroup // its counter presents unfinished requests(pending and waiting)
 }
 
 // request is a message sends to redis server
@@ -60,6 +62,7 @@ func MakeClient(addr string) (*Client, error) {
 		pendingReqs: make(chan *request, chanSize),
 		waitingReqs: make(chan *request, chanSize),
 		working:     &sync.WaitGroup{},
+		protocol:    2,
 	}, nil
 }
 
@@ -108,6 +111,8 @@ func (client *Client) reconnect() {
 		return
 	}
 	client.conn = conn
+	// the new connection has not negotiated a protocol yet, callers must send HELLO again
+	atomic.StoreInt32(&client.protocol, 2)
 
 	close(client.waitingReqs)
 	for req := range client.waitingReqs {
@@ -155,6 +160,20 @@ func (client *Client) Send(args [][]byte) resp.Reply {
 	return req.reply
 }
 
+// Protocol returns the protocol version currently used by the connection
+func (client *Client) Protocol() int {
+	return int(atomic.LoadInt32(&client.protocol))
+}
+
+// SetProtocol switches the protocol version of the connection by HELLO
+func (client *Client) SetProtocol(protocol int) resp.Reply {
+	r := client.Send([][]byte{[]byte("HELLO"), []byte(strconv.Itoa(protocol))})
+	if !reply.IsErrReply(r) {
+		atomic.StoreInt32(&client.protocol, int32(protocol))
+	}
+	return r
+}
+
 func (client *Client) doHeartbeat() {
 	request := &request{
 		args:      [][]byte{[]byte("PING")},
@@ -209,7 +228,7 @@ func (client *Client) finishRequest(reply resp.Reply) {
 }
 
 func (client *Client) handleRead() {
-	ch := parser.ParseStream(client.conn)
+	ch := parser.ParseReplyStream(client.conn)
 	for payload := range ch {
 		if payload.Err != nil {
 			status := atomic.LoadInt32(&client.status)
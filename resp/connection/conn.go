@@ -4,22 +4,55 @@ import (
 	"go_redis/lib/sync/wait"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	nextID  uint64   // 客户端的ID从1开始递增，伪客户端的ID为0
+	clients sync.Map // ID -> *Connection，用于 CLIENT TRACKING 的 REDIRECT 查找客户端
+)
+
 // redis 协议对连接上的客户端的描述-->对连接的conn进行包装
 type Connection struct {
 	conn         net.Conn
 	waitingReply wait.Wait // 用于等待所有待发送的响应数据发送完成后再关闭连接
 	mu           sync.Mutex
 	selectedDB   int
+	id           uint64
+	protocol     int    // 协议版本 2/3，0 表示默认的RESP2
+	name         string // CLIENT SETNAME/HELLO SETNAME 设置的客户端名称
+
+	// 事务的状态
+	multiState bool
+	queue      [][][]byte        // MULTI之后排队等待执行的指令
+	txErrors   []error           // 排队时发现的错误，EXEC时直接放弃事务
+	watching   map[string]uint32 // WATCH的key -> 当时的版本号
+
+	// 订阅的频道和模式，发布消息的协程和客户端断开时的清理会并发访问
+	subsMu        sync.Mutex
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
 }
 
 // 对用户连接进行包装
 func NewConn(conn net.Conn) *Connection {
-	return &Connection{
+	c := &Connection{
 		conn: conn,
+		id:   atomic.AddUint64(&nextID, 1),
 	}
+	clients.Store(c.id, c)
+	return c
+}
+
+// GetConn 根据ID查找仍然连接着的客户端
+func GetConn(id uint64) (*Connection, bool) {
+	raw, ok := clients.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return raw.(*Connection), true
 }
 
 func (c *Connection) RemoteAddr() net.Addr { // 返回客户端的ip地址
@@ -27,6 +60,7 @@ func (c *Connection) RemoteAddr() net.Addr { // 返回客户端的ip地址
 }
 
 func (c *Connection) Close() error {
+	clients.Delete(c.id)
 	c.waitingReply.WaitWithTimeout(10 * time.Second) //关闭前先等待其他协程执行完毕，定时时长为10s，等待redis处理完对应用户的请求
 	c.conn.Close()
 	return nil
@@ -57,6 +91,179 @@ func (c *Connection) SelectDB(a int) {
 	c.selectedDB = a
 }
 
+// GetID 客户端的ID，CLIENT ID 的返回值
+func (c *Connection) GetID() uint64 {
+	return c.id
+}
+
+// GetProtocol 客户端使用的协议版本
+func (c *Connection) GetProtocol() int {
+	if c.protocol == 0 {
+		return 2
+	}
+	return c.protocol
+}
+
+// SetProtocol HELLO 协商之后切换协议版本
+func (c *Connection) SetProtocol(protocol int) {
+	c.protocol = protocol
+}
+
+// GetName 客户端的名称，没有设置时为空
+func (c *Connection) GetName() string {
+	return c.name
+}
+
+// SetName 设置客户端的名称
+func (c *Connection) SetName(name string) {
+	c.name = name
+}
+
+// InMultiState 是否处于事务中(MULTI之后，EXEC/DISCARD之前)
+func (c *Connection) InMultiState() bool {
+	return c.multiState
+}
+
+// SetMultiState 开始或者结束事务，结束时清空排队的指令和错误
+func (c *Connection) SetMultiState(state bool) {
+	if !state {
+		c.queue = nil
+		c.txErrors = nil
+	}
+	c.multiState = state
+}
+
+// GetQueuedCmdLine 返回排队等待执行的指令
+func (c *Connection) GetQueuedCmdLine() [][][]byte {
+	return c.queue
+}
+
+// EnqueueCmd 事务中的指令排队
+func (c *Connection) EnqueueCmd(cmdLine [][]byte) {
+	c.queue = append(c.queue, cmdLine)
+}
+
+// AddTxError 记录排队时发现的错误
+func (c *Connection) AddTxError(err error) {
+	c.txErrors = append(c.txErrors, err)
+}
+
+// GetTxErrors 返回排队时发现的错误
+func (c *Connection) GetTxErrors() []error {
+	return c.txErrors
+}
+
+// GetWatching 返回WATCH的key及其版本号，不存在时初始化
+func (c *Connection) GetWatching() map[string]uint32 {
+	if c.watching == nil {
+		c.watching = make(map[string]uint32)
+	}
+	return c.watching
+}
+
+// ClearWatching 取消所有的WATCH
+func (c *Connection) ClearWatching() {
+	c.watching = nil
+}
+
+// Subscribe 订阅频道
+func (c *Connection) Subscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.channels == nil {
+		c.channels = make(map[string]struct{})
+	}
+	c.channels[channel] = struct{}{}
+}
+
+// UnSubscribe 取消订阅频道
+func (c *Connection) UnSubscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	delete(c.channels, channel)
+}
+
+// PSubscribe 订阅模式
+func (c *Connection) PSubscribe(pattern string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.patterns == nil {
+		c.patterns = make(map[string]struct{})
+	}
+	c.patterns[pattern] = struct{}{}
+}
+
+// PUnSubscribe 取消订阅模式
+func (c *Connection) PUnSubscribe(pattern string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	delete(c.patterns, pattern)
+}
+
+// SSubscribe 订阅分片频道
+func (c *Connection) SSubscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if c.shardChannels == nil {
+		c.shardChannels = make(map[string]struct{})
+	}
+	c.shardChannels[channel] = struct{}{}
+}
+
+// SUnSubscribe 取消订阅分片频道
+func (c *Connection) SUnSubscribe(channel string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	delete(c.shardChannels, channel)
+}
+
+// SubsCount 订阅的频道和模式的个数
+func (c *Connection) SubsCount() int {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	return len(c.channels) + len(c.patterns)
+}
+
+// ShardSubsCount 订阅的分片频道的个数
+func (c *Connection) ShardSubsCount() int {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	return len(c.shardChannels)
+}
+
+// GetChannels 返回订阅的频道
+func (c *Connection) GetChannels() []string {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	channels := make([]string, 0, len(c.channels))
+	for channel := range c.channels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// GetPatterns 返回订阅的模式
+func (c *Connection) GetPatterns() []string {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	patterns := make([]string, 0, len(c.patterns))
+	for pattern := range c.patterns {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// GetShardChannels 返回订阅的分片频道
+func (c *Connection) GetShardChannels() []string {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	channels := make([]string, 0, len(c.shardChannels))
+	for channel := range c.shardChannels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
 /*
 Redis 服务器是多线程的
 在 Redis 服务器中，每个客户端的请求可能由多个 goroutine 处理：
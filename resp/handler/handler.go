@@ -17,7 +17,12 @@ import (
 	"sync"
 )
 
-var unknownErrBytes = []byte("-Err unknown\r\n")
+var (
+	unknownErrBytes = []byte("-Err unknown\r\n")
+	notBulkErrReply = &reply.ProtocolErrReply{Msg: "expected an array of bulk strings"}
+)
+
+const payloadBufferSize = 1024 // 指令执行(阻塞)期间最多缓存的请求个数
 
 type RespHandler struct { // 存在并发的问题,业务数据结构
 	activeConn sync.Map //用来存储已经连接的客户的连接
@@ -55,13 +60,13 @@ func (r *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 	}
 	client := connection.NewConn(conn) // 包装好的用户
 	r.activeConn.Store(client, struct{}{})
-	ch := parser.ParseStream(conn) // 解析数据，resp协议
+	ch := r.watchClose(client, parser.ParseStream(conn)) // 解析数据，resp协议
 	// 获取ch中的数据  ->redis执行命令是单线程的
 	for payload := range ch {
 		// error  错误情况
 		if payload.Err != nil {
 			// 客户端关闭连接，或者网络连接关闭，那么就主动断开该客户端的连接
-			if payload.Err == io.EOF || payload.Err == io.ErrUnexpectedEOF || strings.Contains(payload.Err.Error(), "use of network connection") {
+			if isClosedErr(payload.Err) {
 				r.closeClient(client)
 				logger.Info("connection closed" + client.RemoteAddr().String())
 				return
@@ -82,20 +87,46 @@ func (r *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 		}
 
 		re, ok := payload.Data.(*reply.MultiBulkReply) // 判断是否解析的结果满足redis命令格式的要求 [][]byte 存储命令
-
-		if !ok { // 解析失败，直接跳过
-			logger.Error("require multi bulk reply")
+		if !ok {
+			switch payload.Data.(type) {
+			case *reply.EmptyMultiBulkReply, *reply.NullMultiBulkReply: // 和redis一样忽略空的请求
+			default: // 不是由字符串组成的数组，回复协议错误，不能让客户端一直等待回复
+				_ = client.Write(notBulkErrReply.ToBytes())
+			}
 			continue
 		}
 
 		// redis 业务执行数据
 		result := r.db.Exec(client, re.Args)
 		if result != nil {
-			client.Write(result.ToBytes()) // 将redis处理之后的结果返回给客户端
+			client.Write(reply.ToProtocolBytes(result, client.GetProtocol())) // 将redis处理之后的结果按照协商的协议版本返回给客户端
 		} else {
 			client.Write(unknownErrBytes) // 如果还是出错，那就只能是未知错误
 		}
 	}
+	// 解析器遇到无法恢复的协议错误(长度超出限制等)时结束，后续的数据无法解析，断开连接
+	r.closeClient(client)
+	logger.Info("connection closed" + client.RemoteAddr().String())
+}
+
+// isClosedErr 判断是否为客户端关闭连接，或者网络连接关闭
+func isClosedErr(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF || strings.Contains(err.Error(), "use of network connection")
+}
+
+// watchClose 转发解析的结果，在指令阻塞期间也能及时发现客户端断开连接，提前清理客户端的状态以结束阻塞中的指令
+func (r *RespHandler) watchClose(client *connection.Connection, ch <-chan *parser.Payload) <-chan *parser.Payload {
+	forward := make(chan *parser.Payload, payloadBufferSize)
+	go func() {
+		defer close(forward)
+		for payload := range ch {
+			if payload.Err != nil && isClosedErr(payload.Err) {
+				r.db.AfterClientClose(client)
+			}
+			forward <- payload
+		}
+	}()
+	return forward
 }
 
 func (r *RespHandler) Close() error { // 关闭整个redis
@@ -4,6 +4,7 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"go_redis/interface/resp"
 	"go_redis/lib/logger"
@@ -11,7 +12,16 @@ import (
 	"io"
 	"runtime/debug"
 	"strconv"
-	"strings"
+)
+
+// 解析的限制，长度和个数都由对端发送，不能直接按照它们一次性分配内存
+const (
+	maxBulkLen        = 512 * 1024 * 1024 // 字符串的最大长度，和redis的 proto-max-bulk-len 默认值一致
+	maxMultiBulkLen   = 1024 * 1024       // 请求中数组的最大元素个数，和redis一致
+	maxDepth          = 64                // 聚合类型的最大嵌套层数
+	maxInlineLen      = 64 * 1024         // 一行(类型、长度、简单字符串等)的最大长度，和redis的 PROTO_INLINE_MAX_SIZE 一致
+	bulkPreallocLen   = 64 * 1024         // 不超过该长度的字符串直接分配，更长的随着数据的到达增长
+	aggregatePrealloc = 1024              // 聚合类型预先分配的元素个数，更多的随着元素的到达增长
 )
 
 // 用户解析之后的数据结构
@@ -20,241 +30,220 @@ type Payload struct {
 	Err  error
 }
 
-// 解析器的解析指令的状态
-type readState struct {
-	readingMultiLine  bool     // 是否读取的是多行数据
-	expectedArgsCount int      //希望读取到的参数的数目
-	msgType           byte     //消息的类型
-	args              [][]byte //解析参数的具体内容
-	bulkLen           int64    //字符串的长度
-}
-
-func (s *readState) finished() bool { // 当前的是否解析完成。
-	return s.expectedArgsCount > 0 && len(s.args) == s.expectedArgsCount
+// streamReader 解析一个连接上的数据
+type streamReader struct {
+	reader          *bufio.Reader
+	maxAggregateLen int64 // 聚合类型的最大元素个数，小于0表示不限制
 }
 
 // 异步解析数据 调用redis对客户的命令进行解析   并发执行对于每个连接使用gorutine 进行解析
+// 除了RESP2的类型，还可以解析RESP3的 map(%) set(~) double(,) boolean(#) null(_) verbatim(=) bignumber(() push(>)
 func ParseStream(reader io.Reader) <-chan *Payload {
 	ch := make(chan *Payload)
-	go parse0(reader, ch)
+	go parse0(&streamReader{reader: bufio.NewReader(reader), maxAggregateLen: maxMultiBulkLen}, ch)
+	return ch
+}
+
+// ParseReplyStream 解析服务器的回复(resp/client 使用)，回复中的数组可以超过请求的元素个数限制
+func ParseReplyStream(reader io.Reader) <-chan *Payload {
+	ch := make(chan *Payload)
+	go parse0(&streamReader{reader: bufio.NewReader(reader), maxAggregateLen: -1}, ch)
 	return ch
 }
 
 // 读取用户传入的内容, 将取得的内容放入到chan中
-func parse0(reader io.Reader, ch chan<- *Payload) {
+// 发生io错误或者无法恢复的协议错误(长度超出限制等)时关闭通道，使用方应当断开连接
+func parse0(r *streamReader, ch chan<- *Payload) {
+	defer close(ch)
 	defer func() { // 防止发生pannic错误，导致系统崩溃
 		if err := recover(); err != nil {
 			logger.Error(string(debug.Stack()))
 		}
 	}()
 
-	bufReader := bufio.NewReader(reader) // 设置读取缓冲区，因为传入的数据都是多行数据（resp协议）
-	var state readState                  // 解析器的状态
-	var err error
-	var msg []byte
 	for {
-		var ioErr bool
-		msg, ioErr, err = readLine(bufReader, &state) // 不断地循环读取一行数据，并且进行数据校验
+		result, fatal, err := r.readReply(0) // 每次读取一个完整的回复(数组等聚合类型包含其中所有的元素)
 		if err != nil {
-			if ioErr { // 发生了io错误
-				ch <- &Payload{
-					Err: err,
-				}
-				close(ch)
-				return // 发生io错误，直接终止连接，关闭通道
-			}
-			// 就是协议错误，直接输出错误即可，继续接受用户的输入
 			ch <- &Payload{
 				Err: err,
 			}
-			state = readState{} // 初始化状态
+			if fatal { // 发生io错误，或者后续的数据已经无法正确解析，直接终止连接
+				return
+			}
+			// 就是协议错误，直接输出错误即可，继续接受用户的输入
 			continue
 		}
-		// 正常情况 ->数据校验无误
-		// 判断当前是否为多行解析模式
-		if !state.readingMultiLine { // *3\r\n ------
-			if msg[0] == '*' {
-				err := parseMultiBulkHeader(msg, &state)
-				if err != nil {
-					ch <- &Payload{
-						Err: err,
-					}
-					state = readState{}
-					continue
-				}
-				if state.expectedArgsCount == 0 {
-					ch <- &Payload{
-						Data: &reply.EmptyMultiBulkReply{},
-					}
-					state = readState{}
-					continue
-				}
-			} else if msg[0] == '$' { //$4\r\nPONG\r\n   这个也是多行模式，2行模式
-				err := parseBulkHeader(msg, &state)
-				if err != nil {
-					ch <- &Payload{
-						Err: err,
-					}
-					state = readState{}
-					continue
-				}
-				// 特殊情况  $-1\r\n     空指令
-				if state.bulkLen == -1 {
-					ch <- &Payload{
-						Data: &reply.NullBulkReply{},
-					}
-					state = readState{}
-					continue
-				}
-			} else { // + - : 这三种情况   单行模式
-				result, err := parsrseSingLineReply(msg)
-				ch <- &Payload{
-					Data: result,
-					Err:  err,
-				}
-				state = readState{}
-				continue
-			}
-
-		} else { // 多行模式下
-			err := readBody(msg, &state)
-			if err != nil {
-				ch <- &Payload{
-					Err: err,
-				}
-				state = readState{}
-				continue
-			}
-			if state.finished() { // 判断读取是否完成
-				// 已经完成了，那么就是返回结果了
-				var result resp.Reply
-				if state.msgType == '*' {
-					result = reply.MakeMultiBulkReply(state.args)
-				} else if state.msgType == '$' { // 同样还是多行，将取到的[][]byte 命令封装
-					//result = reply.MakeBulkReply(state.args)   // 最初的代码
-					result = reply.MakeMultiBulkReply(state.args)
-				}
-				ch <- &Payload{
-					Data: result,
-					Err:  err,
-				}
-				state = readState{} // 读取完数据之后，重置状态
-			}
+		ch <- &Payload{
+			Data: result,
 		}
 	}
 }
 
-// *3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n
-// 读一行数据   -->返回的是 数据，io错误，具体的错误
-func readLine(bufReader *bufio.Reader, state *readState) ([]byte, bool, error) { //\r\n换行符
-	var msg []byte
-	var err error
-	if state.bulkLen == 0 { // 情况1：没有字节数，那么直接按照\r\n进行分割，初始状态   *3\r\n    $3\r\nset\r\n$3\r\nkey\r\n$5\r\nvalue\r\n
-		msg, err = bufReader.ReadBytes('\n')
+// readReply 读取一个完整的回复  -->返回的是 数据，是否为无法恢复的错误，具体的错误
+// *3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n   数组、map等聚合类型递归读取其中的元素，depth为嵌套的层数
+func (r *streamReader) readReply(depth int) (resp.Reply, bool, error) {
+	msg, fatal, err := r.readLine()
+	if err != nil {
+		return nil, fatal, err
+	}
+	str := string(msg[1 : len(msg)-2]) // 去掉类型和末尾的\r\n
+	switch msg[0] {
+	case '+':
+		return reply.MakeStatusReply(str), false, nil
+	case '-':
+		return reply.MakeErrReply(str), false, nil
+	case ':':
+		val, err := strconv.ParseInt(str, 10, 64) // 将字符串转化为对应进制的位数的int
 		if err != nil {
-			return nil, true, err
+			return nil, false, protocolError(msg) // 返回协议错误
 		}
-		if len(msg) == 0 || msg[len(msg)-2] != '\r' { // 为空，并且倒数第二个不是'\r'
-			return nil, false, errors.New("prorocol error: " + string(msg))
+		return reply.MakeIntReply(val), false, nil
+	case '$': // $4\r\nPONG\r\n   $-1\r\n 空值
+		body, isNull, fatal, err := r.readBulk(msg)
+		if err != nil {
+			return nil, fatal, err
 		}
-
-	} else { // 情况2 已经读到了$数字， 则按照数字指定的字节数读取，其实就是对字节数进行校验
-		msg = make([]byte, state.bulkLen+2)
-		_, err := io.ReadFull(bufReader, msg)
+		if isNull {
+			return reply.MakeNullBulkReply(), false, nil
+		}
+		return reply.MakeBulkReply(body), false, nil
+	case '=': // =15\r\ntxt:Some string\r\n
+		body, isNull, fatal, err := r.readBulk(msg)
 		if err != nil {
-			return nil, true, err
+			return nil, fatal, err
 		}
-		if len(msg) == 0 || msg[len(msg)-1] != '\n' || msg[len(msg)-2] != '\r' {
-			return nil, false, errors.New("prorocol error: " + string(msg))
+		if isNull || len(body) < 4 || body[3] != ':' {
+			return nil, false, protocolError(msg)
 		}
-		state.bulkLen = 0 // 读取完毕之后，重置为0
-
+		return reply.MakeVerbatimReply(string(body[:3]), body[4:]), false, nil
+	case ',': // ,1.23\r\n  ,inf\r\n
+		val, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, false, protocolError(msg)
+		}
+		return reply.MakeDoubleReply(val), false, nil
+	case '#': // #t\r\n  #f\r\n
+		if str != "t" && str != "f" {
+			return nil, false, protocolError(msg)
+		}
+		return reply.MakeBooleanReply(str == "t"), false, nil
+	case '_':
+		return reply.MakeNullBulkReply(), false, nil
+	case '(': // 大整数超出了int64的范围，按照字符串返回
+		return reply.MakeBulkReply([]byte(str)), false, nil
+	case '*', '~', '>', '%':
+		if depth >= maxDepth {
+			return nil, true, errors.New("protocol error: too many nested aggregates")
+		}
+		return r.readAggregate(msg, depth)
 	}
-	return msg, false, nil // 都没有出现上述错误，则返回正确的结果。
+	return nil, false, protocolError(msg)
 }
 
-// readliine 只是读取一行的数据，parseMultiBulkHeader处理读取数据的消息-->多行数据
-// *3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n     *开头  的头部数据 并且设置解析器的状态
-func parseMultiBulkHeader(msg []byte, state *readState) error {
-	var err error
-	var expectedLine uint64
-	expectedLine, err = strconv.ParseUint(string(msg[1:len(msg)-2]), 10, 64)
-	if err != nil {
-		return errors.New("protocol error: " + string(msg))
+// readAggregate 读取聚合类型   *数组  ~集合  >推送  %map(个数为键值对的个数)
+func (r *streamReader) readAggregate(msg []byte, depth int) (resp.Reply, bool, error) {
+	size, err := strconv.ParseInt(string(msg[1:len(msg)-2]), 10, 64)
+	if err != nil || size < -1 {
+		return nil, false, protocolError(msg)
 	}
-	if expectedLine == 0 { // 为0
-		state.expectedArgsCount = 0
-		return nil
-	} else if expectedLine > 0 { // 读取到多行数据大于0
-		state.msgType = msg[0]
-		state.readingMultiLine = true
-		state.expectedArgsCount = int(expectedLine)
-		state.args = make([][]byte, 0, expectedLine) // 数据大小
-		return nil
-	} else { // 小于0 的情况，显然是不对的
-		return errors.New("protocol error: " + string(msg))
+	if size == -1 { // *-1\r\n 空数组
+		if msg[0] != '*' {
+			return nil, false, protocolError(msg)
+		}
+		return reply.MakeNullMultiBulkReply(), false, nil
 	}
-}
-
-// $4\r\nPING\r\n    $单行数据 解析头部
-func parseBulkHeader(msg []byte, state *readState) error {
-	var err error
-	state.bulkLen, err = strconv.ParseInt(string(msg[1:len(msg)-2]), 10, 64)
-	if err != nil {
-		return errors.New("protocol error: " + string(msg))
+	count := size
+	if msg[0] == '%' {
+		count = 2 * size
 	}
-	if state.bulkLen == -1 {
-		return nil
-	} else if state.bulkLen > 0 {
-		state.msgType = msg[0]
-		state.readingMultiLine = true
-		state.expectedArgsCount = 1
-		state.args = make([][]byte, 0, 1)
-		return nil
-	} else {
-		return errors.New("protocol error: " + string(msg))
+	if r.maxAggregateLen >= 0 && count > r.maxAggregateLen {
+		return nil, true, errors.New("protocol error: invalid multibulk length")
+	}
+	// 元素个数由对端发送，先分配一部分，其余的随着元素的到达增长
+	replies := make([]resp.Reply, 0, min(count, aggregatePrealloc))
+	for i := int64(0); i < count; i++ {
+		element, _, err := r.readReply(depth + 1)
+		if err != nil { // 剩余的元素已经无法和聚合类型对应，不能继续解析
+			return nil, true, err
+		}
+		replies = append(replies, element)
 	}
-}
-
-// TODO:这里在解析数据时会存在类型转化的问题
-// +OK\r\n  -err\r\n  :5\r\n
-func parsrseSingLineReply(msg []byte) (resp.Reply, error) { // 解析用户传入的单行数据
-	str := strings.TrimSuffix(string(msg), "\r\n") // 去掉末尾的\r\n
-	var result resp.Reply                          // 用户传入的数据和回复的数据结构其实是一模一样的
 	switch msg[0] {
-	case '+':
-		result = reply.MakeStatusReply(str[1:])
-	case '-':
-		result = reply.MakeErrReply(str[1:])
-	case ':':
-		val, err := strconv.ParseInt(string(str[1:]), 10, 64) // 将字符串转化为对应进制的位数的int
-		if err != nil {
-			return nil, errors.New("protocol error: " + string(msg)) // 返回协议错误
+	case '~':
+		return reply.MakeSetReply(replies), false, nil
+	case '>':
+		return reply.MakePushReply(replies), false, nil
+	case '%':
+		return reply.MakeMapReply(replies), false, nil
+	}
+	if size == 0 {
+		return reply.MakeEmptyMultiBulkReply(), false, nil
+	}
+	// 元素都是字符串时(客户端发送的指令都是这种格式)返回 MultiBulkReply，空值对应nil
+	args := make([][]byte, len(replies))
+	for i, element := range replies {
+		switch element := element.(type) {
+		case *reply.BulkReply:
+			args[i] = element.Arg
+		case *reply.NullBulkReply:
+			args[i] = nil
+		default:
+			return reply.MakeMultiRawReply(replies), false, nil
 		}
-		result = reply.MakeIntReply(val)
 	}
-	return result, nil
+	return reply.MakeMultiBulkReply(args), false, nil
 }
 
-// 读取数据部分   在用户解析完头部数据之后， 解析器的状态已经设置好了
-// (*3\r\n)  $3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n
-//
-// （$4\r\n)   PING\r\n
-func readBody(msg []byte, state *readState) error {
-	//读取单行的body  , 去除后面\r\n
-	line := msg[0 : len(msg)-2]
-	var err error
-	if line[0] == '$' { //1. $数字的情况
-		state.bulkLen, err = strconv.ParseInt(string(line[1:]), 10, 64) // 转化为数字， 设置当前的数据的长度
-		if err != nil {
-			return errors.New("protocol error: " + string(msg))
+// readBulk 读取$N之后的字符串内容，按照字节数读取，内容中可以包含\r\n
+func (r *streamReader) readBulk(header []byte) ([]byte, bool, bool, error) {
+	bulkLen, err := strconv.ParseInt(string(header[1:len(header)-2]), 10, 64)
+	if err != nil || bulkLen < -1 {
+		return nil, false, false, protocolError(header)
+	}
+	if bulkLen == -1 {
+		return nil, true, false, nil
+	}
+	if bulkLen > maxBulkLen {
+		return nil, false, true, errors.New("protocol error: invalid bulk length")
+	}
+	var buf bytes.Buffer
+	if bulkLen <= bulkPreallocLen {
+		buf.Grow(int(bulkLen) + 2)
+	}
+	if _, err := io.CopyN(&buf, r.reader, bulkLen+2); err != nil {
+		return nil, false, true, err
+	}
+	body := buf.Bytes()
+	if body[len(body)-2] != '\r' || body[len(body)-1] != '\n' {
+		return nil, false, false, protocolError(body)
+	}
+	return body[:bulkLen], false, false, nil
+}
+
+// 读一行数据，以\r\n结尾   -->返回的是 数据，io错误，具体的错误
+// 一行的长度由对端决定，超过 maxInlineLen 时不再继续读取，直接终止连接
+func (r *streamReader) readLine() ([]byte, bool, error) {
+	var msg []byte
+	for {
+		line, err := r.reader.ReadSlice('\n') // 返回的切片在下次读取时会被覆盖，需要拷贝
+		if len(msg)+len(line) > maxInlineLen {
+			return nil, true, errors.New("protocol error: too big inline request")
+		}
+		msg = append(msg, line...)
+		if err == nil {
+			break
 		}
-		if state.bulkLen <= 0 { // $0\r\n 的场景
-			state.args = append(state.args, []byte{})
-			state.bulkLen = 0
+		if err != bufio.ErrBufferFull {
+			return nil, true, err
 		}
-	} else { // 正常的数据部分(具体的内容)  -->直接合并
-		state.args = append(state.args, line)
 	}
-	return nil
+	if len(msg) < 3 || msg[len(msg)-2] != '\r' { // 至少包含类型和\r\n，并且倒数第二个是'\r'
+		return nil, false, protocolError(msg)
+	}
+	return msg, false, nil
+}
+
+// protocolError 错误信息会作为错误回复发送给客户端，不能包含原始的\r\n
+func protocolError(msg []byte) error {
+	return errors.New("protocol error: " + strconv.Quote(string(msg)))
 }
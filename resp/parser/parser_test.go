@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"bytes"
+	"go_redis/resp/reply"
+	"io"
+	"strings"
+	"testing"
+)
+
+// parseAll 解析全部的数据，直到通道关闭
+func parseAll(t *testing.T, data string) []*Payload {
+	t.Helper()
+	var result []*Payload
+	for payload := range ParseStream(strings.NewReader(data)) {
+		result = append(result, payload)
+	}
+	return result
+}
+
+func TestParseFrames(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // 按照RESP3重新编码之后的结果
+	}{
+		{"+OK\r\n", "+OK\r\n"},
+		{"-ERR unknown\r\n", "-ERR unknown\r\n"},
+		{":-42\r\n", ":-42\r\n"},
+		{"$5\r\nhello\r\n", "$5\r\nhello\r\n"},
+		{"$4\r\na\r\nb\r\n", "$4\r\na\r\nb\r\n"}, // 字符串中可以包含\r\n
+		{"$0\r\n\r\n", "$0\r\n\r\n"},
+		{"$-1\r\n", "_\r\n"},
+		{"_\r\n", "_\r\n"},
+		{",1.5\r\n", ",1.5\r\n"},
+		{",-inf\r\n", ",-inf\r\n"},
+		{"#t\r\n", "#t\r\n"},
+		{"#f\r\n", "#f\r\n"},
+		{"=15\r\ntxt:Some string\r\n", "=15\r\ntxt:Some string\r\n"},
+		{"(3492890328409238509324850943850943825024385\r\n", "$43\r\n3492890328409238509324850943850943825024385\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n"},
+		{"*2\r\n$1\r\na\r\n$-1\r\n", "*2\r\n$1\r\na\r\n_\r\n"},
+		{"*0\r\n", "*0\r\n"},
+		{"*-1\r\n", "_\r\n"},
+		{"*2\r\n:1\r\n*1\r\n+OK\r\n", "*2\r\n:1\r\n*1\r\n+OK\r\n"},
+		{"~2\r\n$1\r\na\r\n:1\r\n", "~2\r\n$1\r\na\r\n:1\r\n"},
+		{"%1\r\n$1\r\nk\r\n,2\r\n", "%1\r\n$1\r\nk\r\n,2\r\n"},
+		{">2\r\n$7\r\nmessage\r\n$2\r\nhi\r\n", ">2\r\n$7\r\nmessage\r\n$2\r\nhi\r\n"},
+	}
+	for _, tt := range tests {
+		payloads := parseAll(t, tt.input)
+		if len(payloads) != 2 || payloads[0].Err != nil || payloads[1].Err != io.EOF {
+			t.Errorf("parse %q: unexpected payloads %v", tt.input, payloads)
+			continue
+		}
+		if got := string(reply.ToProtocolBytes(payloads[0].Data, 3)); got != tt.want {
+			t.Errorf("parse %q: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	payloads := parseAll(t, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$-1\r\n*1\r\n$4\r\nPING\r\n")
+	if len(payloads) != 3 {
+		t.Fatalf("expected 2 commands and EOF, got %d payloads", len(payloads))
+	}
+	set, ok := payloads[0].Data.(*reply.MultiBulkReply)
+	if !ok || len(set.Args) != 3 || string(set.Args[0]) != "SET" || set.Args[2] != nil {
+		t.Errorf("unexpected command: %#v", payloads[0].Data)
+	}
+	ping, ok := payloads[1].Data.(*reply.MultiBulkReply)
+	if !ok || len(ping.Args) != 1 || string(ping.Args[0]) != "PING" {
+		t.Errorf("unexpected command: %#v", payloads[1].Data)
+	}
+}
+
+// 可以恢复的协议错误返回错误之后继续解析后面的数据
+func TestParseMalformed(t *testing.T) {
+	inputs := []string{
+		":abc\r\n",
+		",1.x\r\n",
+		"#x\r\n",
+		"=3\r\ntxt\r\n", // 缺少格式
+		"$-2\r\n",
+		"*-2\r\n",
+		"%-1\r\n",
+		"?what\r\n",
+		"+OK\n", // 不是以\r\n结尾
+		"\r\n",
+		"$3\r\nabcde\r\n",
+	}
+	for _, input := range inputs {
+		payloads := parseAll(t, input+"+OK\r\n")
+		if len(payloads) < 2 || payloads[0].Err == nil {
+			t.Errorf("parse %q: expected protocol error, got %v", input, payloads)
+			continue
+		}
+		last := payloads[len(payloads)-2]
+		if last.Err != nil || string(last.Data.ToBytes()) != "+OK\r\n" {
+			t.Errorf("parse %q: expected to continue after error, got %v", input, payloads)
+		}
+	}
+}
+
+// 超出限制的长度和嵌套层数无法恢复，返回错误之后结束解析，不能按照对端发送的长度分配内存
+func TestParseLimits(t *testing.T) {
+	inputs := []string{
+		"*4000000000\r\n",
+		"%600000\r\n",
+		"$4000000000\r\n",
+		"=4000000000\r\n",
+		"*1048577\r\n",
+		strings.Repeat("*1\r\n", maxDepth+1) + "$1\r\na\r\n",
+		"+" + strings.Repeat("a", maxInlineLen) + "\r\n",
+		"$" + strings.Repeat("1", maxInlineLen), // 没有换行也不能无限读取
+		// 聚合类型中的元素出错之后，剩余的元素无法正确解析
+		"*2\r\n:x\r\n$1\r\na\r\n",
+		"*2\r\n$3\r\nabcde\r\n$1\r\nb\r\n",
+	}
+	for _, input := range inputs {
+		payloads := parseAll(t, input+"+OK\r\n")
+		if len(payloads) != 1 || payloads[0].Err == nil || payloads[0].Err == io.EOF {
+			t.Errorf("parse %.20q: expected fatal protocol error, got %v", input, payloads)
+		}
+	}
+
+	// 未超出限制的嵌套可以正常解析
+	nested := strings.Repeat("*1\r\n", maxDepth) + "$1\r\na\r\n"
+	payloads := parseAll(t, nested)
+	if len(payloads) != 2 || payloads[0].Err != nil {
+		t.Errorf("parse nested: unexpected payloads %v", payloads)
+	}
+
+	// 超过缓冲区大小但未超出限制的行可以正常解析
+	long := strings.Repeat("a", maxInlineLen-3)
+	payloads = parseAll(t, "+"+long+"\r\n")
+	if len(payloads) != 2 || payloads[0].Err != nil || string(payloads[0].Data.ToBytes()) != "+"+long+"\r\n" {
+		t.Errorf("parse long line: unexpected payloads %.50v", payloads)
+	}
+
+	// 回复中的数组不受请求的元素个数限制
+	var buf bytes.Buffer
+	buf.WriteString("*1048577\r\n")
+	for i := 0; i < maxMultiBulkLen+1; i++ {
+		buf.WriteString(":1\r\n")
+	}
+	ch := ParseReplyStream(&buf)
+	first := <-ch
+	if first.Err != nil {
+		t.Fatalf("parse large reply: %v", first.Err)
+	}
+	if n := len(first.Data.(*reply.MultiRawReply).Replies); n != maxMultiBulkLen+1 {
+		t.Errorf("parse large reply: got %d elements", n)
+	}
+}
+
+// 声明的长度超过实际发送的数据时，等待数据到达直到连接关闭
+func TestParseTruncated(t *testing.T) {
+	for _, input := range []string{"$100\r\nabc", "*3\r\n$1\r\na\r\n"} {
+		payloads := parseAll(t, input)
+		if len(payloads) != 1 || payloads[0].Err == nil {
+			t.Errorf("parse %q: expected io error, got %v", input, payloads)
+		}
+	}
+}
@@ -43,6 +43,19 @@ func MakeNullBulkReply() *NullBulkReply {
 	return &NullBulkReply{}
 }
 
+// ------------NULL数组---------------------
+type NullMultiBulkReply struct{}
+
+var nullMultiBulkBytes = []byte("*-1\r\n")
+
+func (r NullMultiBulkReply) ToBytes() []byte {
+	return nullMultiBulkBytes
+}
+
+func MakeNullMultiBulkReply() *NullMultiBulkReply {
+	return &NullMultiBulkReply{}
+}
+
 // --------------------空字符串----------------
 type EmptyMultiBulkReply struct{}
 
@@ -68,3 +81,18 @@ func (r NoReply) ToBytes() []byte {
 func MakeNoRply() *NoReply {
 	return &NoReply{}
 }
+
+// ----------------事务中排队的指令------------------
+type QueuedReply struct{}
+
+var queuedBytes = []byte("+QUEUED\r\n")
+
+func (r QueuedReply) ToBytes() []byte {
+	return queuedBytes
+}
+
+var theQueuedReply = new(QueuedReply)
+
+func MakeQueuedReply() *QueuedReply {
+	return theQueuedReply
+}
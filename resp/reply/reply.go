@@ -50,6 +50,37 @@ func MakeMultiBulkReply(args [][]byte) *MultiBulkReply {
 	return &MultiBulkReply{Args: args}
 }
 
+// ---------多个回复组成的数组，元素可以是任意类型的回复(可以嵌套)---------------
+type MultiRawReply struct {
+	Replies []resp.Reply
+}
+
+func (r *MultiRawReply) ToBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(len(r.Replies)) + CRLF)
+	for _, re := range r.Replies {
+		buf.Write(re.ToBytes())
+	}
+	return buf.Bytes()
+}
+
+func MakeMultiRawReply(replies []resp.Reply) *MultiRawReply {
+	return &MultiRawReply{Replies: replies}
+}
+
+// ---------RESP3 推送消息，服务器主动发送给客户端(例如客户端缓存的失效消息)，RESP2中降级为数组---------------
+type PushReply struct {
+	Replies []resp.Reply
+}
+
+func (r *PushReply) ToBytes() []byte {
+	return writeAggregate("*", len(r.Replies), r.Replies, 2)
+}
+
+func MakePushReply(replies []resp.Reply) *PushReply {
+	return &PushReply{Replies: replies}
+}
+
 // ------------状态回复-----------
 type StatusReply struct {
 	Status string
@@ -105,5 +136,6 @@ func (r *StandardErrReply) Error() string {
 
 // 判断当前的回复是否为错误的回复
 func IsErrReply(reply resp.Reply) bool {
-	return reply.ToBytes()[0] == '-'
+	_, ok := reply.(ErrorReply) // 不需要序列化整个回复
+	return ok
 }
@@ -0,0 +1,228 @@
+package reply
+
+import (
+	"bytes"
+	"go_redis/interface/resp"
+	"math"
+	"strconv"
+)
+
+// RESP3 的回复类型，客户端通过 HELLO 3 协商之后使用
+// 这些回复的 ToBytes 返回降级之后的RESP2编码，ToRESP3Bytes 返回RESP3的编码：
+// map->扁平的数组  pair array->扁平的数组  set->数组  double->字符串  boolean->整数1/0  verbatim->字符串  push->数组  null->$-1/*-1
+
+// RESP3Reply 在RESP3中编码不同的回复
+type RESP3Reply interface {
+	ToRESP3Bytes() []byte
+}
+
+// ToProtocolBytes 按照客户端协商的协议版本编码回复
+func ToProtocolBytes(r resp.Reply, protocol int) []byte {
+	if protocol >= 3 {
+		if r3, ok := r.(RESP3Reply); ok {
+			return r3.ToRESP3Bytes()
+		}
+	}
+	return r.ToBytes()
+}
+
+var resp3NullBytes = []byte("_\r\n")
+
+// writeAggregate 聚合类型的头部和元素，元素按照同样的协议版本编码
+func writeAggregate(prefix string, size int, replies []resp.Reply, protocol int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(prefix + strconv.Itoa(size) + CRLF)
+	for _, re := range replies {
+		buf.Write(ToProtocolBytes(re, protocol))
+	}
+	return buf.Bytes()
+}
+
+func (r *BulkReply) ToRESP3Bytes() []byte {
+	if r.Arg == nil {
+		return resp3NullBytes
+	}
+	return r.ToBytes()
+}
+
+func (r *MultiBulkReply) ToRESP3Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(len(r.Args)) + CRLF)
+	for _, arg := range r.Args {
+		if arg == nil {
+			buf.Write(resp3NullBytes)
+		} else {
+			buf.WriteString("$" + strconv.Itoa(len(arg)) + CRLF + string(arg) + CRLF)
+		}
+	}
+	return buf.Bytes()
+}
+
+func (r *MultiRawReply) ToRESP3Bytes() []byte {
+	return writeAggregate("*", len(r.Replies), r.Replies, 3)
+}
+
+func (r *PushReply) ToRESP3Bytes() []byte {
+	return writeAggregate(">", len(r.Replies), r.Replies, 3)
+}
+
+func (r NullBulkReply) ToRESP3Bytes() []byte {
+	return resp3NullBytes
+}
+
+func (r NullMultiBulkReply) ToRESP3Bytes() []byte {
+	return resp3NullBytes
+}
+
+// ------------map，Pairs 中依次为 key1 value1 key2 value2 ...------------
+type MapReply struct {
+	Pairs []resp.Reply
+}
+
+func (r *MapReply) ToBytes() []byte {
+	return writeAggregate("*", len(r.Pairs), r.Pairs, 2)
+}
+
+func (r *MapReply) ToRESP3Bytes() []byte {
+	return writeAggregate("%", len(r.Pairs)/2, r.Pairs, 3)
+}
+
+func MakeMapReply(pairs []resp.Reply) *MapReply {
+	return &MapReply{Pairs: pairs}
+}
+
+// MakeBulkMapReply key和value都是字符串的map，例如 HGETALL、CONFIG GET
+func MakeBulkMapReply(args [][]byte) *MapReply {
+	pairs := make([]resp.Reply, len(args))
+	for i, arg := range args {
+		pairs[i] = MakeBulkReply(arg)
+	}
+	return &MapReply{Pairs: pairs}
+}
+
+// ------------set，成员没有顺序------------
+type SetReply struct {
+	Members []resp.Reply
+}
+
+func (r *SetReply) ToBytes() []byte {
+	return writeAggregate("*", len(r.Members), r.Members, 2)
+}
+
+func (r *SetReply) ToRESP3Bytes() []byte {
+	return writeAggregate("~", len(r.Members), r.Members, 3)
+}
+
+func MakeSetReply(members []resp.Reply) *SetReply {
+	return &SetReply{Members: members}
+}
+
+// MakeBulkSetReply 成员都是字符串的set，例如 SMEMBERS
+func MakeBulkSetReply(members [][]byte) *SetReply {
+	replies := make([]resp.Reply, len(members))
+	for i, member := range members {
+		replies[i] = MakeBulkReply(member)
+	}
+	return &SetReply{Members: replies}
+}
+
+// ------------成对的数组，Pairs 中依次为 member1 score1 member2 score2 ...------------
+// RESP2 中为扁平的数组，RESP3 中每一对为一个两个元素的数组，例如 ZRANGE WITHSCORES
+type PairArrayReply struct {
+	Pairs []resp.Reply
+}
+
+func (r *PairArrayReply) ToBytes() []byte {
+	return writeAggregate("*", len(r.Pairs), r.Pairs, 2)
+}
+
+func (r *PairArrayReply) ToRESP3Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(len(r.Pairs)/2) + CRLF)
+	for i := 0; i+1 < len(r.Pairs); i += 2 {
+		buf.Write(writeAggregate("*", 2, r.Pairs[i:i+2], 3))
+	}
+	return buf.Bytes()
+}
+
+func MakePairArrayReply(pairs []resp.Reply) *PairArrayReply {
+	return &PairArrayReply{Pairs: pairs}
+}
+
+// ------------浮点数------------
+type DoubleReply struct {
+	Value float64
+}
+
+// formatDouble 和 redis 的格式保持一致：inf/-inf/nan，其余不使用科学计数法
+func formatDouble(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (r *DoubleReply) ToBytes() []byte {
+	return MakeBulkReply([]byte(formatDouble(r.Value))).ToBytes()
+}
+
+func (r *DoubleReply) ToRESP3Bytes() []byte {
+	return []byte("," + formatDouble(r.Value) + CRLF)
+}
+
+func MakeDoubleReply(value float64) *DoubleReply {
+	return &DoubleReply{Value: value}
+}
+
+// ------------布尔值------------
+type BooleanReply struct {
+	Value bool
+}
+
+var (
+	oneBytes   = []byte(":1\r\n")
+	zeroBytes  = []byte(":0\r\n")
+	trueBytes  = []byte("#t\r\n")
+	falseBytes = []byte("#f\r\n")
+)
+
+func (r *BooleanReply) ToBytes() []byte {
+	if r.Value {
+		return oneBytes
+	}
+	return zeroBytes
+}
+
+func (r *BooleanReply) ToRESP3Bytes() []byte {
+	if r.Value {
+		return trueBytes
+	}
+	return falseBytes
+}
+
+func MakeBooleanReply(value bool) *BooleanReply {
+	return &BooleanReply{Value: value}
+}
+
+// ------------带格式的字符串，Format 为3个字符，例如 txt、mkd------------
+type VerbatimReply struct {
+	Format string
+	Text   []byte
+}
+
+func (r *VerbatimReply) ToBytes() []byte {
+	return MakeBulkReply(r.Text).ToBytes()
+}
+
+func (r *VerbatimReply) ToRESP3Bytes() []byte {
+	return []byte("=" + strconv.Itoa(len(r.Format)+1+len(r.Text)) + CRLF + r.Format + ":" + string(r.Text) + CRLF)
+}
+
+func MakeVerbatimReply(format string, text []byte) *VerbatimReply {
+	return &VerbatimReply{Format: format, Text: text}
+}
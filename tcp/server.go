@@ -18,7 +18,7 @@ type Config struct {
 func ListenAndServeWithSignal(cfg *Config, hander tcp.Handler) error {
 
 	closeChan := make(chan struct{})
-	signChan := make(chan os.Signal)
+	signChan := make(chan os.Signal, 1)
 	signal.Notify(signChan, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT) // 系统的指令 -- 挂起，退出，终止、中断
 	go func() {                                                                               // 注意服务段的关闭信号，使用chan来同步关闭信号的操作
 		sig := <-signChan // 系统传递的关闭连接的信号